@@ -1,15 +1,26 @@
 package main
 
 import (
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/q4ow/qkrn/internal/api"
 	"github.com/q4ow/qkrn/internal/auth"
+	"github.com/q4ow/qkrn/internal/backup"
 	"github.com/q4ow/qkrn/internal/config"
+	"github.com/q4ow/qkrn/internal/grpcapi"
+	"github.com/q4ow/qkrn/internal/keyring"
+	_ "github.com/q4ow/qkrn/internal/lsm"
+	"github.com/q4ow/qkrn/internal/memcache"
+	"github.com/q4ow/qkrn/internal/namespace"
+	"github.com/q4ow/qkrn/internal/resp"
 	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/pkg/types"
 )
 
 func main() {
@@ -25,7 +36,39 @@ func main() {
 		log.Printf("IMPORTANT: Save this API key - it will be required for all API requests")
 	}
 
-	kvStore := store.NewMemoryStore()
+	keys, err := keyring.Load(cfg.EncryptionKeyFile, os.Getenv(keyring.EnvVar), cfg.EncryptionKeyID)
+	if err != nil {
+		log.Fatalf("Failed to load encryption keys: %v", err)
+	}
+	if keys != nil {
+		log.Printf("Encryption at rest enabled with key %s", keys.Active())
+	}
+
+	kvStore, err := openStore(cfg, keys, namespace.Default)
+	if err != nil {
+		log.Fatalf("Failed to open the %s storage engine: %v", cfg.StorageEngine, err)
+	}
+	if _, ok := types.ParseEvictionPolicy(cfg.EvictionPolicy); !ok {
+		log.Fatalf("Invalid eviction policy %q", cfg.EvictionPolicy)
+	}
+	if limited, ok := kvStore.(types.LimitedStore); ok {
+		limited.SetLimits(cfg.MemoryLimits())
+	} else if cfg.MaxMemory > 0 {
+		log.Fatalf("The %s storage engine does not support max_memory", cfg.StorageEngine)
+	}
+	if _, ok := kvStore.(types.HistoryStore); !ok && cfg.HistoryLimits() != (types.HistoryLimits{}) {
+		log.Printf("WARNING: The %s storage engine does not keep history; history settings are ignored", cfg.StorageEngine)
+	}
+
+	if cfg.RestoreFrom != "" {
+		backupStore, ok := kvStore.(types.BackupStore)
+		if !ok {
+			log.Fatalf("The %s storage engine does not support restoring backups", cfg.StorageEngine)
+		}
+		if err := restoreBackup(backupStore, keys, cfg.RestoreFrom, cfg.RestorePolicy); err != nil {
+			log.Fatalf("Failed to restore backup %s: %v", cfg.RestoreFrom, err)
+		}
+	}
 
 	authenticator := auth.NewAuthenticator(cfg.AuthEnabled, cfg.APIKey)
 	if cfg.AuthEnabled {
@@ -34,7 +77,41 @@ func main() {
 		}
 	}
 
+	namespaces := namespace.NewRegistry(kvStore)
+	namespaces.SetStoreFactory(func(name string) (types.Store, error) {
+		return openStore(cfg, keys, name)
+	})
+	dirs, _ := os.ReadDir(filepath.Join(cfg.DataDir, cfg.StorageEngine))
+	for _, dir := range dirs {
+		if dir.IsDir() && dir.Name() != namespace.Default && namespace.ValidName(dir.Name()) {
+			if _, _, err := namespaces.Ensure(dir.Name(), types.Limits{}); err != nil {
+				log.Fatalf("Failed to reopen namespace %q: %v", dir.Name(), err)
+			}
+		}
+	}
+	for name, nsCfg := range cfg.Namespaces {
+		if _, ok := types.ParseEvictionPolicy(nsCfg.Eviction); !ok {
+			log.Fatalf("Invalid eviction policy %q for namespace %q", nsCfg.Eviction, name)
+		}
+		if _, _, err := namespaces.Ensure(name, nsCfg.Limits()); err != nil {
+			log.Fatalf("Invalid namespace %q: %v", name, err)
+		}
+		for _, key := range nsCfg.APIKeys {
+			authenticator.AddNamespaceKey(name, key)
+		}
+	}
+
 	server := api.NewServer(kvStore, cfg.Port, authenticator)
+	server.SetNamespaces(namespaces)
+	server.SetKeyring(keys)
+	server.SetNode(types.Node{
+		ID:      cfg.NodeID,
+		Address: cfg.Address,
+		Port:    cfg.Port,
+	})
+	if cfg.MinFreeDisk > 0 {
+		server.AddReadinessCheck("disk", api.DiskSpaceCheck(cfg.DataDir, cfg.MinFreeDisk))
+	}
 
 	go func() {
 		if err := server.Start(); err != nil {
@@ -42,7 +119,80 @@ func main() {
 		}
 	}()
 
+	if cfg.RESPEnabled {
+		respServer := resp.NewServer(kvStore, cfg.RESPPort, authenticator)
+		go func() {
+			if err := respServer.Start(); err != nil {
+				log.Fatalf("RESP listener failed to start: %v", err)
+			}
+		}()
+	}
+
+	if cfg.MemcacheEnabled {
+		memcacheServer := memcache.NewServer(kvStore, cfg.MemcachePort, authenticator)
+		go func() {
+			if err := memcacheServer.Start(); err != nil {
+				log.Fatalf("Memcached listener failed to start: %v", err)
+			}
+		}()
+	}
+
+	if cfg.GRPCEnabled {
+		grpcServer := grpcapi.NewServer(kvStore, cfg.GRPCPort, authenticator)
+		go func() {
+			if err := grpcServer.Start(); err != nil {
+				log.Fatalf("gRPC listener failed to start: %v", err)
+			}
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			namespaces.PurgeExpired()
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
+
+	for _, ns := range namespaces.List() {
+		if c, ok := ns.Store.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				log.Printf("Failed to close namespace %q: %v", ns.Name, err)
+			}
+		}
+	}
+}
+
+func openStore(cfg *config.Config, keys *keyring.Keyring, name string) (types.Store, error) {
+	return store.OpenBackend(cfg.StorageEngine, store.BackendConfig{
+		Namespace: name,
+		Dir:       filepath.Join(cfg.DataDir, cfg.StorageEngine, name),
+		History:   cfg.HistoryLimits(),
+		Keys:      keys,
+		Options:   cfg.StorageOptions(),
+	})
+}
+
+func restoreBackup(kvStore types.BackupStore, keys *keyring.Keyring, path, policyName string) error {
+	policy, err := backup.ParsePolicy(policyName)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	result, err := backup.Load(f, keys, kvStore, policy)
+	if err != nil {
+		return err
+	}
+	log.Printf("Restored backup %s (revision %d): %d loaded, %d skipped, %d deleted", path, result.Revision, result.Loaded, result.Skipped, result.Deleted)
+	return nil
 }
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/client"
+)
+
+var errNotReady = errors.New("node is not ready")
+
+func newFlagSet(a *app, name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(a.stderr)
+	return flags
+}
+
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return usagef("%v", err)
+	}
+	return nil
+}
+
+func runGet(a *app, args []string) error {
+	flags := newFlagSet(a, "get")
+	raw := flags.Bool("raw", false, "Write the stored bytes to stdout unmodified")
+	revision := flags.Uint64("revision", 0, "Read the value as of this revision")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return usagef("get takes exactly one key")
+	}
+	key := flags.Arg(0)
+
+	if *revision > 0 {
+		if *raw {
+			return usagef("-raw cannot be combined with -revision")
+		}
+		value, err := a.client.GetRevision(a.ctx, key, *revision)
+		if err != nil {
+			return err
+		}
+		return a.printer.value(key, value)
+	}
+
+	if *raw {
+		obj, err := a.client.GetBytes(a.ctx, key)
+		if err != nil {
+			return err
+		}
+		_, err = a.printer.out.Write(obj.Data)
+		return err
+	}
+
+	value, err := a.client.Get(a.ctx, key)
+	if err != nil {
+		return err
+	}
+
+	return a.printer.value(key, value)
+}
+
+func runPut(a *app, args []string) error {
+	flags := newFlagSet(a, "put")
+	contentType := flags.String("content-type", "", "Store the value as raw bytes with this content type")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	args = flags.Args()
+	if len(args) < 1 || len(args) > 2 {
+		return usagef("put takes a key and an optional value")
+	}
+
+	var value []byte
+	if len(args) == 2 && args[1] != "-" {
+		value = []byte(args[1])
+	} else {
+		data, err := io.ReadAll(a.stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read value from stdin: %w", err)
+		}
+		value = data
+		if *contentType == "" {
+			value = []byte(strings.TrimSuffix(string(data), "\n"))
+		}
+	}
+
+	var err error
+	if *contentType != "" {
+		err = a.client.SetBytes(a.ctx, args[0], value, *contentType)
+	} else {
+		err = a.client.Set(a.ctx, args[0], string(value))
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.printer.ok("stored", args[0])
+}
+
+func runDelete(a *app, args []string) error {
+	if len(args) != 1 {
+		return usagef("delete takes exactly one key")
+	}
+
+	if err := a.client.Delete(a.ctx, args[0]); err != nil {
+		return err
+	}
+
+	return a.printer.ok("deleted", args[0])
+}
+
+func runIncr(a *app, args []string) error {
+	flags := newFlagSet(a, "incr")
+	by := flags.String("by", "1", "Amount to add, negative to decrement")
+	float := flags.Bool("float", false, "Treat the value as a floating point number")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return usagef("incr takes exactly one key")
+	}
+	key := flags.Arg(0)
+
+	var value string
+	if *float {
+		delta, err := strconv.ParseFloat(*by, 64)
+		if err != nil {
+			return usagef("invalid -by value: %s", *by)
+		}
+		f, err := a.client.IncrFloat(a.ctx, key, delta)
+		if err != nil {
+			return err
+		}
+		value = strconv.FormatFloat(f, 'f', -1, 64)
+	} else {
+		delta, err := strconv.ParseInt(*by, 10, 64)
+		if err != nil {
+			return usagef("invalid -by value: %s", *by)
+		}
+		n, err := a.client.Incr(a.ctx, key, delta)
+		if err != nil {
+			return err
+		}
+		value = strconv.FormatInt(n, 10)
+	}
+
+	return a.printer.value(key, value)
+}
+
+func runHistory(a *app, args []string) error {
+	if len(args) != 1 {
+		return usagef("history takes exactly one key")
+	}
+
+	revisions, err := a.client.History(a.ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	switch a.printer.format {
+	case formatJSON:
+		return a.printer.json(revisions)
+	case formatRaw:
+		for _, meta := range revisions {
+			if _, err := fmt.Fprintln(a.printer.out, meta.Revision); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		rows := make([][]string, 0, len(revisions))
+		for _, meta := range revisions {
+			rows = append(rows, []string{
+				strconv.FormatUint(meta.Revision, 10),
+				strconv.FormatUint(meta.Version, 10),
+				string(meta.Type),
+				strconv.Itoa(meta.Size),
+				meta.ModifiedAt.Format(time.RFC3339),
+			})
+		}
+		return a.printer.table([]string{"REVISION", "VERSION", "TYPE", "SIZE", "MODIFIED"}, rows)
+	}
+}
+
+func runRestore(a *app, args []string) error {
+	flags := newFlagSet(a, "restore")
+	file := flags.String("file", "", "Load a backup file into the store (- for stdin)")
+	policy := flags.String("policy", client.RestoreMerge, "How existing keys are treated (merge, overwrite, replace)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	args = flags.Args()
+
+	if *file != "" {
+		if len(args) != 0 {
+			return usagef("restore -file takes no arguments")
+		}
+		return restoreBackup(a, *file, *policy)
+	}
+
+	if len(args) != 2 {
+		return usagef("restore takes a key and a revision")
+	}
+
+	revision, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || revision == 0 {
+		return usagef("invalid revision: %s", args[1])
+	}
+
+	if _, err := a.client.Restore(a.ctx, args[0], revision); err != nil {
+		return err
+	}
+	return a.printer.ok("restored", args[0])
+}
+
+func restoreBackup(a *app, file, policy string) error {
+	in := a.stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", file, err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	result, err := a.client.RestoreBackup(a.ctx, in, policy)
+	if err != nil {
+		return err
+	}
+
+	switch a.printer.format {
+	case formatJSON:
+		return a.printer.json(result)
+	default:
+		_, err := fmt.Fprintf(a.printer.out, "restored revision %d: %d loaded, %d skipped, %d deleted\n", result.Revision, result.Loaded, result.Skipped, result.Deleted)
+		return err
+	}
+}
+
+func runBackup(a *app, args []string) error {
+	flags := newFlagSet(a, "backup")
+	prefix := flags.String("prefix", "", "Only back up keys with this prefix")
+	file := flags.String("file", "", "Write to this file instead of stdout")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return usagef("backup takes no arguments")
+	}
+
+	out := a.printer.out
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", *file, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	n, err := a.client.Backup(a.ctx, out, *prefix)
+	if err != nil {
+		return err
+	}
+
+	if *file != "" {
+		fmt.Fprintf(a.stderr, "Wrote %d byte backup to %s\n", n, *file)
+	}
+	return nil
+}
+
+func runCompact(a *app, args []string) error {
+	if len(args) > 1 {
+		return usagef("compact takes an optional revision")
+	}
+
+	var revision uint64
+	if len(args) == 1 {
+		var err error
+		if revision, err = strconv.ParseUint(args[0], 10, 64); err != nil {
+			return usagef("invalid revision: %s", args[0])
+		}
+	}
+
+	compacted, err := a.client.Compact(a.ctx, revision)
+	if err != nil {
+		return err
+	}
+	return a.printer.ok("compacted to revision", strconv.FormatUint(compacted, 10))
+}
+
+func runList(a *app, args []string) error {
+	flags := newFlagSet(a, "list")
+	prefix := flags.String("prefix", "", "Only list keys with this prefix")
+	revision := flags.Uint64("revision", 0, "List the keys as of this revision")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	keys, err := a.client.KeysAt(a.ctx, *prefix, *revision)
+	if err != nil {
+		return err
+	}
+	sort.Strings(keys)
+
+	return a.printer.keys(keys)
+}
+
+type watchEvent struct {
+	Type  string `json:"type"`
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+	Time  string `json:"time"`
+}
+
+func runWatch(a *app, args []string) error {
+	flags := newFlagSet(a, "watch")
+	prefix := flags.String("prefix", "", "Watch all keys with this prefix")
+	interval := flags.Duration("interval", time.Second, "Polling interval")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if (flags.NArg() == 0) == (*prefix == "") {
+		return usagef("watch takes either a key or -prefix")
+	}
+
+	ctx := a.ctx
+
+	snapshot := func() (map[string]string, error) {
+		keys := flags.Args()
+		if *prefix != "" {
+			var err error
+			if keys, err = a.client.KeysWithPrefix(ctx, *prefix); err != nil {
+				return nil, err
+			}
+		}
+
+		values := make(map[string]string, len(keys))
+		for _, key := range keys {
+			value, err := a.client.Get(ctx, key)
+			if errors.Is(err, client.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+		return values, nil
+	}
+
+	previous, err := snapshot()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := snapshot()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		for _, event := range diffSnapshots(previous, current) {
+			if err := a.printEvent(event); err != nil {
+				return err
+			}
+		}
+		previous = current
+	}
+}
+
+func diffSnapshots(previous, current map[string]string) []watchEvent {
+	now := time.Now().UTC().Format(time.RFC3339)
+	var events []watchEvent
+
+	for key, value := range current {
+		if old, ok := previous[key]; !ok || old != value {
+			events = append(events, watchEvent{Type: "put", Key: key, Value: value, Time: now})
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			events = append(events, watchEvent{Type: "delete", Key: key, Time: now})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Key < events[j].Key
+	})
+	return events
+}
+
+func (a *app) printEvent(event watchEvent) error {
+	switch a.printer.format {
+	case formatJSON:
+		return json.NewEncoder(a.printer.out).Encode(event)
+	case formatRaw:
+		_, err := fmt.Fprintf(a.printer.out, "%s %s %s\n", event.Type, event.Key, event.Value)
+		return err
+	default:
+		_, err := fmt.Fprintf(a.printer.out, "%s\t%-6s\t%s\t%s\n", event.Time, strings.ToUpper(event.Type), event.Key, event.Value)
+		return err
+	}
+}
+
+func runExport(a *app, args []string) error {
+	flags := newFlagSet(a, "export")
+	prefix := flags.String("prefix", "", "Only export keys with this prefix")
+	file := flags.String("file", "", "Write to this file instead of stdout")
+	format := flags.String("format", "", "Output format: json, ndjson or csv (default from the file extension, else json)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	out := a.printer.out
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", *file, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if _, err := a.client.Export(a.ctx, out, *prefix, bulkFormat(*format, *file)); err != nil {
+		return err
+	}
+
+	if *file != "" {
+		fmt.Fprintf(a.stderr, "Exported to %s\n", *file)
+	}
+	return nil
+}
+
+func runImport(a *app, args []string) error {
+	flags := newFlagSet(a, "import")
+	file := flags.String("file", "", "Read from this file instead of stdin")
+	format := flags.String("format", "", "Input format: json, ndjson or csv (default from the file extension, else json)")
+	dryRun := flags.Bool("dry-run", false, "Validate the data without writing anything")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	in := a.stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", *file, err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	result, err := a.client.Import(a.ctx, in, bulkFormat(*format, *file), *dryRun)
+	if err != nil {
+		return err
+	}
+
+	switch a.printer.format {
+	case formatJSON:
+		if err := a.printer.json(result); err != nil {
+			return err
+		}
+	default:
+		verb := "imported"
+		if result.DryRun {
+			verb = "would import"
+		}
+		if _, err := fmt.Fprintf(a.printer.out, "%s %d keys, %d failed\n", verb, result.Imported, result.Failed); err != nil {
+			return err
+		}
+		for _, e := range result.Errors {
+			position := fmt.Sprintf("line %d", e.Line)
+			if e.Item > 0 {
+				position = fmt.Sprintf("item %d", e.Item)
+			}
+			if e.Key != "" {
+				fmt.Fprintf(a.stderr, "%s (%s): %s\n", position, e.Key, e.Error)
+			} else {
+				fmt.Fprintf(a.stderr, "%s: %s\n", position, e.Error)
+			}
+		}
+	}
+
+	if result.Failed > 0 {
+		return fmt.Errorf("%d of %d entries failed", result.Failed, result.Imported+result.Failed)
+	}
+	return nil
+}
+
+func bulkFormat(format, file string) string {
+	if format != "" {
+		return format
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".ndjson", ".jsonl":
+		return client.FormatNDJSON
+	case ".csv":
+		return client.FormatCSV
+	}
+	return client.FormatJSON
+}
+
+func runCluster(a *app, args []string) error {
+	if len(args) != 0 {
+		return usagef("cluster takes no arguments")
+	}
+
+	info, err := a.client.Cluster(a.ctx)
+	if err != nil {
+		return err
+	}
+
+	switch a.printer.format {
+	case formatJSON:
+		return a.printer.json(info)
+	case formatRaw:
+		for _, node := range info.Nodes {
+			fmt.Fprintf(a.printer.out, "%s %s:%d\n", node.ID, node.Address, node.Port)
+		}
+		return nil
+	default:
+		rows := make([][]string, 0, len(info.Nodes))
+		for _, node := range info.Nodes {
+			role := "follower"
+			if node.ID == info.Leader {
+				role = "leader"
+			}
+			rows = append(rows, []string{node.ID, node.Address, strconv.Itoa(node.Port), role})
+		}
+		return a.printer.table([]string{"ID", "ADDRESS", "PORT", "ROLE"}, rows)
+	}
+}
+
+func runStatus(a *app, args []string) error {
+	if len(args) != 0 {
+		return usagef("status takes no arguments")
+	}
+
+	report, err := a.client.Ready(a.ctx)
+	if err != nil {
+		return err
+	}
+
+	switch a.printer.format {
+	case formatJSON:
+		err = a.printer.json(report)
+	case formatRaw:
+		_, err = fmt.Fprintln(a.printer.out, report.Status)
+	default:
+		rows := make([][]string, 0, len(report.Checks))
+		for _, check := range report.Checks {
+			rows = append(rows, []string{check.Name, check.Status, fmt.Sprintf("%.3fms", check.DurationMs), check.Error})
+		}
+		err = a.printer.table([]string{"CHECK", "STATUS", "DURATION", "ERROR"}, rows)
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.Status != "ready" {
+		return errNotReady
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/client"
+)
+
+const (
+	exitOK           = 0
+	exitError        = 1
+	exitUsage        = 2
+	exitNotFound     = 3
+	exitUnauthorized = 4
+	exitUnavailable  = 5
+)
+
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{message: fmt.Sprintf(format, args...)}
+}
+
+type command struct {
+	usage string
+	run   func(a *app, args []string) error
+}
+
+var commands = map[string]command{
+	"get":     {"get [-raw] [-revision n] <key>", runGet},
+	"put":     {"put [-content-type type] <key> [value|-]", runPut},
+	"delete":  {"delete <key>", runDelete},
+	"incr":    {"incr [-by n] [-float] <key>", runIncr},
+	"history": {"history <key>", runHistory},
+	"restore": {"restore <key> <revision> | restore -file f [-policy p]", runRestore},
+	"list":    {"list [-prefix p] [-revision n]", runList},
+	"compact": {"compact [revision]", runCompact},
+	"watch":   {"watch [-prefix p] [-interval d] [key]", runWatch},
+	"export":  {"export [-prefix p] [-format f] [-file f]", runExport},
+	"import":  {"import [-format f] [-dry-run] [-file f]", runImport},
+	"backup":  {"backup [-prefix p] [-file f]", runBackup},
+	"cluster": {"cluster", runCluster},
+	"status":  {"status", runStatus},
+	"shell":   {"shell", runShell},
+}
+
+type app struct {
+	ctx           context.Context
+	client        *client.Client
+	clientOptions []client.Option
+	endpoint      string
+	namespace     string
+	printer       *printer
+	stdin         io.Reader
+	stderr        io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("qkrnctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	endpoint := flags.String("endpoint", "", "qkrn endpoint (env QKRN_ENDPOINT)")
+	apiKey := flags.String("api-key", "", "API key (env QKRN_API_KEY)")
+	authScheme := flags.String("auth-scheme", "", "How to send the API key: bearer or header")
+	namespace := flags.String("namespace", "", "Namespace to operate on (env QKRN_NAMESPACE)")
+	profileName := flags.String("profile", os.Getenv("QKRN_PROFILE"), "Profile name from the profile file (env QKRN_PROFILE)")
+	profilePath := flags.String("profile-file", defaultProfilePath(), "Path to the profile file")
+	output := flags.String("output", formatTable, "Output format: table, json or raw")
+	timeout := flags.Duration("timeout", 10*time.Second, "Request timeout")
+
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: qkrnctl [flags] <command> [args]\n\nCommands:\n")
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stderr, "  %s\n", commands[name].usage)
+		}
+		fmt.Fprintf(stderr, "\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command: %s\n", flags.Arg(0))
+		flags.Usage()
+		return exitUsage
+	}
+
+	if !validFormat(*output) {
+		fmt.Fprintf(stderr, "Invalid output format: %s\n", *output)
+		return exitUsage
+	}
+
+	profile, err := loadProfile(*profilePath, *profileName)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	resolvedEndpoint := firstNonEmpty(*endpoint, os.Getenv("QKRN_ENDPOINT"), profile.Endpoint, "http://localhost:8080")
+	resolvedKey := firstNonEmpty(*apiKey, os.Getenv("QKRN_API_KEY"), profile.APIKey)
+	resolvedScheme := firstNonEmpty(*authScheme, os.Getenv("QKRN_AUTH_SCHEME"), profile.AuthScheme, "bearer")
+	resolvedNamespace := firstNonEmpty(*namespace, os.Getenv("QKRN_NAMESPACE"), profile.Namespace)
+
+	opts := []client.Option{client.WithTimeout(*timeout), client.WithNamespace(resolvedNamespace)}
+	switch resolvedScheme {
+	case "bearer":
+		opts = append(opts, client.WithBearerToken(resolvedKey))
+	case "header":
+		opts = append(opts, client.WithAPIKey(resolvedKey))
+	default:
+		fmt.Fprintf(stderr, "Invalid auth scheme: %s\n", resolvedScheme)
+		return exitUsage
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	a := &app{
+		ctx:           ctx,
+		client:        client.New(resolvedEndpoint, opts...),
+		clientOptions: opts,
+		endpoint:      resolvedEndpoint,
+		namespace:     resolvedNamespace,
+		printer:       &printer{out: stdout, format: *output},
+		stdin:         stdin,
+		stderr:        stderr,
+	}
+
+	if err := cmd.run(a, flags.Args()[1:]); err != nil {
+		var usage *usageError
+		if errors.As(err, &usage) {
+			fmt.Fprintf(stderr, "Error: %v\nUsage: qkrnctl %s\n", err, cmd.usage)
+		} else {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+		}
+		return exitCode(err)
+	}
+
+	return exitOK
+}
+
+func exitCode(err error) int {
+	var usage *usageError
+	var transportErr *url.Error
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.Is(err, client.ErrKeyNotFound):
+		return exitNotFound
+	case errors.Is(err, client.ErrUnauthorized):
+		return exitUnauthorized
+	case errors.As(err, &transportErr), errors.Is(err, errNotReady):
+		return exitUnavailable
+	default:
+		return exitError
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/q4ow/qkrn/internal/api"
+	"github.com/q4ow/qkrn/internal/auth"
+	"github.com/q4ow/qkrn/internal/store"
+)
+
+func isolate(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, name := range []string{"QKRN_ENDPOINT", "QKRN_API_KEY", "QKRN_AUTH_SCHEME", "QKRN_NAMESPACE", "QKRN_PROFILE"} {
+		t.Setenv(name, "")
+	}
+	return home
+}
+
+func testServer(t *testing.T, apiKey string, values map[string]string) string {
+	t.Helper()
+	kvStore := store.NewMemoryStore()
+	for key, value := range values {
+		kvStore.Set(key, []byte(value))
+	}
+	server := api.NewServer(kvStore, 0, auth.NewAuthenticator(apiKey != "", apiKey))
+
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func runCtl(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(""), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunExitCodes(t *testing.T) {
+	isolate(t)
+	endpoint := testServer(t, "secret", map[string]string{"hello": "world"})
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"get", []string{"-endpoint", endpoint, "-api-key", "secret", "get", "hello"}, exitOK},
+		{"put", []string{"-endpoint", endpoint, "-api-key", "secret", "put", "new", "value"}, exitOK},
+		{"status", []string{"-endpoint", endpoint, "status"}, exitOK},
+		{"not found", []string{"-endpoint", endpoint, "-api-key", "secret", "get", "missing"}, exitNotFound},
+		{"unauthorized", []string{"-endpoint", endpoint, "-api-key", "wrong", "get", "hello"}, exitUnauthorized},
+		{"unreachable", []string{"-endpoint", "http://127.0.0.1:1", "-timeout", "100ms", "delete", "hello"}, exitUnavailable},
+		{"no command", []string{"-endpoint", endpoint}, exitUsage},
+		{"unknown command", []string{"-endpoint", endpoint, "frobnicate"}, exitUsage},
+		{"bad arguments", []string{"-endpoint", endpoint, "get"}, exitUsage},
+		{"bad output", []string{"-endpoint", endpoint, "-output", "yaml", "get", "hello"}, exitUsage},
+		{"bad auth scheme", []string{"-endpoint", endpoint, "-auth-scheme", "basic", "get", "hello"}, exitUsage},
+	}
+	for _, tt := range tests {
+		if code, _, stderr := runCtl(tt.args...); code != tt.code {
+			t.Errorf("%s: expected exit code %d, got %d (%s)", tt.name, tt.code, code, stderr)
+		}
+	}
+}
+
+func TestRunOutputFormats(t *testing.T) {
+	isolate(t)
+	endpoint := testServer(t, "", map[string]string{"hello": "world"})
+
+	code, out, _ := runCtl("-endpoint", endpoint, "-output", "json", "get", "hello")
+	var got map[string]string
+	if err := json.Unmarshal([]byte(out), &got); code != exitOK || err != nil || got["key"] != "hello" || got["value"] != "world" {
+		t.Errorf("Expected a JSON object, got %q (exit %d)", out, code)
+	}
+
+	if _, out, _ := runCtl("-endpoint", endpoint, "-output", "table", "get", "hello"); !strings.Contains(out, "KEY") || !strings.Contains(out, "hello  world") {
+		t.Errorf("Expected a table with a header, got %q", out)
+	}
+	if _, out, _ := runCtl("-endpoint", endpoint, "-output", "raw", "get", "hello"); out != "world\n" {
+		t.Errorf("Expected the bare value, got %q", out)
+	}
+
+	_, out, _ = runCtl("-endpoint", endpoint, "-output", "json", "list")
+	var keys []string
+	if err := json.Unmarshal([]byte(out), &keys); err != nil || len(keys) != 1 || keys[0] != "hello" {
+		t.Errorf("Expected a JSON list of keys, got %q", out)
+	}
+}
+
+func TestRunConfigPrecedence(t *testing.T) {
+	home := isolate(t)
+	fromProfile := testServer(t, "secret", map[string]string{"source": "profile"})
+	fromOther := testServer(t, "secret", map[string]string{"source": "other"})
+	fromEnv := testServer(t, "secret", map[string]string{"source": "env"})
+	fromFlag := testServer(t, "secret", map[string]string{"source": "flag"})
+
+	path := filepath.Join(home, ".config", "qkrn", "profiles.toml")
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	os.WriteFile(path, []byte(`[profiles.default]
+endpoint = "`+fromProfile+`"
+api_key = "wrong"
+
+[profiles.other]
+endpoint = "`+fromOther+`"
+api_key = "secret"
+`), 0o600)
+
+	source := func(args ...string) string {
+		t.Helper()
+		code, out, stderr := runCtl(append(args, "-output", "raw", "get", "source")...)
+		if code != exitOK {
+			return stderr
+		}
+		return strings.TrimSpace(out)
+	}
+
+	if code, _, _ := runCtl("get", "source"); code != exitUnauthorized {
+		t.Errorf("Expected the default profile's API key to be used, got exit %d", code)
+	}
+	if got := source("-api-key", "secret"); got != "profile" {
+		t.Errorf("Expected the default profile's endpoint, got %q", got)
+	}
+	if got := source("-profile", "other"); got != "other" {
+		t.Errorf("Expected -profile to select a profile, got %q", got)
+	}
+
+	t.Setenv("QKRN_PROFILE", "other")
+	if got := source(); got != "other" {
+		t.Errorf("Expected QKRN_PROFILE to select a profile, got %q", got)
+	}
+
+	t.Setenv("QKRN_ENDPOINT", fromEnv)
+	if got := source(); got != "env" {
+		t.Errorf("Expected the environment to override the profile, got %q", got)
+	}
+	if got := source("-endpoint", fromFlag); got != "flag" {
+		t.Errorf("Expected flags to override the environment, got %q", got)
+	}
+
+	t.Setenv("QKRN_API_KEY", "wrong")
+	if code, _, _ := runCtl("get", "source"); code != exitUnauthorized {
+		t.Errorf("Expected QKRN_API_KEY to override the profile, got exit %d", code)
+	}
+	if got := source("-api-key", "secret"); got != "env" {
+		t.Errorf("Expected -api-key to override QKRN_API_KEY, got %q", got)
+	}
+}
+
+func TestRunInterruptCancelsRetries(t *testing.T) {
+	isolate(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(ts.Close)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		syscall.Kill(os.Getpid(), syscall.SIGINT)
+	}()
+
+	start := time.Now()
+	code, _, stderr := runCtl("-endpoint", ts.URL, "get", "hello")
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected an interrupt to stop retrying, took %v", elapsed)
+	}
+	if code == exitOK || !strings.Contains(stderr, "context canceled") {
+		t.Errorf("Expected the request to be canceled, got exit %d (%s)", code, stderr)
+	}
+}
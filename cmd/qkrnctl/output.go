@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatRaw   = "raw"
+)
+
+type printer struct {
+	out    io.Writer
+	format string
+}
+
+func validFormat(format string) bool {
+	switch format {
+	case formatTable, formatJSON, formatRaw:
+		return true
+	}
+	return false
+}
+
+func (p *printer) json(v interface{}) error {
+	encoder := json.NewEncoder(p.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (p *printer) table(header []string, rows [][]string) error {
+	w := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func (p *printer) value(key, value string) error {
+	switch p.format {
+	case formatJSON:
+		return p.json(map[string]string{"key": key, "value": value})
+	case formatRaw:
+		_, err := fmt.Fprintln(p.out, value)
+		return err
+	default:
+		return p.table([]string{"KEY", "VALUE"}, [][]string{{key, value}})
+	}
+}
+
+func (p *printer) keys(keys []string) error {
+	switch p.format {
+	case formatJSON:
+		return p.json(keys)
+	case formatRaw:
+		for _, key := range keys {
+			if _, err := fmt.Fprintln(p.out, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		rows := make([][]string, 0, len(keys))
+		for _, key := range keys {
+			rows = append(rows, []string{key})
+		}
+		return p.table([]string{"KEY"}, rows)
+	}
+}
+
+func (p *printer) ok(action, key string) error {
+	switch p.format {
+	case formatJSON:
+		return p.json(map[string]interface{}{"success": true, "action": action, "key": key})
+	case formatRaw:
+		return nil
+	default:
+		_, err := fmt.Fprintf(p.out, "%s %s\n", action, key)
+		return err
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+type Profile struct {
+	Endpoint   string `toml:"endpoint"`
+	APIKey     string `toml:"api_key"`
+	AuthScheme string `toml:"auth_scheme"`
+	Namespace  string `toml:"namespace"`
+}
+
+type profileFile struct {
+	Profiles map[string]Profile `toml:"profiles"`
+}
+
+func defaultProfilePath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".config", "qkrn", "profiles.toml")
+}
+
+func loadProfile(path, name string) (Profile, error) {
+	if path == "" {
+		return Profile{}, nil
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if name != "" && name != "default" {
+			return Profile{}, fmt.Errorf("profile file does not exist: %s", path)
+		}
+		return Profile{}, nil
+	}
+
+	var file profileFile
+	if _, err := toml.DecodeFile(path, &file); err != nil {
+		return Profile{}, fmt.Errorf("failed to parse profile file %s: %w", path, err)
+	}
+
+	if name == "" {
+		name = "default"
+	}
+
+	profile, ok := file.Profiles[name]
+	if !ok && name != "default" {
+		return Profile{}, fmt.Errorf("profile %q not found in %s", name, path)
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peterh/liner"
+
+	"github.com/q4ow/qkrn/pkg/client"
+)
+
+var shellCommands = []string{"cluster", "connect", "delete", "exit", "get", "help", "list", "ns", "put", "quit", "status"}
+
+type shell struct {
+	app       *app
+	line      *liner.State
+	endpoint  string
+	namespace string
+}
+
+func historyPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".config", "qkrn", "shell_history")
+}
+
+func runShell(a *app, args []string) error {
+	if len(args) != 0 {
+		return usagef("shell takes no arguments")
+	}
+
+	sh := &shell{
+		app:       a,
+		line:      liner.NewLiner(),
+		endpoint:  a.endpoint,
+		namespace: a.namespace,
+	}
+	defer sh.line.Close()
+
+	sh.line.SetCtrlCAborts(true)
+	sh.line.SetTabCompletionStyle(liner.TabPrints)
+	sh.line.SetWordCompleter(sh.complete)
+
+	history := historyPath()
+	if f, err := os.Open(history); err == nil {
+		sh.line.ReadHistory(f)
+		f.Close()
+	}
+	defer sh.saveHistory(history)
+
+	fmt.Fprintf(a.printer.out, "Connected to %s. Type 'help' for commands.\n", sh.endpoint)
+
+	for {
+		input, err := sh.line.Prompt(sh.prompt())
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if err == io.EOF {
+			fmt.Fprintln(a.printer.out)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+		sh.line.AppendHistory(input)
+
+		done, err := sh.execute(input)
+		if err != nil {
+			fmt.Fprintf(a.printer.out, "Error: %v\n", err)
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+func (sh *shell) saveHistory(path string) {
+	if path == "" {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+
+	if f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600); err == nil {
+		f.Chmod(0600)
+		sh.line.WriteHistory(f)
+		f.Close()
+	}
+}
+
+func (sh *shell) prompt() string {
+	if sh.namespace != "" {
+		return fmt.Sprintf("qkrn %s [%s]> ", sh.endpoint, sh.namespace)
+	}
+	return fmt.Sprintf("qkrn %s> ", sh.endpoint)
+}
+
+func (sh *shell) newClient(endpoint string, opts []client.Option) *client.Client {
+	return client.New(endpoint, append(append([]client.Option{}, opts...), client.WithNamespace(sh.namespace))...)
+}
+
+func (sh *shell) execute(input string) (bool, error) {
+	fields := strings.Fields(input)
+	cmd, args := fields[0], fields[1:]
+	ctx, cancel := signalContext()
+	defer cancel()
+	sh.app.ctx = ctx
+	out := sh.app.printer.out
+
+	switch cmd {
+	case "exit", "quit":
+		return true, nil
+
+	case "help":
+		fmt.Fprint(out, `Commands:
+  get <key>                 Show a value, pretty-printing JSON
+  put <key> [value]         Store a value; without a value, read lines until a single "."
+  delete <key>              Delete a key
+  list [prefix]             List keys
+  ns [name]                 Switch namespace; without a name, return to the default
+  connect <endpoint> [key]  Connect to another node
+  cluster                   Show cluster topology
+  status                    Show readiness checks
+  exit                      Leave the shell
+`)
+
+	case "get":
+		if len(args) != 1 {
+			return false, errors.New("usage: get <key>")
+		}
+		value, err := sh.app.client.Get(ctx, args[0])
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintln(out, prettyValue(value))
+
+	case "put":
+		if len(args) < 1 {
+			return false, errors.New("usage: put <key> [value]")
+		}
+		value := strings.TrimSpace(strings.TrimPrefix(input, "put"))
+		value = strings.TrimSpace(strings.TrimPrefix(value, args[0]))
+		if value == "" {
+			var err error
+			if value, err = sh.readMultiline(); err != nil {
+				return false, err
+			}
+		}
+		if err := sh.app.client.Set(ctx, args[0], value); err != nil {
+			return false, err
+		}
+		fmt.Fprintln(out, "OK")
+
+	case "delete":
+		if len(args) != 1 {
+			return false, errors.New("usage: delete <key>")
+		}
+		if err := sh.app.client.Delete(ctx, args[0]); err != nil {
+			return false, err
+		}
+		fmt.Fprintln(out, "OK")
+
+	case "list":
+		prefix := ""
+		if len(args) > 0 {
+			prefix = args[0]
+		}
+		keys, err := sh.keys(ctx, prefix)
+		if err != nil {
+			return false, err
+		}
+		for _, key := range keys {
+			fmt.Fprintln(out, key)
+		}
+		fmt.Fprintf(out, "(%d keys)\n", len(keys))
+
+	case "ns":
+		if len(args) > 1 {
+			return false, errors.New("usage: ns [name]")
+		}
+		sh.namespace = ""
+		if len(args) == 1 {
+			sh.namespace = args[0]
+		}
+		sh.app.client = sh.newClient(sh.endpoint, sh.app.clientOptions)
+
+	case "connect":
+		if len(args) < 1 || len(args) > 2 {
+			return false, errors.New("usage: connect <endpoint> [api-key]")
+		}
+		opts := sh.app.clientOptions
+		if len(args) == 2 {
+			opts = append(append([]client.Option{}, opts...), client.WithBearerToken(args[1]))
+		}
+		next := sh.newClient(args[0], opts)
+		if _, err := next.Ready(ctx); err != nil {
+			return false, fmt.Errorf("failed to connect to %s: %w", args[0], err)
+		}
+		sh.app.client = next
+		sh.app.clientOptions = opts
+		sh.endpoint = args[0]
+		fmt.Fprintf(out, "Connected to %s\n", args[0])
+
+	case "cluster":
+		return false, runCluster(sh.app, args)
+
+	case "status":
+		err := runStatus(sh.app, args)
+		if errors.Is(err, errNotReady) {
+			return false, nil
+		}
+		return false, err
+
+	default:
+		return false, fmt.Errorf("unknown command %q, type 'help' for a list", cmd)
+	}
+
+	return false, nil
+}
+
+func (sh *shell) readMultiline() (string, error) {
+	fmt.Fprintln(sh.app.printer.out, `Enter value, end with a line containing only "."`)
+
+	var lines []string
+	for {
+		line, err := sh.line.Prompt("... ")
+		if err != nil {
+			return "", err
+		}
+		if line == "." {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (sh *shell) keys(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := sh.app.client.KeysWithPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (sh *shell) complete(line string, pos int) (string, []string, string) {
+	head, tail := line[:pos], line[pos:]
+
+	start := strings.LastIndex(head, " ") + 1
+	word := head[start:]
+	head = head[:start]
+
+	var candidates []string
+	switch fields := strings.Fields(head); len(fields) {
+	case 0:
+		candidates = shellCommands
+	case 1:
+		switch fields[0] {
+		case "get", "put", "delete", "list":
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			candidates, _ = sh.keys(ctx, word)
+		}
+	}
+
+	var completions []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			completions = append(completions, candidate)
+		}
+	}
+	return head, completions, tail
+}
+
+func prettyValue(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return value
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(trimmed), "", "  "); err != nil {
+		return value
+	}
+	return buf.String()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/q4ow/qkrn/pkg/client"
+)
+
+func TestShellNamespaces(t *testing.T) {
+	isolate(t)
+	endpoint := testServer(t, "", map[string]string{"shared": "default"})
+	req, _ := http.NewRequest(http.MethodPut, endpoint+"/ns/team", strings.NewReader(`{}`))
+	if resp, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+
+	var out bytes.Buffer
+	a := &app{
+		ctx:      context.Background(),
+		client:   client.New(endpoint),
+		endpoint: endpoint,
+		printer:  &printer{out: &out, format: formatTable},
+	}
+	sh := &shell{app: a, endpoint: endpoint}
+
+	for _, input := range []string{"ns team", "put key scoped"} {
+		if _, err := sh.execute(input); err != nil {
+			t.Fatalf("%s failed: %v", input, err)
+		}
+	}
+	if sh.prompt() != "qkrn "+endpoint+" [team]> " {
+		t.Errorf("Expected the namespace in the prompt, got %q", sh.prompt())
+	}
+
+	team := client.New(endpoint, client.WithNamespace("team"))
+	if value, err := team.Get(context.Background(), "key"); err != nil || value != "scoped" {
+		t.Errorf("Expected the key in namespace team, got %q (%v)", value, err)
+	}
+	if _, err := client.New(endpoint).Get(context.Background(), "team/key"); !client.IsNotFound(err) {
+		t.Errorf("Expected nothing written to the default namespace, got %v", err)
+	}
+	if keys, _ := sh.keys(context.Background(), ""); len(keys) != 1 || keys[0] != "key" {
+		t.Errorf("Expected only the team keys, got %v", keys)
+	}
+
+	out.Reset()
+	if _, err := sh.execute("ns"); err != nil {
+		t.Fatalf("ns failed: %v", err)
+	}
+	if _, err := sh.execute("get shared"); err != nil || !strings.Contains(out.String(), "default") {
+		t.Errorf("Expected ns without a name to return to the default namespace, got %q (%v)", out.String(), err)
+	}
+}
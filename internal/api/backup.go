@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/q4ow/qkrn/internal/backup"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	s.backup(w, r, s.store)
+}
+
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	s.restore(w, r, s.store)
+}
+
+var maxRestoreSize int64 = 4 * 1024 * 1024 * 1024
+
+func (s *Server) sendRestoreError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		s.sendErrorResponse(w, "Backup too large", http.StatusRequestEntityTooLarge)
+	case errors.Is(err, backup.ErrInvalidBackup):
+		s.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+	default:
+		s.sendStoreError(w, err)
+	}
+}
+
+func (s *Server) backup(w http.ResponseWriter, r *http.Request, store types.Store) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	backups, ok := store.(types.BackupStore)
+	if !ok {
+		s.sendErrorResponse(w, "Storage backend does not support backups", http.StatusNotImplemented)
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	snap := backups.Snapshot(prefix)
+
+	if s.keys != nil {
+		w.Header().Set("Content-Type", backup.EncryptedContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="qkrn-%d.backup.enc"`, snap.Revision()))
+		if _, err := backup.WriteEncrypted(w, snap, prefix, s.keys); err != nil {
+			log.Printf("backup: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", backup.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="qkrn-%d.backup.gz"`, snap.Revision()))
+	if _, err := backup.Write(w, snap, prefix); err != nil {
+		log.Printf("backup: %v", err)
+	}
+}
+
+func (s *Server) restore(w http.ResponseWriter, r *http.Request, store types.Store) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	backups, ok := store.(types.BackupStore)
+	if !ok {
+		s.sendErrorResponse(w, "Storage backend does not support backups", http.StatusNotImplemented)
+		return
+	}
+
+	policy, err := backup.ParsePolicy(r.URL.Query().Get("policy"))
+	if err != nil {
+		s.sendErrorResponse(w, "Invalid policy", http.StatusBadRequest)
+		return
+	}
+
+	result, err := backup.Load(http.MaxBytesReader(w, r.Body, maxRestoreSize), s.keys, backups, policy)
+	if err != nil {
+		s.sendRestoreError(w, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success":  true,
+		"revision": result.Revision,
+		"loaded":   result.Loaded,
+		"skipped":  result.Skipped,
+		"deleted":  result.Deleted,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
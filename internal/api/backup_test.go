@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/q4ow/qkrn/internal/keyring"
+)
+
+func TestBackupRestore(t *testing.T) {
+	server := setupTestServer(false, "")
+	handler := server.Handler()
+
+	serve(handler, "PUT", "/kv/app/a", `{"value":"1"}`, "")
+	serve(handler, "PUT", "/kv/app/b", `{"value":"2"}`, "")
+	serve(handler, "PUT", "/kv/other", `{"value":"3"}`, "")
+
+	w := serve(handler, "GET", "/backup?prefix=app/", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected backup to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/gzip" {
+		t.Errorf("Expected gzip content type, got %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "qkrn-3.backup.gz") {
+		t.Errorf("Expected the snapshot revision in the filename, got %q", cd)
+	}
+	data := w.Body.String()
+
+	serve(handler, "PUT", "/ns/team", `{}`, "")
+	serve(handler, "PUT", "/ns/team/kv/app/a", `{"value":"local"}`, "")
+
+	w = serve(handler, "POST", "/ns/team/restore", data, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected restore to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	var result struct {
+		Revision uint64 `json:"revision"`
+		Loaded   int    `json:"loaded"`
+		Skipped  int    `json:"skipped"`
+	}
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.Revision != 3 || result.Loaded != 1 || result.Skipped != 1 {
+		t.Errorf("Unexpected merge result %+v", result)
+	}
+
+	w = serve(handler, "POST", "/ns/team/restore?policy=overwrite", data, "")
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.Loaded != 2 {
+		t.Errorf("Expected overwrite to load every record, got %+v", result)
+	}
+	w = serve(handler, "GET", "/ns/team/kv/app/a", "", "")
+	if !strings.Contains(w.Body.String(), `"value":"1"`) {
+		t.Errorf("Expected the backed up value, got %s", w.Body.String())
+	}
+
+	serve(handler, "PUT", "/ns/team/kv/app/a", `{"value":"local"}`, "")
+	if w := serve(handler, "POST", "/ns/team/restore?policy=overwrite", data[:len(data)-10], ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a cut-off backup to be rejected, got %d", w.Code)
+	}
+	if w := serve(handler, "GET", "/ns/team/kv/app/a", "", ""); !strings.Contains(w.Body.String(), `"value":"local"`) {
+		t.Errorf("Expected a rejected backup to leave the store unchanged, got %s", w.Body.String())
+	}
+
+	if w := serve(handler, "POST", "/restore?policy=clobber", data, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid policy to be rejected, got %d", w.Code)
+	}
+	if w := serve(handler, "POST", "/restore", "not a backup", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid backup to be rejected, got %d", w.Code)
+	}
+	if w := serve(handler, "POST", "/backup", "", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected POST /backup to be rejected, got %d", w.Code)
+	}
+
+	defer func(size int64) { maxRestoreSize = size }(maxRestoreSize)
+	maxRestoreSize = int64(len(data) / 2)
+	if w := serve(handler, "POST", "/restore", data, ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected an oversized backup to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestBackupAuth(t *testing.T) {
+	server := setupTestServer(true, "secret")
+	server.auth.AddNamespaceKey("billing", "billing-key")
+	handler := server.Handler()
+	serve(handler, "PUT", "/ns/billing", `{}`, "secret")
+
+	for _, path := range []string{"/ns/billing/backup", "/backup"} {
+		if w := serve(handler, "GET", path, "", "billing-key"); w.Code == http.StatusOK {
+			t.Errorf("Expected scoped key to be refused for %s", path)
+		}
+	}
+	if w := serve(handler, "GET", "/ns/billing/backup", "", "secret"); w.Code != http.StatusOK {
+		t.Errorf("Expected global key to back up a namespace, got %d", w.Code)
+	}
+}
+
+func TestEncryptedBackup(t *testing.T) {
+	server := setupTestServer(false, "")
+	keys := keyring.New()
+	keys.Add("v1", make([]byte, 32))
+	server.SetKeyring(keys)
+	handler := server.Handler()
+
+	serve(handler, "PUT", "/kv/app/secret", `{"value":"hunter2"}`, "")
+	w := serve(handler, "GET", "/backup", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected backup to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, ".backup.enc") {
+		t.Errorf("Expected an encrypted backup filename, got %q", cd)
+	}
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Error("Expected encrypted backups not to be compressed again")
+	}
+	data := w.Body.String()
+	if !keyring.IsEncrypted([]byte(data)) {
+		t.Fatal("Expected the backup to be encrypted")
+	}
+
+	serve(handler, "DELETE", "/kv/app/secret", "", "")
+	if w := serve(handler, "POST", "/restore", data, ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected restore to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(handler, "GET", "/kv/app/secret", "", ""); !strings.Contains(w.Body.String(), "hunter2") {
+		t.Errorf("Expected the restored value, got %s", w.Body.String())
+	}
+
+	server.SetKeyring(nil)
+	if w := serve(handler, "POST", "/restore", data, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected restoring without keys to be rejected, got %d", w.Code)
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/q4ow/qkrn/internal/bulk"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+type importResponse struct {
+	Success bool `json:"success"`
+	types.ImportResult
+	Error string `json:"error,omitempty"`
+}
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	s.exportItems(w, r, s.store)
+}
+
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	s.importItems(w, r, s.store)
+}
+
+func (s *Server) exportItems(w http.ResponseWriter, r *http.Request, store types.Store) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format, err := bulk.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		s.sendErrorResponse(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="qkrn-export.`+string(format)+`"`)
+	if _, err := bulk.Export(store, r.URL.Query().Get("prefix"), bulk.NewEncoder(w, format)); err != nil {
+		log.Printf("export: %v", err)
+	}
+}
+
+func (s *Server) importItems(w http.ResponseWriter, r *http.Request, store types.Store) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	format, err := bulk.ParseFormat(query.Get("format"))
+	if query.Get("format") == "" {
+		if f, ok := bulk.FormatForContentType(r.Header.Get("Content-Type")); ok {
+			format = f
+		}
+	}
+	if err != nil {
+		s.sendErrorResponse(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+
+	dryRun := false
+	if v := query.Get("dry_run"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			s.sendErrorResponse(w, "Invalid dry_run", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := bulk.Import(store, bulk.NewDecoder(r.Body, format), dryRun)
+	response := importResponse{Success: err == nil && result.Failed == 0, ImportResult: result}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestImportExport(t *testing.T) {
+	server := setupTestServer(false, "")
+	handler := server.Handler()
+
+	body := "{\"key\":\"app/a\",\"value\":\"1\"}\n{\"key\":\"app/b\",\"value\":\"2\",\"ttl\":\"1h\"}\nbroken\n"
+	w := serve(handler, "POST", "/import?dry_run=true", body, "")
+	var result struct {
+		Success  bool `json:"success"`
+		DryRun   bool `json:"dry_run"`
+		Imported int  `json:"imported"`
+		Failed   int  `json:"failed"`
+		Errors   []struct {
+			Line int `json:"line"`
+		} `json:"errors"`
+	}
+	json.NewDecoder(w.Body).Decode(&result)
+	if w.Code != http.StatusOK || !result.DryRun || result.Imported != 2 || result.Failed != 1 || result.Errors[0].Line != 3 {
+		t.Fatalf("Unexpected dry run response %d %+v", w.Code, result)
+	}
+	if w := serve(handler, "GET", "/kv/app/a", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected dry run to write nothing, got %d", w.Code)
+	}
+
+	w = serve(handler, "POST", "/import", body, "")
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.Success || result.Imported != 2 {
+		t.Errorf("Unexpected import response %+v", result)
+	}
+
+	w = serve(handler, "GET", "/export?format=csv&prefix=app/", "", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("Expected CSV export, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 3 || lines[0] != "key,value,ttl,content_type,labels,encoding" || !strings.HasPrefix(lines[2], "app/b,2,1h0m0s,") {
+		t.Errorf("Unexpected CSV export:\n%s", w.Body.String())
+	}
+
+	serve(handler, "PUT", "/ns/team", `{}`, "")
+	req := "key,value\nx,1\n"
+	w = serve(handler, "POST", "/ns/team/import?format=csv", req, "")
+	json.NewDecoder(w.Body).Decode(&result)
+	if !result.Success || result.Imported != 1 {
+		t.Errorf("Expected namespaced CSV import, got %+v", result)
+	}
+	if w := serve(handler, "GET", "/ns/team/export?format=json", "", ""); w.Body.String() != "{\n  \"x\": \"1\"\n}\n" {
+		t.Errorf("Unexpected JSON export %q", w.Body.String())
+	}
+
+	if w := serve(handler, "POST", "/import?format=json", `["nope"]`, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected malformed JSON to be rejected, got %d", w.Code)
+	}
+	if w := serve(handler, "GET", "/export?format=xml", "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected unknown format to be rejected, got %d", w.Code)
+	}
+}
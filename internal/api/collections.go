@@ -0,0 +1,289 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.serveList(w, r, s.store, strings.TrimPrefix(r.URL.Path, "/list/"))
+}
+
+func (s *Server) handleSetCollection(w http.ResponseWriter, r *http.Request) {
+	s.serveSet(w, r, s.store, strings.TrimPrefix(r.URL.Path, "/set/"))
+}
+
+func (s *Server) handleHash(w http.ResponseWriter, r *http.Request) {
+	s.serveHash(w, r, s.store, strings.TrimPrefix(r.URL.Path, "/hash/"))
+}
+
+func (s *Server) serveList(w http.ResponseWriter, r *http.Request, store types.Store, key string) {
+	if key == "" {
+		http.Error(w, "Key is required", http.StatusBadRequest)
+		return
+	}
+
+	lists, ok := store.(types.ListStore)
+	if !ok {
+		s.sendErrorResponse(w, "Storage backend does not support lists", http.StatusNotImplemented)
+		return
+	}
+
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodGet:
+		start, err := queryInt(query.Get("start"), 0)
+		if err != nil {
+			s.sendErrorResponse(w, "Invalid start", http.StatusBadRequest)
+			return
+		}
+		stop, err := queryInt(query.Get("stop"), -1)
+		if err != nil {
+			s.sendErrorResponse(w, "Invalid stop", http.StatusBadRequest)
+			return
+		}
+
+		values, err := lists.ListRange(key, start, stop)
+		if err != nil {
+			s.sendStoreError(w, err)
+			return
+		}
+		sendCollectionResponse(w, map[string]interface{}{"success": true, "values": listValues(values)})
+	case http.MethodPut, http.MethodPost:
+		front, ok := listEnd(query.Get("end"), false)
+		if !ok {
+			s.sendErrorResponse(w, "Invalid end", http.StatusBadRequest)
+			return
+		}
+
+		var req types.CollectionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if len(req.Values) == 0 {
+			s.sendErrorResponse(w, "Values are required", http.StatusBadRequest)
+			return
+		}
+
+		values := make([][]byte, len(req.Values))
+		for i, v := range req.Values {
+			values[i] = []byte(v)
+		}
+
+		length, err := lists.ListPush(key, values, front)
+		if err != nil {
+			s.sendStoreError(w, err)
+			return
+		}
+		sendCollectionResponse(w, map[string]interface{}{"success": true, "length": length})
+	case http.MethodDelete:
+		front, ok := listEnd(query.Get("end"), true)
+		if !ok {
+			s.sendErrorResponse(w, "Invalid end", http.StatusBadRequest)
+			return
+		}
+		count, err := queryInt(query.Get("count"), 1)
+		if err != nil || count < 1 {
+			s.sendErrorResponse(w, "Invalid count", http.StatusBadRequest)
+			return
+		}
+
+		values, err := lists.ListPop(key, count, front)
+		if err != nil {
+			s.sendStoreError(w, err)
+			return
+		}
+		sendCollectionResponse(w, map[string]interface{}{"success": true, "values": listValues(values)})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveSet(w http.ResponseWriter, r *http.Request, store types.Store, key string) {
+	if key == "" {
+		http.Error(w, "Key is required", http.StatusBadRequest)
+		return
+	}
+
+	sets, ok := store.(types.SetStore)
+	if !ok {
+		s.sendErrorResponse(w, "Storage backend does not support sets", http.StatusNotImplemented)
+		return
+	}
+
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodGet:
+		if query.Has("member") {
+			isMember, err := sets.SetIsMember(key, query.Get("member"))
+			if err != nil {
+				s.sendStoreError(w, err)
+				return
+			}
+			sendCollectionResponse(w, map[string]interface{}{"success": true, "is_member": isMember})
+			return
+		}
+
+		members, err := sets.SetMembers(key)
+		if err != nil {
+			s.sendStoreError(w, err)
+			return
+		}
+		sendCollectionResponse(w, map[string]interface{}{"success": true, "members": members})
+	case http.MethodPut, http.MethodPost:
+		var req types.CollectionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if len(req.Members) == 0 {
+			s.sendErrorResponse(w, "Members are required", http.StatusBadRequest)
+			return
+		}
+
+		added, err := sets.SetAdd(key, req.Members...)
+		if err != nil {
+			s.sendStoreError(w, err)
+			return
+		}
+		sendCollectionResponse(w, map[string]interface{}{"success": true, "count": added})
+	case http.MethodDelete:
+		members := query["member"]
+		if len(members) == 0 {
+			var req types.CollectionRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+				s.sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+				return
+			}
+			members = req.Members
+		}
+
+		if len(members) == 0 {
+			s.sendErrorResponse(w, "Members are required", http.StatusBadRequest)
+			return
+		}
+
+		removed, err := sets.SetRemove(key, members...)
+		if err != nil {
+			s.sendStoreError(w, err)
+			return
+		}
+		sendCollectionResponse(w, map[string]interface{}{"success": true, "count": removed})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveHash(w http.ResponseWriter, r *http.Request, store types.Store, key string) {
+	if key == "" {
+		http.Error(w, "Key is required", http.StatusBadRequest)
+		return
+	}
+
+	hashes, ok := store.(types.HashStore)
+	if !ok {
+		s.sendErrorResponse(w, "Storage backend does not support hashes", http.StatusNotImplemented)
+		return
+	}
+
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodGet:
+		if query.Has("field") {
+			value, err := hashes.HashGet(key, query.Get("field"))
+			if err != nil {
+				s.sendStoreError(w, err)
+				return
+			}
+			sendCollectionResponse(w, map[string]interface{}{"success": true, "value": string(value)})
+			return
+		}
+
+		fields, err := hashes.HashGetAll(key)
+		if err != nil {
+			s.sendStoreError(w, err)
+			return
+		}
+
+		response := make(map[string]string, len(fields))
+		for f, v := range fields {
+			response[f] = string(v)
+		}
+		sendCollectionResponse(w, map[string]interface{}{"success": true, "fields": response})
+	case http.MethodPut, http.MethodPost:
+		var req types.CollectionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if len(req.Fields) == 0 {
+			s.sendErrorResponse(w, "Fields are required", http.StatusBadRequest)
+			return
+		}
+
+		fields := make(map[string][]byte, len(req.Fields))
+		for f, v := range req.Fields {
+			fields[f] = []byte(v)
+		}
+
+		added, err := hashes.HashSet(key, fields)
+		if err != nil {
+			s.sendStoreError(w, err)
+			return
+		}
+		sendCollectionResponse(w, map[string]interface{}{"success": true, "count": added})
+	case http.MethodDelete:
+		fields := query["field"]
+		if len(fields) == 0 {
+			s.sendErrorResponse(w, "Fields are required", http.StatusBadRequest)
+			return
+		}
+
+		deleted, err := hashes.HashDelete(key, fields...)
+		if err != nil {
+			s.sendStoreError(w, err)
+			return
+		}
+		sendCollectionResponse(w, map[string]interface{}{"success": true, "count": deleted})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func sendCollectionResponse(w http.ResponseWriter, response map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func listValues(values [][]byte) []string {
+	result := make([]string, len(values))
+	for i, v := range values {
+		result[i] = string(v)
+	}
+	return result
+}
+
+func listEnd(end string, def bool) (bool, bool) {
+	switch end {
+	case "":
+		return def, true
+	case "front":
+		return true, true
+	case "back":
+		return false, true
+	}
+	return false, false
+}
+
+func queryInt(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func decodeCollection(t *testing.T, body []byte) map[string]interface{} {
+	t.Helper()
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return response
+}
+
+func TestHandleLists(t *testing.T) {
+	server := setupTestServer(false, "")
+	handler := server.Handler()
+
+	w := serve(handler, "POST", "/list/queue", `{"values":["a","b"]}`, "")
+	if w.Code != http.StatusOK || decodeCollection(t, w.Body.Bytes())["length"] != float64(2) {
+		t.Fatalf("Unexpected push response: %d %s", w.Code, w.Body.String())
+	}
+	serve(handler, "POST", "/list/queue?end=front", `{"values":["z"]}`, "")
+
+	w = serve(handler, "GET", "/list/queue?start=0&stop=-1", "", "")
+	if got := decodeCollection(t, w.Body.Bytes())["values"]; !reflect.DeepEqual(got, []interface{}{"z", "a", "b"}) {
+		t.Errorf("Unexpected range: %v", got)
+	}
+
+	w = serve(handler, "DELETE", "/list/queue?count=2", "", "")
+	if got := decodeCollection(t, w.Body.Bytes())["values"]; !reflect.DeepEqual(got, []interface{}{"z", "a"}) {
+		t.Errorf("Unexpected pop: %v", got)
+	}
+	w = serve(handler, "DELETE", "/list/queue?end=back", "", "")
+	if got := decodeCollection(t, w.Body.Bytes())["values"]; !reflect.DeepEqual(got, []interface{}{"b"}) {
+		t.Errorf("Unexpected pop: %v", got)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"GET", "/list/queue", "", http.StatusNotFound},
+		{"GET", "/list/queue?start=x", "", http.StatusBadRequest},
+		{"POST", "/list/queue?end=middle", `{"values":["a"]}`, http.StatusBadRequest},
+		{"POST", "/list/queue", `{"values":[]}`, http.StatusBadRequest},
+		{"DELETE", "/list/queue?count=0", "", http.StatusBadRequest},
+		{"PATCH", "/list/queue", "", http.StatusMethodNotAllowed},
+		{"POST", "/list/", `{"values":["a"]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := serve(handler, tt.method, tt.path, tt.body, ""); w.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, w.Code)
+		}
+	}
+}
+
+func TestHandleSetsAndHashes(t *testing.T) {
+	server := setupTestServer(false, "")
+	handler := server.Handler()
+
+	w := serve(handler, "POST", "/set/tags", `{"members":["go","kv","go"]}`, "")
+	if decodeCollection(t, w.Body.Bytes())["count"] != float64(2) {
+		t.Errorf("Unexpected add response: %s", w.Body.String())
+	}
+	w = serve(handler, "GET", "/set/tags", "", "")
+	if got := decodeCollection(t, w.Body.Bytes())["members"]; !reflect.DeepEqual(got, []interface{}{"go", "kv"}) {
+		t.Errorf("Unexpected members: %v", got)
+	}
+	w = serve(handler, "GET", "/set/tags?member=kv", "", "")
+	if decodeCollection(t, w.Body.Bytes())["is_member"] != true {
+		t.Errorf("Expected kv to be a member: %s", w.Body.String())
+	}
+	w = serve(handler, "DELETE", "/set/tags?member=kv&member=nope", "", "")
+	if decodeCollection(t, w.Body.Bytes())["count"] != float64(1) {
+		t.Errorf("Unexpected remove response: %s", w.Body.String())
+	}
+
+	w = serve(handler, "PUT", "/hash/user", `{"fields":{"name":"ada","lang":"go"}}`, "")
+	if decodeCollection(t, w.Body.Bytes())["count"] != float64(2) {
+		t.Errorf("Unexpected hash set response: %s", w.Body.String())
+	}
+	w = serve(handler, "GET", "/hash/user?field=name", "", "")
+	if decodeCollection(t, w.Body.Bytes())["value"] != "ada" {
+		t.Errorf("Unexpected field value: %s", w.Body.String())
+	}
+	if w := serve(handler, "GET", "/hash/user?field=missing", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a missing field, got %d", http.StatusNotFound, w.Code)
+	}
+	serve(handler, "DELETE", "/hash/user?field=lang", "", "")
+	w = serve(handler, "GET", "/hash/user", "", "")
+	if got := decodeCollection(t, w.Body.Bytes())["fields"]; !reflect.DeepEqual(got, map[string]interface{}{"name": "ada"}) {
+		t.Errorf("Unexpected fields: %v", got)
+	}
+
+	w = serve(handler, "HEAD", "/kv/user", "", "")
+	if w.Code != http.StatusOK || w.Header().Get("X-Qkrn-Type") != "hash" || w.Header().Get("X-Qkrn-Length") != "1" {
+		t.Errorf("Unexpected HEAD response: %d %v", w.Code, w.Header())
+	}
+
+	wrongType := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"GET", "/kv/user", ""},
+		{"POST", "/list/user", `{"values":["a"]}`},
+		{"GET", "/set/user", ""},
+		{"POST", "/incr/tags", ""},
+		{"PUT", "/hash/tags", `{"fields":{"a":"b"}}`},
+	}
+	for _, tt := range wrongType {
+		if w := serve(handler, tt.method, tt.path, tt.body, ""); w.Code != http.StatusConflict {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, http.StatusConflict, w.Code)
+		}
+	}
+
+	serve(handler, "PUT", "/ns/team", `{}`, "")
+	serve(handler, "POST", "/ns/team/set/tags", `{"members":["x"]}`, "")
+	w = serve(handler, "GET", "/ns/team/set/tags", "", "")
+	if got := decodeCollection(t, w.Body.Bytes())["members"]; !reflect.DeepEqual(got, []interface{}{"x"}) {
+		t.Errorf("Expected namespaced set, got %v", got)
+	}
+}
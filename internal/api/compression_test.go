@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/q4ow/qkrn/internal/auth"
+	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func gzipped(t *testing.T, s string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return &buf
+}
+
+func TestGzipRequestAndResponse(t *testing.T) {
+	handler := setupTestServer(false, "").Handler()
+	value := strings.Repeat("compressible ", 200)
+
+	req := httptest.NewRequest("PUT", "/kv/doc", gzipped(t, `{"value":"`+value+`"}`))
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected a gzip request body to be accepted, got %d %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/kv/doc?raw=true", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Length") != "" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("Expected a gzip response, got headers %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Expected a valid gzip body: %v", err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != value {
+		t.Errorf("Expected the decompressed value, got %d bytes", len(body))
+	}
+
+	req = httptest.NewRequest("GET", "/kv/doc?raw=true", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != value {
+		t.Errorf("Expected an identity response when gzip is refused, got %v", w.Header())
+	}
+}
+
+func TestGzipRequestErrors(t *testing.T) {
+	handler := setupTestServer(false, "").Handler()
+
+	req := httptest.NewRequest("PUT", "/kv/doc", strings.NewReader(`{"value":"x"}`))
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid gzip body to be rejected, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/kv/doc", strings.NewReader(`{"value":"x"}`))
+	req.Header.Set("Content-Encoding", "br")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected an unknown encoding to be rejected, got %d", w.Code)
+	}
+}
+
+func TestGzipBomb(t *testing.T) {
+	defer func(size int64) { maxDecompressedSize = size }(maxDecompressedSize)
+	maxDecompressedSize = 64 * 1024
+	handler := setupTestServer(false, "").Handler()
+	bomb := gzipped(t, `{"value":"`+strings.Repeat("a", 16*1024*1024)+`"}`)
+
+	for _, path := range []string{"/kv/bomb?raw=true", "/kv/bomb"} {
+		req := httptest.NewRequest("PUT", path, bytes.NewReader(bomb.Bytes()))
+		req.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected a body that inflates past the limit to be rejected, got %d %s", path, w.Code, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/kv/bomb", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected nothing to be stored, got %d", w.Code)
+	}
+}
+
+func TestCompressionMetrics(t *testing.T) {
+	kvStore := store.NewMemoryStore()
+	kvStore.SetCompression(types.Compression{Codec: "gzip", Threshold: 64})
+	handler := NewServer(kvStore, 8080, auth.NewAuthenticator(false, "")).Handler()
+
+	kvStore.Set("doc", []byte(strings.Repeat("a", 1000)))
+
+	body := serve(handler, "GET", "/metrics", "", "").Body.String()
+	for _, want := range []string{
+		`qkrn_compressed_keys{namespace="default"} 1`,
+		`qkrn_uncompressed_bytes{namespace="default"} 1000`,
+		"# TYPE qkrn_compression_ratio gauge",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `qkrn_compression_ratio{namespace="default"} 1.000`) {
+		t.Errorf("Expected a compression ratio above 1, got:\n%s", body)
+	}
+}
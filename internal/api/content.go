@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const (
+	maxRawValueSize    = 64 * 1024 * 1024
+	defaultContentType = "application/octet-stream"
+)
+
+func mediaType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	}
+	return media
+}
+
+func queryBool(r *http.Request, name string) bool {
+	value, err := strconv.ParseBool(r.URL.Query().Get(name))
+	return err == nil && value
+}
+
+func isRawRequest(r *http.Request) bool {
+	if queryBool(r, "raw") {
+		return true
+	}
+
+	switch mediaType(r.Header.Get("Content-Type")) {
+	case "", "application/json", "application/x-www-form-urlencoded":
+		return false
+	}
+	return true
+}
+
+func wantsRaw(r *http.Request, contentType string) bool {
+	if queryBool(r, "raw") {
+		return true
+	}
+
+	stored := mediaType(contentType)
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		media, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue
+		}
+
+		switch {
+		case media == defaultContentType:
+			return true
+		case media == "application/json", media == "*/*", stored == "":
+			continue
+		case media == stored:
+			return true
+		case strings.HasSuffix(media, "/*") && strings.HasPrefix(stored, strings.TrimSuffix(media, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+func rawContentType(entry types.Entry) string {
+	if entry.ContentType == "" {
+		return defaultContentType
+	}
+	return entry.ContentType
+}
+
+func writeRaw(w http.ResponseWriter, entry types.Entry) {
+	w.Header().Set("Content-Type", rawContentType(entry))
+	w.Header().Set("Content-Length", strconv.Itoa(len(entry.Value)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.WriteHeader(http.StatusOK)
+	w.Write(entry.Value)
+}
+
+func valueResponse(entry types.Entry) types.Response {
+	meta := entry.Metadata()
+	response := types.Response{
+		Success:     true,
+		ContentType: entry.ContentType,
+		Metadata:    &meta,
+	}
+
+	response.Value, response.Encoding = encodeValue(entry.Value)
+	return response
+}
+
+func encodeValue(value []byte) (string, string) {
+	if utf8.Valid(value) {
+		return string(value), ""
+	}
+	return base64.StdEncoding.EncodeToString(value), "base64"
+}
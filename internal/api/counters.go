@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func (s *Server) handleIncr(w http.ResponseWriter, r *http.Request) {
+	s.serveCounter(w, r, s.store, strings.TrimPrefix(r.URL.Path, "/incr/"), false)
+}
+
+func (s *Server) handleDecr(w http.ResponseWriter, r *http.Request) {
+	s.serveCounter(w, r, s.store, strings.TrimPrefix(r.URL.Path, "/decr/"), true)
+}
+
+func (s *Server) serveCounter(w http.ResponseWriter, r *http.Request, store types.Store, key string, decr bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if key == "" {
+		http.Error(w, "Key is required", http.StatusBadRequest)
+		return
+	}
+
+	counters, ok := store.(types.CounterStore)
+	if !ok {
+		s.sendErrorResponse(w, "Storage backend does not support counters", http.StatusNotImplemented)
+		return
+	}
+
+	var req types.CounterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		s.sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var value string
+	var err error
+	if req.Float {
+		value, err = incrFloat(counters, key, req, decr)
+	} else {
+		value, err = incrInt(counters, key, req, decr)
+	}
+
+	var invalid invalidFieldError
+	if errors.As(err, &invalid) {
+		s.sendErrorResponse(w, invalid.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+
+	response := types.Response{
+		Success: true,
+		Value:   value,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+type invalidFieldError string
+
+func (e invalidFieldError) Error() string {
+	return "Invalid " + string(e)
+}
+
+func incrInt(counters types.CounterStore, key string, req types.CounterRequest, decr bool) (string, error) {
+	by, err := intField(req.By, "by", 1)
+	if err != nil {
+		return "", err
+	}
+	if decr {
+		by = -by
+	}
+
+	var opts types.IncrOptions
+	if opts.Initial, err = intField(req.Initial, "initial", 0); err != nil {
+		return "", err
+	}
+	if req.Min != "" {
+		min, err := intField(req.Min, "min", 0)
+		if err != nil {
+			return "", err
+		}
+		opts.Min = &min
+	}
+	if req.Max != "" {
+		max, err := intField(req.Max, "max", 0)
+		if err != nil {
+			return "", err
+		}
+		opts.Max = &max
+	}
+
+	n, err := counters.Incr(key, by, opts)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+func incrFloat(counters types.CounterStore, key string, req types.CounterRequest, decr bool) (string, error) {
+	by, err := floatField(req.By, "by", 1)
+	if err != nil {
+		return "", err
+	}
+	if decr {
+		by = -by
+	}
+
+	var opts types.IncrFloatOptions
+	if opts.Initial, err = floatField(req.Initial, "initial", 0); err != nil {
+		return "", err
+	}
+	if req.Min != "" {
+		min, err := floatField(req.Min, "min", 0)
+		if err != nil {
+			return "", err
+		}
+		opts.Min = &min
+	}
+	if req.Max != "" {
+		max, err := floatField(req.Max, "max", 0)
+		if err != nil {
+			return "", err
+		}
+		opts.Max = &max
+	}
+
+	f, err := counters.IncrFloat(key, by, opts)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), nil
+}
+
+func intField(n json.Number, name string, def int64) (int64, error) {
+	if n == "" {
+		return def, nil
+	}
+	v, err := n.Int64()
+	if err != nil {
+		return 0, invalidFieldError(name)
+	}
+	return v, nil
+}
+
+func floatField(n json.Number, name string, def float64) (float64, error) {
+	if n == "" {
+		return def, nil
+	}
+	v, err := n.Float64()
+	if err != nil {
+		return 0, invalidFieldError(name)
+	}
+	return v, nil
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func TestHandleCounters(t *testing.T) {
+	server := setupTestServer(false, "")
+	handler := server.Handler()
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+		value  string
+	}{
+		{"/incr/hits", "", http.StatusOK, "1"},
+		{"/incr/hits", `{"by":10}`, http.StatusOK, "11"},
+		{"/decr/hits", `{"by":3}`, http.StatusOK, "8"},
+		{"/incr/fresh", `{"by":1,"initial":100}`, http.StatusOK, "101"},
+		{"/incr/slots", `{"by":2,"max":1}`, http.StatusConflict, ""},
+		{"/decr/slots", `{"min":0}`, http.StatusConflict, ""},
+		{"/incr/temp", `{"by":1.5,"float":true,"initial":20}`, http.StatusOK, "21.5"},
+		{"/decr/temp", `{"by":0.25,"float":true}`, http.StatusOK, "21.25"},
+		{"/incr/temp", "", http.StatusConflict, ""},
+		{"/incr/hits", `{"by":1.5}`, http.StatusBadRequest, ""},
+		{"/incr/hits", `{not json`, http.StatusBadRequest, ""},
+		{"/incr/", "", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		w := serve(handler, "POST", tt.path, tt.body, "")
+		if w.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tt.path, tt.body, tt.status, w.Code, w.Body.String())
+			continue
+		}
+		if tt.value == "" {
+			continue
+		}
+
+		var response types.Response
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Value != tt.value {
+			t.Errorf("%s %s: expected %s, got %s", tt.path, tt.body, tt.value, response.Value)
+		}
+	}
+
+	if w := serve(handler, "GET", "/incr/hits", "", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	serve(handler, "PUT", "/ns/team", `{}`, "")
+	if w := serve(handler, "POST", "/ns/team/incr/hits", "", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected namespaced counter to work, got %d", w.Code)
+	}
+	if value, _ := server.store.Get("hits"); string(value) != "8" {
+		t.Errorf("Expected namespaced counter to be isolated, got %s", value)
+	}
+}
+
+func TestHandleCountersConcurrent(t *testing.T) {
+	server := setupTestServer(false, "")
+	handler := server.Handler()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				serve(handler, "POST", "/incr/counter", "", "")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if value, _ := server.store.Get("counter"); string(value) != "500" {
+		t.Errorf("Expected 500 after concurrent increments, got %s", value)
+	}
+}
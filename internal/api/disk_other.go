@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package api
+
+import "errors"
+
+func freeDiskBytes(path string) (uint64, error) {
+	return 0, errors.New("disk space check not supported on this platform")
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+type ReadinessCheck func() error
+
+type namedCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+type readiness struct {
+	mu     sync.RWMutex
+	checks []namedCheck
+}
+
+func (s *Server) AddReadinessCheck(name string, check ReadinessCheck) {
+	s.ready.mu.Lock()
+	defer s.ready.mu.Unlock()
+	s.ready.checks = append(s.ready.checks, namedCheck{name: name, check: check})
+}
+
+func StoreCheck(store types.Store) ReadinessCheck {
+	return func() error {
+		if hc, ok := store.(types.HealthChecker); ok {
+			return hc.Healthy()
+		}
+		store.Keys()
+		return nil
+	}
+}
+
+func DiskSpaceCheck(path string, minFreeMB int64) ReadinessCheck {
+	return func() error {
+		free, err := freeDiskBytes(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+
+		freeMB := int64(free / (1024 * 1024))
+		if freeMB < minFreeMB {
+			return fmt.Errorf("%d MB free on %s, need at least %d MB", freeMB, path, minFreeMB)
+		}
+		return nil
+	}
+}
+
+func (s *Server) runReadinessChecks() types.ReadinessReport {
+	s.ready.mu.RLock()
+	checks := make([]namedCheck, len(s.ready.checks))
+	copy(checks, s.ready.checks)
+	s.ready.mu.RUnlock()
+
+	start := time.Now()
+	results := make([]types.CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+
+			checkStart := time.Now()
+			err := c.check()
+			result := types.CheckResult{
+				Name:       c.name,
+				Status:     "pass",
+				DurationMs: millis(time.Since(checkStart)),
+			}
+			if err != nil {
+				result.Status = "fail"
+				result.Error = err.Error()
+			}
+			results[i] = result
+		}(i, c)
+	}
+	wg.Wait()
+
+	response := types.ReadinessReport{
+		Status:     "ready",
+		DurationMs: millis(time.Since(start)),
+		Checks:     results,
+	}
+	for _, result := range results {
+		if result.Status != "pass" {
+			response.Status = "not_ready"
+			break
+		}
+	}
+
+	return response
+}
+
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := s.runReadinessChecks()
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func TestHandleReadiness(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		extraCheck     ReadinessCheck
+		expectedStatus int
+		expectedState  string
+	}{
+		{
+			name:           "GET ready with default checks",
+			method:         "GET",
+			expectedStatus: http.StatusOK,
+			expectedState:  "ready",
+		},
+		{
+			name:           "GET not ready when a check fails",
+			method:         "GET",
+			extraCheck:     func() error { return errors.New("leader unknown") },
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedState:  "not_ready",
+		},
+		{
+			name:           "POST ready not allowed",
+			method:         "POST",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupTestServer(false, "")
+			if tt.extraCheck != nil {
+				server.AddReadinessCheck("extra", tt.extraCheck)
+			}
+
+			req := httptest.NewRequest(tt.method, "/health/ready", nil)
+			w := httptest.NewRecorder()
+
+			server.handleReadiness(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if tt.expectedState == "" {
+				return
+			}
+
+			var response types.ReadinessReport
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if response.Status != tt.expectedState {
+				t.Errorf("Expected status '%s', got '%s'", tt.expectedState, response.Status)
+			}
+
+			if len(response.Checks) == 0 || response.Checks[0].Name != "store" {
+				t.Fatalf("Expected store check to be reported first, got %+v", response.Checks)
+			}
+
+			for _, check := range response.Checks {
+				if check.Name == "extra" {
+					if check.Status != "fail" || check.Error != "leader unknown" {
+						t.Errorf("Expected failing extra check with error, got %+v", check)
+					}
+				} else if check.Status != "pass" {
+					t.Errorf("Expected check %s to pass, got %+v", check.Name, check)
+				}
+			}
+		})
+	}
+}
+
+func TestDiskSpaceCheck(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := DiskSpaceCheck(dir, 0)(); err != nil {
+		t.Errorf("Expected disk check with zero threshold to pass, got %v", err)
+	}
+
+	if err := DiskSpaceCheck(dir, 1<<40)(); err == nil {
+		t.Error("Expected disk check with huge threshold to fail")
+	}
+
+	if err := DiskSpaceCheck("/nonexistent/path", 0)(); err == nil {
+		t.Error("Expected disk check on missing path to fail")
+	}
+}
+
+func TestLivenessEndpoint(t *testing.T) {
+	server := setupTestServer(true, "test-key")
+	req := httptest.NewRequest("GET", "/health/live", nil)
+	w := httptest.NewRecorder()
+
+	server.server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
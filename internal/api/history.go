@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+var errHistoryUnsupported = errors.New("storage backend does not keep history")
+
+func revisionParam(r *http.Request) (uint64, error) {
+	param := r.URL.Query().Get("revision")
+	if param == "" {
+		return 0, nil
+	}
+
+	revision, err := strconv.ParseUint(param, 10, 64)
+	if err != nil || revision == 0 {
+		return 0, errors.New("invalid revision")
+	}
+	return revision, nil
+}
+
+func getEntryAt(store types.Store, key string, revision uint64) (types.Entry, error) {
+	if revision == 0 {
+		return getEntry(store, key)
+	}
+
+	history, ok := store.(types.HistoryStore)
+	if !ok {
+		return types.Entry{}, errHistoryUnsupported
+	}
+	return history.GetRevision(key, revision)
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	s.serveHistory(w, r, s.store, strings.TrimPrefix(r.URL.Path, "/history/"))
+}
+
+func (s *Server) serveHistory(w http.ResponseWriter, r *http.Request, store types.Store, key string) {
+	if key == "" {
+		http.Error(w, "Key is required", http.StatusBadRequest)
+		return
+	}
+
+	history, ok := store.(types.HistoryStore)
+	if !ok {
+		s.sendStoreError(w, errHistoryUnsupported)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		entries, err := history.History(key)
+		if err != nil {
+			s.sendStoreError(w, err)
+			return
+		}
+
+		revisions := make([]types.Metadata, 0, len(entries))
+		for _, entry := range entries {
+			revisions = append(revisions, entry.Metadata())
+		}
+
+		response := map[string]interface{}{
+			"key":       key,
+			"revisions": revisions,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	case http.MethodPost:
+		var req types.RevisionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Revision == 0 {
+			s.sendErrorResponse(w, "Revision is required", http.StatusBadRequest)
+			return
+		}
+
+		entry, err := history.Restore(key, req.Revision)
+		if err != nil {
+			s.sendStoreError(w, err)
+			return
+		}
+
+		meta := entry.Metadata()
+		response := types.Response{
+			Success:  true,
+			Metadata: &meta,
+		}
+
+		writeMetadataHeaders(w.Header(), entry)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
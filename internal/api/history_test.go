@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func TestHandleHistory(t *testing.T) {
+	server := setupTestServer(false, "")
+	server.store.(*store.MemoryStore).SetHistoryLimits(types.HistoryLimits{MaxVersions: 5})
+	handler := server.Handler()
+
+	serve(handler, "PUT", "/kv/config", `{"value":"v1"}`, "")
+	serve(handler, "PUT", "/kv/config", `{"value":"v2"}`, "")
+
+	w := serve(handler, "GET", "/history/config", "", "")
+	var history struct {
+		Key       string           `json:"key"`
+		Revisions []types.Metadata `json:"revisions"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if history.Key != "config" || len(history.Revisions) != 2 || history.Revisions[1].Version != 1 {
+		t.Fatalf("Unexpected history: %+v", history)
+	}
+	first := strconv.FormatUint(history.Revisions[1].Revision, 10)
+
+	w = serve(handler, "GET", "/kv/config?revision="+first, "", "")
+	var response types.Response
+	json.NewDecoder(w.Body).Decode(&response)
+	if w.Code != http.StatusOK || response.Value != "v1" {
+		t.Errorf("Expected v1 at revision %s, got %d %+v", first, w.Code, response)
+	}
+	if w := serve(handler, "HEAD", "/kv/config?revision="+first, "", ""); w.Header().Get("X-Qkrn-Revision") != first {
+		t.Errorf("Expected HEAD to describe revision %s, got %v", first, w.Header())
+	}
+
+	w = serve(handler, "POST", "/history/config", `{"revision":`+first+`}`, "")
+	if w.Code != http.StatusOK || w.Header().Get("X-Qkrn-Version") != "3" {
+		t.Errorf("Expected restore to write version 3, got %d %v", w.Code, w.Header())
+	}
+	w = serve(handler, "GET", "/kv/config", "", "")
+	response = types.Response{}
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.Value != "v1" {
+		t.Errorf("Expected restored value v1, got %s", response.Value)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"GET", "/kv/config?revision=abc", "", http.StatusBadRequest},
+		{"GET", "/kv/config?revision=0", "", http.StatusBadRequest},
+		{"GET", "/history/missing", "", http.StatusNotFound},
+		{"POST", "/history/config", `{}`, http.StatusBadRequest},
+		{"POST", "/history/missing", `{"revision":1}`, http.StatusNotFound},
+		{"DELETE", "/history/config", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if w := serve(handler, tt.method, tt.path, tt.body, ""); w.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const labelsHeader = "X-Qkrn-Labels"
+
+func writeMetadataHeaders(h http.Header, entry types.Entry) {
+	meta := entry.Metadata()
+
+	h.Set("X-Qkrn-Type", string(meta.Type))
+	h.Set("X-Qkrn-Version", strconv.FormatUint(meta.Version, 10))
+	h.Set("X-Qkrn-Revision", strconv.FormatUint(meta.Revision, 10))
+	h.Set("X-Qkrn-Size", strconv.Itoa(meta.Size))
+	if meta.Length > 0 {
+		h.Set("X-Qkrn-Length", strconv.Itoa(meta.Length))
+	}
+	if !meta.CreatedAt.IsZero() {
+		h.Set("X-Qkrn-Created", meta.CreatedAt.UTC().Format(time.RFC3339Nano))
+	}
+	if !meta.ModifiedAt.IsZero() {
+		h.Set("X-Qkrn-Modified", meta.ModifiedAt.UTC().Format(time.RFC3339Nano))
+		h.Set("Last-Modified", meta.ModifiedAt.UTC().Format(http.TimeFormat))
+	}
+	if meta.ExpiresAt != nil {
+		h.Set("X-Qkrn-Expires", meta.ExpiresAt.UTC().Format(time.RFC3339Nano))
+	}
+	if len(meta.Labels) > 0 {
+		labels := url.Values{}
+		for name, value := range meta.Labels {
+			labels.Set(name, value)
+		}
+		h.Set(labelsHeader, labels.Encode())
+	}
+}
+
+func parseLabelsHeader(header string) (map[string]string, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	values, err := url.ParseQuery(header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %w", labelsHeader, err)
+	}
+
+	labels := make(map[string]string, len(values))
+	for name, v := range values {
+		if name == "" {
+			return nil, fmt.Errorf("invalid %s header: empty label name", labelsHeader)
+		}
+		labels[name] = v[len(v)-1]
+	}
+	return labels, nil
+}
+
+type labelSelector struct {
+	name  string
+	value string
+	exact bool
+}
+
+func parseLabelSelectors(params []string) []labelSelector {
+	selectors := make([]labelSelector, 0, len(params))
+	for _, param := range params {
+		if param == "" {
+			continue
+		}
+		name, value, exact := strings.Cut(param, "=")
+		selectors = append(selectors, labelSelector{name: name, value: value, exact: exact})
+	}
+	return selectors
+}
+
+func matchLabels(labels map[string]string, selectors []labelSelector) bool {
+	for _, sel := range selectors {
+		value, ok := labels[sel.name]
+		if !ok || (sel.exact && value != sel.value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+type metric struct {
+	name    string
+	help    string
+	kind    string
+	samples []sample
+}
+
+type sample struct {
+	labels [][2]string
+	value  string
+}
+
+func (m *metric) add(value string, labels ...[2]string) {
+	m.samples = append(m.samples, sample{labels: labels, value: value})
+}
+
+func (m *metric) writeTo(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+	for _, s := range m.samples {
+		pairs := make([]string, len(s.labels))
+		for i, label := range s.labels {
+			pairs[i] = label[0] + "=" + strconv.Quote(label[1])
+		}
+		fmt.Fprintf(w, "%s{%s} %s\n", m.name, strings.Join(pairs, ","), s.value)
+	}
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	keys := &metric{name: "qkrn_keys", help: "Number of live keys.", kind: "gauge"}
+	used := &metric{name: "qkrn_memory_used_bytes", help: "Estimated memory held by stored entries.", kind: "gauge"}
+	limit := &metric{name: "qkrn_memory_max_bytes", help: "Configured memory limit, 0 when unlimited.", kind: "gauge"}
+	evictions := &metric{name: "qkrn_evicted_keys_total", help: "Keys evicted to stay under the memory limit.", kind: "counter"}
+	compressedKeys := &metric{name: "qkrn_compressed_keys", help: "Number of keys stored compressed.", kind: "gauge"}
+	compressedBytes := &metric{name: "qkrn_compressed_bytes", help: "Stored size of compressed values.", kind: "gauge"}
+	uncompressedBytes := &metric{name: "qkrn_uncompressed_bytes", help: "Original size of compressed values.", kind: "gauge"}
+	ratio := &metric{name: "qkrn_compression_ratio", help: "Original size divided by stored size of compressed values, 1 when nothing is compressed.", kind: "gauge"}
+
+	for _, ns := range s.namespaces.List() {
+		name := [2]string{"namespace", ns.Name}
+
+		stats, ok := ns.Store.(types.StatsStore)
+		if !ok {
+			keys.add(strconv.Itoa(len(ns.Store.Keys())), name)
+			continue
+		}
+
+		st := stats.Stats()
+		keys.add(strconv.Itoa(st.Keys), name)
+		used.add(strconv.FormatInt(st.MemoryUsed, 10), name)
+		limit.add(strconv.FormatInt(st.MaxMemory, 10), name)
+		evictions.add(strconv.FormatUint(st.Evictions, 10), name, [2]string{"policy", string(st.EvictionPolicy)})
+		compressedKeys.add(strconv.FormatInt(st.CompressedKeys, 10), name)
+		compressedBytes.add(strconv.FormatInt(st.CompressedBytes, 10), name)
+		uncompressedBytes.add(strconv.FormatInt(st.UncompressedBytes, 10), name)
+		ratio.add(strconv.FormatFloat(compressionRatio(st), 'f', 3, 64), name)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range []*metric{keys, used, limit, evictions, compressedKeys, compressedBytes, uncompressedBytes, ratio} {
+		m.writeTo(w)
+	}
+}
+
+func compressionRatio(st types.StoreStats) float64 {
+	if st.CompressedBytes == 0 {
+		return 1
+	}
+	return float64(st.UncompressedBytes) / float64(st.CompressedBytes)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func TestMemoryLimitAndMetrics(t *testing.T) {
+	server := setupTestServer(false, "")
+	handler := server.Handler()
+
+	w := serve(handler, "PUT", "/ns/cache", `{"max_memory":600,"eviction_policy":"lru"}`, "")
+	var info types.NamespaceInfo
+	json.NewDecoder(w.Body).Decode(&info)
+	if w.Code != http.StatusCreated || info.MaxMemory != 600 || info.EvictionPolicy != "lru" {
+		t.Fatalf("Expected namespace with a memory limit, got %d %+v", w.Code, info)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if w := serve(handler, "PUT", "/ns/cache/kv/"+key, `{"value":"v"}`, ""); w.Code != http.StatusCreated {
+			t.Fatalf("Expected write to evict instead of failing, got %d", w.Code)
+		}
+	}
+
+	serve(handler, "PUT", "/ns/strict", `{"max_memory":300}`, "")
+	serve(handler, "PUT", "/ns/strict/kv/a", `{"value":"v"}`, "")
+	w = serve(handler, "PUT", "/ns/strict/kv/b", `{"value":"v"}`, "")
+	if w.Code != http.StatusInsufficientStorage || !strings.Contains(w.Body.String(), "Memory limit reached") {
+		t.Errorf("Expected 507 with noeviction, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := serve(handler, "PUT", "/ns/bad", `{"max_memory":100,"eviction_policy":"fifo"}`, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected unknown policy to be rejected, got %d", w.Code)
+	}
+
+	w = serve(handler, "GET", "/metrics", "", "")
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE qkrn_evicted_keys_total counter",
+		`qkrn_evicted_keys_total{namespace="cache",policy="lru"} 1`,
+		`qkrn_keys{namespace="cache"} 2`,
+		`qkrn_memory_max_bytes{namespace="strict"} 300`,
+		`qkrn_evicted_keys_total{namespace="default",policy="noeviction"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/q4ow/qkrn/internal/namespace"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func splitNamespacePath(path string) (string, string) {
+	name, rest, _ := strings.Cut(strings.TrimPrefix(path, "/ns/"), "/")
+	return name, rest
+}
+
+func namespaceScope(r *http.Request) string {
+	name, rest := splitNamespacePath(r.URL.Path)
+	switch {
+	case rest == "" && r.Method != http.MethodGet:
+		return ""
+	case rest == "compact", rest == "backup", rest == "restore":
+		return ""
+	}
+	return name
+}
+
+func (s *Server) handleNamespaces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	list := s.namespaces.List()
+	infos := make([]types.NamespaceInfo, 0, len(list))
+	for _, ns := range list {
+		infos = append(infos, ns.Info())
+	}
+
+	response := map[string][]types.NamespaceInfo{
+		"namespaces": infos,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) handleNamespace(w http.ResponseWriter, r *http.Request) {
+	name, rest := splitNamespacePath(r.URL.Path)
+	if name == "" {
+		http.Error(w, "Namespace is required", http.StatusBadRequest)
+		return
+	}
+
+	if rest == "" {
+		s.handleNamespaceSettings(w, r, name)
+		return
+	}
+
+	ns, err := s.namespaces.Get(name)
+	if err != nil {
+		s.sendErrorResponse(w, "Namespace not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case rest == "keys":
+		s.listKeys(w, r, ns.Store)
+	case rest == "compact":
+		s.compact(w, r, ns.Store)
+	case rest == "backup":
+		s.backup(w, r, ns.Store)
+	case rest == "restore":
+		s.restore(w, r, ns.Store)
+	case rest == "export":
+		s.exportItems(w, r, ns.Store)
+	case rest == "import":
+		s.importItems(w, r, ns.Store)
+	case strings.HasPrefix(rest, "kv/"):
+		key := strings.TrimPrefix(rest, "kv/")
+		if key == "" {
+			http.Error(w, "Key is required", http.StatusBadRequest)
+			return
+		}
+		s.serveKeyValue(w, r, ns.Store, key)
+	case strings.HasPrefix(rest, "incr/"):
+		s.serveCounter(w, r, ns.Store, strings.TrimPrefix(rest, "incr/"), false)
+	case strings.HasPrefix(rest, "decr/"):
+		s.serveCounter(w, r, ns.Store, strings.TrimPrefix(rest, "decr/"), true)
+	case strings.HasPrefix(rest, "list/"):
+		s.serveList(w, r, ns.Store, strings.TrimPrefix(rest, "list/"))
+	case strings.HasPrefix(rest, "set/"):
+		s.serveSet(w, r, ns.Store, strings.TrimPrefix(rest, "set/"))
+	case strings.HasPrefix(rest, "hash/"):
+		s.serveHash(w, r, ns.Store, strings.TrimPrefix(rest, "hash/"))
+	case strings.HasPrefix(rest, "history/"):
+		s.serveHistory(w, r, ns.Store, strings.TrimPrefix(rest, "history/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleNamespaceSettings(w http.ResponseWriter, r *http.Request, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		ns, err := s.namespaces.Get(name)
+		if err != nil {
+			s.sendErrorResponse(w, "Namespace not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ns.Info())
+	case http.MethodPut, http.MethodPost:
+		var req types.NamespaceInfo
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		limits, err := namespaceLimits(req)
+		if err != nil {
+			s.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ns, created, err := s.namespaces.Ensure(name, limits)
+		switch err {
+		case nil:
+		case namespace.ErrInvalidName:
+			s.sendErrorResponse(w, "Invalid namespace name", http.StatusBadRequest)
+			return
+		default:
+			s.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if created {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(ns.Info())
+	case http.MethodDelete:
+		switch err := s.namespaces.Delete(name); err {
+		case nil:
+		case namespace.ErrNotFound:
+			s.sendErrorResponse(w, "Namespace not found", http.StatusNotFound)
+			return
+		case namespace.ErrDeleteDefault:
+			s.sendErrorResponse(w, "The default namespace cannot be deleted", http.StatusBadRequest)
+			return
+		default:
+			s.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(types.Response{Success: true})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func namespaceLimits(info types.NamespaceInfo) (types.Limits, error) {
+	limits := types.Limits{MaxKeys: info.MaxKeys, MaxValueSize: info.MaxValueSize, MaxMemory: info.MaxMemory}
+	if info.DefaultTTL != "" {
+		ttl, err := time.ParseDuration(info.DefaultTTL)
+		if err != nil || ttl < 0 {
+			return types.Limits{}, errors.New("Invalid default_ttl")
+		}
+		limits.DefaultTTL = ttl
+	}
+	if limits.MaxKeys < 0 {
+		return types.Limits{}, errors.New("Invalid max_keys")
+	}
+	if limits.MaxValueSize < 0 {
+		return types.Limits{}, errors.New("Invalid max_value_size")
+	}
+	if limits.MaxMemory < 0 {
+		return types.Limits{}, errors.New("Invalid max_memory")
+	}
+	policy, ok := types.ParseEvictionPolicy(info.EvictionPolicy)
+	if !ok {
+		return types.Limits{}, errors.New("Invalid eviction_policy")
+	}
+	if limits.MaxMemory > 0 {
+		limits.Eviction = policy
+	}
+	return limits, nil
+}
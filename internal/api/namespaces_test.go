@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func serve(handler http.Handler, method, path, body, token string) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, path, nil)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(body))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestNamespaces(t *testing.T) {
+	server := setupTestServer(false, "")
+	handler := server.Handler()
+
+	w := serve(handler, "PUT", "/ns/billing", `{"max_keys":1,"max_value_size":8,"default_ttl":"1h"}`, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var info types.NamespaceInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if info.Name != "billing" || info.MaxKeys != 1 || info.DefaultTTL != "1h0m0s" {
+		t.Errorf("Unexpected namespace info: %+v", info)
+	}
+
+	if w := serve(handler, "PUT", "/ns/billing/kv/shared", `{"value":"billing"}`, ""); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	serve(handler, "PUT", "/kv/shared", `{"value":"default"}`, "")
+
+	var response types.Response
+	json.NewDecoder(serve(handler, "GET", "/ns/billing/kv/shared", "", "").Body).Decode(&response)
+	if response.Value != "billing" || response.Metadata == nil || response.Metadata.ExpiresAt == nil {
+		t.Errorf("Expected namespaced value with default TTL, got %+v", response)
+	}
+	json.NewDecoder(serve(handler, "GET", "/ns/default/kv/shared", "", "").Body).Decode(&response)
+	if response.Value != "default" {
+		t.Errorf("Expected /kv/ to map to the default namespace, got %+v", response)
+	}
+
+	if w := serve(handler, "PUT", "/ns/billing/kv/other", `{"value":"v"}`, ""); w.Code != http.StatusInsufficientStorage {
+		t.Errorf("Expected key limit to return %d, got %d", http.StatusInsufficientStorage, w.Code)
+	}
+	if w := serve(handler, "PUT", "/ns/billing/kv/shared", `{"value":"way too large"}`, ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected value size limit to return %d, got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+
+	var keys map[string][]string
+	json.NewDecoder(serve(handler, "GET", "/ns/billing/keys", "", "").Body).Decode(&keys)
+	if len(keys["keys"]) != 1 || keys["keys"][0] != "shared" {
+		t.Errorf("Expected namespaced keys, got %v", keys)
+	}
+
+	var list map[string][]types.NamespaceInfo
+	json.NewDecoder(serve(handler, "GET", "/ns", "", "").Body).Decode(&list)
+	if len(list["namespaces"]) != 2 {
+		t.Errorf("Expected 2 namespaces, got %v", list)
+	}
+
+	for path, want := range map[string]int{
+		"/ns/missing/kv/key": http.StatusNotFound,
+		"/ns/missing":        http.StatusNotFound,
+		"/ns/billing/bogus":  http.StatusNotFound,
+	} {
+		if w := serve(handler, "GET", path, "", ""); w.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, w.Code)
+		}
+	}
+	if w := serve(handler, "PUT", "/ns/Bad_Name", `{}`, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid name to return %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := serve(handler, "DELETE", "/ns/default", "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected deleting the default namespace to fail, got %d", w.Code)
+	}
+	if w := serve(handler, "DELETE", "/ns/billing", "", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected delete to succeed, got %d", w.Code)
+	}
+}
+
+func TestNamespaceAuth(t *testing.T) {
+	server := setupTestServer(true, "secret")
+	server.auth.AddNamespaceKey("billing", "billing-key")
+	handler := server.Handler()
+
+	if w := serve(handler, "PUT", "/ns/billing", `{}`, "billing-key"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected scoped key to be refused for admin routes, got %d", w.Code)
+	}
+	if w := serve(handler, "PUT", "/ns/billing", `{}`, "secret"); w.Code != http.StatusCreated {
+		t.Fatalf("Expected global key to create namespace, got %d", w.Code)
+	}
+	serve(handler, "PUT", "/ns/search", `{}`, "secret")
+
+	if w := serve(handler, "PUT", "/ns/billing/kv/key", `{"value":"v"}`, "billing-key"); w.Code != http.StatusCreated {
+		t.Errorf("Expected scoped key to write its namespace, got %d", w.Code)
+	}
+	if w := serve(handler, "GET", "/ns/billing", "", "billing-key"); w.Code != http.StatusOK {
+		t.Errorf("Expected scoped key to read its settings, got %d", w.Code)
+	}
+	if w := serve(handler, "GET", "/ns/search/kv/key", "", "billing-key"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected scoped key to be refused for another namespace, got %d", w.Code)
+	}
+	if w := serve(handler, "GET", "/kv/key", "", "billing-key"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected scoped key to be refused for /kv/, got %d", w.Code)
+	}
+	if w := serve(handler, "GET", "/ns", "", "billing-key"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected scoped key to be refused for namespace listing, got %d", w.Code)
+	}
+	if w := serve(handler, "GET", "/ns/billing/kv/key", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected missing token to be refused, got %d", w.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const storeRevisionHeader = "X-Qkrn-Store-Revision"
+
+type revisionWriter struct {
+	http.ResponseWriter
+	store   types.MVCCStore
+	stamped bool
+}
+
+func (w *revisionWriter) stamp() {
+	if w.stamped {
+		return
+	}
+	w.stamped = true
+	w.Header().Set(storeRevisionHeader, strconv.FormatUint(w.store.Revision(), 10))
+}
+
+func (w *revisionWriter) WriteHeader(statusCode int) {
+	w.stamp()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *revisionWriter) Write(b []byte) (int, error) {
+	w.stamp()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *revisionWriter) Flush() {
+	w.stamp()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *revisionWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+func (s *Server) withRevision(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mvcc, ok := s.storeFor(r).(types.MVCCStore)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&revisionWriter{ResponseWriter: w, store: mvcc}, r)
+	})
+}
+
+func (s *Server) storeFor(r *http.Request) types.Store {
+	if strings.HasPrefix(r.URL.Path, "/ns/") {
+		name, _ := splitNamespacePath(r.URL.Path)
+		if ns, err := s.namespaces.Get(name); err == nil {
+			return ns.Store
+		}
+	}
+	return s.store
+}
+
+func keysAt(store types.Store, revision uint64) ([]string, error) {
+	if revision == 0 {
+		return store.Keys(), nil
+	}
+
+	mvcc, ok := store.(types.MVCCStore)
+	if !ok {
+		return nil, errHistoryUnsupported
+	}
+	return mvcc.KeysAt(revision)
+}
+
+func (s *Server) handleCompact(w http.ResponseWriter, r *http.Request) {
+	s.compact(w, r, s.store)
+}
+
+func (s *Server) compact(w http.ResponseWriter, r *http.Request, store types.Store) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mvcc, ok := store.(types.MVCCStore)
+	if !ok {
+		s.sendStoreError(w, errHistoryUnsupported)
+		return
+	}
+
+	var req types.RevisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		s.sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Revision == 0 {
+		req.Revision = mvcc.Revision()
+	}
+
+	if err := mvcc.Compact(req.Revision); err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success":  true,
+		"revision": req.Revision,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
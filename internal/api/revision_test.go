@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func TestStoreRevisionHeader(t *testing.T) {
+	server := setupTestServer(false, "")
+	handler := server.Handler()
+
+	w := serve(handler, "PUT", "/kv/a", `{"value":"1"}`, "")
+	if got := w.Header().Get(storeRevisionHeader); got != "1" {
+		t.Errorf("Expected store revision 1 after the first write, got %q", got)
+	}
+	if got := serve(handler, "GET", "/keys", "", "").Header().Get(storeRevisionHeader); got != "1" {
+		t.Errorf("Expected store revision 1, got %q", got)
+	}
+	if got := serve(handler, "GET", "/kv/missing", "", "").Header().Get(storeRevisionHeader); got != "1" {
+		t.Errorf("Expected store revision on error responses, got %q", got)
+	}
+
+	serve(handler, "PUT", "/ns/team", `{}`, "")
+	if got := serve(handler, "GET", "/ns/team/keys", "", "").Header().Get(storeRevisionHeader); got != "0" {
+		t.Errorf("Expected the namespace's own revision, got %q", got)
+	}
+}
+
+func TestSnapshotKeys(t *testing.T) {
+	server := setupTestServer(false, "")
+	server.store.(*store.MemoryStore).SetHistoryLimits(types.HistoryLimits{MaxVersions: 10})
+	handler := server.Handler()
+
+	serve(handler, "PUT", "/kv/a", `{"value":"1"}`, "")
+	w := serve(handler, "PUT", "/kv/b", `{"value":"1"}`, "")
+	snapshot := w.Header().Get(storeRevisionHeader)
+	serve(handler, "PUT", "/kv/c", `{"value":"1"}`, "")
+	serve(handler, "DELETE", "/kv/a", "", "")
+
+	w = serve(handler, "GET", "/keys?revision="+snapshot, "", "")
+	var response struct {
+		Keys []string `json:"keys"`
+	}
+	json.NewDecoder(w.Body).Decode(&response)
+	sort.Strings(response.Keys)
+	if strings.Join(response.Keys, ",") != "a,b" {
+		t.Errorf("Expected a,b at revision %s, got %v", snapshot, response.Keys)
+	}
+
+	w = serve(handler, "POST", "/compact", `{"revision":4}`, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected compaction to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(handler, "GET", "/keys?revision="+snapshot, "", ""); w.Code != http.StatusGone {
+		t.Errorf("Expected status %d after compaction, got %d", http.StatusGone, w.Code)
+	}
+	if w := serve(handler, "GET", "/kv/a?revision="+snapshot, "", ""); w.Code != http.StatusGone {
+		t.Errorf("Expected status %d after compaction, got %d", http.StatusGone, w.Code)
+	}
+
+	future := strconv.Itoa(100)
+	if w := serve(handler, "GET", "/keys?revision="+future, "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a future revision, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := serve(handler, "POST", "/compact", `{"revision":2}`, ""); w.Code != http.StatusGone {
+		t.Errorf("Expected status %d compacting backwards, got %d", http.StatusGone, w.Code)
+	}
+	if w := serve(handler, "POST", "/compact", "", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected compaction to the current revision to succeed, got %d", w.Code)
+	}
+	if w := serve(handler, "GET", "/compact", "", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
@@ -2,30 +2,45 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/q4ow/qkrn/internal/auth"
+	"github.com/q4ow/qkrn/internal/keyring"
+	"github.com/q4ow/qkrn/internal/namespace"
 	"github.com/q4ow/qkrn/pkg/types"
 )
 
 type Server struct {
-	store  types.Store
-	port   int
-	server *http.ServeMux
-	auth   *auth.Authenticator
+	store      types.Store
+	namespaces *namespace.Registry
+	port       int
+	server     *http.ServeMux
+	auth       *auth.Authenticator
+	ready      readiness
+	node       types.Node
+	keys       *keyring.Keyring
 }
 
 func NewServer(store types.Store, port int, authenticator *auth.Authenticator) *Server {
 	s := &Server{
-		store:  store,
-		port:   port,
-		server: http.NewServeMux(),
-		auth:   authenticator,
+		store:      store,
+		namespaces: namespace.NewRegistry(store),
+		port:       port,
+		server:     http.NewServeMux(),
+		auth:       authenticator,
+		node: types.Node{
+			Address: "localhost",
+			Port:    port,
+		},
 	}
 
+	s.AddReadinessCheck("store", StoreCheck(store))
 	s.setupRoutes()
 	return s
 }
@@ -33,14 +48,48 @@ func NewServer(store types.Store, port int, authenticator *auth.Authenticator) *
 func (s *Server) setupRoutes() {
 	s.server.HandleFunc("/", s.handleRoot)
 	s.server.HandleFunc("/health", s.handleHealth)
+	s.server.HandleFunc("/health/live", s.handleHealth)
+	s.server.HandleFunc("/health/ready", s.handleReadiness)
+	s.server.HandleFunc("/metrics", s.auth.Middleware(s.handleMetrics))
+	s.server.HandleFunc("/cluster", s.auth.Middleware(s.handleCluster))
 	s.server.HandleFunc("/keys", s.auth.Middleware(s.handleKeys))
 	s.server.HandleFunc("/kv/", s.auth.Middleware(s.handleKeyValue))
+	s.server.HandleFunc("/incr/", s.auth.Middleware(s.handleIncr))
+	s.server.HandleFunc("/decr/", s.auth.Middleware(s.handleDecr))
+	s.server.HandleFunc("/list/", s.auth.Middleware(s.handleList))
+	s.server.HandleFunc("/set/", s.auth.Middleware(s.handleSetCollection))
+	s.server.HandleFunc("/hash/", s.auth.Middleware(s.handleHash))
+	s.server.HandleFunc("/history/", s.auth.Middleware(s.handleHistory))
+	s.server.HandleFunc("/compact", s.auth.Middleware(s.handleCompact))
+	s.server.HandleFunc("/backup", s.auth.Middleware(s.handleBackup))
+	s.server.HandleFunc("/restore", s.auth.Middleware(s.handleRestore))
+	s.server.HandleFunc("/export", s.auth.Middleware(s.handleExport))
+	s.server.HandleFunc("/import", s.auth.Middleware(s.handleImport))
+	s.server.HandleFunc("/ws", s.auth.Middleware(s.handleWebSocket))
+	s.server.HandleFunc("/ns", s.auth.Middleware(s.handleNamespaces))
+	s.server.HandleFunc("/ns/", s.auth.NamespaceMiddleware(namespaceScope, s.handleNamespace))
+}
+
+func (s *Server) SetNode(node types.Node) {
+	s.node = node
+}
+
+func (s *Server) SetNamespaces(namespaces *namespace.Registry) {
+	s.namespaces = namespaces
+}
+
+func (s *Server) SetKeyring(keys *keyring.Keyring) {
+	s.keys = keys
+}
+
+func (s *Server) Handler() http.Handler {
+	return s.withCompression(s.withRevision(s.server))
 }
 
 func (s *Server) Start() error {
 	addr := fmt.Sprintf(":%d", s.port)
 	log.Printf("Starting server on %s", addr)
-	return http.ListenAndServe(addr, s.server)
+	return http.ListenAndServe(addr, s.Handler())
 }
 
 func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
@@ -79,13 +128,64 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+func (s *Server) handleCluster(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := types.ClusterInfo{
+		Leader: s.node.ID,
+		Nodes:  []types.Node{s.node},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 func (s *Server) handleKeys(w http.ResponseWriter, r *http.Request) {
+	s.listKeys(w, r, s.store)
+}
+
+func (s *Server) listKeys(w http.ResponseWriter, r *http.Request, store types.Store) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	keys := s.store.Keys()
+	revision, err := revisionParam(r)
+	if err != nil {
+		s.sendErrorResponse(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	keys, err := keysAt(store, revision)
+	if err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+
+	if prefix := r.URL.Query().Get("prefix"); prefix != "" {
+		filtered := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				filtered = append(filtered, key)
+			}
+		}
+		keys = filtered
+	}
+
+	if selectors := parseLabelSelectors(r.URL.Query()["label"]); len(selectors) > 0 {
+		filtered := make([]string, 0, len(keys))
+		for _, key := range keys {
+			entry, err := getEntryAt(store, key, revision)
+			if err == nil && matchLabels(entry.Labels, selectors) {
+				filtered = append(filtered, key)
+			}
+		}
+		keys = filtered
+	}
+
 	response := map[string][]string{
 		"keys": keys,
 	}
@@ -101,49 +201,156 @@ func (s *Server) handleKeyValue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	key := path
+	s.serveKeyValue(w, r, s.store, path)
+}
 
+func (s *Server) serveKeyValue(w http.ResponseWriter, r *http.Request, store types.Store, key string) {
 	switch r.Method {
 	case http.MethodGet:
-		s.handleGet(w, r, key)
+		s.handleGet(w, r, store, key)
+	case http.MethodHead:
+		s.handleHead(w, r, store, key)
 	case http.MethodPut, http.MethodPost:
-		s.handleSet(w, r, key)
+		s.handleSet(w, r, store, key)
 	case http.MethodDelete:
-		s.handleDelete(w, r, key)
+		s.handleDelete(w, r, store, key)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func (s *Server) handleGet(w http.ResponseWriter, _ *http.Request, key string) {
-	value, err := s.store.Get(key)
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request, store types.Store, key string) {
+	revision, err := revisionParam(r)
 	if err != nil {
-		if err == types.ErrKeyNotFound {
-			s.sendErrorResponse(w, "Key not found", http.StatusNotFound)
-			return
-		}
-		s.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		s.sendErrorResponse(w, "Invalid revision", http.StatusBadRequest)
 		return
 	}
 
-	response := types.Response{
-		Success: true,
-		Value:   value,
+	entry, err := getEntryAt(store, key, revision)
+	if err != nil {
+		s.sendStoreError(w, err)
+		return
+	}
+	if entry.Type != "" && entry.Type != types.TypeString {
+		s.sendStoreError(w, types.ErrWrongType)
+		return
+	}
+
+	writeMetadataHeaders(w.Header(), entry)
+	if wantsRaw(r, entry.ContentType) {
+		writeRaw(w, entry)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(valueResponse(entry))
 }
 
-func (s *Server) handleSet(w http.ResponseWriter, r *http.Request, key string) {
-	var req types.Request
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+func (s *Server) handleHead(w http.ResponseWriter, r *http.Request, store types.Store, key string) {
+	revision, err := revisionParam(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	if err := s.store.Set(key, req.Value); err != nil {
-		s.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+	entry, err := getEntryAt(store, key, revision)
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrKeyNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, types.ErrCompacted):
+			w.WriteHeader(http.StatusGone)
+		case errors.Is(err, types.ErrFutureRevision):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, errHistoryUnsupported):
+			w.WriteHeader(http.StatusNotImplemented)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeMetadataHeaders(w.Header(), entry)
+	if wantsRaw(r, entry.ContentType) {
+		w.Header().Set("Content-Type", rawContentType(entry))
+		w.Header().Set("Content-Length", strconv.Itoa(len(entry.Value)))
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func getEntry(store types.Store, key string) (types.Entry, error) {
+	if atomic, ok := store.(types.AtomicStore); ok {
+		return atomic.GetEntry(key)
+	}
+
+	value, err := store.Get(key)
+	if err != nil {
+		return types.Entry{}, err
+	}
+	return types.Entry{Value: value}, nil
+}
+
+func (s *Server) handleSet(w http.ResponseWriter, r *http.Request, store types.Store, key string) {
+	var value []byte
+	var contentType string
+	var labels map[string]string
+
+	if isRawRequest(r) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRawValueSize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				s.sendErrorResponse(w, "Value too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			s.sendErrorResponse(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		labels, err = parseLabelsHeader(r.Header.Get(labelsHeader))
+		if err != nil {
+			s.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		value = body
+		contentType = r.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = defaultContentType
+		}
+	} else {
+		var req types.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				s.sendErrorResponse(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			s.sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		value = []byte(req.Value)
+		labels = req.Labels
+	}
+
+	keepTTL := queryBool(r, "keep_ttl")
+	var err error
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		revision, parseErr := strconv.ParseUint(strings.Trim(ifMatch, `"`), 10, 64)
+		if parseErr != nil {
+			s.sendErrorResponse(w, "Invalid If-Match header", http.StatusBadRequest)
+			return
+		}
+		err = setIfMatch(store, key, revision, types.Entry{Value: value, ContentType: contentType, Labels: labels}, keepTTL)
+	} else if expiring, ok := store.(types.ExpiringStore); ok {
+		err = expiring.SetWithOptions(key, value, types.SetOptions{ContentType: contentType, Labels: labels, KeepTTL: keepTTL})
+	} else {
+		err = store.Set(key, value)
+	}
+	if err != nil {
+		s.sendStoreError(w, err)
 		return
 	}
 
@@ -156,8 +363,28 @@ func (s *Server) handleSet(w http.ResponseWriter, r *http.Request, key string) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func (s *Server) handleDelete(w http.ResponseWriter, _ *http.Request, key string) {
-	if err := s.store.Delete(key); err != nil {
+var errConditionalUnsupported = errors.New("storage backend does not support conditional writes")
+
+func setIfMatch(store types.Store, key string, revision uint64, next types.Entry, keepTTL bool) error {
+	atomic, ok := store.(types.AtomicStore)
+	if !ok {
+		return errConditionalUnsupported
+	}
+
+	_, err := atomic.Update(key, func(current types.Entry, exists bool) (types.Entry, error) {
+		if !exists || current.Revision != revision {
+			return current, types.ErrConflict
+		}
+		if keepTTL {
+			next.ExpiresAt = current.ExpiresAt
+		}
+		return next, nil
+	})
+	return err
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, _ *http.Request, store types.Store, key string) {
+	if err := store.Delete(key); err != nil {
 		if err == types.ErrKeyNotFound {
 			s.sendErrorResponse(w, "Key not found", http.StatusNotFound)
 			return
@@ -174,6 +401,39 @@ func (s *Server) handleDelete(w http.ResponseWriter, _ *http.Request, key string
 	json.NewEncoder(w).Encode(response)
 }
 
+func (s *Server) sendStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrKeyNotFound):
+		s.sendErrorResponse(w, "Key not found", http.StatusNotFound)
+	case errors.Is(err, types.ErrEmptyKey):
+		s.sendErrorResponse(w, "Key is required", http.StatusBadRequest)
+	case errors.Is(err, types.ErrValueTooLarge):
+		s.sendErrorResponse(w, "Value too large", http.StatusRequestEntityTooLarge)
+	case errors.Is(err, types.ErrKeyLimit):
+		s.sendErrorResponse(w, "Key limit reached", http.StatusInsufficientStorage)
+	case errors.Is(err, types.ErrOutOfMemory):
+		s.sendErrorResponse(w, "Memory limit reached", http.StatusInsufficientStorage)
+	case errors.Is(err, types.ErrNotNumber):
+		s.sendErrorResponse(w, "Value is not a number", http.StatusConflict)
+	case errors.Is(err, types.ErrOutOfRange):
+		s.sendErrorResponse(w, "Value out of range", http.StatusConflict)
+	case errors.Is(err, types.ErrWrongType):
+		s.sendErrorResponse(w, "Key holds a different type of value", http.StatusConflict)
+	case errors.Is(err, types.ErrCompacted):
+		s.sendErrorResponse(w, "Revision has been compacted", http.StatusGone)
+	case errors.Is(err, types.ErrFutureRevision):
+		s.sendErrorResponse(w, "Revision is in the future", http.StatusBadRequest)
+	case errors.Is(err, types.ErrConflict):
+		s.sendErrorResponse(w, "Key has changed since the given revision", http.StatusPreconditionFailed)
+	case errors.Is(err, errHistoryUnsupported):
+		s.sendErrorResponse(w, "Storage backend does not keep history", http.StatusNotImplemented)
+	case errors.Is(err, errConditionalUnsupported):
+		s.sendErrorResponse(w, "Storage backend does not support conditional writes", http.StatusNotImplemented)
+	default:
+		s.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func (s *Server) sendErrorResponse(w http.ResponseWriter, message string, statusCode int) {
 	response := types.Response{
 		Success: false,
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/q4ow/qkrn/internal/auth"
 	"github.com/q4ow/qkrn/internal/store"
@@ -241,7 +244,7 @@ func TestHandleKeys(t *testing.T) {
 			server := setupTestServer(false, "")
 
 			for key, value := range tt.setupKeys {
-				server.store.Set(key, value)
+				server.store.Set(key, []byte(value))
 			}
 
 			req := httptest.NewRequest(tt.method, "/keys", nil)
@@ -366,7 +369,7 @@ func TestHandleKeyValue(t *testing.T) {
 			server := setupTestServer(false, "")
 
 			for key, value := range tt.setupData {
-				server.store.Set(key, value)
+				server.store.Set(key, []byte(value))
 			}
 
 			var reqBody *bytes.Buffer
@@ -566,3 +569,248 @@ func TestContentTypeHeaders(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleCluster(t *testing.T) {
+	server := setupTestServer(false, "")
+	server.SetNode(types.Node{ID: "node-1", Address: "10.0.0.1", Port: 8080})
+
+	req := httptest.NewRequest("GET", "/cluster", nil)
+	w := httptest.NewRecorder()
+
+	server.handleCluster(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var info types.ClusterInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if info.Leader != "node-1" {
+		t.Errorf("Expected leader 'node-1', got '%s'", info.Leader)
+	}
+
+	if len(info.Nodes) != 1 || info.Nodes[0].Address != "10.0.0.1" || info.Nodes[0].Port != 8080 {
+		t.Errorf("Unexpected nodes: %+v", info.Nodes)
+	}
+
+	req = httptest.NewRequest("POST", "/cluster", nil)
+	w = httptest.NewRecorder()
+	server.handleCluster(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestHandleKeysPrefix(t *testing.T) {
+	server := setupTestServer(false, "")
+	server.store.Set("app/one", []byte("1"))
+	server.store.Set("app/two", []byte("2"))
+	server.store.Set("other", []byte("3"))
+
+	req := httptest.NewRequest("GET", "/keys?prefix=app/", nil)
+	w := httptest.NewRecorder()
+
+	server.handleKeys(w, req)
+
+	var response map[string][]string
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(response["keys"]) != 2 {
+		t.Errorf("Expected 2 keys with prefix, got %v", response["keys"])
+	}
+}
+
+func TestHandleRawValues(t *testing.T) {
+	server := setupTestServer(false, "")
+	handler := server.Handler()
+	png := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff}
+
+	req := httptest.NewRequest("PUT", "/kv/logo", bytes.NewReader(png))
+	req.Header.Set("Content-Type", "image/png")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/kv/logo", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var response types.Response
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.ContentType != "image/png" || response.Encoding != "base64" || response.Value != "iVBORwD/" {
+		t.Errorf("Unexpected JSON envelope for binary value: %+v", response)
+	}
+
+	for _, accept := range []string{"image/png", "image/*", "application/octet-stream"} {
+		req = httptest.NewRequest("GET", "/kv/logo", nil)
+		req.Header.Set("Accept", accept)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Type") != "image/png" || !bytes.Equal(w.Body.Bytes(), png) {
+			t.Errorf("Accept %q: expected raw image/png body, got %q %v", accept, w.Header().Get("Content-Type"), w.Body.Bytes())
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/kv/logo", nil)
+	req.Header.Set("Accept", "text/html, */*;q=0.8")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON envelope for wildcard Accept, got %q", w.Header().Get("Content-Type"))
+	}
+
+	doc := `{"value":"not an envelope"}`
+	req = httptest.NewRequest("PUT", "/kv/doc?raw=true", strings.NewReader(doc))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	req = httptest.NewRequest("GET", "/kv/doc?raw=true", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Header().Get("Content-Type") != "application/json" || w.Body.String() != doc {
+		t.Errorf("Expected raw JSON document, got %q", w.Body.String())
+	}
+
+	req = httptest.NewRequest("PUT", "/kv/text", strings.NewReader(`{"value":"plain"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	req = httptest.NewRequest("GET", "/kv/text", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	response = types.Response{}
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.Value != "plain" || response.Encoding != "" || response.ContentType != "" {
+		t.Errorf("Expected JSON envelope value to round-trip, got %+v", response)
+	}
+}
+
+func TestHandleMetadata(t *testing.T) {
+	server := setupTestServer(false, "")
+	handler := server.Handler()
+
+	req := httptest.NewRequest("PUT", "/kv/config", strings.NewReader(`{"value":"hello","labels":{"team":"core","env":"prod"}}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/kv/config", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var response types.Response
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	meta := response.Metadata
+	if meta == nil || meta.Version != 1 || meta.Size != 5 || meta.Labels["team"] != "core" || meta.CreatedAt.IsZero() {
+		t.Errorf("Unexpected metadata: %+v", meta)
+	}
+	if w.Header().Get("X-Qkrn-Version") != "1" || w.Header().Get("Last-Modified") == "" {
+		t.Errorf("Expected metadata headers on GET, got %v", w.Header())
+	}
+
+	req = httptest.NewRequest("HEAD", "/kv/config", nil)
+	req.Header.Set("Accept", "application/octet-stream")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("Expected empty 200 for HEAD, got %d with %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Length") != "5" || w.Header().Get("X-Qkrn-Size") != "5" {
+		t.Errorf("Expected size headers on HEAD, got %v", w.Header())
+	}
+	if labels := w.Header().Get(labelsHeader); labels != "env=prod&team=core" {
+		t.Errorf("Expected labels header, got %q", labels)
+	}
+
+	req = httptest.NewRequest("HEAD", "/kv/missing", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/kv/blob", strings.NewReader("raw"))
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set(labelsHeader, "team=edge")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	server.store.Set("plain", []byte("v"))
+
+	for query, want := range map[string]int{
+		"/keys?label=team":                2,
+		"/keys?label=team=core":           1,
+		"/keys?label=team&label=env=prod": 1,
+		"/keys?label=missing":             0,
+	} {
+		req = httptest.NewRequest("GET", query, nil)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		var keys map[string][]string
+		if err := json.NewDecoder(w.Body).Decode(&keys); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(keys["keys"]) != want {
+			t.Errorf("%s: expected %d keys, got %v", query, want, keys["keys"])
+		}
+	}
+}
+
+func TestConditionalPut(t *testing.T) {
+	server := setupTestServer(false, "")
+	handler := server.Handler()
+	expiring := server.store.(types.ExpiringStore)
+	expiring.SetWithOptions("config", []byte("v1"), types.SetOptions{TTL: time.Hour})
+	entry, _ := server.store.(types.AtomicStore).GetEntry("config")
+
+	put := func(ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/kv/config?keep_ttl=true", strings.NewReader(body))
+		req.Header.Set("If-Match", ifMatch)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := put(strconv.FormatUint(entry.Revision+1, 10), `{"value":"stale"}`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected a stale revision to be refused, got %d", w.Code)
+	}
+	if w := put("abc", `{"value":"bad"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid If-Match to be refused, got %d", w.Code)
+	}
+	if w := put(`"`+strconv.FormatUint(entry.Revision, 10)+`"`, `{"value":"v2"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected a matching revision to be accepted, got %d: %s", w.Code, w.Body.String())
+	}
+
+	updated, _ := server.store.(types.AtomicStore).GetEntry("config")
+	if string(updated.Value) != "v2" || !updated.ExpiresAt.Equal(entry.ExpiresAt) {
+		t.Errorf("Expected the write to keep the TTL, got %+v", updated)
+	}
+	if w := put(strconv.FormatUint(entry.Revision, 10), `{"value":"v3"}`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected the old revision to be refused after a write, got %d", w.Code)
+	}
+
+	w := serve(handler, "PUT", "/kv/config", `{"value":"v4"}`, "")
+	if updated, _ := server.store.(types.AtomicStore).GetEntry("config"); w.Code != http.StatusCreated || !updated.ExpiresAt.IsZero() {
+		t.Errorf("Expected a plain write to clear the TTL, got %+v", updated)
+	}
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = wsPongWait * 9 / 10
+	wsMaxMessageSize = 1024 * 1024
+)
+
+const (
+	wsTypeResponse = "response"
+	wsTypeEvent    = "event"
+	wsTypeError    = "error"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+type wsConn struct {
+	conn *websocket.Conn
+
+	writeMu sync.Mutex
+
+	mu   sync.Mutex
+	subs map[string]func()
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	c := &wsConn{conn: conn, subs: make(map[string]func())}
+	defer c.close()
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go c.keepAlive(done)
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("websocket connection: %v", err)
+			}
+			return
+		}
+
+		var req types.WebSocketRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.send(types.WebSocketMessage{Type: wsTypeError, Error: "Invalid JSON"})
+			continue
+		}
+
+		s.handleWebSocketRequest(c, req)
+	}
+}
+
+func (s *Server) handleWebSocketRequest(c *wsConn, req types.WebSocketRequest) {
+	resp := types.WebSocketMessage{Type: wsTypeResponse, ID: req.ID}
+
+	switch req.Op {
+	case "get":
+		value, err := s.store.Get(req.Key)
+		if err != nil {
+			resp.Error = wsErrorMessage(err)
+			break
+		}
+		resp.Success = true
+		resp.Key = req.Key
+		resp.Value, resp.Encoding = encodeValue(value)
+	case "put":
+		if err := s.store.Set(req.Key, []byte(req.Value)); err != nil {
+			resp.Error = wsErrorMessage(err)
+			break
+		}
+		resp.Success = true
+	case "delete":
+		if err := s.store.Delete(req.Key); err != nil {
+			resp.Error = wsErrorMessage(err)
+			break
+		}
+		resp.Success = true
+	case "incr", "decr":
+		counters, ok := s.store.(types.CounterStore)
+		if !ok {
+			resp.Error = "Storage backend does not support counters"
+			break
+		}
+
+		counter := types.CounterRequest{By: req.By, Float: req.Float}
+		var value string
+		var err error
+		if req.Float {
+			value, err = incrFloat(counters, req.Key, counter, req.Op == "decr")
+		} else {
+			value, err = incrInt(counters, req.Key, counter, req.Op == "decr")
+		}
+		if err != nil {
+			resp.Error = wsErrorMessage(err)
+			break
+		}
+		resp.Success = true
+		resp.Key = req.Key
+		resp.Value = value
+	case "subscribe":
+		s.subscribe(c, req)
+		return
+	case "unsubscribe":
+		if !c.unsubscribe(req.Subscription) {
+			resp.Error = "Subscription not found"
+			break
+		}
+		resp.Success = true
+	default:
+		resp.Error = "Unknown operation"
+	}
+
+	c.send(resp)
+}
+
+func (s *Server) subscribe(c *wsConn, req types.WebSocketRequest) {
+	resp := types.WebSocketMessage{Type: wsTypeResponse, ID: req.ID}
+
+	watchable, ok := s.store.(types.WatchableStore)
+	switch {
+	case !ok:
+		resp.Error = "Storage backend does not support subscriptions"
+	case req.ID == "":
+		resp.Error = "Subscribe requires an id"
+	case req.Key == "" && !req.Prefix:
+		resp.Error = types.ErrEmptyKey.Error()
+	}
+	if resp.Error != "" {
+		c.send(resp)
+		return
+	}
+
+	c.mu.Lock()
+	if _, exists := c.subs[req.ID]; exists {
+		c.mu.Unlock()
+		resp.Error = "Subscription already exists"
+		c.send(resp)
+		return
+	}
+	events, cancel := watchable.Subscribe(req.Key)
+	c.subs[req.ID] = cancel
+	c.mu.Unlock()
+
+	resp.Success = true
+	c.send(resp)
+
+	go func() {
+		for event := range events {
+			if !req.Prefix && event.Key != req.Key {
+				continue
+			}
+			msg := types.WebSocketMessage{
+				Type:     wsTypeEvent,
+				ID:       req.ID,
+				Event:    event.Type,
+				Key:      event.Key,
+				Version:  event.Entry.Version,
+				Revision: event.Entry.Revision,
+			}
+			msg.Value, msg.Encoding = encodeValue(event.Entry.Value)
+			c.send(msg)
+		}
+
+		if c.unsubscribe(req.ID) {
+			c.send(types.WebSocketMessage{Type: wsTypeError, ID: req.ID, Error: "Subscriber fell behind, subscribe again"})
+		}
+	}()
+}
+
+func wsErrorMessage(err error) string {
+	if errors.Is(err, types.ErrKeyNotFound) {
+		return "Key not found"
+	}
+	return err.Error()
+}
+
+func (c *wsConn) send(msg types.WebSocketMessage) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		c.conn.Close()
+	}
+}
+
+func (c *wsConn) keepAlive(done <-chan struct{}) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			c.writeMu.Unlock()
+			if err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+func (c *wsConn) unsubscribe(id string) bool {
+	c.mu.Lock()
+	cancel, ok := c.subs[id]
+	delete(c.subs, id)
+	c.mu.Unlock()
+
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+func (c *wsConn) close() {
+	c.mu.Lock()
+	subs := c.subs
+	c.subs = make(map[string]func())
+	c.mu.Unlock()
+
+	for _, cancel := range subs {
+		cancel()
+	}
+	c.conn.Close()
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func dialWebSocket(t *testing.T, server *Server, query string, header http.Header) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws" + query
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	}
+	return conn, resp, err
+}
+
+func wsRoundTrip(t *testing.T, conn *websocket.Conn, req types.WebSocketRequest) types.WebSocketMessage {
+	t.Helper()
+
+	if err := conn.WriteJSON(req); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+	return wsRead(t, conn)
+}
+
+func wsRead(t *testing.T, conn *websocket.Conn) types.WebSocketMessage {
+	t.Helper()
+
+	var msg types.WebSocketMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	return msg
+}
+
+func TestWebSocketOperations(t *testing.T) {
+	server := setupTestServer(false, "")
+	conn, _, err := dialWebSocket(t, server, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+
+	resp := wsRoundTrip(t, conn, types.WebSocketRequest{ID: "1", Op: "put", Key: "greeting", Value: "hello"})
+	if resp.Type != "response" || resp.ID != "1" || !resp.Success {
+		t.Errorf("Unexpected put response: %+v", resp)
+	}
+
+	resp = wsRoundTrip(t, conn, types.WebSocketRequest{ID: "2", Op: "get", Key: "greeting"})
+	if resp.ID != "2" || !resp.Success || resp.Value != "hello" {
+		t.Errorf("Unexpected get response: %+v", resp)
+	}
+
+	resp = wsRoundTrip(t, conn, types.WebSocketRequest{ID: "3", Op: "delete", Key: "greeting"})
+	if resp.ID != "3" || !resp.Success {
+		t.Errorf("Unexpected delete response: %+v", resp)
+	}
+
+	resp = wsRoundTrip(t, conn, types.WebSocketRequest{ID: "4", Op: "get", Key: "greeting"})
+	if resp.ID != "4" || resp.Success || resp.Error != "Key not found" {
+		t.Errorf("Expected key not found, got %+v", resp)
+	}
+
+	resp = wsRoundTrip(t, conn, types.WebSocketRequest{ID: "c1", Op: "incr", Key: "hits", By: "5"})
+	if !resp.Success || resp.Value != "5" {
+		t.Errorf("Unexpected incr response: %+v", resp)
+	}
+	resp = wsRoundTrip(t, conn, types.WebSocketRequest{ID: "c2", Op: "decr", Key: "hits", By: "0.5", Float: true})
+	if !resp.Success || resp.Value != "4.5" {
+		t.Errorf("Unexpected decr response: %+v", resp)
+	}
+
+	server.store.Set("blob", []byte{0x00, 0xff})
+	resp = wsRoundTrip(t, conn, types.WebSocketRequest{ID: "b1", Op: "get", Key: "blob"})
+	if !resp.Success || resp.Value != "AP8=" || resp.Encoding != "base64" {
+		t.Errorf("Expected a base64 value for binary data, got %+v", resp)
+	}
+
+	resp = wsRoundTrip(t, conn, types.WebSocketRequest{ID: "5", Op: "bogus"})
+	if resp.ID != "5" || resp.Error != "Unknown operation" {
+		t.Errorf("Expected unknown operation error, got %+v", resp)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte("{not json"))
+	if msg := wsRead(t, conn); msg.Type != "error" || msg.Error != "Invalid JSON" {
+		t.Errorf("Expected invalid JSON error, got %+v", msg)
+	}
+}
+
+func TestWebSocketSubscribe(t *testing.T) {
+	server := setupTestServer(false, "")
+	conn, _, err := dialWebSocket(t, server, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+
+	resp := wsRoundTrip(t, conn, types.WebSocketRequest{ID: "sub", Op: "subscribe", Key: "app/", Prefix: true})
+	if !resp.Success {
+		t.Fatalf("Subscribe failed: %+v", resp)
+	}
+
+	server.store.Set("other", []byte("ignored"))
+	server.store.Set("app/a", []byte("1"))
+	server.store.Delete("app/a")
+
+	put := wsRead(t, conn)
+	if put.Type != "event" || put.ID != "sub" || put.Event != types.EventPut || put.Key != "app/a" || put.Value != "1" {
+		t.Errorf("Unexpected put event: %+v", put)
+	}
+	del := wsRead(t, conn)
+	if del.Event != types.EventDelete || del.Key != "app/a" {
+		t.Errorf("Unexpected delete event: %+v", del)
+	}
+
+	resp = wsRoundTrip(t, conn, types.WebSocketRequest{ID: "again", Op: "subscribe", Key: "app/", Prefix: true})
+	if !resp.Success {
+		t.Fatalf("Second subscribe failed: %+v", resp)
+	}
+	if resp := wsRoundTrip(t, conn, types.WebSocketRequest{ID: "exact", Op: "subscribe", Key: "x"}); resp.ID != "exact" || !resp.Success {
+		t.Fatalf("Exact-key subscribe failed: %+v", resp)
+	}
+
+	resp = wsRoundTrip(t, conn, types.WebSocketRequest{ID: "6", Op: "unsubscribe", Subscription: "sub"})
+	if !resp.Success {
+		t.Errorf("Unsubscribe failed: %+v", resp)
+	}
+	resp = wsRoundTrip(t, conn, types.WebSocketRequest{ID: "7", Op: "unsubscribe", Subscription: "sub"})
+	if resp.Success {
+		t.Error("Expected second unsubscribe to fail")
+	}
+
+	first := wsRoundTrip(t, conn, types.WebSocketRequest{ID: "8", Op: "put", Key: "app/b", Value: "2"})
+	second := wsRead(t, conn)
+	if first.Type == "event" {
+		first, second = second, first
+	}
+	if first.ID != "8" || second.ID != "again" || second.Key != "app/b" {
+		t.Errorf("Expected only the remaining subscription to fire, got %+v and %+v", first, second)
+	}
+}
+
+func TestWebSocketAuth(t *testing.T) {
+	server := setupTestServer(true, "secret")
+
+	_, resp, err := dialWebSocket(t, server, "", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 at handshake, got %v", err)
+	}
+
+	_, resp, err = dialWebSocket(t, server, "?api_key=wrong", nil)
+	if err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 for invalid key, got %v", err)
+	}
+
+	if _, _, err := dialWebSocket(t, server, "?api_key=secret", nil); err != nil {
+		t.Errorf("Expected api_key query parameter to be accepted, got %v", err)
+	}
+
+	header := http.Header{"Authorization": {"Bearer secret"}}
+	if _, _, err := dialWebSocket(t, server, "", header); err != nil {
+		t.Errorf("Expected bearer token to be accepted, got %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/q4ow/qkrn/pkg/types"
 )
@@ -13,6 +14,14 @@ import (
 type Authenticator struct {
 	enabled bool
 	apiKey  string
+
+	mu            sync.RWMutex
+	namespaceKeys []namespaceKey
+}
+
+type namespaceKey struct {
+	key       string
+	namespace string
 }
 
 func NewAuthenticator(enabled bool, apiKey string) *Authenticator {
@@ -31,34 +40,113 @@ func GenerateAPIKey() (string, error) {
 }
 
 func (a *Authenticator) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return a.guard(func(_ *http.Request, token string) error {
+		return a.Authenticate(token)
+	}, next)
+}
+
+func (a *Authenticator) NamespaceMiddleware(namespace func(*http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	return a.guard(func(r *http.Request, token string) error {
+		return a.AuthenticateNamespace(token, namespace(r))
+	}, next)
+}
+
+func (a *Authenticator) guard(check func(r *http.Request, token string) error, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if !a.enabled {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		if r.URL.Path == "/health" || r.URL.Path == "/" {
+		if r.URL.Path == "/health" || strings.HasPrefix(r.URL.Path, "/health/") || r.URL.Path == "/" {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		token := a.extractToken(r)
-		if token == "" {
+		switch check(r, a.extractToken(r)) {
+		case types.ErrMissingToken:
 			a.sendAuthError(w, "Missing authentication token", http.StatusUnauthorized)
 			return
-		}
-
-		if !a.validateToken(token) {
+		case types.ErrInvalidToken:
 			a.sendAuthError(w, "Invalid authentication token", http.StatusUnauthorized)
 			return
+		case types.ErrUnauthorized:
+			a.sendAuthError(w, "Token is not valid for this namespace", http.StatusForbidden)
+			return
 		}
 
 		next.ServeHTTP(w, r)
 	}
 }
 
+func (a *Authenticator) Authenticate(token string) error {
+	if !a.enabled {
+		return nil
+	}
+
+	if token == "" {
+		return types.ErrMissingToken
+	}
+
+	if !a.validateToken(token) {
+		return types.ErrInvalidToken
+	}
+
+	return nil
+}
+
+func (a *Authenticator) AddNamespaceKey(namespace, key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.namespaceKeys = append(a.namespaceKeys, namespaceKey{key: key, namespace: namespace})
+}
+
+func (a *Authenticator) AuthenticateNamespace(token, namespace string) error {
+	if !a.enabled {
+		return nil
+	}
+
+	if token == "" {
+		return types.ErrMissingToken
+	}
+
+	if a.validateToken(token) {
+		return nil
+	}
+
+	scope, ok := a.namespaceFor(token)
+	switch {
+	case !ok:
+		return types.ErrInvalidToken
+	case namespace == "" || scope != namespace:
+		return types.ErrUnauthorized
+	}
+	return nil
+}
+
+func (a *Authenticator) namespaceFor(token string) (string, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	scope, found := "", false
+	for _, nk := range a.namespaceKeys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(nk.key)) == 1 && !found {
+			scope, found = nk.namespace, true
+		}
+	}
+	return scope, found
+}
+
 func (a *Authenticator) extractToken(r *http.Request) string {
-	authHeader := r.Header.Get("Authorization")
+	if token := TokenFromHeader(r.Header); token != "" {
+		return token
+	}
+
+	return r.URL.Query().Get("api_key")
+}
+
+func TokenFromHeader(header http.Header) string {
+	authHeader := header.Get("Authorization")
 	if authHeader != "" {
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
@@ -66,11 +154,7 @@ func (a *Authenticator) extractToken(r *http.Request) string {
 		}
 	}
 
-	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
-		return apiKey
-	}
-
-	return r.URL.Query().Get("api_key")
+	return header.Get("X-API-Key")
 }
 
 func (a *Authenticator) validateToken(token string) bool {
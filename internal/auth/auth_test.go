@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/q4ow/qkrn/pkg/types"
 )
 
 func TestGenerateAPIKey(t *testing.T) {
@@ -236,3 +238,78 @@ func TestAuthenticator_NoAPIKey(t *testing.T) {
 		t.Error("Should report no valid key when API key is empty")
 	}
 }
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		token    string
+		expected error
+	}{
+		{
+			name:     "disabled accepts anything",
+			enabled:  false,
+			token:    "",
+			expected: nil,
+		},
+		{
+			name:     "missing token",
+			enabled:  true,
+			token:    "",
+			expected: types.ErrMissingToken,
+		},
+		{
+			name:     "invalid token",
+			enabled:  true,
+			token:    "wrong",
+			expected: types.ErrInvalidToken,
+		},
+		{
+			name:     "valid token",
+			enabled:  true,
+			token:    "secret",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := NewAuthenticator(tt.enabled, "secret")
+			if err := auth.Authenticate(tt.token); err != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_AuthenticateNamespace(t *testing.T) {
+	auth := NewAuthenticator(true, "secret")
+	auth.AddNamespaceKey("billing", "billing-key")
+
+	tests := []struct {
+		name      string
+		token     string
+		namespace string
+		expected  error
+	}{
+		{"global key", "secret", "billing", nil},
+		{"global key for admin routes", "secret", "", nil},
+		{"scoped key", "billing-key", "billing", nil},
+		{"scoped key for another namespace", "billing-key", "search", types.ErrUnauthorized},
+		{"scoped key for admin routes", "billing-key", "", types.ErrUnauthorized},
+		{"invalid token", "wrong", "billing", types.ErrInvalidToken},
+		{"missing token", "", "billing", types.ErrMissingToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := auth.AuthenticateNamespace(tt.token, tt.namespace); err != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	if err := auth.Authenticate("billing-key"); err != types.ErrInvalidToken {
+		t.Errorf("Expected scoped key to be rejected outside namespaces, got %v", err)
+	}
+}
@@ -0,0 +1,357 @@
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/q4ow/qkrn/internal/keyring"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const (
+	Format      = "qkrn-backup"
+	Version     = 1
+	ContentType = "application/gzip"
+
+	EncryptedContentType = "application/vnd.qkrn.backup+encrypted"
+)
+
+var maxLineSize = 128 * 1024 * 1024
+
+type Policy string
+
+const (
+	PolicyMerge     Policy = "merge"
+	PolicyOverwrite Policy = "overwrite"
+	PolicyReplace   Policy = "replace"
+)
+
+var (
+	ErrInvalidFormat  = errors.New("not a qkrn backup")
+	ErrUnsupported    = errors.New("unsupported backup version")
+	ErrChecksum       = errors.New("backup checksum mismatch")
+	ErrTruncated      = errors.New("backup is truncated")
+	ErrInvalidPolicy  = errors.New("invalid restore policy")
+	ErrInvalidRecord  = errors.New("invalid backup record")
+	ErrRecordMismatch = errors.New("backup record count mismatch")
+	ErrInvalidBackup  = errors.New("invalid backup")
+)
+
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	Revision  uint64    `json:"revision"`
+	Prefix    string    `json:"prefix,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Trailer struct {
+	Records int    `json:"records"`
+	SHA256  string `json:"sha256"`
+}
+
+type line struct {
+	types.Record
+	Trailer *Trailer `json:"trailer,omitempty"`
+}
+
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(s) {
+	case "":
+		return PolicyMerge, nil
+	case PolicyMerge, PolicyOverwrite, PolicyReplace:
+		return Policy(s), nil
+	}
+	return "", ErrInvalidPolicy
+}
+
+type hashWriter struct {
+	w    io.Writer
+	hash hash.Hash
+}
+
+func (h *hashWriter) writeLine(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	h.hash.Write(data)
+	_, err = h.w.Write(data)
+	return err
+}
+
+func Write(w io.Writer, snap types.Snapshot, prefix string) (Trailer, error) {
+	zw := gzip.NewWriter(w)
+	hw := &hashWriter{w: zw, hash: sha256.New()}
+
+	header := Header{
+		Format:    Format,
+		Version:   Version,
+		Revision:  snap.Revision(),
+		Prefix:    prefix,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := hw.writeLine(header); err != nil {
+		return Trailer{}, err
+	}
+
+	var trailer Trailer
+	err := snap.Each(func(rec types.Record) error {
+		trailer.Records++
+		return hw.writeLine(rec)
+	})
+	if err != nil {
+		return Trailer{}, err
+	}
+
+	trailer.SHA256 = hex.EncodeToString(hw.hash.Sum(nil))
+	data, err := json.Marshal(line{Trailer: &trailer})
+	if err != nil {
+		return Trailer{}, err
+	}
+	if _, err := zw.Write(append(data, '\n')); err != nil {
+		return Trailer{}, err
+	}
+	return trailer, zw.Close()
+}
+
+func WriteEncrypted(w io.Writer, snap types.Snapshot, prefix string, keys *keyring.Keyring) (Trailer, error) {
+	ew, err := keys.NewWriter(w)
+	if err != nil {
+		return Trailer{}, err
+	}
+	trailer, err := Write(ew, snap, prefix)
+	if err != nil {
+		return Trailer{}, err
+	}
+	return trailer, ew.Close()
+}
+
+func Decrypt(r io.Reader, keys *keyring.Keyring) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	prefix, _ := br.Peek(len(keyring.Magic))
+	if !keyring.IsEncrypted(prefix) {
+		return br, nil
+	}
+	return keys.NewReader(br)
+}
+
+type Reader struct {
+	zr      *gzip.Reader
+	br      *bufio.Reader
+	sum     hash.Hash
+	header  Header
+	records int
+	done    bool
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	zr, err := gzip.NewReader(r)
+	if errors.Is(err, keyring.ErrDecrypt) || errors.Is(err, keyring.ErrTruncated) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, ErrInvalidFormat
+	}
+
+	rd := &Reader{zr: zr, br: bufio.NewReader(zr), sum: sha256.New()}
+	data, err := rd.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &rd.header); err != nil || rd.header.Format != Format {
+		return nil, ErrInvalidFormat
+	}
+	if rd.header.Version != Version {
+		return nil, ErrUnsupported
+	}
+	rd.sum.Write(data)
+	return rd, nil
+}
+
+func (rd *Reader) Header() Header {
+	return rd.header
+}
+
+func (rd *Reader) Next() (types.Record, error) {
+	if rd.done {
+		return types.Record{}, io.EOF
+	}
+
+	data, err := rd.readLine()
+	if err != nil {
+		return types.Record{}, err
+	}
+
+	var l line
+	if err := json.Unmarshal(data, &l); err != nil {
+		return types.Record{}, fmt.Errorf("%w: line %d", ErrInvalidRecord, rd.records+2)
+	}
+	if l.Trailer != nil {
+		if err := rd.finish(l.Trailer); err != nil {
+			return types.Record{}, err
+		}
+		return types.Record{}, io.EOF
+	}
+	if err := validRecord(l.Record, rd.header.Prefix); err != nil {
+		return types.Record{}, fmt.Errorf("%w: line %d", err, rd.records+2)
+	}
+	rd.sum.Write(data)
+	rd.records++
+	return l.Record, nil
+}
+
+func (rd *Reader) finish(trailer *Trailer) error {
+	if hex.EncodeToString(rd.sum.Sum(nil)) != trailer.SHA256 {
+		return ErrChecksum
+	}
+	if trailer.Records != rd.records {
+		return ErrRecordMismatch
+	}
+
+	switch _, err := rd.br.ReadByte(); {
+	case err == nil:
+		return ErrInvalidFormat
+	case errors.Is(err, gzip.ErrChecksum):
+		return ErrChecksum
+	case err != io.EOF:
+		return err
+	}
+	rd.done = true
+	return rd.zr.Close()
+}
+
+func (rd *Reader) readLine() ([]byte, error) {
+	var data []byte
+	for {
+		chunk, err := rd.br.ReadSlice('\n')
+		if len(data)+len(chunk) > maxLineSize {
+			return nil, fmt.Errorf("%w: line %d is too long", ErrInvalidRecord, rd.records+2)
+		}
+		data = append(data, chunk...)
+		switch {
+		case err == nil:
+			return data, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF):
+			return nil, ErrTruncated
+		case errors.Is(err, gzip.ErrChecksum):
+			return nil, ErrChecksum
+		default:
+			return nil, err
+		}
+	}
+}
+
+func validRecord(rec types.Record, prefix string) error {
+	if rec.Key == "" || !strings.HasPrefix(rec.Key, prefix) {
+		return ErrInvalidRecord
+	}
+	switch rec.Type {
+	case "", types.TypeString, types.TypeList, types.TypeSet, types.TypeHash:
+		return nil
+	}
+	return ErrInvalidRecord
+}
+
+func Restore(store types.BackupStore, rd *Reader, policy Policy) (types.RestoreResult, error) {
+	header := rd.Header()
+	result := types.RestoreResult{Revision: header.Revision}
+
+	var restored map[string]struct{}
+	if policy == PolicyReplace {
+		restored = make(map[string]struct{})
+	}
+
+	for {
+		rec, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+		}
+
+		loaded, err := store.Load(rec, policy != PolicyMerge)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", rec.Key, err)
+		}
+		if loaded {
+			result.Loaded++
+		} else {
+			result.Skipped++
+		}
+		if restored != nil {
+			restored[rec.Key] = struct{}{}
+		}
+	}
+
+	if policy == PolicyReplace {
+		for _, key := range store.Keys() {
+			if _, ok := restored[key]; ok || !strings.HasPrefix(key, header.Prefix) {
+				continue
+			}
+			if err := store.Delete(key); err != nil && !errors.Is(err, types.ErrKeyNotFound) {
+				return result, err
+			}
+			result.Deleted++
+		}
+	}
+	return result, nil
+}
+
+func Verify(r io.Reader, keys *keyring.Keyring) (Header, error) {
+	r, err := Decrypt(r, keys)
+	if err != nil {
+		return Header{}, err
+	}
+	rd, err := NewReader(r)
+	if err != nil {
+		return Header{}, err
+	}
+	for {
+		if _, err := rd.Next(); err == io.EOF {
+			return rd.Header(), nil
+		} else if err != nil {
+			return Header{}, err
+		}
+	}
+}
+
+func Load(r io.Reader, keys *keyring.Keyring, store types.BackupStore, policy Policy) (types.RestoreResult, error) {
+	spool, err := os.CreateTemp("", "qkrn-restore-*")
+	if err != nil {
+		return types.RestoreResult{}, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	if _, err := Verify(io.TeeReader(r, spool), keys); err != nil {
+		return types.RestoreResult{}, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return types.RestoreResult{}, err
+	}
+
+	body, err := Decrypt(spool, keys)
+	if err != nil {
+		return types.RestoreResult{}, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+	}
+	rd, err := NewReader(body)
+	if err != nil {
+		return types.RestoreResult{}, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+	}
+	return Restore(store, rd, policy)
+}
@@ -0,0 +1,323 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q4ow/qkrn/internal/keyring"
+	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func seededStore(t *testing.T) *store.MemoryStore {
+	t.Helper()
+
+	s := store.NewMemoryStore()
+	s.SetWithOptions("app/config", []byte(`{"debug":true}`), types.SetOptions{
+		ContentType: "application/json",
+		Labels:      map[string]string{"env": "prod"},
+		TTL:         time.Hour,
+	})
+	s.Set("app/name", []byte("qkrn"))
+	s.ListPush("app/queue", [][]byte{[]byte("a"), []byte("b")}, false)
+	s.SetAdd("app/tags", "x", "y")
+	s.HashSet("app/user", map[string][]byte{"name": []byte("ada")})
+	s.Set("other", []byte("skip"))
+	return s
+}
+
+func backupBytes(t *testing.T, s *store.MemoryStore, prefix string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	trailer, err := Write(&buf, s.Snapshot(prefix), prefix)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if trailer.SHA256 == "" {
+		t.Error("Expected a checksum in the trailer")
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := seededStore(t)
+	data := backupBytes(t, src, "app/")
+
+	header, records, err := readAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if header.Format != Format || header.Version != Version || header.Prefix != "app/" {
+		t.Errorf("Unexpected header %+v", header)
+	}
+	if header.Revision != src.Revision() {
+		t.Errorf("Expected revision %d, got %d", src.Revision(), header.Revision)
+	}
+	if len(records) != 5 {
+		t.Fatalf("Expected 5 records, got %d", len(records))
+	}
+
+	dst := store.NewMemoryStore()
+	rd, _ := NewReader(bytes.NewReader(data))
+	result, err := Restore(dst, rd, PolicyMerge)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if result.Loaded != 5 || result.Skipped != 0 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	e, err := dst.GetEntry("app/config")
+	if err != nil || e.ContentType != "application/json" || e.Labels["env"] != "prod" || e.ExpiresAt.IsZero() {
+		t.Errorf("Expected metadata to survive, got %+v (%v)", e, err)
+	}
+	if list, _ := dst.ListRange("app/queue", 0, -1); len(list) != 2 || string(list[1]) != "b" {
+		t.Errorf("Expected list to survive, got %q", list)
+	}
+	if ok, _ := dst.SetIsMember("app/tags", "y"); !ok {
+		t.Error("Expected set to survive")
+	}
+	if v, _ := dst.HashGet("app/user", "name"); string(v) != "ada" {
+		t.Errorf("Expected hash to survive, got %q", v)
+	}
+	if _, err := dst.Get("other"); !errors.Is(err, types.ErrKeyNotFound) {
+		t.Errorf("Expected keys outside the prefix to be left out, got %v", err)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	data := backupBytes(t, seededStore(t), "app/")
+
+	restore := func(policy Policy) (*store.MemoryStore, types.RestoreResult) {
+		dst := store.NewMemoryStore()
+		dst.Set("app/name", []byte("local"))
+		dst.Set("app/extra", []byte("local"))
+		dst.Set("unrelated", []byte("local"))
+
+		result, err := Load(bytes.NewReader(data), nil, dst, policy)
+		if err != nil {
+			t.Fatalf("%s restore failed: %v", policy, err)
+		}
+		return dst, result
+	}
+
+	dst, result := restore(PolicyMerge)
+	if v, _ := dst.Get("app/name"); string(v) != "local" {
+		t.Errorf("Expected merge to keep existing keys, got %q", v)
+	}
+	if result.Loaded != 4 || result.Skipped != 1 {
+		t.Errorf("Unexpected merge result %+v", result)
+	}
+
+	dst, result = restore(PolicyOverwrite)
+	if v, _ := dst.Get("app/name"); string(v) != "qkrn" {
+		t.Errorf("Expected overwrite to replace existing keys, got %q", v)
+	}
+	if _, err := dst.Get("app/extra"); err != nil {
+		t.Errorf("Expected overwrite to keep keys missing from the backup, got %v", err)
+	}
+	if result.Loaded != 5 {
+		t.Errorf("Unexpected overwrite result %+v", result)
+	}
+
+	dst, result = restore(PolicyReplace)
+	if _, err := dst.Get("app/extra"); !errors.Is(err, types.ErrKeyNotFound) {
+		t.Errorf("Expected replace to delete keys missing from the backup, got %v", err)
+	}
+	if _, err := dst.Get("unrelated"); err != nil {
+		t.Errorf("Expected replace to leave keys outside the prefix alone, got %v", err)
+	}
+	if result.Deleted != 1 || result.Loaded != 5 {
+		t.Errorf("Unexpected replace result %+v", result)
+	}
+}
+
+func TestCorruptBackups(t *testing.T) {
+	data := backupBytes(t, seededStore(t), "")
+
+	plain, err := io.ReadAll(mustGzipReader(t, data))
+	if err != nil {
+		t.Fatalf("Failed to decompress: %v", err)
+	}
+
+	tampered := bytes.Replace(plain, []byte(`"cWtybg=="`), []byte(`"ZXZpbA=="`), 1)
+	if _, _, err := readAll(bytes.NewReader(compress(t, tampered))); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected checksum error for tampered records, got %v", err)
+	}
+
+	lines := strings.SplitAfter(string(plain), "\n")
+	truncated := strings.Join(lines[:len(lines)-2], "")
+	if _, _, err := readAll(bytes.NewReader(compress(t, []byte(truncated)))); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected truncation error, got %v", err)
+	}
+
+	if _, _, err := readAll(bytes.NewReader(data[:len(data)/2])); err == nil {
+		t.Error("Expected an error for a cut-off gzip stream")
+	}
+	if _, _, err := readAll(strings.NewReader("not a backup")); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected invalid format, got %v", err)
+	}
+	if _, err := ParsePolicy("clobber"); !errors.Is(err, ErrInvalidPolicy) {
+		t.Errorf("Expected invalid policy, got %v", err)
+	}
+}
+
+func TestSnapshotIsConsistent(t *testing.T) {
+	s := seededStore(t)
+	snap := s.Snapshot("app/")
+
+	s.Set("app/name", []byte("changed"))
+	s.Set("app/new", []byte("new"))
+	s.Delete("app/user")
+
+	var buf bytes.Buffer
+	if _, err := Write(&buf, snap, "app/"); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	_, records, err := readAll(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	keys := make([]string, 0, len(records))
+	for _, rec := range records {
+		keys = append(keys, rec.Key)
+		if rec.Key == "app/name" && string(rec.Value) != "qkrn" {
+			t.Errorf("Expected the value as of the snapshot, got %q", rec.Value)
+		}
+	}
+	if got := strings.Join(keys, ","); got != "app/config,app/name,app/queue,app/tags,app/user" {
+		t.Errorf("Unexpected snapshot keys %s", got)
+	}
+}
+
+func TestFailedReplaceKeepsExistingKeys(t *testing.T) {
+	data := backupBytes(t, seededStore(t), "app/")
+	plain, err := io.ReadAll(mustGzipReader(t, data))
+	if err != nil {
+		t.Fatalf("Failed to decompress: %v", err)
+	}
+	tampered := bytes.Replace(plain, []byte(`"cWtybg=="`), []byte(`"ZXZpbA=="`), 1)
+
+	dst := store.NewMemoryStore()
+	dst.Set("app/extra", []byte("local"))
+	if _, err := Load(bytes.NewReader(compress(t, tampered)), nil, dst, PolicyReplace); !errors.Is(err, ErrChecksum) || !errors.Is(err, ErrInvalidBackup) {
+		t.Fatalf("Expected a checksum error, got %v", err)
+	}
+	if v, err := dst.Get("app/extra"); err != nil || string(v) != "local" {
+		t.Errorf("Expected a failed replace to delete nothing, got %q (%v)", v, err)
+	}
+
+	dst.Set("app/name", []byte("local"))
+	lines := strings.SplitAfter(string(plain), "\n")
+	for name, data := range map[string][]byte{
+		"tampered":  compress(t, tampered),
+		"truncated": compress(t, []byte(strings.Join(lines[:len(lines)-2], ""))),
+		"cut off":   data[:len(data)-10],
+	} {
+		if _, err := Load(bytes.NewReader(data), nil, dst, PolicyOverwrite); !errors.Is(err, ErrInvalidBackup) {
+			t.Errorf("%s: expected an invalid backup, got %v", name, err)
+		}
+	}
+	if v, _ := dst.Get("app/name"); string(v) != "local" || dst.Size() != 2 {
+		t.Errorf("Expected a bad backup to leave the store unchanged, got %q and %d keys", v, dst.Size())
+	}
+
+	dst.SetLimits(types.Limits{MaxValueSize: 8})
+	if _, err := Load(bytes.NewReader(data), nil, dst, PolicyReplace); !errors.Is(err, types.ErrValueTooLarge) {
+		t.Fatalf("Expected the store to reject a record, got %v", err)
+	}
+	if _, err := dst.Get("app/extra"); err != nil {
+		t.Errorf("Expected a replace that fails to load to delete nothing, got %v", err)
+	}
+
+	defer func(size int) { maxLineSize = size }(maxLineSize)
+	maxLineSize = 1024
+	long := lines[0] + `{"key":"app/long","value":"` + strings.Repeat("A", 8192) + `"}` + "\n"
+	if _, _, err := readAll(bytes.NewReader(compress(t, []byte(long)))); !errors.Is(err, ErrInvalidRecord) {
+		t.Errorf("Expected an oversized line to be rejected, got %v", err)
+	}
+}
+
+func readAll(r io.Reader) (Header, []types.Record, error) {
+	rd, err := NewReader(r)
+	if err != nil {
+		return Header{}, nil, err
+	}
+	var records []types.Record
+	for {
+		rec, err := rd.Next()
+		if err == io.EOF {
+			return rd.Header(), records, nil
+		}
+		if err != nil {
+			return Header{}, nil, err
+		}
+		records = append(records, rec)
+	}
+}
+
+func mustGzipReader(t *testing.T, data []byte) io.Reader {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to open gzip stream: %v", err)
+	}
+	return zr
+}
+
+func compress(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestEncryptedBackup(t *testing.T) {
+	key := make([]byte, 32)
+	keys := keyring.New()
+	keys.Add("v1", key)
+
+	src := seededStore(t)
+	var buf bytes.Buffer
+	if _, err := WriteEncrypted(&buf, src.Snapshot("app/"), "app/", keys); err != nil {
+		t.Fatalf("WriteEncrypted failed: %v", err)
+	}
+	if !keyring.IsEncrypted(buf.Bytes()) {
+		t.Fatal("Expected an encrypted backup")
+	}
+	if _, _, err := readAll(bytes.NewReader(buf.Bytes())); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected an encrypted backup to need decrypting, got %v", err)
+	}
+
+	rotated := keyring.New()
+	rotated.Add("v1", key)
+	rotated.Add("v2", make([]byte, 16))
+	dst := store.NewMemoryStore()
+	result, err := Load(bytes.NewReader(buf.Bytes()), rotated, dst, PolicyMerge)
+	if err != nil || result.Loaded != 5 {
+		t.Fatalf("Expected backups sealed with an old key to restore, got %+v (%v)", result, err)
+	}
+
+	if _, err := Load(bytes.NewReader(buf.Bytes()), nil, dst, PolicyMerge); !errors.Is(err, keyring.ErrNoKeys) {
+		t.Errorf("Expected ErrNoKeys without a keyring, got %v", err)
+	}
+	other := keyring.New()
+	other.Add("v1", append(make([]byte, 31), 1))
+	if _, err := Load(bytes.NewReader(buf.Bytes()), other, dst, PolicyMerge); !errors.Is(err, keyring.ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt with the wrong key, got %v", err)
+	}
+
+	plain := backupBytes(t, src, "")
+	if _, err := Load(bytes.NewReader(plain), keys, store.NewMemoryStore(), PolicyMerge); err != nil {
+		t.Errorf("Expected plaintext backups to restore with a keyring, got %v", err)
+	}
+}
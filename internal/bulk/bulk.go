@@ -0,0 +1,146 @@
+package bulk
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const maxReportedErrors = 100
+
+func Import(store types.Store, dec *Decoder, dryRun bool) (types.ImportResult, error) {
+	result := types.ImportResult{DryRun: dryRun}
+
+	fail := func(line, item int, key string, err error) {
+		result.Failed++
+		if len(result.Errors) < maxReportedErrors {
+			result.Errors = append(result.Errors, types.ImportError{Line: line, Item: item, Key: key, Error: err.Error()})
+		}
+	}
+
+	for {
+		item, err := dec.Next()
+		if err == io.EOF {
+			return result, nil
+		}
+		var lineErr *LineError
+		if errors.As(err, &lineErr) {
+			fail(lineErr.Line, lineErr.Item, lineErr.Key, lineErr.Err)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+
+		if err := importItem(store, item, dryRun); err != nil {
+			fail(dec.Line(), dec.Item(), item.Key, err)
+			continue
+		}
+		result.Imported++
+	}
+}
+
+func importItem(store types.Store, item Item, dryRun bool) error {
+	value, ttl, err := item.decode()
+	if err != nil {
+		return err
+	}
+
+	var opts types.SetOptions
+	opts.TTL = ttl
+	if item.Metadata != nil {
+		opts.ContentType = item.Metadata.ContentType
+		opts.Labels = item.Metadata.Labels
+	}
+
+	expiring, ok := store.(types.ExpiringStore)
+	if !ok && (opts.TTL > 0 || opts.ContentType != "" || len(opts.Labels) > 0) {
+		return errors.New("storage backend does not support TTLs or metadata")
+	}
+	if dryRun {
+		return nil
+	}
+	if ok {
+		return expiring.SetWithOptions(item.Key, value, opts)
+	}
+	return store.Set(item.Key, value)
+}
+
+func Export(store types.Store, prefix string, enc *Encoder) (int, error) {
+	now := time.Now()
+	written := 0
+	write := func(key string, value []byte, contentType string, labels map[string]string, expiresAt time.Time) error {
+		item := Item{Key: key}
+		if utf8.Valid(value) {
+			item.Value = string(value)
+		} else {
+			item.Value = base64.StdEncoding.EncodeToString(value)
+			item.Encoding = "base64"
+		}
+		if !expiresAt.IsZero() {
+			ttl := expiresAt.Sub(now).Round(time.Second)
+			if ttl < time.Second {
+				ttl = time.Second
+			}
+			item.TTL = ttl.String()
+		}
+		if contentType != "" || len(labels) > 0 {
+			item.Metadata = &Metadata{ContentType: contentType, Labels: labels}
+		}
+		written++
+		return enc.Encode(item)
+	}
+
+	if backups, ok := store.(types.BackupStore); ok {
+		err := backups.Snapshot(prefix).Each(func(rec types.Record) error {
+			if rec.Type != "" && rec.Type != types.TypeString {
+				return nil
+			}
+			var expiresAt time.Time
+			if rec.ExpiresAt != nil {
+				expiresAt = *rec.ExpiresAt
+			}
+			return write(rec.Key, rec.Value, rec.ContentType, rec.Labels, expiresAt)
+		})
+		if err != nil {
+			return written, err
+		}
+		return written, enc.Close()
+	}
+
+	keys := store.Keys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		var entry types.Entry
+		var err error
+		if atomic, ok := store.(types.AtomicStore); ok {
+			entry, err = atomic.GetEntry(key)
+		} else {
+			entry.Value, err = store.Get(key)
+		}
+		if errors.Is(err, types.ErrKeyNotFound) || errors.Is(err, types.ErrWrongType) {
+			continue
+		}
+		if err != nil {
+			return written, err
+		}
+		if entry.Type != "" && entry.Type != types.TypeString {
+			continue
+		}
+
+		if err := write(key, entry.Value, entry.ContentType, entry.Labels, entry.ExpiresAt); err != nil {
+			return written, err
+		}
+	}
+	return written, enc.Close()
+}
@@ -0,0 +1,164 @@
+package bulk
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func importString(t *testing.T, s types.Store, format Format, data string, dryRun bool) types.ImportResult {
+	t.Helper()
+
+	result, err := Import(s, NewDecoder(strings.NewReader(data), format), dryRun)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	return result
+}
+
+func TestImportNDJSON(t *testing.T) {
+	s := store.NewMemoryStore()
+	data := `{"key":"a","value":"1"}
+{"key":"b","value":"2","ttl":"1h","metadata":{"content_type":"text/plain","labels":{"env":"prod"}}}
+
+not json
+{"key":"","value":"x"}
+{"key":"c","value":"aGk=","encoding":"base64"}
+{"key":"d","value":"x","ttl":"soon"}
+`
+	result := importString(t, s, FormatNDJSON, data, false)
+	if result.Imported != 3 || result.Failed != 3 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+
+	lines := []int{}
+	for _, e := range result.Errors {
+		lines = append(lines, e.Line)
+	}
+	if len(lines) != 3 || lines[0] != 4 || lines[1] != 5 || lines[2] != 7 {
+		t.Errorf("Expected errors on lines 4, 5 and 7, got %+v", result.Errors)
+	}
+	if result.Errors[2].Key != "d" {
+		t.Errorf("Expected the failing key to be reported, got %+v", result.Errors[2])
+	}
+
+	e, err := s.GetEntry("b")
+	if err != nil || e.ContentType != "text/plain" || e.Labels["env"] != "prod" || e.ExpiresAt.IsZero() {
+		t.Errorf("Expected ttl and metadata to be applied, got %+v (%v)", e, err)
+	}
+	if v, _ := s.Get("c"); string(v) != "hi" {
+		t.Errorf("Expected base64 value to be decoded, got %q", v)
+	}
+}
+
+func TestImportNDJSONLineLimit(t *testing.T) {
+	defer func(n int) { maxLineSize = n }(maxLineSize)
+	maxLineSize = 64
+
+	s := store.NewMemoryStore()
+	data := `{"key":"a","value":"1"}` + "\n" + `{"key":"b","value":"` + strings.Repeat("x", 100) + `"}` + "\n"
+	result, err := Import(s, NewDecoder(strings.NewReader(data), FormatNDJSON), false)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error for the long line, got %v", err)
+	}
+	if result.Imported != 1 {
+		t.Errorf("Expected the first line to be imported, got %+v", result)
+	}
+}
+
+func TestImportJSONAndCSV(t *testing.T) {
+	s := store.NewMemoryStore()
+
+	result := importString(t, s, FormatJSON, `{"a": "1", "n": 42, "obj": {"x": true}, "bad": null}`, false)
+	if result.Imported != 3 || result.Failed != 1 || result.Errors[0].Key != "bad" || result.Errors[0].Item != 4 || result.Errors[0].Line != 0 {
+		t.Errorf("Unexpected JSON result %+v", result)
+	}
+	if v, _ := s.Get("obj"); string(v) != `{"x": true}` {
+		t.Errorf("Expected non-string values to be stored as JSON, got %q", v)
+	}
+
+	importString(t, s, FormatJSON, `{"enc": {"value": "/wA=", "encoding": "base64"}, "doc": {"value": "v", "kind": "x"}, "text": {"value": "v", "encoding": "utf-8"}}`, false)
+	if v, _ := s.Get("enc"); !bytes.Equal(v, []byte{0xff, 0x00}) {
+		t.Errorf("Expected an encoded value to be decoded, got %q", v)
+	}
+	if v, _ := s.Get("doc"); string(v) != `{"value": "v", "kind": "x"}` {
+		t.Errorf("Expected other objects to be stored as JSON, got %q", v)
+	}
+	if v, _ := s.Get("text"); string(v) != `{"value": "v", "encoding": "utf-8"}` {
+		t.Errorf("Expected objects with an unknown encoding to be stored as JSON, got %q", v)
+	}
+
+	csvData := "key,value,ttl,labels\nx,1,,env=dev\ny,\"two, quoted\",30s,\nz,1,2,3,4\n"
+	result = importString(t, s, FormatCSV, csvData, false)
+	if result.Imported != 2 || result.Failed != 1 || result.Errors[0].Line != 4 {
+		t.Errorf("Unexpected CSV result %+v", result)
+	}
+	if v, _ := s.Get("y"); string(v) != "two, quoted" {
+		t.Errorf("Expected quoted CSV value, got %q", v)
+	}
+
+	if _, err := Import(s, NewDecoder(strings.NewReader("name,value\n"), FormatCSV), false); err == nil {
+		t.Error("Expected an error for an unknown CSV column")
+	}
+	if _, err := Import(s, NewDecoder(strings.NewReader(`["a"]`), FormatJSON), false); err == nil {
+		t.Error("Expected an error for a JSON array")
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	s := store.NewMemoryStore()
+
+	result := importString(t, s, FormatNDJSON, "{\"key\":\"a\",\"value\":\"1\"}\n{\"value\":\"2\"}\n", true)
+	if !result.DryRun || result.Imported != 1 || result.Failed != 1 {
+		t.Errorf("Unexpected dry run result %+v", result)
+	}
+	if len(s.Keys()) != 0 {
+		t.Errorf("Expected dry run to write nothing, got %v", s.Keys())
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	src := store.NewMemoryStore()
+	src.SetWithOptions("app/a", []byte("1"), types.SetOptions{TTL: time.Hour, Labels: map[string]string{"env": "prod"}})
+	src.Set("app/bin", []byte{0xff, 0x00})
+	src.Set("app/text", []byte("hello, world"))
+	src.ListPush("app/list", [][]byte{[]byte("x")}, false)
+	src.Set("other", []byte("skip"))
+
+	for _, format := range []Format{FormatNDJSON, FormatJSON, FormatCSV} {
+		var buf bytes.Buffer
+		n, err := Export(src, "app/", NewEncoder(&buf, format))
+		if err != nil || n != 3 {
+			t.Fatalf("%s export wrote %d items: %v", format, n, err)
+		}
+
+		dst := store.NewMemoryStore()
+		result := importString(t, dst, format, buf.String(), false)
+		if result.Imported != 3 || result.Failed != 0 {
+			t.Errorf("%s round trip: unexpected result %+v\n%s", format, result, buf.String())
+		}
+		if v, _ := dst.Get("app/text"); string(v) != "hello, world" {
+			t.Errorf("%s round trip: got %q", format, v)
+		}
+		if v, _ := dst.Get("app/bin"); !bytes.Equal(v, []byte{0xff, 0x00}) {
+			t.Errorf("%s round trip: expected binary value, got %q", format, v)
+		}
+		if format == FormatJSON {
+			continue
+		}
+
+		e, _ := dst.GetEntry("app/a")
+		if e.Labels["env"] != "prod" || e.ExpiresAt.IsZero() {
+			t.Errorf("%s round trip: expected ttl and labels, got %+v", format, e)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := Export(store.NewMemoryStore(), "", NewEncoder(&buf, FormatJSON)); err != nil || buf.String() != "{}\n" {
+		t.Errorf("Expected an empty JSON object, got %q (%v)", buf.String(), err)
+	}
+}
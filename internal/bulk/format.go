@@ -0,0 +1,416 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatNDJSON Format = "ndjson"
+	FormatJSON   Format = "json"
+	FormatCSV    Format = "csv"
+)
+
+var ErrInvalidFormat = errors.New("invalid format")
+
+var maxLineSize = 128 * 1024 * 1024
+
+var csvHeader = []string{"key", "value", "ttl", "content_type", "labels", "encoding"}
+
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "", "ndjson", "jsonl":
+		return FormatNDJSON, nil
+	case "json":
+		return FormatJSON, nil
+	case "csv":
+		return FormatCSV, nil
+	}
+	return "", ErrInvalidFormat
+}
+
+func FormatForContentType(contentType string) (Format, bool) {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch strings.TrimSpace(strings.ToLower(mediaType)) {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return FormatNDJSON, true
+	case "application/json":
+		return FormatJSON, true
+	case "text/csv":
+		return FormatCSV, true
+	}
+	return "", false
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatJSON:
+		return "application/json"
+	case FormatCSV:
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+type Metadata struct {
+	ContentType string            `json:"content_type,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+type Item struct {
+	Key      string    `json:"key"`
+	Value    string    `json:"value"`
+	Encoding string    `json:"encoding,omitempty"`
+	TTL      string    `json:"ttl,omitempty"`
+	Metadata *Metadata `json:"metadata,omitempty"`
+}
+
+func (it Item) decode() ([]byte, time.Duration, error) {
+	if it.Key == "" {
+		return nil, 0, errors.New("key is required")
+	}
+
+	var value []byte
+	switch it.Encoding {
+	case "":
+		value = []byte(it.Value)
+	case "base64":
+		var err error
+		if value, err = base64.StdEncoding.DecodeString(it.Value); err != nil {
+			return nil, 0, errors.New("invalid base64 value")
+		}
+	default:
+		return nil, 0, fmt.Errorf("unknown encoding %q", it.Encoding)
+	}
+
+	var ttl time.Duration
+	if it.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(it.TTL); err != nil || ttl <= 0 {
+			return nil, 0, fmt.Errorf("invalid ttl %q", it.TTL)
+		}
+	}
+	return value, ttl, nil
+}
+
+type LineError struct {
+	Line int
+	Item int
+	Key  string
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	if e.Item > 0 {
+		return fmt.Sprintf("item %d: %v", e.Item, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+type Decoder struct {
+	line int
+	item int
+	next func() (Item, error)
+}
+
+func NewDecoder(r io.Reader, format Format) *Decoder {
+	d := &Decoder{}
+	switch format {
+	case FormatJSON:
+		d.next = d.jsonReader(r)
+	case FormatCSV:
+		d.next = d.csvReader(r)
+	default:
+		d.next = d.ndjsonReader(r)
+	}
+	return d
+}
+
+func (d *Decoder) Next() (Item, error) {
+	return d.next()
+}
+
+func (d *Decoder) Line() int {
+	return d.line
+}
+
+func (d *Decoder) Item() int {
+	return d.item
+}
+
+func (d *Decoder) lineError(key string, err error) error {
+	return &LineError{Line: d.line, Item: d.item, Key: key, Err: err}
+}
+
+func (d *Decoder) ndjsonReader(r io.Reader) func() (Item, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	return func() (Item, error) {
+		for {
+			if !scanner.Scan() {
+				if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+					return Item{}, fmt.Errorf("line %d is longer than %d bytes", d.line+1, maxLineSize)
+				}
+				if err := scanner.Err(); err != nil {
+					return Item{}, err
+				}
+				return Item{}, io.EOF
+			}
+			d.line++
+
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+
+			var item Item
+			if jsonErr := json.Unmarshal(data, &item); jsonErr != nil {
+				return Item{}, d.lineError("", errors.New("invalid JSON"))
+			}
+			return item, nil
+		}
+	}
+}
+
+func (d *Decoder) csvReader(r io.Reader) func() (Item, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	var columns map[string]int
+	return func() (Item, error) {
+		for {
+			record, err := cr.Read()
+			var parseErr *csv.ParseError
+			switch {
+			case errors.As(err, &parseErr):
+				if columns == nil {
+					return Item{}, fmt.Errorf("invalid CSV header: %w", err)
+				}
+				d.line = parseErr.StartLine
+				return Item{}, d.lineError("", parseErr.Err)
+			case err != nil:
+				return Item{}, err
+			}
+			d.line, _ = cr.FieldPos(0)
+
+			if columns == nil {
+				if columns, err = csvColumns(record); err != nil {
+					return Item{}, err
+				}
+				continue
+			}
+			return csvItem(columns, record, d)
+		}
+	}
+}
+
+func csvColumns(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.ToLower(name))
+		known := false
+		for _, c := range csvHeader {
+			known = known || c == name
+		}
+		if !known {
+			return nil, fmt.Errorf("invalid CSV header: unknown column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["key"]; !ok {
+		return nil, errors.New("invalid CSV header: missing key column")
+	}
+	if _, ok := columns["value"]; !ok {
+		return nil, errors.New("invalid CSV header: missing value column")
+	}
+	return columns, nil
+}
+
+func csvItem(columns map[string]int, record []string, d *Decoder) (Item, error) {
+	if len(record) != len(columns) {
+		return Item{}, d.lineError("", fmt.Errorf("expected %d fields, got %d", len(columns), len(record)))
+	}
+
+	field := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	item := Item{
+		Key:      field("key"),
+		Value:    field("value"),
+		TTL:      field("ttl"),
+		Encoding: field("encoding"),
+	}
+
+	contentType := field("content_type")
+	var labels map[string]string
+	if raw := field("labels"); raw != "" {
+		values, err := url.ParseQuery(raw)
+		if err != nil {
+			return Item{}, d.lineError(item.Key, errors.New("invalid labels"))
+		}
+		labels = make(map[string]string, len(values))
+		for name, v := range values {
+			labels[name] = v[len(v)-1]
+		}
+	}
+	if contentType != "" || labels != nil {
+		item.Metadata = &Metadata{ContentType: contentType, Labels: labels}
+	}
+	return item, nil
+}
+
+func (d *Decoder) jsonReader(r io.Reader) func() (Item, error) {
+	dec := json.NewDecoder(r)
+	started := false
+	return func() (Item, error) {
+		if !started {
+			started = true
+			if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+				return Item{}, errors.New("invalid JSON: expected an object")
+			}
+		}
+
+		tok, err := dec.Token()
+		if err != nil {
+			return Item{}, fmt.Errorf("invalid JSON: %w", err)
+		}
+		if tok == json.Delim('}') {
+			if _, err := dec.Token(); err != io.EOF {
+				return Item{}, errors.New("invalid JSON: unexpected data after object")
+			}
+			return Item{}, io.EOF
+		}
+		d.item++
+
+		key := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return Item{}, fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		if string(raw) == "null" {
+			return Item{}, d.lineError(key, errors.New("value is null"))
+		}
+		if encoded, ok := decodeEncodedValue(raw); ok {
+			return Item{Key: key, Value: encoded.Value, Encoding: encoded.Encoding}, nil
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		return Item{Key: key, Value: value}, nil
+	}
+}
+
+type encodedValue struct {
+	Value    string `json:"value"`
+	Encoding string `json:"encoding"`
+}
+
+func decodeEncodedValue(raw json.RawMessage) (encodedValue, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || len(fields) != 2 {
+		return encodedValue{}, false
+	}
+	var v encodedValue
+	if json.Unmarshal(fields["value"], &v.Value) != nil || json.Unmarshal(fields["encoding"], &v.Encoding) != nil || v.Encoding != "base64" {
+		return encodedValue{}, false
+	}
+	return v, true
+}
+
+type Encoder struct {
+	w       io.Writer
+	format  Format
+	csv     *csv.Writer
+	written int
+}
+
+func NewEncoder(w io.Writer, format Format) *Encoder {
+	e := &Encoder{w: w, format: format}
+	if format == FormatCSV {
+		e.csv = csv.NewWriter(w)
+	}
+	return e
+}
+
+func (e *Encoder) Encode(item Item) error {
+	defer func() { e.written++ }()
+
+	switch e.format {
+	case FormatJSON:
+		key, _ := json.Marshal(item.Key)
+		value, _ := json.Marshal(item.Value)
+		if item.Encoding != "" {
+			value, _ = json.Marshal(encodedValue{Value: item.Value, Encoding: item.Encoding})
+		}
+		separator := ",\n"
+		if e.written == 0 {
+			separator = "{\n"
+		}
+		_, err := fmt.Fprintf(e.w, "%s  %s: %s", separator, key, value)
+		return err
+	case FormatCSV:
+		if e.written == 0 {
+			if err := e.csv.Write(csvHeader); err != nil {
+				return err
+			}
+		}
+		var contentType, labels string
+		if item.Metadata != nil {
+			contentType = item.Metadata.ContentType
+			values := url.Values{}
+			for name, v := range item.Metadata.Labels {
+				values.Set(name, v)
+			}
+			labels = values.Encode()
+		}
+		return e.csv.Write([]string{item.Key, item.Value, item.TTL, contentType, labels, item.Encoding})
+	default:
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		_, err = e.w.Write(append(data, '\n'))
+		return err
+	}
+}
+
+func (e *Encoder) Close() error {
+	switch e.format {
+	case FormatJSON:
+		closing := "\n}\n"
+		if e.written == 0 {
+			closing = "{}\n"
+		}
+		_, err := io.WriteString(e.w, closing)
+		return err
+	case FormatCSV:
+		if e.written == 0 {
+			e.csv.Write(csvHeader)
+		}
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+	return nil
+}
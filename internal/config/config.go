@@ -6,28 +6,120 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
+
+	"github.com/q4ow/qkrn/pkg/types"
 )
 
 type Config struct {
-	NodeID      string `toml:"node_id"`
-	Address     string `toml:"address"`
-	Port        int    `toml:"port"`
-	LogLevel    string `toml:"log_level"`
-	AuthEnabled bool   `toml:"auth_enabled"`
-	APIKey      string `toml:"api_key"`
+	NodeID          string `toml:"node_id"`
+	Address         string `toml:"address"`
+	Port            int    `toml:"port"`
+	LogLevel        string `toml:"log_level"`
+	AuthEnabled     bool   `toml:"auth_enabled"`
+	APIKey          string `toml:"api_key"`
+	DataDir         string `toml:"data_dir"`
+	MinFreeDisk     int64  `toml:"min_free_disk_mb"`
+	RESPEnabled     bool   `toml:"resp_enabled"`
+	RESPPort        int    `toml:"resp_port"`
+	MemcacheEnabled bool   `toml:"memcached_enabled"`
+	MemcachePort    int    `toml:"memcached_port"`
+	GRPCEnabled     bool   `toml:"grpc_enabled"`
+	GRPCPort        int    `toml:"grpc_port"`
+
+	HistoryVersions int           `toml:"history_versions"`
+	HistoryMaxAge   time.Duration `toml:"history_max_age"`
+
+	StorageEngine string                    `toml:"storage_engine"`
+	Storage       map[string]map[string]any `toml:"storage"`
+	Shards        int                       `toml:"shards"`
+
+	Compression          string `toml:"compression"`
+	CompressionThreshold int    `toml:"compression_threshold"`
+
+	EncryptionKeyFile string `toml:"encryption_key_file"`
+	EncryptionKeyID   string `toml:"encryption_key_id"`
+
+	MaxMemory      int64  `toml:"max_memory"`
+	EvictionPolicy string `toml:"eviction_policy"`
+
+	RestoreFrom   string `toml:"restore_from"`
+	RestorePolicy string `toml:"restore_policy"`
+
+	Namespaces map[string]NamespaceConfig `toml:"namespaces"`
+}
+
+type NamespaceConfig struct {
+	DefaultTTL   time.Duration `toml:"default_ttl"`
+	MaxKeys      int           `toml:"max_keys"`
+	MaxValueSize int           `toml:"max_value_size"`
+	MaxMemory    int64         `toml:"max_memory"`
+	Eviction     string        `toml:"eviction_policy"`
+	APIKeys      []string      `toml:"api_keys"`
+}
+
+func (n NamespaceConfig) Limits() types.Limits {
+	return types.Limits{
+		DefaultTTL:   n.DefaultTTL,
+		MaxKeys:      n.MaxKeys,
+		MaxValueSize: n.MaxValueSize,
+		MaxMemory:    n.MaxMemory,
+		Eviction:     types.EvictionPolicy(n.Eviction),
+	}
+}
+
+func (c *Config) MemoryLimits() types.Limits {
+	return types.Limits{
+		MaxMemory: c.MaxMemory,
+		Eviction:  types.EvictionPolicy(c.EvictionPolicy),
+	}
+}
+
+func (c *Config) HistoryLimits() types.HistoryLimits {
+	return types.HistoryLimits{
+		MaxVersions: c.HistoryVersions,
+		MaxAge:      c.HistoryMaxAge,
+	}
+}
+
+func (c *Config) StorageOptions() map[string]any {
+	options := map[string]any{}
+	for name, value := range c.Storage[c.StorageEngine] {
+		options[name] = value
+	}
+	if c.StorageEngine != "memory" {
+		return options
+	}
+	for name, value := range map[string]any{"shards": c.Shards, "compression": c.Compression, "compression_threshold": c.CompressionThreshold} {
+		if _, ok := options[name]; !ok && value != 0 && value != "" {
+			options[name] = value
+		}
+	}
+	return options
 }
 
 func DefaultConfig() *Config {
 	hostname, _ := os.Hostname()
 	return &Config{
-		NodeID:      hostname,
-		Address:     "localhost",
-		Port:        8080,
-		LogLevel:    "info",
-		AuthEnabled: false,
-		APIKey:      "",
+		NodeID:          hostname,
+		Address:         "localhost",
+		Port:            8080,
+		LogLevel:        "info",
+		AuthEnabled:     false,
+		APIKey:          "",
+		DataDir:         ".",
+		MinFreeDisk:     100,
+		RESPEnabled:     false,
+		RESPPort:        6379,
+		MemcacheEnabled: false,
+		MemcachePort:    11211,
+		GRPCEnabled:     false,
+		GRPCPort:        9090,
+		RestorePolicy:   "merge",
+		EvictionPolicy:  string(types.EvictNone),
+		StorageEngine:   "memory",
 	}
 }
 
@@ -40,6 +132,26 @@ func ParseFlags() *Config {
 	flag.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "Log level (debug, info, warn, error)")
 	flag.BoolVar(&cfg.AuthEnabled, "auth-enabled", cfg.AuthEnabled, "Enable authentication")
 	flag.StringVar(&cfg.APIKey, "api-key", cfg.APIKey, "API key for authentication")
+	flag.StringVar(&cfg.DataDir, "data-dir", cfg.DataDir, "Data directory")
+	flag.Int64Var(&cfg.MinFreeDisk, "min-free-disk-mb", cfg.MinFreeDisk, "Minimum free disk space in MB for readiness")
+	flag.BoolVar(&cfg.RESPEnabled, "resp-enabled", cfg.RESPEnabled, "Enable the Redis protocol listener")
+	flag.IntVar(&cfg.RESPPort, "resp-port", cfg.RESPPort, "Redis protocol listener port")
+	flag.BoolVar(&cfg.MemcacheEnabled, "memcached-enabled", cfg.MemcacheEnabled, "Enable the memcached text protocol listener")
+	flag.IntVar(&cfg.MemcachePort, "memcached-port", cfg.MemcachePort, "Memcached protocol listener port")
+	flag.BoolVar(&cfg.GRPCEnabled, "grpc-enabled", cfg.GRPCEnabled, "Enable the gRPC listener")
+	flag.IntVar(&cfg.GRPCPort, "grpc-port", cfg.GRPCPort, "gRPC listener port")
+	flag.IntVar(&cfg.HistoryVersions, "history-versions", cfg.HistoryVersions, "Number of past versions to keep per key")
+	flag.DurationVar(&cfg.HistoryMaxAge, "history-max-age", cfg.HistoryMaxAge, "How long to keep past versions of a key")
+	flag.StringVar(&cfg.StorageEngine, "storage-engine", cfg.StorageEngine, "Storage engine for keys (memory, lsm)")
+	flag.IntVar(&cfg.Shards, "shards", cfg.Shards, "Number of lock shards in the in-memory store (0 for the default)")
+	flag.StringVar(&cfg.Compression, "compression", cfg.Compression, "Codec for compressing large values in the in-memory store (none, gzip, flate)")
+	flag.IntVar(&cfg.CompressionThreshold, "compression-threshold", cfg.CompressionThreshold, "Minimum value size in bytes to compress (0 for the default)")
+	flag.StringVar(&cfg.EncryptionKeyFile, "encryption-key-file", cfg.EncryptionKeyFile, "File with AES keys for encrypting data at rest (id:base64 per line)")
+	flag.StringVar(&cfg.EncryptionKeyID, "encryption-key-id", cfg.EncryptionKeyID, "ID of the key used for new files (defaults to the last key)")
+	flag.Int64Var(&cfg.MaxMemory, "max-memory", cfg.MaxMemory, "Maximum memory in bytes for stored data (0 for no limit)")
+	flag.StringVar(&cfg.EvictionPolicy, "eviction-policy", cfg.EvictionPolicy, "What to do when max-memory is reached (noeviction, lru, lfu, random, volatile-ttl)")
+	flag.StringVar(&cfg.RestoreFrom, "restore-from", cfg.RestoreFrom, "Backup file to load into the store at startup")
+	flag.StringVar(&cfg.RestorePolicy, "restore-policy", cfg.RestorePolicy, "How a startup restore treats existing keys (merge, overwrite, replace)")
 	flag.Parse()
 
 	return cfg
@@ -125,6 +237,26 @@ func LoadConfigWithArgs(args []string) *Config {
 		flag.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "Log level (debug, info, warn, error)")
 		flag.BoolVar(&cfg.AuthEnabled, "auth-enabled", cfg.AuthEnabled, "Enable authentication")
 		flag.StringVar(&cfg.APIKey, "api-key", cfg.APIKey, "API key for authentication")
+		flag.StringVar(&cfg.DataDir, "data-dir", cfg.DataDir, "Data directory")
+		flag.Int64Var(&cfg.MinFreeDisk, "min-free-disk-mb", cfg.MinFreeDisk, "Minimum free disk space in MB for readiness")
+		flag.BoolVar(&cfg.RESPEnabled, "resp-enabled", cfg.RESPEnabled, "Enable the Redis protocol listener")
+		flag.IntVar(&cfg.RESPPort, "resp-port", cfg.RESPPort, "Redis protocol listener port")
+		flag.BoolVar(&cfg.MemcacheEnabled, "memcached-enabled", cfg.MemcacheEnabled, "Enable the memcached text protocol listener")
+		flag.IntVar(&cfg.MemcachePort, "memcached-port", cfg.MemcachePort, "Memcached protocol listener port")
+		flag.BoolVar(&cfg.GRPCEnabled, "grpc-enabled", cfg.GRPCEnabled, "Enable the gRPC listener")
+		flag.IntVar(&cfg.GRPCPort, "grpc-port", cfg.GRPCPort, "gRPC listener port")
+		flag.IntVar(&cfg.HistoryVersions, "history-versions", cfg.HistoryVersions, "Number of past versions to keep per key")
+		flag.DurationVar(&cfg.HistoryMaxAge, "history-max-age", cfg.HistoryMaxAge, "How long to keep past versions of a key")
+		flag.StringVar(&cfg.StorageEngine, "storage-engine", cfg.StorageEngine, "Storage engine for keys (memory, lsm)")
+		flag.IntVar(&cfg.Shards, "shards", cfg.Shards, "Number of lock shards in the in-memory store (0 for the default)")
+		flag.StringVar(&cfg.Compression, "compression", cfg.Compression, "Codec for compressing large values in the in-memory store (none, gzip, flate)")
+		flag.IntVar(&cfg.CompressionThreshold, "compression-threshold", cfg.CompressionThreshold, "Minimum value size in bytes to compress (0 for the default)")
+		flag.StringVar(&cfg.EncryptionKeyFile, "encryption-key-file", cfg.EncryptionKeyFile, "File with AES keys for encrypting data at rest (id:base64 per line)")
+		flag.StringVar(&cfg.EncryptionKeyID, "encryption-key-id", cfg.EncryptionKeyID, "ID of the key used for new files (defaults to the last key)")
+		flag.Int64Var(&cfg.MaxMemory, "max-memory", cfg.MaxMemory, "Maximum memory in bytes for stored data (0 for no limit)")
+		flag.StringVar(&cfg.EvictionPolicy, "eviction-policy", cfg.EvictionPolicy, "What to do when max-memory is reached (noeviction, lru, lfu, random, volatile-ttl)")
+		flag.StringVar(&cfg.RestoreFrom, "restore-from", cfg.RestoreFrom, "Backup file to load into the store at startup")
+		flag.StringVar(&cfg.RestorePolicy, "restore-policy", cfg.RestorePolicy, "How a startup restore treats existing keys (merge, overwrite, replace)")
 		flag.StringVar(&configFile, "config", "", "Path to config file")
 		flag.BoolVar(&exportConfig, "export-config", false, "Export current configuration to ./config.toml")
 
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"flag"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/types"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -28,6 +32,10 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.APIKey != "" {
 		t.Errorf("Expected default API key to be empty, got '%s'", cfg.APIKey)
 	}
+
+	if cfg.StorageEngine != "memory" {
+		t.Errorf("Expected default storage engine to be 'memory', got '%s'", cfg.StorageEngine)
+	}
 }
 
 func TestGetConfigPaths(t *testing.T) {
@@ -50,6 +58,20 @@ func TestGetConfigPaths(t *testing.T) {
 	}
 }
 
+func TestLoadConfigWithArgs(t *testing.T) {
+	t.Chdir(t.TempDir())
+	args, commandLine := os.Args, flag.CommandLine
+	t.Cleanup(func() { os.Args, flag.CommandLine = args, commandLine })
+
+	os.Args = []string{"qkrn", "-port", "9090", "-resp-enabled", "-resp-port", "7000"}
+	flag.CommandLine = flag.NewFlagSet("qkrn", flag.ContinueOnError)
+
+	cfg := LoadConfigWithArgs(os.Args[1:])
+	if cfg.Port != 9090 || !cfg.RESPEnabled || cfg.RESPPort != 7000 {
+		t.Errorf("Expected flags to override the defaults, got %+v", cfg)
+	}
+}
+
 func TestLoadFromFile(t *testing.T) {
 	tempDir := t.TempDir()
 	configFile := filepath.Join(tempDir, "test-config.toml")
@@ -207,3 +229,91 @@ func TestConfigString(t *testing.T) {
 		t.Errorf("Expected string representation to be '%s', got '%s'", expected, actual)
 	}
 }
+
+func TestLoadNamespaces(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "namespaces.toml")
+
+	configContent := `port = 8080
+history_versions = 5
+history_max_age = "24h"
+max_memory = 1048576
+eviction_policy = "lru"
+
+[namespaces.billing]
+default_ttl = "1h"
+max_keys = 1000
+max_value_size = 1048576
+api_keys = ["billing-key"]
+
+[namespaces.search]
+max_keys = 10
+max_memory = 4096
+eviction_policy = "volatile-ttl"`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	cfg, err := LoadFromFile(configFile)
+	if err != nil {
+		t.Fatalf("Failed to load config from file: %v", err)
+	}
+
+	if len(cfg.Namespaces) != 2 {
+		t.Fatalf("Expected 2 namespaces, got %v", cfg.Namespaces)
+	}
+
+	billing := cfg.Namespaces["billing"]
+	if billing.DefaultTTL != time.Hour || billing.MaxKeys != 1000 || billing.MaxValueSize != 1048576 {
+		t.Errorf("Unexpected billing settings: %+v", billing)
+	}
+	if len(billing.APIKeys) != 1 || billing.APIKeys[0] != "billing-key" {
+		t.Errorf("Expected billing API key, got %v", billing.APIKeys)
+	}
+	if limits := cfg.Namespaces["search"].Limits(); limits.MaxKeys != 10 || limits.DefaultTTL != 0 || limits.MaxMemory != 4096 || limits.Eviction != types.EvictVolatileTTL {
+		t.Errorf("Unexpected search limits: %+v", limits)
+	}
+	if history := cfg.HistoryLimits(); history.MaxVersions != 5 || history.MaxAge != 24*time.Hour {
+		t.Errorf("Unexpected history limits: %+v", history)
+	}
+	if limits := cfg.MemoryLimits(); limits.MaxMemory != 1048576 || limits.Eviction != types.EvictLRU {
+		t.Errorf("Unexpected memory limits: %+v", limits)
+	}
+}
+
+func TestLoadStorage(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "storage.toml")
+
+	configContent := `storage_engine = "lsm"
+shards = 16
+
+[storage.lsm]
+memtable_size = 1048576
+no_sync = true
+
+[storage.memory]
+shards = 8`
+
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	cfg, err := LoadFromFile(configFile)
+	if err != nil {
+		t.Fatalf("Failed to load config from file: %v", err)
+	}
+
+	options := cfg.StorageOptions()
+	if cfg.StorageEngine != "lsm" || options["memtable_size"] != int64(1048576) || options["no_sync"] != true || len(options) != 2 {
+		t.Errorf("Unexpected lsm options: %s %v", cfg.StorageEngine, options)
+	}
+
+	cfg.StorageEngine = "memory"
+	if options := cfg.StorageOptions(); options["shards"] != int64(8) {
+		t.Errorf("Expected the storage section to override shards, got %v", options)
+	}
+	delete(cfg.Storage, "memory")
+	if options := cfg.StorageOptions(); options["shards"] != 16 {
+		t.Errorf("Expected shards to fall back to the top-level setting, got %v", options)
+	}
+}
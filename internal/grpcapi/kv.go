@@ -0,0 +1,485 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/q4ow/qkrn/pkg/kvpb"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const maxWatchBatch = 128
+
+func (s *Server) Get(ctx context.Context, req *kvpb.GetRequest) (*kvpb.GetResponse, error) {
+	resp, err := get(storeTx{s.store}, req)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if resp.Kv == nil {
+		return nil, status.Errorf(codes.NotFound, "key %q not found", req.Key)
+	}
+	return resp, nil
+}
+
+func (s *Server) Put(ctx context.Context, req *kvpb.PutRequest) (*kvpb.PutResponse, error) {
+	var (
+		resp *kvpb.PutResponse
+		err  error
+	)
+	if atomic, ok := s.store.(types.AtomicStore); ok && req.PrevKv {
+		resp, err = swap(atomic, req)
+	} else {
+		resp, err = put(storeTx{s.store}, req)
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return resp, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *kvpb.DeleteRequest) (*kvpb.DeleteResponse, error) {
+	resp, err := del(storeTx{s.store}, req)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return resp, nil
+}
+
+func (s *Server) Range(ctx context.Context, req *kvpb.RangeRequest) (*kvpb.RangeResponse, error) {
+	resp, err := rangeKeys(storeTx{s.store}, req)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return resp, nil
+}
+
+func (s *Server) Incr(ctx context.Context, req *kvpb.IncrRequest) (*kvpb.IncrResponse, error) {
+	counters, ok := s.store.(types.CounterStore)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "storage backend does not support counters")
+	}
+
+	value, err := counters.Incr(req.Key, req.Delta, types.IncrOptions{Initial: req.Initial, Min: req.Min, Max: req.Max})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &kvpb.IncrResponse{Value: value}, nil
+}
+
+func (s *Server) IncrFloat(ctx context.Context, req *kvpb.IncrFloatRequest) (*kvpb.IncrFloatResponse, error) {
+	counters, ok := s.store.(types.CounterStore)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "storage backend does not support counters")
+	}
+
+	value, err := counters.IncrFloat(req.Key, req.Delta, types.IncrFloatOptions{Initial: req.Initial, Min: req.Min, Max: req.Max})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &kvpb.IncrFloatResponse{Value: value}, nil
+}
+
+func (s *Server) Txn(ctx context.Context, req *kvpb.TxnRequest) (*kvpb.TxnResponse, error) {
+	txnStore, ok := s.store.(types.TxnStore)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "storage backend does not support transactions")
+	}
+
+	var resp *kvpb.TxnResponse
+	err := txnStore.Txn(func(tx types.Tx) error {
+		succeeded := true
+		for _, c := range req.Compare {
+			ok, err := compare(tx, c)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				succeeded = false
+				break
+			}
+		}
+
+		ops := req.Success
+		if !succeeded {
+			ops = req.Failure
+		}
+
+		resp = &kvpb.TxnResponse{Succeeded: succeeded}
+		for _, op := range ops {
+			result, err := apply(tx, op)
+			if err != nil {
+				return err
+			}
+			resp.Responses = append(resp.Responses, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return resp, nil
+}
+
+func (s *Server) Watch(req *kvpb.WatchRequest, stream grpc.ServerStreamingServer[kvpb.WatchResponse]) error {
+	watchable, ok := s.store.(types.WatchableStore)
+	if !ok {
+		return status.Error(codes.Unimplemented, "storage backend does not support watches")
+	}
+	if req.Key == "" && !req.Prefix {
+		return status.Error(codes.InvalidArgument, types.ErrEmptyKey.Error())
+	}
+
+	events, cancel := watchable.Subscribe(req.Key)
+	defer cancel()
+
+	if err := stream.Send(&kvpb.WatchResponse{Created: true}); err != nil {
+		return err
+	}
+
+	matches := func(event types.Event) bool {
+		return req.Prefix || event.Key == req.Key
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher fell behind, restart the watch")
+			}
+
+			var batch []*kvpb.Event
+			if matches(event) {
+				batch = append(batch, toEvent(event))
+			}
+
+		drain:
+			for len(batch) < maxWatchBatch {
+				select {
+				case event, ok := <-events:
+					if !ok {
+						break drain
+					}
+					if matches(event) {
+						batch = append(batch, toEvent(event))
+					}
+				default:
+					break drain
+				}
+			}
+
+			if len(batch) == 0 {
+				continue
+			}
+			if err := stream.Send(&kvpb.WatchResponse{Events: batch}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func apply(tx types.Tx, op *kvpb.RequestOp) (*kvpb.ResponseOp, error) {
+	switch r := op.Request.(type) {
+	case *kvpb.RequestOp_Get:
+		resp, err := get(tx, r.Get)
+		return &kvpb.ResponseOp{Response: &kvpb.ResponseOp_Get{Get: resp}}, err
+	case *kvpb.RequestOp_Put:
+		resp, err := put(tx, r.Put)
+		return &kvpb.ResponseOp{Response: &kvpb.ResponseOp_Put{Put: resp}}, err
+	case *kvpb.RequestOp_Delete:
+		resp, err := del(tx, r.Delete)
+		return &kvpb.ResponseOp{Response: &kvpb.ResponseOp_Delete{Delete: resp}}, err
+	case *kvpb.RequestOp_Range:
+		resp, err := rangeKeys(tx, r.Range)
+		return &kvpb.ResponseOp{Response: &kvpb.ResponseOp_Range{Range: resp}}, err
+	}
+	return nil, status.Error(codes.InvalidArgument, "empty transaction operation")
+}
+
+func get(tx types.Tx, req *kvpb.GetRequest) (*kvpb.GetResponse, error) {
+	entry, err := tx.GetEntry(req.Key)
+	if errors.Is(err, types.ErrKeyNotFound) {
+		return &kvpb.GetResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if entry.Type != "" && entry.Type != types.TypeString {
+		return nil, types.ErrWrongType
+	}
+	return &kvpb.GetResponse{Kv: toKeyValue(req.Key, entry, false)}, nil
+}
+
+func put(tx types.Tx, req *kvpb.PutRequest) (*kvpb.PutResponse, error) {
+	if req.TtlMs < 0 {
+		return nil, status.Error(codes.InvalidArgument, "ttl_ms must not be negative")
+	}
+
+	resp := &kvpb.PutResponse{}
+	if req.PrevKv {
+		prev, err := tx.GetEntry(req.Key)
+		if err == nil {
+			resp.PrevKv = toKeyValue(req.Key, prev, false)
+		} else if !errors.Is(err, types.ErrKeyNotFound) {
+			return nil, err
+		}
+	}
+
+	entry, err := tx.Put(req.Key, putEntry(req))
+	if err != nil {
+		return nil, err
+	}
+	resp.Kv = toKeyValue(req.Key, entry, false)
+	return resp, nil
+}
+
+func swap(store types.AtomicStore, req *kvpb.PutRequest) (*kvpb.PutResponse, error) {
+	resp := &kvpb.PutResponse{}
+	entry, err := store.Update(req.Key, func(current types.Entry, exists bool) (types.Entry, error) {
+		resp.PrevKv = nil
+		if exists {
+			resp.PrevKv = toKeyValue(req.Key, current, false)
+		}
+		return putEntry(req), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp.Kv = toKeyValue(req.Key, entry, false)
+	return resp, nil
+}
+
+func putEntry(req *kvpb.PutRequest) types.Entry {
+	entry := types.Entry{Value: req.Value, ContentType: req.ContentType, Labels: req.Labels}
+	if req.TtlMs > 0 {
+		entry.ExpiresAt = time.Now().Add(time.Duration(req.TtlMs) * time.Millisecond)
+	}
+	return entry
+}
+
+func del(tx types.Tx, req *kvpb.DeleteRequest) (*kvpb.DeleteResponse, error) {
+	if !req.Prefix {
+		err := tx.Delete(req.Key)
+		if errors.Is(err, types.ErrKeyNotFound) {
+			return &kvpb.DeleteResponse{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &kvpb.DeleteResponse{Deleted: 1}, nil
+	}
+
+	resp := &kvpb.DeleteResponse{}
+	for _, key := range tx.Keys() {
+		if !strings.HasPrefix(key, req.Key) {
+			continue
+		}
+		err := tx.Delete(key)
+		if errors.Is(err, types.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		resp.Deleted++
+	}
+	return resp, nil
+}
+
+func rangeKeys(tx types.Tx, req *kvpb.RangeRequest) (*kvpb.RangeResponse, error) {
+	if req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+
+	var keys []string
+	for _, key := range tx.Keys() {
+		if !strings.HasPrefix(key, req.Prefix) {
+			continue
+		}
+		if req.Start != "" && key < req.Start {
+			continue
+		}
+		if req.End != "" && key >= req.End {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	resp := &kvpb.RangeResponse{Count: int64(len(keys))}
+	for _, key := range keys {
+		if req.Limit > 0 && int64(len(resp.Kvs)) == req.Limit {
+			resp.More = true
+			break
+		}
+
+		entry, err := tx.GetEntry(key)
+		if errors.Is(err, types.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		resp.Kvs = append(resp.Kvs, toKeyValue(key, entry, req.KeysOnly))
+	}
+	return resp, nil
+}
+
+func compare(tx types.Tx, c *kvpb.Compare) (bool, error) {
+	entry, err := tx.GetEntry(c.Key)
+	exists := err == nil
+	if err != nil && !errors.Is(err, types.ErrKeyNotFound) {
+		return false, err
+	}
+
+	var result int
+	switch c.Target {
+	case kvpb.Compare_VALUE:
+		if !exists {
+			return false, nil
+		}
+		result = bytes.Compare(entry.Value, c.Value)
+	case kvpb.Compare_VERSION:
+		result = compareUint(entry.Version, c.Version)
+	case kvpb.Compare_EXISTS:
+		result = compareUint(boolToUint(exists), boolToUint(c.Exists))
+	default:
+		return false, status.Errorf(codes.InvalidArgument, "unknown compare target %v", c.Target)
+	}
+
+	switch c.Result {
+	case kvpb.Compare_EQUAL:
+		return result == 0, nil
+	case kvpb.Compare_NOT_EQUAL:
+		return result != 0, nil
+	case kvpb.Compare_GREATER:
+		return result > 0, nil
+	case kvpb.Compare_LESS:
+		return result < 0, nil
+	}
+	return false, status.Errorf(codes.InvalidArgument, "unknown compare result %v", c.Result)
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolToUint(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func toKeyValue(key string, entry types.Entry, keysOnly bool) *kvpb.KeyValue {
+	kv := &kvpb.KeyValue{
+		Key:         key,
+		Type:        string(entry.Type),
+		Version:     entry.Version,
+		Revision:    entry.Revision,
+		ContentType: entry.ContentType,
+		Labels:      entry.Labels,
+		CreatedAt:   unixMilli(entry.CreatedAt),
+		ModifiedAt:  unixMilli(entry.ModifiedAt),
+		ExpiresAt:   unixMilli(entry.ExpiresAt),
+	}
+	if !keysOnly {
+		kv.Value = entry.Value
+	}
+	return kv
+}
+
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func toEvent(event types.Event) *kvpb.Event {
+	e := &kvpb.Event{Kv: toKeyValue(event.Key, event.Entry, false)}
+	if event.Type == types.EventDelete {
+		e.Type = kvpb.Event_DELETE
+	}
+	return e
+}
+
+func toStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, types.ErrEmptyKey):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, types.ErrKeyNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, types.ErrValueTooLarge), errors.Is(err, types.ErrKeyLimit), errors.Is(err, types.ErrOutOfMemory):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, types.ErrNotNumber), errors.Is(err, types.ErrWrongType):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, types.ErrOutOfRange):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, types.ErrConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, types.ErrKeyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+type storeTx struct {
+	store types.Store
+}
+
+func (t storeTx) GetEntry(key string) (types.Entry, error) {
+	if atomic, ok := t.store.(types.AtomicStore); ok {
+		return atomic.GetEntry(key)
+	}
+
+	value, err := t.store.Get(key)
+	if err != nil {
+		return types.Entry{}, err
+	}
+	return types.Entry{Value: value}, nil
+}
+
+func (t storeTx) Put(key string, entry types.Entry) (types.Entry, error) {
+	var err error
+	if expiring, ok := t.store.(types.ExpiringStore); ok {
+		opts := types.SetOptions{ContentType: entry.ContentType, Labels: entry.Labels}
+		if !entry.ExpiresAt.IsZero() {
+			opts.TTL = time.Until(entry.ExpiresAt)
+		}
+		err = expiring.SetWithOptions(key, entry.Value, opts)
+	} else {
+		err = t.store.Set(key, entry.Value)
+	}
+	if err != nil {
+		return types.Entry{}, err
+	}
+	return t.GetEntry(key)
+}
+
+func (t storeTx) Delete(key string) error {
+	return t.store.Delete(key)
+}
+
+func (t storeTx) Keys() []string {
+	return t.store.Keys()
+}
@@ -0,0 +1,91 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/textproto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/q4ow/qkrn/internal/auth"
+	"github.com/q4ow/qkrn/pkg/kvpb"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+type Server struct {
+	kvpb.UnimplementedKVServer
+
+	store types.Store
+	port  int
+	auth  *auth.Authenticator
+	grpc  *grpc.Server
+}
+
+func NewServer(store types.Store, port int, authenticator *auth.Authenticator) *Server {
+	s := &Server{
+		store: store,
+		port:  port,
+		auth:  authenticator,
+	}
+
+	s.grpc = grpc.NewServer(
+		grpc.UnaryInterceptor(s.unaryAuth),
+		grpc.StreamInterceptor(s.streamAuth),
+	)
+	kvpb.RegisterKVServer(s.grpc, s)
+
+	return s
+}
+
+func (s *Server) Start() error {
+	addr := fmt.Sprintf(":%d", s.port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Starting gRPC listener on %s", addr)
+	return s.Serve(listener)
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	return s.grpc.Serve(listener)
+}
+
+func (s *Server) Close() error {
+	s.grpc.Stop()
+	return nil
+}
+
+func (s *Server) authenticate(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := make(http.Header, len(md))
+	for key, values := range md {
+		header[textproto.CanonicalMIMEHeaderKey(key)] = values
+	}
+
+	if err := s.auth.Authenticate(auth.TokenFromHeader(header)); err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	return nil
+}
+
+func (s *Server) unaryAuth(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamAuth(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authenticate(stream.Context()); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
@@ -0,0 +1,304 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/q4ow/qkrn/internal/auth"
+	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/pkg/kvpb"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func setupTestServer(t *testing.T, apiKey string) (kvpb.KVClient, *store.MemoryStore) {
+	t.Helper()
+
+	kvStore := store.NewMemoryStore()
+	return dial(t, NewServer(kvStore, 0, auth.NewAuthenticator(apiKey != "", apiKey))), kvStore
+}
+
+func dial(t *testing.T, server *Server) kvpb.KVClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return kvpb.NewKVClient(conn)
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func expectCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if status.Code(err) != code {
+		t.Errorf("Expected %v, got %v", code, err)
+	}
+}
+
+func TestGRPCGetPutDelete(t *testing.T) {
+	client, kvStore := setupTestServer(t, "")
+	ctx := testContext(t)
+
+	put, err := client.Put(ctx, &kvpb.PutRequest{Key: "greeting", Value: []byte("hello")})
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if put.Kv.Version == 0 {
+		t.Error("Expected a version on put")
+	}
+
+	if value, err := kvStore.Get("greeting"); err != nil || string(value) != "hello" {
+		t.Errorf("Expected value to be shared with the store, got '%s' (%v)", value, err)
+	}
+
+	put, err = client.Put(ctx, &kvpb.PutRequest{Key: "greeting", Value: []byte("hi"), PrevKv: true, TtlMs: 60000, ContentType: "text/plain"})
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if string(put.PrevKv.GetValue()) != "hello" || put.Kv.ExpiresAt == 0 || put.Kv.ContentType != "text/plain" {
+		t.Errorf("Unexpected put response: %v", put)
+	}
+	if put.Kv.Version != 2 || put.Kv.Revision <= put.PrevKv.Revision || put.Kv.CreatedAt != put.PrevKv.CreatedAt {
+		t.Errorf("Expected metadata to advance on overwrite, got %v", put)
+	}
+
+	if _, err := client.Put(ctx, &kvpb.PutRequest{Key: "tagged", Value: []byte("v"), Labels: map[string]string{"team": "core"}}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if get, err := client.Get(ctx, &kvpb.GetRequest{Key: "tagged"}); err != nil || get.Kv.Labels["team"] != "core" {
+		t.Errorf("Expected labels to round-trip, got %v (%v)", get, err)
+	}
+
+	get, err := client.Get(ctx, &kvpb.GetRequest{Key: "greeting"})
+	if err != nil || string(get.Kv.Value) != "hi" {
+		t.Errorf("Expected 'hi', got %v (%v)", get, err)
+	}
+
+	_, err = client.Get(ctx, &kvpb.GetRequest{Key: "missing"})
+	expectCode(t, err, codes.NotFound)
+	_, err = client.Put(ctx, &kvpb.PutRequest{Value: []byte("v")})
+	expectCode(t, err, codes.InvalidArgument)
+
+	del, err := client.Delete(ctx, &kvpb.DeleteRequest{Key: "greeting"})
+	if err != nil || del.Deleted != 1 {
+		t.Errorf("Expected 1 deleted, got %v (%v)", del, err)
+	}
+	del, err = client.Delete(ctx, &kvpb.DeleteRequest{Key: "greeting"})
+	if err != nil || del.Deleted != 0 {
+		t.Errorf("Expected 0 deleted, got %v (%v)", del, err)
+	}
+}
+
+func TestGRPCRangeAndPrefixDelete(t *testing.T) {
+	client, kvStore := setupTestServer(t, "")
+	ctx := testContext(t)
+
+	for _, key := range []string{"app/c", "app/a", "app/b", "other"} {
+		kvStore.Set(key, []byte("v-"+key))
+	}
+
+	resp, err := client.Range(ctx, &kvpb.RangeRequest{Prefix: "app/", Limit: 2})
+	if err != nil {
+		t.Fatalf("Range failed: %v", err)
+	}
+	if len(resp.Kvs) != 2 || resp.Kvs[0].Key != "app/a" || resp.Kvs[1].Key != "app/b" || !resp.More || resp.Count != 3 {
+		t.Errorf("Unexpected range response: %v", resp)
+	}
+
+	resp, err = client.Range(ctx, &kvpb.RangeRequest{Start: "app/b", End: "other", KeysOnly: true})
+	if err != nil {
+		t.Fatalf("Range failed: %v", err)
+	}
+	if len(resp.Kvs) != 2 || resp.Kvs[0].Key != "app/b" || resp.Kvs[0].Value != nil {
+		t.Errorf("Unexpected range response: %v", resp)
+	}
+
+	del, err := client.Delete(ctx, &kvpb.DeleteRequest{Key: "app/", Prefix: true})
+	if err != nil || del.Deleted != 3 {
+		t.Errorf("Expected 3 deleted, got %v (%v)", del, err)
+	}
+	if keys := kvStore.Keys(); len(keys) != 1 || keys[0] != "other" {
+		t.Errorf("Expected only 'other' to remain, got %v", keys)
+	}
+}
+
+type txnCountingStore struct {
+	*store.MemoryStore
+	txns atomic.Int32
+}
+
+func (s *txnCountingStore) Txn(fn func(tx types.Tx) error) error {
+	s.txns.Add(1)
+	return s.MemoryStore.Txn(fn)
+}
+
+func TestGRPCSingleKeyOpsSkipTxn(t *testing.T) {
+	kvStore := &txnCountingStore{MemoryStore: store.NewMemoryStore()}
+	client := dial(t, NewServer(kvStore, 0, auth.NewAuthenticator(false, "")))
+	ctx := testContext(t)
+
+	if _, err := client.Put(ctx, &kvpb.PutRequest{Key: "a", Value: []byte("1")}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	put, err := client.Put(ctx, &kvpb.PutRequest{Key: "a", Value: []byte("2"), PrevKv: true})
+	if err != nil || string(put.PrevKv.GetValue()) != "1" || string(put.Kv.GetValue()) != "2" {
+		t.Fatalf("Expected the previous value with prev_kv, got %v (%v)", put, err)
+	}
+	if _, err := client.Range(ctx, &kvpb.RangeRequest{}); err != nil {
+		t.Fatalf("Range failed: %v", err)
+	}
+	if _, err := client.Delete(ctx, &kvpb.DeleteRequest{Key: "a"}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if n := kvStore.txns.Load(); n != 0 {
+		t.Errorf("Expected single-key operations to bypass transactions, got %d", n)
+	}
+
+	expectCode(t, toStatus(types.ErrConflict), codes.Aborted)
+	expectCode(t, toStatus(types.ErrKeyExists), codes.AlreadyExists)
+}
+
+func TestGRPCTxn(t *testing.T) {
+	client, kvStore := setupTestServer(t, "")
+	ctx := testContext(t)
+
+	createIfAbsent := &kvpb.TxnRequest{
+		Compare: []*kvpb.Compare{{Key: "lock", Target: kvpb.Compare_VERSION, Result: kvpb.Compare_EQUAL, Version: 0}},
+		Success: []*kvpb.RequestOp{{Request: &kvpb.RequestOp_Put{Put: &kvpb.PutRequest{Key: "lock", Value: []byte("owner-1")}}}},
+		Failure: []*kvpb.RequestOp{{Request: &kvpb.RequestOp_Get{Get: &kvpb.GetRequest{Key: "lock"}}}},
+	}
+
+	resp, err := client.Txn(ctx, createIfAbsent)
+	if err != nil || !resp.Succeeded {
+		t.Fatalf("Expected first txn to succeed, got %v (%v)", resp, err)
+	}
+
+	resp, err = client.Txn(ctx, createIfAbsent)
+	if err != nil || resp.Succeeded {
+		t.Fatalf("Expected second txn to fail, got %v (%v)", resp, err)
+	}
+	if got := string(resp.Responses[0].GetGet().GetKv().GetValue()); got != "owner-1" {
+		t.Errorf("Expected failure branch to read 'owner-1', got '%s'", got)
+	}
+
+	resp, err = client.Txn(ctx, &kvpb.TxnRequest{
+		Compare: []*kvpb.Compare{{Key: "lock", Target: kvpb.Compare_VALUE, Result: kvpb.Compare_EQUAL, Value: []byte("owner-1")}},
+		Success: []*kvpb.RequestOp{
+			{Request: &kvpb.RequestOp_Delete{Delete: &kvpb.DeleteRequest{Key: "lock"}}},
+			{Request: &kvpb.RequestOp_Put{Put: &kvpb.PutRequest{Key: "", Value: []byte("bad")}}},
+		},
+	})
+	expectCode(t, err, codes.InvalidArgument)
+	if value, err := kvStore.Get("lock"); err != nil || string(value) != "owner-1" {
+		t.Errorf("Expected failed txn to roll back, got '%s' (%v)", value, err)
+	}
+}
+
+func TestGRPCWatch(t *testing.T) {
+	client, kvStore := setupTestServer(t, "")
+	ctx := testContext(t)
+
+	stream, err := client.Watch(ctx, &kvpb.WatchRequest{Key: "app/", Prefix: true})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if resp, err := stream.Recv(); err != nil || !resp.Created {
+		t.Fatalf("Expected created response, got %v (%v)", resp, err)
+	}
+
+	kvStore.Set("other", []byte("ignored"))
+	kvStore.Set("app/a", []byte("1"))
+	kvStore.Delete("app/a")
+
+	var events []*kvpb.Event
+	for len(events) < 2 {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		events = append(events, resp.Events...)
+	}
+
+	if events[0].Type != kvpb.Event_PUT || events[0].Kv.Key != "app/a" || string(events[0].Kv.Value) != "1" {
+		t.Errorf("Unexpected put event: %v", events[0])
+	}
+	if events[1].Type != kvpb.Event_DELETE || events[1].Kv.Key != "app/a" {
+		t.Errorf("Unexpected delete event: %v", events[1])
+	}
+}
+
+func TestGRPCIncr(t *testing.T) {
+	client, kvStore := setupTestServer(t, "")
+	ctx := testContext(t)
+
+	max := int64(12)
+	resp, err := client.Incr(ctx, &kvpb.IncrRequest{Key: "hits", Delta: 2, Initial: 10, Max: &max})
+	if err != nil || resp.Value != 12 {
+		t.Fatalf("Expected 12, got %v (%v)", resp, err)
+	}
+	_, err = client.Incr(ctx, &kvpb.IncrRequest{Key: "hits", Delta: 1, Max: &max})
+	expectCode(t, err, codes.OutOfRange)
+
+	fresp, err := client.IncrFloat(ctx, &kvpb.IncrFloatRequest{Key: "hits", Delta: -0.5})
+	if err != nil || fresp.Value != 11.5 {
+		t.Errorf("Expected 11.5, got %v (%v)", fresp, err)
+	}
+
+	kvStore.Set("text", []byte("abc"))
+	_, err = client.Incr(ctx, &kvpb.IncrRequest{Key: "text", Delta: 1})
+	expectCode(t, err, codes.FailedPrecondition)
+}
+
+func TestGRPCAuth(t *testing.T) {
+	client, _ := setupTestServer(t, "secret")
+	ctx := testContext(t)
+
+	_, err := client.Get(ctx, &kvpb.GetRequest{Key: "key"})
+	expectCode(t, err, codes.Unauthenticated)
+
+	bad := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong")
+	_, err = client.Put(bad, &kvpb.PutRequest{Key: "key", Value: []byte("v")})
+	expectCode(t, err, codes.Unauthenticated)
+
+	stream, err := client.Watch(bad, &kvpb.WatchRequest{Key: "key"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	expectCode(t, err, codes.Unauthenticated)
+
+	bearer := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+	if _, err := client.Put(bearer, &kvpb.PutRequest{Key: "key", Value: []byte("v")}); err != nil {
+		t.Errorf("Expected bearer token to be accepted, got %v", err)
+	}
+
+	apiKey := metadata.AppendToOutgoingContext(ctx, "x-api-key", "secret")
+	if _, err := client.Get(apiKey, &kvpb.GetRequest{Key: "key"}); err != nil {
+		t.Errorf("Expected x-api-key to be accepted, got %v", err)
+	}
+}
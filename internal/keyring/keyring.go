@@ -0,0 +1,182 @@
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const EnvVar = "QKRN_ENCRYPTION_KEY"
+
+var (
+	ErrInvalidKey = errors.New("invalid encryption key")
+	ErrUnknownKey = errors.New("unknown encryption key")
+	ErrDecrypt    = errors.New("decryption failed")
+	ErrNoKeys     = errors.New("data is encrypted but no encryption key is configured")
+)
+
+type Keyring struct {
+	keys   map[string]cipher.AEAD
+	ids    []string
+	active string
+}
+
+func New() *Keyring {
+	return &Keyring{keys: make(map[string]cipher.AEAD)}
+}
+
+func (k *Keyring) Add(id string, key []byte) error {
+	if id == "" || len(id) > 255 {
+		return fmt.Errorf("%w: key ID must be 1 to 255 bytes", ErrInvalidKey)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("%w %q: %v", ErrInvalidKey, id, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	if _, exists := k.keys[id]; !exists {
+		k.ids = append(k.ids, id)
+	}
+	k.keys[id] = aead
+	k.active = id
+	return nil
+}
+
+func (k *Keyring) SetActive(id string) error {
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	k.active = id
+	return nil
+}
+
+func (k *Keyring) Active() string {
+	return k.active
+}
+
+func (k *Keyring) IDs() []string {
+	return append([]string{}, k.ids...)
+}
+
+func (k *Keyring) aead(id string) (cipher.AEAD, error) {
+	if k == nil {
+		return nil, ErrNoKeys
+	}
+	aead, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	return aead, nil
+}
+
+func (k *Keyring) Check(id string) error {
+	_, err := k.aead(id)
+	return err
+}
+
+func (k *Keyring) Seal(id string, plaintext, aad []byte) ([]byte, error) {
+	aead, err := k.aead(id)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func (k *Keyring) Open(id string, data, aad []byte) ([]byte, error) {
+	aead, err := k.aead(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func Parse(spec string) (*Keyring, error) {
+	k := New()
+	if err := k.parse(spec); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *Keyring) parse(spec string) error {
+	for _, line := range strings.FieldsFunc(spec, func(r rune) bool { return r == '\n' || r == ',' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, encoded, named := strings.Cut(line, ":")
+		if !named {
+			encoded = id
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return fmt.Errorf("%w: key is not valid base64", ErrInvalidKey)
+		}
+		if !named {
+			sum := sha256.Sum256(key)
+			id = hex.EncodeToString(sum[:4])
+		}
+		if err := k.Add(strings.TrimSpace(id), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func Load(path, env, active string) (*Keyring, error) {
+	if path == "" && env == "" {
+		if active != "" {
+			return nil, fmt.Errorf("%w %q", ErrUnknownKey, active)
+		}
+		return nil, nil
+	}
+
+	k := New()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := k.parse(string(data)); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := k.parse(env); err != nil {
+		return nil, fmt.Errorf("%s: %w", EnvVar, err)
+	}
+	if len(k.ids) == 0 {
+		return nil, fmt.Errorf("%w: no keys found", ErrInvalidKey)
+	}
+
+	if active != "" {
+		if err := k.SetActive(active); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
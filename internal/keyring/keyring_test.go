@@ -0,0 +1,139 @@
+package keyring
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	rand.Read(key)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestParse(t *testing.T) {
+	k, err := Parse("# keys\nold:" + newKey(t) + "\nnew:" + newKey(t) + "\n")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if k.Active() != "new" || len(k.IDs()) != 2 {
+		t.Errorf("Expected the last key to be active, got %s of %v", k.Active(), k.IDs())
+	}
+
+	bare := newKey(t)
+	k, err = Parse(bare)
+	if err != nil || len(k.Active()) != 8 {
+		t.Fatalf("Expected a derived key ID for a bare key, got %q (%v)", k.Active(), err)
+	}
+	again, _ := Parse(bare)
+	if again.Active() != k.Active() {
+		t.Errorf("Expected derived key IDs to be stable, got %s and %s", k.Active(), again.Active())
+	}
+
+	for _, bad := range []string{"id:not base64!", "id:" + base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := Parse(bad); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Expected ErrInvalidKey for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(path, []byte("v1:"+newKey(t)+"\n"), 0o600)
+
+	k, err := Load(path, "v2:"+newKey(t), "")
+	if err != nil || k.Active() != "v2" || len(k.IDs()) != 2 {
+		t.Fatalf("Expected keys from the file and the environment, got %v (%v)", k, err)
+	}
+	if k, err = Load(path, "", "v1"); err != nil || k.Active() != "v1" {
+		t.Errorf("Expected v1 to be active, got %v (%v)", k, err)
+	}
+	if _, err := Load(path, "", "v3"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+	if k, err := Load("", "", ""); k != nil || err != nil {
+		t.Errorf("Expected no keyring without keys, got %v (%v)", k, err)
+	}
+}
+
+func TestSealAndRotate(t *testing.T) {
+	k, _ := Parse("v1:" + newKey(t))
+	sealed, err := k.Seal("v1", []byte("secret"), []byte("aad"))
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("secret")) {
+		t.Fatal("Expected the plaintext to be hidden")
+	}
+
+	k.parse("v2:" + newKey(t))
+	if k.Active() != "v2" {
+		t.Errorf("Expected the new key to become active, got %s", k.Active())
+	}
+	if plain, err := k.Open("v1", sealed, []byte("aad")); err != nil || string(plain) != "secret" {
+		t.Errorf("Expected data sealed with the old key to stay readable, got %q (%v)", plain, err)
+	}
+	if _, err := k.Open("v1", sealed, []byte("other")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected a mismatched AAD to fail, got %v", err)
+	}
+	if _, err := k.Open("v2", sealed, []byte("aad")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected the wrong key to fail, got %v", err)
+	}
+	if _, err := k.Open("v3", sealed, nil); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+
+	var none *Keyring
+	if _, err := none.Open("v1", sealed, nil); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("Expected ErrNoKeys without a keyring, got %v", err)
+	}
+}
+
+func TestStream(t *testing.T) {
+	k, _ := Parse("v1:" + newKey(t))
+	data := make([]byte, 3*chunkSize+123)
+	rand.Read(data)
+
+	var buf bytes.Buffer
+	w, err := k.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	w.Write(data[:100])
+	w.Write(data[100:])
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	sealed := buf.Bytes()
+	if !IsEncrypted(sealed) {
+		t.Fatal("Expected the stream to start with the magic header")
+	}
+
+	k.parse("v2:" + newKey(t))
+	r, err := k.NewReader(bytes.NewReader(sealed))
+	if err != nil || r.KeyID != "v1" {
+		t.Fatalf("Expected a reader for key v1, got %v", err)
+	}
+	if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Expected the original data back, got %d bytes (%v)", len(got), err)
+	}
+
+	r, _ = k.NewReader(bytes.NewReader(sealed[:len(sealed)-chunkSize/2]))
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected a truncated stream to fail, got %v", err)
+	}
+
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-5] ^= 1
+	r, _ = k.NewReader(bytes.NewReader(tampered))
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected a tampered stream to fail, got %v", err)
+	}
+}
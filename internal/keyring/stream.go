@@ -0,0 +1,177 @@
+package keyring
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	Magic      = "QKRNENC1"
+	chunkSize  = 64 << 10
+	finalChunk = 1 << 31
+)
+
+var (
+	ErrTruncated = errors.New("encrypted stream is truncated")
+	errClosed    = errors.New("keyring: write to closed writer")
+)
+
+func IsEncrypted(prefix []byte) bool {
+	return bytes.HasPrefix(prefix, []byte(Magic))
+}
+
+type Writer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	nonce   []byte
+	counter uint64
+	buf     []byte
+	err     error
+}
+
+func (k *Keyring) NewWriter(w io.Writer) (*Writer, error) {
+	aead, err := k.aead(k.Active())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header := append([]byte(Magic), byte(len(k.active)))
+	header = append(header, k.active...)
+	header = append(header, nonce...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, header: header, nonce: nonce}, nil
+}
+
+func chunkNonce(base []byte, counter uint64) []byte {
+	nonce := append([]byte{}, base...)
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)^counter)
+	return nonce
+}
+
+func chunkAAD(header []byte, frame uint32) []byte {
+	return binary.BigEndian.AppendUint32(append([]byte{}, header...), frame)
+}
+
+func (w *Writer) seal(data []byte, final bool) error {
+	frame := uint32(len(data) + w.aead.Overhead())
+	if final {
+		frame |= finalChunk
+	}
+	sealed := w.aead.Seal(binary.BigEndian.AppendUint32(nil, frame), chunkNonce(w.nonce, w.counter), data, chunkAAD(w.header, frame))
+	w.counter++
+	_, err := w.w.Write(sealed)
+	return err
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= chunkSize {
+		if w.err = w.seal(w.buf[:chunkSize], false); w.err != nil {
+			return 0, w.err
+		}
+		w.buf = append(w.buf[:0], w.buf[chunkSize:]...)
+	}
+	return len(p), nil
+}
+
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.seal(w.buf, true); err != nil {
+		w.err = err
+		return err
+	}
+	w.err = errClosed
+	return nil
+}
+
+type Reader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header []byte
+	nonce  []byte
+	KeyID  string
+
+	counter uint64
+	plain   []byte
+	final   bool
+}
+
+func (k *Keyring) NewReader(r io.Reader) (*Reader, error) {
+	prefix := make([]byte, len(Magic)+1)
+	if _, err := io.ReadFull(r, prefix); err != nil || !IsEncrypted(prefix) {
+		return nil, ErrDecrypt
+	}
+	id := make([]byte, prefix[len(Magic)])
+	if _, err := io.ReadFull(r, id); err != nil {
+		return nil, ErrTruncated
+	}
+
+	aead, err := k.aead(string(id))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(r, nonce); err != nil {
+		return nil, ErrTruncated
+	}
+
+	header := append(append(prefix, id...), nonce...)
+	return &Reader{r: r, aead: aead, header: header, nonce: nonce, KeyID: string(id)}, nil
+}
+
+func (r *Reader) next() error {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r.r, prefix[:]); err != nil {
+		return ErrTruncated
+	}
+	frame := binary.BigEndian.Uint32(prefix[:])
+	n := int(frame &^ finalChunk)
+	if n < r.aead.Overhead() || n > chunkSize+r.aead.Overhead() {
+		return ErrDecrypt
+	}
+
+	sealed := make([]byte, n)
+	if _, err := io.ReadFull(r.r, sealed); err != nil {
+		return ErrTruncated
+	}
+	plain, err := r.aead.Open(sealed[:0], chunkNonce(r.nonce, r.counter), sealed, chunkAAD(r.header, frame))
+	if err != nil {
+		return ErrDecrypt
+	}
+	r.counter++
+	r.plain, r.final = plain, frame&finalChunk != 0
+	return nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.final {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
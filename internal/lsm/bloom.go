@@ -0,0 +1,59 @@
+package lsm
+
+type bloom []byte
+
+func keyHash(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+func newBloom(hashes []uint64, bitsPerKey int) bloom {
+	k := bitsPerKey * 69 / 100
+	if k < 1 {
+		k = 1
+	}
+	if k > 30 {
+		k = 30
+	}
+
+	bits := len(hashes) * bitsPerKey
+	if bits < 64 {
+		bits = 64
+	}
+	filter := make(bloom, (bits+7)/8+1)
+	bits = (len(filter) - 1) * 8
+	filter[len(filter)-1] = byte(k)
+
+	for _, h := range hashes {
+		delta := h>>33 | h<<31
+		for i := 0; i < k; i++ {
+			pos := h % uint64(bits)
+			filter[pos/8] |= 1 << (pos % 8)
+			h += delta
+		}
+	}
+	return filter
+}
+
+func (b bloom) mayContain(key string) bool {
+	if len(b) < 2 {
+		return true
+	}
+
+	k := int(b[len(b)-1])
+	bits := uint64(len(b)-1) * 8
+	h := keyHash(key)
+	delta := h>>33 | h<<31
+	for i := 0; i < k; i++ {
+		pos := h % bits
+		if b[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+		h += delta
+	}
+	return true
+}
@@ -0,0 +1,223 @@
+package lsm
+
+import "path/filepath"
+
+type iterator interface {
+	valid() bool
+	key() string
+	value() []byte
+	next()
+	err() error
+}
+
+type mergeIter struct {
+	sources []iterator
+	k       string
+	v       []byte
+	ok      bool
+	e       error
+}
+
+func newMergeIter(sources []iterator) *mergeIter {
+	it := &mergeIter{sources: sources}
+	it.next()
+	return it
+}
+
+func (it *mergeIter) next() {
+	it.ok = false
+	best := -1
+	for i, src := range it.sources {
+		if err := src.err(); err != nil {
+			it.e = err
+			return
+		}
+		if src.valid() && (best < 0 || src.key() < it.sources[best].key()) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return
+	}
+
+	it.k, it.v, it.ok = it.sources[best].key(), it.sources[best].value(), true
+	for _, src := range it.sources {
+		if src.valid() && src.key() == it.k {
+			src.next()
+		}
+	}
+}
+
+func (it *mergeIter) valid() bool   { return it.ok }
+func (it *mergeIter) key() string   { return it.k }
+func (it *mergeIter) value() []byte { return it.v }
+func (it *mergeIter) err() error    { return it.e }
+
+func (s *Store) compactLoop() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.compactCh:
+		}
+
+		for {
+			compacted, err := s.compactOnce()
+			if err != nil {
+				s.mu.Lock()
+				s.bgErr = err
+				s.mu.Unlock()
+				break
+			}
+			if !compacted {
+				break
+			}
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+		}
+	}
+}
+
+func (s *Store) triggerCompaction() {
+	select {
+	case s.compactCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Store) pickCompaction() (int, int) {
+	if len(s.tables) >= s.opts.CompactionThreshold {
+		start := len(s.tables) - 1
+		total := s.tables[start].size
+		for start > 0 && s.tables[start-1].size <= 2*total {
+			start--
+			total += s.tables[start].size
+		}
+		if len(s.tables)-start >= s.opts.CompactionThreshold {
+			return start, len(s.tables)
+		}
+	}
+
+	active := ""
+	if s.opts.Keys != nil {
+		active = s.opts.Keys.Active()
+	}
+	for i, t := range s.tables {
+		if t.keyID != active {
+			return i, i + 1
+		}
+	}
+	return -1, -1
+}
+
+func (s *Store) Compact() error {
+	for {
+		compacted, err := s.compactOnce()
+		if err != nil || !compacted {
+			return err
+		}
+	}
+}
+
+func (s *Store) compactOnce() (bool, error) {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return false, nil
+	}
+	start, end := s.pickCompaction()
+	if start < 0 {
+		s.mu.Unlock()
+		return false, nil
+	}
+	inputs := append([]*table{}, s.tables[start:end]...)
+	path := filepath.Join(s.dir, s.newFile(".sst"))
+	s.mu.Unlock()
+
+	out, err := s.merge(path, inputs, start == 0)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		if out != nil {
+			out.obsolete.Store(true)
+			out.release()
+		}
+		return false, nil
+	}
+	tables := append([]*table{}, s.tables[:start]...)
+	if out != nil {
+		tables = append(tables, out)
+	}
+	tables = append(tables, s.tables[start+len(inputs):]...)
+	if err := s.saveManifest(tables, s.walName, s.frozen); err != nil {
+		s.mu.Unlock()
+		if out != nil {
+			out.obsolete.Store(true)
+			out.release()
+		}
+		return false, err
+	}
+	s.tables = tables
+	s.mu.Unlock()
+
+	for _, t := range inputs {
+		t.obsolete.Store(true)
+		t.release()
+	}
+	return true, nil
+}
+
+func (s *Store) merge(path string, inputs []*table, bottom bool) (*table, error) {
+	sources := make([]iterator, 0, len(inputs))
+	for i := len(inputs) - 1; i >= 0; i-- {
+		sources = append(sources, inputs[i].rangeIter("", ""))
+	}
+
+	tw, err := newTableWriter(path, s.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	it := newMergeIter(sources)
+	for ; it.valid(); it.next() {
+		if bottom {
+			r, err := decodeRecord(it.value())
+			if err != nil {
+				tw.abort()
+				return nil, err
+			}
+			if !r.live(now) {
+				continue
+			}
+		}
+		if err := tw.add(it.key(), it.value()); err != nil {
+			tw.abort()
+			return nil, err
+		}
+	}
+	if err := it.err(); err != nil {
+		tw.abort()
+		return nil, err
+	}
+
+	if tw.count() == 0 {
+		tw.abort()
+		return nil, nil
+	}
+	if err := tw.finish(); err != nil {
+		return nil, err
+	}
+	return openTable(path, s.opts.Keys)
+}
@@ -0,0 +1,167 @@
+package lsm
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/q4ow/qkrn/internal/keyring"
+)
+
+func testKeys(t *testing.T, ids ...string) *keyring.Keyring {
+	t.Helper()
+	keys := keyring.New()
+	for _, id := range ids {
+		key := make([]byte, 32)
+		rand.Read(key)
+		if err := keys.Add(id, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return keys
+}
+
+func filesContain(t *testing.T, dir string, needle []byte) bool {
+	t.Helper()
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		data, _ := os.ReadFile(filepath.Join(dir, e.Name()))
+		if bytes.Contains(data, needle) {
+			return true
+		}
+	}
+	return false
+}
+
+func tableKeys(s *Store) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, len(s.tables))
+	for i, t := range s.tables {
+		ids[i] = t.keyID
+	}
+	return ids
+}
+
+func TestEncryptedStore(t *testing.T) {
+	dir := t.TempDir()
+	keys := testKeys(t, "v1")
+
+	s, _ := Open(dir, Options{MemtableSize: 2 << 10, CompactionThreshold: 100, Keys: keys})
+	for i := 0; i < 100; i++ {
+		s.Set(fmt.Sprintf("secret-key-%03d", i), []byte(fmt.Sprintf("secret-value-%03d", i)))
+	}
+	if tableCount(s) == 0 {
+		t.Fatal("Expected some tables to be flushed")
+	}
+	s.Close()
+
+	if filesContain(t, dir, []byte("secret-")) {
+		t.Error("Expected keys and values to be encrypted on disk")
+	}
+
+	if _, err := Open(dir, Options{}); !errors.Is(err, keyring.ErrNoKeys) {
+		t.Errorf("Expected opening without keys to fail, got %v", err)
+	}
+	if _, err := Open(dir, Options{Keys: testKeys(t, "other")}); !errors.Is(err, keyring.ErrUnknownKey) {
+		t.Errorf("Expected opening with the wrong keys to fail, got %v", err)
+	}
+
+	s = openStore(t, dir, Options{Keys: keys})
+	if got := len(s.Keys()); got != 100 {
+		t.Errorf("Expected 100 keys after reopening, got %d", got)
+	}
+	if v, err := s.Get("secret-key-042"); err != nil || string(v) != "secret-value-042" {
+		t.Errorf("Expected secret-value-042, got %q (%v)", v, err)
+	}
+}
+
+func TestEncryptedWALRecordsAreBound(t *testing.T) {
+	dir := t.TempDir()
+	keys := testKeys(t, "v1")
+
+	s, _ := Open(dir, Options{Keys: keys})
+	s.Set("a", []byte("1"))
+	s.Set("b", []byte("2"))
+	walPath := s.log.path
+	s.Close()
+
+	data, _ := os.ReadFile(walPath)
+	_, start, _ := readFileHeader(bytes.NewReader(data), walMagic)
+	size := (int64(len(data)) - start) / 2
+	first := append([]byte{}, data[start:start+size]...)
+	copy(data[start:], data[start+size:])
+	copy(data[start+size:], first)
+	os.WriteFile(walPath, data, 0o644)
+
+	if _, err := Open(dir, Options{Keys: keys}); err == nil {
+		t.Error("Expected reordered WAL records to be rejected")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	keys := testKeys(t, "v1")
+
+	s, _ := Open(dir, Options{MemtableSize: 2 << 10, CompactionThreshold: 100, Keys: keys})
+	for i := 0; i < 100; i++ {
+		s.Set(fmt.Sprintf("key-%03d", i), []byte("old"))
+	}
+	s.Close()
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	keys.Add("v2", key)
+	s, err := Open(dir, Options{MemtableSize: 2 << 10, CompactionThreshold: 100, Keys: keys})
+	if err != nil {
+		t.Fatalf("Expected files sealed with the old key to stay readable, got %v", err)
+	}
+	s.Set("key-100", []byte("new"))
+	s.Flush()
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	for _, id := range tableKeys(s) {
+		if id != "v2" {
+			t.Errorf("Expected compaction to re-encrypt every table with v2, got %v", tableKeys(s))
+			break
+		}
+	}
+	s.Close()
+
+	only := keyring.New()
+	only.Add("v2", key)
+	s = openStore(t, dir, Options{Keys: only})
+	if got := len(s.Keys()); got != 101 {
+		t.Errorf("Expected 101 keys readable with only the new key, got %d", got)
+	}
+}
+
+func TestEncryptPlaintextStore(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir, Options{MemtableSize: 2 << 10, CompactionThreshold: 100})
+	for i := 0; i < 100; i++ {
+		s.Set(fmt.Sprintf("plain-%03d", i), []byte("visible"))
+	}
+	s.Flush()
+	s.Close()
+	if !filesContain(t, dir, []byte("plain-")) {
+		t.Fatal("Expected an unencrypted store to write plaintext")
+	}
+
+	s, err := Open(dir, Options{Keys: testKeys(t, "v1")})
+	if err != nil {
+		t.Fatalf("Expected plaintext files to stay readable, got %v", err)
+	}
+	s.Compact()
+	s.Close()
+
+	if filesContain(t, dir, []byte("plain-")) {
+		t.Error("Expected compaction to encrypt plaintext tables")
+	}
+}
@@ -0,0 +1,337 @@
+package lsm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/internal/store/storetest"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func openStore(t *testing.T, dir string, opts Options) *Store {
+	t.Helper()
+
+	s, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func tableCount(s *Store) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.tables)
+}
+
+func TestStoreBasics(t *testing.T) {
+	s := openStore(t, t.TempDir(), Options{})
+
+	if err := s.Set("greeting", []byte("hello")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if v, err := s.Get("greeting"); err != nil || string(v) != "hello" {
+		t.Errorf("Expected hello, got %q (%v)", v, err)
+	}
+	if _, err := s.Get("missing"); !errors.Is(err, types.ErrKeyNotFound) {
+		t.Errorf("Expected key not found, got %v", err)
+	}
+	if err := s.Set("", []byte("x")); !errors.Is(err, types.ErrEmptyKey) {
+		t.Errorf("Expected empty key error, got %v", err)
+	}
+
+	err := s.SetWithOptions("greeting", []byte("hi"), types.SetOptions{OnlyIfAbsent: true})
+	if !errors.Is(err, types.ErrKeyExists) {
+		t.Errorf("Expected key exists, got %v", err)
+	}
+	s.SetWithOptions("greeting", []byte("hi"), types.SetOptions{ContentType: "text/plain", Labels: map[string]string{"env": "prod"}})
+	e, err := s.GetEntry("greeting")
+	if err != nil || e.Version != 2 || e.ContentType != "text/plain" || e.Labels["env"] != "prod" {
+		t.Errorf("Unexpected entry %+v (%v)", e, err)
+	}
+
+	if err := s.Delete("greeting"); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if err := s.Delete("greeting"); !errors.Is(err, types.ErrKeyNotFound) {
+		t.Errorf("Expected deleting twice to fail, got %v", err)
+	}
+
+	e, err = s.Update("counter", func(current types.Entry, exists bool) (types.Entry, error) {
+		if exists {
+			t.Error("Expected counter not to exist")
+		}
+		return types.Entry{Value: []byte("1")}, nil
+	})
+	if err != nil || string(e.Value) != "1" || e.Version != 1 {
+		t.Errorf("Unexpected update result %+v (%v)", e, err)
+	}
+	if err := s.Healthy(); err != nil {
+		t.Errorf("Expected an open store to be healthy, got %v", err)
+	}
+	s.Close()
+	if err := s.Healthy(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected a closed store to be unhealthy, got %v", err)
+	}
+}
+
+func TestStoreTTL(t *testing.T) {
+	s := openStore(t, t.TempDir(), Options{})
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	s.SetWithOptions("session", []byte("x"), types.SetOptions{TTL: time.Minute})
+	if ttl, ok, err := s.TTL("session"); err != nil || !ok || ttl != time.Minute {
+		t.Errorf("Expected a one minute TTL, got %v %v (%v)", ttl, ok, err)
+	}
+
+	s.Persist("session")
+	if _, ok, _ := s.TTL("session"); ok {
+		t.Error("Expected Persist to clear the TTL")
+	}
+
+	s.Expire("session", time.Second)
+	now = now.Add(2 * time.Second)
+	if _, err := s.Get("session"); !errors.Is(err, types.ErrKeyNotFound) {
+		t.Errorf("Expected the key to expire, got %v", err)
+	}
+	if keys := s.Keys(); len(keys) != 0 {
+		t.Errorf("Expected no live keys, got %v", keys)
+	}
+}
+
+func TestFlushAndReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, Options{MemtableSize: 4 << 10, CompactionThreshold: 100})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for i := 0; i < 500; i++ {
+		s.Set(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%d", i)))
+	}
+	for i := 0; i < 500; i += 5 {
+		s.Delete(fmt.Sprintf("key-%03d", i))
+	}
+	if tableCount(s) < 2 {
+		t.Fatalf("Expected the memtable to be flushed into several tables, got %d", tableCount(s))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	s = openStore(t, dir, Options{MemtableSize: 4 << 10, CompactionThreshold: 100})
+	if got := len(s.Keys()); got != 400 {
+		t.Errorf("Expected 400 keys after reopening, got %d", got)
+	}
+	if v, err := s.Get("key-123"); err != nil || string(v) != "value-123" {
+		t.Errorf("Expected value-123, got %q (%v)", v, err)
+	}
+	if _, err := s.Get("key-125"); !errors.Is(err, types.ErrKeyNotFound) {
+		t.Errorf("Expected deleted key to stay deleted, got %v", err)
+	}
+}
+
+func TestWritesDuringFlush(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, Options{MemtableSize: 4 << 10, CompactionThreshold: 100})
+
+	s.flushMu.Lock()
+	written := make(chan error, 1)
+	go func() {
+		for i := 0; i < 60; i++ {
+			if err := s.Set(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%d", i))); err != nil {
+				written <- err
+				return
+			}
+		}
+		written <- nil
+	}()
+
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected writes to continue while the memtable is flushed")
+	}
+	s.mu.RLock()
+	frozen := s.imm != nil
+	s.mu.RUnlock()
+	if !frozen || tableCount(s) != 0 {
+		t.Fatalf("Expected a frozen memtable waiting for its flush, got %d tables", tableCount(s))
+	}
+	if v, err := s.Get("key-001"); err != nil || string(v) != "value-1" {
+		t.Errorf("Expected reads to see the frozen memtable, got %q (%v)", v, err)
+	}
+
+	crashed := t.TempDir()
+	files, _ := os.ReadDir(dir)
+	for _, f := range files {
+		data, _ := os.ReadFile(filepath.Join(dir, f.Name()))
+		os.WriteFile(filepath.Join(crashed, f.Name()), data, 0o644)
+	}
+	s.flushMu.Unlock()
+
+	s = openStore(t, crashed, Options{MemtableSize: 4 << 10, CompactionThreshold: 100})
+	if got := len(s.Keys()); got != 60 {
+		t.Errorf("Expected the frozen log to be replayed after a crash, got %d keys", got)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if tableCount(s) == 0 {
+		t.Error("Expected Flush to write a table")
+	}
+	if files, _ := filepath.Glob(filepath.Join(crashed, "*.log")); len(files) != 1 {
+		t.Errorf("Expected only the active log to remain, got %v", files)
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, Options{MemtableSize: 2 << 10, CompactionThreshold: 2})
+
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 200; i++ {
+			s.Set(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("round-%d", round)))
+		}
+	}
+	for i := 0; i < 100; i++ {
+		s.Delete(fmt.Sprintf("key-%03d", i))
+	}
+	s.Flush()
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	if got := tableCount(s); got > 6 {
+		t.Errorf("Expected compaction to merge tables, got %d", got)
+	}
+	if keys := s.Keys(); len(keys) != 100 || keys[0] != "key-100" {
+		t.Errorf("Expected keys 100-199 to remain, got %d keys starting at %v", len(keys), keys[:min(len(keys), 1)])
+	}
+	if v, _ := s.Get("key-150"); string(v) != "round-4" {
+		t.Errorf("Expected the newest version to win, got %q", v)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.sst"))
+	if len(files) != tableCount(s) {
+		t.Errorf("Expected obsolete tables to be removed, found %d files for %d tables", len(files), tableCount(s))
+	}
+}
+
+func TestScan(t *testing.T) {
+	s := openStore(t, t.TempDir(), Options{MemtableSize: 1 << 10, CompactionThreshold: 100})
+
+	for i := 0; i < 100; i++ {
+		s.Set(fmt.Sprintf("user/%02d", i), []byte("old"))
+	}
+	s.Set("user/10", []byte("new"))
+	s.Delete("user/11")
+	s.Set("other", []byte("x"))
+
+	var got []types.KeyValue
+	err := s.Scan("user/10", "user/15", func(kv types.KeyValue) bool {
+		got = append(got, kv)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	want := []string{"user/10", "user/12", "user/13", "user/14"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i, kv := range got {
+		if kv.Key != want[i] {
+			t.Errorf("Expected %s at %d, got %s", want[i], i, kv.Key)
+		}
+	}
+	if string(got[0].Value) != "new" {
+		t.Errorf("Expected the memtable version of user/10, got %q", got[0].Value)
+	}
+
+	n := 0
+	s.Scan("", "", func(kv types.KeyValue) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("Expected the scan to stop when the callback returns false, got %d calls", n)
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	var hashes []uint64
+	for i := 0; i < 1000; i++ {
+		hashes = append(hashes, keyHash(fmt.Sprintf("present-%d", i)))
+	}
+	filter := newBloom(hashes, 10)
+
+	for i := 0; i < 1000; i++ {
+		if !filter.mayContain(fmt.Sprintf("present-%d", i)) {
+			t.Fatalf("Bloom filter lost present-%d", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.mayContain(fmt.Sprintf("absent-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("Expected about 1%% false positives, got %d in 10000", falsePositives)
+	}
+}
+
+func TestTableChecksum(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir, Options{})
+	s.Set("key", []byte("value"))
+	s.Flush()
+	s.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.sst"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one table, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	data[len(tableHeader)+3] ^= 0xff
+	os.WriteFile(files[0], data, 0o644)
+
+	s = openStore(t, dir, Options{})
+	if _, err := s.Get("key"); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected a checksum error, got %v", err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) types.Store {
+		return openStore(t, t.TempDir(), Options{MemtableSize: 4 << 10, CompactionThreshold: 2, NoSync: true})
+	})
+}
+
+func TestBackend(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "default")
+	s, err := store.OpenBackend("lsm", store.BackendConfig{Dir: dir, Options: map[string]any{"memtable_size": int64(1024)}})
+	if err != nil {
+		t.Fatalf("Failed to open the lsm backend: %v", err)
+	}
+	defer s.(*Store).Close()
+
+	if s.(*Store).opts.MemtableSize != 1024 {
+		t.Errorf("Expected the memtable size option to apply, got %d", s.(*Store).opts.MemtableSize)
+	}
+	if _, err := os.Stat(filepath.Join(dir, manifestName)); err != nil {
+		t.Errorf("Expected the store to live in the backend directory, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package lsm
+
+import "math/rand/v2"
+
+const (
+	maxHeight    = 12
+	nodeOverhead = 64
+)
+
+type node struct {
+	key  string
+	rec  []byte
+	next []*node
+}
+
+type memtable struct {
+	head   *node
+	height int
+	size   int
+	count  int
+}
+
+func newMemtable() *memtable {
+	return &memtable{head: &node{next: make([]*node, maxHeight)}, height: 1}
+}
+
+func (m *memtable) findGreaterOrEqual(key string, prev []*node) *node {
+	x := m.head
+	for level := m.height - 1; level >= 0; level-- {
+		for next := x.next[level]; next != nil && next.key < key; next = x.next[level] {
+			x = next
+		}
+		if prev != nil {
+			prev[level] = x
+		}
+	}
+	return x.next[0]
+}
+
+func (m *memtable) put(key string, rec []byte) {
+	prev := make([]*node, maxHeight)
+	if n := m.findGreaterOrEqual(key, prev); n != nil && n.key == key {
+		m.size += len(rec) - len(n.rec)
+		n.rec = rec
+		return
+	}
+
+	height := 1
+	for height < maxHeight && rand.IntN(4) == 0 {
+		height++
+	}
+	if height > m.height {
+		for level := m.height; level < height; level++ {
+			prev[level] = m.head
+		}
+		m.height = height
+	}
+
+	n := &node{key: key, rec: rec, next: make([]*node, height)}
+	for level := 0; level < height; level++ {
+		n.next[level] = prev[level].next[level]
+		prev[level].next[level] = n
+	}
+	m.size += nodeOverhead + len(key) + len(rec)
+	m.count++
+}
+
+func (m *memtable) get(key string) ([]byte, bool) {
+	if n := m.findGreaterOrEqual(key, nil); n != nil && n.key == key {
+		return n.rec, true
+	}
+	return nil, false
+}
+
+func (m *memtable) rangeIter(start, end string) *sliceIter {
+	it := &sliceIter{}
+	for n := m.findGreaterOrEqual(start, nil); n != nil && (end == "" || n.key < end); n = n.next[0] {
+		it.keys = append(it.keys, n.key)
+		it.recs = append(it.recs, n.rec)
+	}
+	return it
+}
+
+type sliceIter struct {
+	keys []string
+	recs [][]byte
+	i    int
+}
+
+func (it *sliceIter) valid() bool   { return it.i < len(it.keys) }
+func (it *sliceIter) key() string   { return it.keys[it.i] }
+func (it *sliceIter) value() []byte { return it.recs[it.i] }
+func (it *sliceIter) next()         { it.i++ }
+func (it *sliceIter) err() error    { return nil }
@@ -0,0 +1,171 @@
+package lsm
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+var ErrCorrupt = errors.New("lsm: corrupt data")
+
+const (
+	kindPut    = 0
+	kindDelete = 1
+)
+
+type record struct {
+	deleted     bool
+	value       []byte
+	contentType string
+	labels      map[string]string
+	flags       uint32
+	version     uint64
+	createdAt   int64
+	modifiedAt  int64
+	expiresAt   int64
+}
+
+func (r record) expired(now time.Time) bool {
+	return r.expiresAt != 0 && now.UnixNano() >= r.expiresAt
+}
+
+func (r record) live(now time.Time) bool {
+	return !r.deleted && !r.expired(now)
+}
+
+func (r record) entry() types.Entry {
+	e := types.Entry{
+		Value:       append([]byte{}, r.value...),
+		Type:        types.TypeString,
+		ContentType: r.contentType,
+		Labels:      r.labels,
+		Flags:       r.flags,
+		Version:     r.version,
+		CreatedAt:   time.Unix(0, r.createdAt),
+		ModifiedAt:  time.Unix(0, r.modifiedAt),
+	}
+	if r.expiresAt != 0 {
+		e.ExpiresAt = time.Unix(0, r.expiresAt)
+	}
+	return e
+}
+
+func (r record) encode() []byte {
+	if r.deleted {
+		return []byte{kindDelete}
+	}
+
+	buf := make([]byte, 0, 32+len(r.value)+len(r.contentType))
+	buf = append(buf, kindPut)
+	buf = binary.AppendUvarint(buf, r.version)
+	buf = binary.AppendVarint(buf, r.createdAt)
+	buf = binary.AppendVarint(buf, r.modifiedAt)
+	buf = binary.AppendVarint(buf, r.expiresAt)
+	buf = binary.AppendUvarint(buf, uint64(r.flags))
+	buf = appendString(buf, r.contentType)
+
+	names := make([]string, 0, len(r.labels))
+	for name := range r.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	buf = binary.AppendUvarint(buf, uint64(len(names)))
+	for _, name := range names {
+		buf = appendString(buf, name)
+		buf = appendString(buf, r.labels[name])
+	}
+
+	return append(buf, r.value...)
+}
+
+func decodeRecord(data []byte) (record, error) {
+	if len(data) == 0 {
+		return record{}, ErrCorrupt
+	}
+	switch data[0] {
+	case kindDelete:
+		return record{deleted: true}, nil
+	case kindPut:
+	default:
+		return record{}, ErrCorrupt
+	}
+
+	d := decoder{data: data[1:]}
+	r := record{
+		version:    d.uvarint(),
+		createdAt:  d.varint(),
+		modifiedAt: d.varint(),
+		expiresAt:  d.varint(),
+		flags:      uint32(d.uvarint()),
+	}
+	r.contentType = d.string()
+	if n := d.uvarint(); n > 0 && d.err == nil {
+		r.labels = make(map[string]string, n)
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			name := d.string()
+			r.labels[name] = d.string()
+		}
+	}
+	if d.err != nil {
+		return record{}, d.err
+	}
+	r.value = append([]byte{}, d.data...)
+	return r, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = ErrCorrupt
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.err = ErrCorrupt
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.data)) {
+		d.err = ErrCorrupt
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
@@ -0,0 +1,158 @@
+package lsm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func TestRecoverTornWAL(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir, Options{})
+	s.Set("a", []byte("1"))
+	s.Set("b", []byte("2"))
+	walPath := s.log.path
+	s.Close()
+
+	f, _ := os.OpenFile(walPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.Write([]byte{0x12, 0x34, 0x56, 0x78, 0xff, 0x00, 0x00, 0x00, 'c'})
+	f.Close()
+
+	s = openStore(t, dir, Options{})
+	if v, err := s.Get("b"); err != nil || string(v) != "2" {
+		t.Errorf("Expected writes before the torn record to survive, got %q (%v)", v, err)
+	}
+	if err := s.Set("c", []byte("3")); err != nil {
+		t.Fatalf("Set after recovery failed: %v", err)
+	}
+	s.Close()
+
+	s = openStore(t, dir, Options{})
+	if v, err := s.Get("c"); err != nil || string(v) != "3" {
+		t.Errorf("Expected writes after recovery to be readable, got %q (%v)", v, err)
+	}
+}
+
+func TestRecoverStrayFiles(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir, Options{})
+	s.Set("a", []byte("1"))
+	s.Close()
+
+	stray := filepath.Join(dir, "000099.sst")
+	os.WriteFile(stray, []byte("half written table"), 0o644)
+	os.WriteFile(filepath.Join(dir, manifestName+".tmp"), []byte("{"), 0o644)
+
+	s = openStore(t, dir, Options{})
+	if _, err := os.Stat(stray); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected tables missing from the manifest to be removed, got %v", err)
+	}
+	if v, err := s.Get("a"); err != nil || string(v) != "1" {
+		t.Errorf("Expected a to survive, got %q (%v)", v, err)
+	}
+}
+
+const crashDirEnv = "QKRN_LSM_CRASH_DIR"
+
+func crashWriter(dir string) {
+	s, err := Open(dir, Options{MemtableSize: 2 << 10, CompactionThreshold: 2})
+	if err != nil {
+		fmt.Println("error", err)
+		os.Exit(1)
+	}
+
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("key-%06d", i)
+		if err := s.Set(key, []byte("value-"+key)); err != nil {
+			fmt.Println("error", err)
+			os.Exit(1)
+		}
+		fmt.Println("set", key)
+
+		if i%10 == 9 {
+			old := fmt.Sprintf("key-%06d", i-5)
+			if err := s.Delete(old); err != nil {
+				fmt.Println("error", err)
+				os.Exit(1)
+			}
+			fmt.Println("del", old)
+		}
+	}
+}
+
+func TestCrashRecovery(t *testing.T) {
+	if dir := os.Getenv(crashDirEnv); dir != "" {
+		crashWriter(dir)
+		return
+	}
+	if testing.Short() {
+		t.Skip("skipping crash test in short mode")
+	}
+
+	dir := t.TempDir()
+	for run := 0; run < 3; run++ {
+		acked := killWriter(t, dir, 300)
+
+		s, err := Open(dir, Options{})
+		if err != nil {
+			t.Fatalf("Run %d: reopening after the crash failed: %v", run, err)
+		}
+		for key, live := range acked {
+			v, err := s.Get(key)
+			switch {
+			case live && (err != nil || string(v) != "value-"+key):
+				t.Errorf("Run %d: acknowledged write of %s lost: %q (%v)", run, key, v, err)
+			case !live && !errors.Is(err, types.ErrKeyNotFound):
+				t.Errorf("Run %d: acknowledged delete of %s lost: %v", run, key, err)
+			}
+		}
+		s.Close()
+	}
+}
+
+func killWriter(t *testing.T, dir string, acks int) map[string]bool {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCrashRecovery$")
+	cmd.Env = append(os.Environ(), crashDirEnv+"="+dir)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	acked := map[string]bool{}
+	scanner := bufio.NewScanner(out)
+	for n := 0; n < acks && scanner.Scan(); n++ {
+		op, key, _ := strings.Cut(scanner.Text(), " ")
+		switch op {
+		case "set":
+			acked[key] = true
+		case "del":
+			acked[key] = false
+		default:
+			t.Fatalf("Writer failed: %s", scanner.Text())
+		}
+	}
+
+	cmd.Process.Kill()
+	for scanner.Scan() {
+		op, key, _ := strings.Cut(scanner.Text(), " ")
+		acked[key] = op == "set"
+	}
+	cmd.Wait()
+
+	if len(acked) == 0 {
+		t.Fatal("Writer acknowledged nothing")
+	}
+	return acked
+}
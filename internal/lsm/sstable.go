@@ -0,0 +1,366 @@
+package lsm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"github.com/q4ow/qkrn/internal/keyring"
+)
+
+const (
+	footerSize   = 48
+	tableMagicV1 = 0x716b726e4c534d31
+	tableMagic   = 0x716b726e4c534d32
+	tableHeader  = "QKRNSST1"
+)
+
+type blockHandle struct {
+	lastKey string
+	offset  uint64
+	length  uint64
+}
+
+type tableWriter struct {
+	f          *os.File
+	w          *bufio.Writer
+	path       string
+	blockSize  int
+	bitsPerKey int
+	keys       *keyring.Keyring
+	keyID      string
+
+	offset  uint64
+	block   []byte
+	lastKey string
+	index   []blockHandle
+	hashes  []uint64
+}
+
+func newTableWriter(path string, opts Options) (*tableWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	tw := &tableWriter{
+		f:          f,
+		w:          bufio.NewWriterSize(f, 64<<10),
+		path:       path,
+		blockSize:  opts.BlockSize,
+		bitsPerKey: opts.BloomBitsPerKey,
+		keys:       opts.Keys,
+	}
+	if opts.Keys != nil {
+		tw.keyID = opts.Keys.Active()
+	}
+
+	header := appendFileHeader(nil, tableHeader, tw.keyID)
+	if _, err := tw.w.Write(header); err != nil {
+		tw.abort()
+		return nil, err
+	}
+	tw.offset = uint64(len(header))
+	return tw, nil
+}
+
+func blockAAD(offset uint64) []byte {
+	return binary.AppendUvarint(nil, offset)
+}
+
+func (tw *tableWriter) add(key string, rec []byte) error {
+	tw.block = appendString(tw.block, key)
+	tw.block = binary.AppendUvarint(tw.block, uint64(len(rec)))
+	tw.block = append(tw.block, rec...)
+	tw.lastKey = key
+	tw.hashes = append(tw.hashes, keyHash(key))
+
+	if len(tw.block) >= tw.blockSize {
+		return tw.flushBlock()
+	}
+	return nil
+}
+
+func (tw *tableWriter) writeBlock(data []byte) (uint64, uint64, error) {
+	if tw.keyID != "" {
+		var err error
+		if data, err = tw.keys.Seal(tw.keyID, data, blockAAD(tw.offset)); err != nil {
+			return 0, 0, err
+		}
+	}
+	data = binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+	if _, err := tw.w.Write(data); err != nil {
+		return 0, 0, err
+	}
+	offset := tw.offset
+	tw.offset += uint64(len(data))
+	return offset, uint64(len(data)), nil
+}
+
+func (tw *tableWriter) flushBlock() error {
+	if len(tw.block) == 0 {
+		return nil
+	}
+	offset, length, err := tw.writeBlock(tw.block)
+	if err != nil {
+		return err
+	}
+	tw.index = append(tw.index, blockHandle{lastKey: tw.lastKey, offset: offset, length: length})
+	tw.block = tw.block[:0]
+	return nil
+}
+
+func (tw *tableWriter) count() int {
+	return len(tw.hashes)
+}
+
+func (tw *tableWriter) finish() error {
+	if err := tw.flushBlock(); err != nil {
+		tw.abort()
+		return err
+	}
+
+	bloomOffset, bloomLen, err := tw.writeBlock(newBloom(tw.hashes, tw.bitsPerKey))
+	if err != nil {
+		tw.abort()
+		return err
+	}
+
+	var index []byte
+	for _, h := range tw.index {
+		index = appendString(index, h.lastKey)
+		index = binary.AppendUvarint(index, h.offset)
+		index = binary.AppendUvarint(index, h.length)
+	}
+	indexOffset, indexLen, err := tw.writeBlock(index)
+	if err != nil {
+		tw.abort()
+		return err
+	}
+
+	footer := make([]byte, 0, footerSize)
+	for _, v := range []uint64{indexOffset, indexLen, bloomOffset, bloomLen, uint64(len(tw.hashes)), tableMagic} {
+		footer = binary.LittleEndian.AppendUint64(footer, v)
+	}
+	if _, err := tw.w.Write(footer); err != nil {
+		tw.abort()
+		return err
+	}
+
+	if err := tw.w.Flush(); err != nil {
+		tw.abort()
+		return err
+	}
+	if err := tw.f.Sync(); err != nil {
+		tw.abort()
+		return err
+	}
+	return tw.f.Close()
+}
+
+func (tw *tableWriter) abort() {
+	tw.f.Close()
+	os.Remove(tw.path)
+}
+
+type table struct {
+	path  string
+	file  *os.File
+	size  int64
+	count uint64
+	index []blockHandle
+	bloom bloom
+	keys  *keyring.Keyring
+	keyID string
+
+	refs     atomic.Int32
+	obsolete atomic.Bool
+}
+
+func openTable(path string, keys *keyring.Keyring) (*table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := loadTable(f, path, keys)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	t.refs.Store(1)
+	return t, nil
+}
+
+func loadTable(f *os.File, path string, keys *keyring.Keyring) (*table, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < footerSize {
+		return nil, ErrCorrupt
+	}
+
+	footer := make([]byte, footerSize)
+	if _, err := f.ReadAt(footer, info.Size()-footerSize); err != nil {
+		return nil, err
+	}
+	field := func(i int) uint64 { return binary.LittleEndian.Uint64(footer[i*8:]) }
+	t := &table{path: path, file: f, size: info.Size(), count: field(4), keys: keys}
+	switch field(5) {
+	case tableMagicV1:
+	case tableMagic:
+		var offset int64
+		if t.keyID, offset, err = readFileHeader(f, tableHeader); err != nil || offset == 0 {
+			return nil, ErrCorrupt
+		}
+		if t.keyID != "" {
+			if err := keys.Check(t.keyID); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, ErrCorrupt
+	}
+
+	if t.bloom, err = t.readBlock(field(2), field(3)); err != nil {
+		return nil, err
+	}
+
+	index, err := t.readBlock(field(0), field(1))
+	if err != nil {
+		return nil, err
+	}
+	d := decoder{data: index}
+	for len(d.data) > 0 && d.err == nil {
+		t.index = append(t.index, blockHandle{lastKey: d.string(), offset: d.uvarint(), length: d.uvarint()})
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return t, nil
+}
+
+func (t *table) readBlock(offset, length uint64) ([]byte, error) {
+	if length < 4 || offset+length > uint64(t.size) {
+		return nil, ErrCorrupt
+	}
+
+	buf := make([]byte, length)
+	if _, err := t.file.ReadAt(buf, int64(offset)); err != nil {
+		return nil, err
+	}
+	data, sum := buf[:length-4], binary.LittleEndian.Uint32(buf[length-4:])
+	if crc32.ChecksumIEEE(data) != sum {
+		return nil, ErrCorrupt
+	}
+	if t.keyID != "" {
+		return t.keys.Open(t.keyID, data, blockAAD(offset))
+	}
+	return data, nil
+}
+
+func (t *table) get(key string) ([]byte, bool, error) {
+	if !t.bloom.mayContain(key) {
+		return nil, false, nil
+	}
+
+	i := sort.Search(len(t.index), func(i int) bool { return t.index[i].lastKey >= key })
+	if i == len(t.index) {
+		return nil, false, nil
+	}
+
+	block, err := t.readBlock(t.index[i].offset, t.index[i].length)
+	if err != nil {
+		return nil, false, err
+	}
+	d := decoder{data: block}
+	for len(d.data) > 0 {
+		k := d.string()
+		rec := d.bytes()
+		if d.err != nil {
+			return nil, false, d.err
+		}
+		if k == key {
+			return rec, true, nil
+		}
+		if k > key {
+			break
+		}
+	}
+	return nil, false, nil
+}
+
+func (t *table) acquire() {
+	t.refs.Add(1)
+}
+
+func (t *table) release() {
+	if t.refs.Add(-1) > 0 {
+		return
+	}
+	t.file.Close()
+	if t.obsolete.Load() {
+		os.Remove(t.path)
+	}
+}
+
+type tableIter struct {
+	t     *table
+	block int
+	d     decoder
+	end   string
+	k     string
+	rec   []byte
+	ok    bool
+	e     error
+}
+
+func (t *table) rangeIter(start, end string) *tableIter {
+	it := &tableIter{t: t, end: end}
+	it.block = sort.Search(len(t.index), func(i int) bool { return t.index[i].lastKey >= start })
+	it.load()
+	for it.ok && it.k < start {
+		it.next()
+	}
+	return it
+}
+
+func (it *tableIter) load() {
+	it.ok = false
+	if it.block >= len(it.t.index) {
+		return
+	}
+	h := it.t.index[it.block]
+	data, err := it.t.readBlock(h.offset, h.length)
+	if err != nil {
+		it.e = err
+		return
+	}
+	it.d = decoder{data: data}
+	it.advance()
+}
+
+func (it *tableIter) advance() {
+	if len(it.d.data) == 0 {
+		it.block++
+		it.load()
+		return
+	}
+
+	it.k = it.d.string()
+	it.rec = it.d.bytes()
+	if it.d.err != nil {
+		it.e, it.ok = it.d.err, false
+		return
+	}
+	it.ok = it.end == "" || it.k < it.end
+}
+
+func (it *tableIter) valid() bool   { return it.ok }
+func (it *tableIter) key() string   { return it.k }
+func (it *tableIter) value() []byte { return it.rec }
+func (it *tableIter) next()         { it.advance() }
+func (it *tableIter) err() error    { return it.e }
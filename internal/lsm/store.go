@@ -0,0 +1,628 @@
+package lsm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/q4ow/qkrn/internal/keyring"
+	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+var ErrClosed = errors.New("lsm: store is closed")
+
+type Options struct {
+	MemtableSize        int  `toml:"memtable_size"`
+	BlockSize           int  `toml:"block_size"`
+	BloomBitsPerKey     int  `toml:"bloom_bits_per_key"`
+	CompactionThreshold int  `toml:"compaction_threshold"`
+	NoSync              bool `toml:"no_sync"`
+
+	Keys *keyring.Keyring `toml:"-"`
+}
+
+func init() {
+	store.RegisterBackend("lsm", func(cfg store.BackendConfig) (types.Store, error) {
+		opts := Options{Keys: cfg.Keys}
+		if err := store.DecodeOptions(cfg.Options, &opts); err != nil {
+			return nil, err
+		}
+		return Open(cfg.Dir, opts)
+	})
+}
+
+func (o Options) withDefaults() Options {
+	if o.MemtableSize <= 0 {
+		o.MemtableSize = 4 << 20
+	}
+	if o.BlockSize <= 0 {
+		o.BlockSize = 4 << 10
+	}
+	if o.BloomBitsPerKey <= 0 {
+		o.BloomBitsPerKey = 10
+	}
+	if o.CompactionThreshold < 2 {
+		o.CompactionThreshold = 4
+	}
+	return o
+}
+
+type Store struct {
+	dir  string
+	opts Options
+	now  func() time.Time
+
+	mu       sync.RWMutex
+	mem      *memtable
+	log      *wal
+	walName  string
+	imm      *memtable
+	immLog   *wal
+	frozen   string
+	tables   []*table
+	nextFile uint64
+	closed   bool
+	bgErr    error
+	flushed  *sync.Cond
+
+	flushMu   sync.Mutex
+	flushCh   chan struct{}
+	compactMu sync.Mutex
+	compactCh chan struct{}
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func Open(dir string, opts Options) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	m, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := removeStray(dir, m); err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		dir:       dir,
+		opts:      opts.withDefaults(),
+		now:       time.Now,
+		mem:       newMemtable(),
+		nextFile:  m.NextFile,
+		flushCh:   make(chan struct{}, 1),
+		compactCh: make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	s.flushed = sync.NewCond(&s.mu)
+
+	for _, name := range m.Tables {
+		t, err := openTable(filepath.Join(dir, name), s.opts.Keys)
+		if err != nil {
+			s.releaseTables()
+			return nil, fmt.Errorf("open table %s: %w", name, err)
+		}
+		s.tables = append(s.tables, t)
+	}
+
+	s.walName = m.WAL
+	if s.walName == "" {
+		s.walName = s.newFile(".log")
+		if err := s.saveManifest(s.tables, s.walName, ""); err != nil {
+			s.releaseTables()
+			return nil, err
+		}
+	}
+
+	if m.Frozen != "" {
+		s.imm, s.frozen = newMemtable(), m.Frozen
+		if err := replayWAL(filepath.Join(dir, m.Frozen), s.opts.Keys, s.imm.put); err != nil {
+			s.releaseTables()
+			return nil, err
+		}
+	}
+
+	walPath := filepath.Join(dir, s.walName)
+	if err := replayWAL(walPath, s.opts.Keys, s.mem.put); err != nil {
+		s.releaseTables()
+		return nil, err
+	}
+	if s.log, err = createWAL(walPath, !s.opts.NoSync, s.opts.Keys); err != nil {
+		s.releaseTables()
+		return nil, err
+	}
+
+	s.wg.Add(2)
+	go s.flushLoop()
+	go s.compactLoop()
+	if s.imm != nil {
+		s.triggerFlush()
+	}
+	s.triggerCompaction()
+	return s, nil
+}
+
+func (s *Store) newFile(ext string) string {
+	name := fmt.Sprintf("%06d%s", s.nextFile, ext)
+	s.nextFile++
+	return name
+}
+
+func (s *Store) saveManifest(tables []*table, walName, frozen string) error {
+	m := manifest{NextFile: s.nextFile, WAL: walName, Frozen: frozen, Tables: make([]string, len(tables))}
+	for i, t := range tables {
+		m.Tables[i] = filepath.Base(t.path)
+	}
+	return writeManifest(s.dir, m)
+}
+
+func (s *Store) releaseTables() {
+	for _, t := range s.tables {
+		t.release()
+	}
+	s.tables = nil
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.flushed.Broadcast()
+	s.mu.Unlock()
+
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.log.close()
+	if s.immLog != nil {
+		s.immLog.close()
+	}
+	s.releaseTables()
+	return err
+}
+
+func (s *Store) Drop() error {
+	if err := s.Close(); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.dir)
+}
+
+func (s *Store) Healthy() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	return s.bgErr
+}
+
+func (s *Store) Flush() error {
+	for range 2 {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return ErrClosed
+		}
+		if s.imm == nil {
+			if s.mem.count == 0 {
+				s.mu.Unlock()
+				return nil
+			}
+			if err := s.rotate(); err != nil {
+				s.mu.Unlock()
+				return err
+			}
+		}
+		s.mu.Unlock()
+
+		if err := s.flushFrozen(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) rotate() error {
+	walName := s.newFile(".log")
+	log, err := createWAL(filepath.Join(s.dir, walName), !s.opts.NoSync, s.opts.Keys)
+	if err != nil {
+		return err
+	}
+	if err := s.saveManifest(s.tables, walName, s.walName); err != nil {
+		log.close()
+		os.Remove(log.path)
+		return err
+	}
+
+	s.imm, s.immLog, s.frozen = s.mem, s.log, s.walName
+	s.mem, s.log, s.walName = newMemtable(), log, walName
+	return nil
+}
+
+func (s *Store) triggerFlush() {
+	select {
+	case s.flushCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Store) flushLoop() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.flushCh:
+		}
+
+		err := s.flushFrozen()
+		s.mu.Lock()
+		s.bgErr = err
+		s.flushed.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+func (s *Store) flushFrozen() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	imm, log := s.imm, s.immLog
+	if imm == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	path := filepath.Join(s.dir, s.newFile(".sst"))
+	s.mu.Unlock()
+
+	if log != nil {
+		if err := log.close(); err != nil {
+			return err
+		}
+	}
+
+	t, err := s.writeTable(path, imm)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tables := s.tables
+	if t != nil {
+		tables = append(append([]*table{}, s.tables...), t)
+	}
+	if s.closed {
+		err = ErrClosed
+	} else {
+		err = s.saveManifest(tables, s.walName, "")
+	}
+	if err != nil {
+		if t != nil {
+			t.obsolete.Store(true)
+			t.release()
+		}
+		return err
+	}
+
+	os.Remove(filepath.Join(s.dir, s.frozen))
+	s.tables, s.imm, s.immLog, s.frozen = tables, nil, nil, ""
+	s.flushed.Broadcast()
+	s.triggerCompaction()
+	return nil
+}
+
+func (s *Store) writeTable(path string, m *memtable) (*table, error) {
+	if m.count == 0 {
+		return nil, nil
+	}
+
+	tw, err := newTableWriter(path, s.opts)
+	if err != nil {
+		return nil, err
+	}
+	for n := m.head.next[0]; n != nil; n = n.next[0] {
+		if err := tw.add(n.key, n.rec); err != nil {
+			tw.abort()
+			return nil, err
+		}
+	}
+	if err := tw.finish(); err != nil {
+		return nil, err
+	}
+
+	t, err := openTable(path, s.opts.Keys)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *Store) apply(key string, r record) (*wal, uint64, error) {
+	rec := r.encode()
+	seq, err := s.log.append(key, rec)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.mem.put(key, rec)
+	log := s.log
+
+	if s.mem.size >= s.opts.MemtableSize {
+		if s.imm == nil {
+			if err := s.rotate(); err != nil {
+				s.bgErr = err
+			}
+		}
+		s.triggerFlush()
+	}
+	return log, seq, nil
+}
+
+func (s *Store) lookup(key string, now time.Time) (record, bool, error) {
+	rec, found := s.mem.get(key)
+	if !found && s.imm != nil {
+		rec, found = s.imm.get(key)
+	}
+	for i := len(s.tables) - 1; !found && i >= 0; i-- {
+		var err error
+		if rec, found, err = s.tables[i].get(key); err != nil {
+			return record{}, false, err
+		}
+	}
+	if !found {
+		return record{}, false, nil
+	}
+
+	r, err := decodeRecord(rec)
+	if err != nil || !r.live(now) {
+		return record{}, false, err
+	}
+	return r, true, nil
+}
+
+func (s *Store) read(key string) (record, bool, error) {
+	if key == "" {
+		return record{}, false, types.ErrEmptyKey
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return record{}, false, ErrClosed
+	}
+	return s.lookup(key, s.now())
+}
+
+func (s *Store) write(key string, fn func(current record, exists bool, now time.Time) (record, error)) (record, error) {
+	if key == "" {
+		return record{}, types.ErrEmptyKey
+	}
+
+	s.mu.Lock()
+	for !s.closed && s.imm != nil && s.mem.size >= s.opts.MemtableSize && s.bgErr == nil {
+		s.flushed.Wait()
+	}
+	if s.closed {
+		s.mu.Unlock()
+		return record{}, ErrClosed
+	}
+
+	now := s.now()
+	current, exists, err := s.lookup(key, now)
+	if err != nil {
+		s.mu.Unlock()
+		return record{}, err
+	}
+
+	next, err := fn(current, exists, now)
+	if err != nil {
+		s.mu.Unlock()
+		return record{}, err
+	}
+	log, seq, err := s.apply(key, next)
+	s.mu.Unlock()
+	if err != nil {
+		return record{}, err
+	}
+
+	if err := log.syncTo(seq); err != nil {
+		s.mu.Lock()
+		s.bgErr = err
+		s.mu.Unlock()
+		return record{}, err
+	}
+	return next, nil
+}
+
+func (s *Store) Get(key string) ([]byte, error) {
+	r, exists, err := s.read(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, types.ErrKeyNotFound
+	}
+	return r.value, nil
+}
+
+func (s *Store) GetEntry(key string) (types.Entry, error) {
+	r, exists, err := s.read(key)
+	if err != nil {
+		return types.Entry{}, err
+	}
+	if !exists {
+		return types.Entry{}, types.ErrKeyNotFound
+	}
+	return r.entry(), nil
+}
+
+func (s *Store) Set(key string, value []byte) error {
+	return s.SetWithOptions(key, value, types.SetOptions{})
+}
+
+func (s *Store) SetWithOptions(key string, value []byte, opts types.SetOptions) error {
+	_, err := s.write(key, func(current record, exists bool, now time.Time) (record, error) {
+		if opts.OnlyIfAbsent && exists {
+			return record{}, types.ErrKeyExists
+		}
+		if opts.OnlyIfExists && !exists {
+			return record{}, types.ErrKeyNotFound
+		}
+
+		r := record{value: value, contentType: opts.ContentType, labels: opts.Labels}
+		switch {
+		case opts.TTL > 0:
+			r.expiresAt = now.Add(opts.TTL).UnixNano()
+		case opts.KeepTTL && exists:
+			r.expiresAt = current.expiresAt
+		}
+		return revise(r, current, exists, now), nil
+	})
+	return err
+}
+
+func revise(r, current record, exists bool, now time.Time) record {
+	r.version, r.createdAt = 1, now.UnixNano()
+	if exists {
+		r.version, r.createdAt = current.version+1, current.createdAt
+	}
+	r.modifiedAt = now.UnixNano()
+	return r
+}
+
+func (s *Store) Update(key string, fn types.UpdateFunc) (types.Entry, error) {
+	r, err := s.write(key, func(current record, exists bool, now time.Time) (record, error) {
+		var e types.Entry
+		if exists {
+			e = current.entry()
+		}
+		next, err := fn(e, exists)
+		if err != nil {
+			return record{}, err
+		}
+
+		r := record{value: next.Value, contentType: next.ContentType, labels: next.Labels, flags: next.Flags}
+		if !next.ExpiresAt.IsZero() {
+			r.expiresAt = next.ExpiresAt.UnixNano()
+		}
+		return revise(r, current, exists, now), nil
+	})
+	if err != nil {
+		return types.Entry{}, err
+	}
+	return r.entry(), nil
+}
+
+func (s *Store) Delete(key string) error {
+	_, err := s.write(key, func(current record, exists bool, now time.Time) (record, error) {
+		if !exists {
+			return record{}, types.ErrKeyNotFound
+		}
+		return record{deleted: true}, nil
+	})
+	return err
+}
+
+func (s *Store) Expire(key string, ttl time.Duration) error {
+	_, err := s.write(key, func(current record, exists bool, now time.Time) (record, error) {
+		if !exists {
+			return record{}, types.ErrKeyNotFound
+		}
+		if ttl <= 0 {
+			return record{deleted: true}, nil
+		}
+		current.expiresAt = now.Add(ttl).UnixNano()
+		return current, nil
+	})
+	return err
+}
+
+func (s *Store) Persist(key string) error {
+	_, err := s.write(key, func(current record, exists bool, now time.Time) (record, error) {
+		if !exists {
+			return record{}, types.ErrKeyNotFound
+		}
+		current.expiresAt = 0
+		return current, nil
+	})
+	return err
+}
+
+func (s *Store) TTL(key string) (time.Duration, bool, error) {
+	r, exists, err := s.read(key)
+	if err != nil {
+		return 0, false, err
+	}
+	if !exists {
+		return 0, false, types.ErrKeyNotFound
+	}
+	if r.expiresAt == 0 {
+		return 0, false, nil
+	}
+	return time.Unix(0, r.expiresAt).Sub(s.now()), true, nil
+}
+
+func (s *Store) Scan(start, end string, fn func(kv types.KeyValue) bool) error {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return ErrClosed
+	}
+	sources := []iterator{s.mem.rangeIter(start, end)}
+	if s.imm != nil {
+		sources = append(sources, s.imm.rangeIter(start, end))
+	}
+	tables := append([]*table{}, s.tables...)
+	for _, t := range tables {
+		t.acquire()
+	}
+	now := s.now()
+	s.mu.RUnlock()
+
+	defer func() {
+		for _, t := range tables {
+			t.release()
+		}
+	}()
+
+	for i := len(tables) - 1; i >= 0; i-- {
+		sources = append(sources, tables[i].rangeIter(start, end))
+	}
+
+	it := newMergeIter(sources)
+	for ; it.valid(); it.next() {
+		r, err := decodeRecord(it.value())
+		if err != nil {
+			return err
+		}
+		if r.live(now) && !fn(types.KeyValue{Key: it.key(), Value: r.value}) {
+			return nil
+		}
+	}
+	return it.err()
+}
+
+func (s *Store) Keys() []string {
+	keys := []string{}
+	s.Scan("", "", func(kv types.KeyValue) bool {
+		keys = append(keys, kv.Key)
+		return true
+	})
+	return keys
+}
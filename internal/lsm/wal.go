@@ -0,0 +1,222 @@
+package lsm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/q4ow/qkrn/internal/keyring"
+)
+
+const (
+	walHeaderSize = 8
+	walMagic      = "QKRNWAL1"
+)
+
+type wal struct {
+	f     *os.File
+	w     *bufio.Writer
+	path  string
+	sync  bool
+	keys  *keyring.Keyring
+	keyID string
+	size  int64
+
+	written atomic.Uint64
+	syncMu  sync.Mutex
+	synced  uint64
+	closed  bool
+}
+
+func createWAL(path string, sync bool, keys *keyring.Keyring) (*wal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := &wal{f: f, w: bufio.NewWriter(f), path: path, sync: sync, keys: keys}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.Size() > 0 {
+		if l.keyID, _, err = readFileHeader(f, walMagic); err != nil {
+			f.Close()
+			return nil, err
+		}
+		l.size = info.Size()
+		return l, nil
+	}
+
+	if keys != nil {
+		l.keyID = keys.Active()
+	}
+	header := appendFileHeader(nil, walMagic, l.keyID)
+	if _, err := f.Write(header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	l.size = int64(len(header))
+	return l, nil
+}
+
+func walAAD(path string, offset int64) []byte {
+	aad := appendString(nil, filepath.Base(path))
+	return binary.AppendUvarint(aad, uint64(offset))
+}
+
+func (l *wal) append(key string, rec []byte) (uint64, error) {
+	payload := appendString(nil, key)
+	payload = append(payload, rec...)
+	if l.keyID != "" {
+		var err error
+		if payload, err = l.keys.Seal(l.keyID, payload, walAAD(l.path, l.size)); err != nil {
+			return 0, err
+		}
+	}
+
+	header := make([]byte, walHeaderSize)
+	binary.LittleEndian.PutUint32(header, crc32.ChecksumIEEE(payload))
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(payload)))
+	if _, err := l.w.Write(header); err != nil {
+		return 0, err
+	}
+	if _, err := l.w.Write(payload); err != nil {
+		return 0, err
+	}
+	if err := l.w.Flush(); err != nil {
+		return 0, err
+	}
+	l.size += int64(len(header) + len(payload))
+	return l.written.Add(1), nil
+}
+
+func (l *wal) syncTo(seq uint64) error {
+	if !l.sync {
+		return nil
+	}
+
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+	if l.synced >= seq {
+		return nil
+	}
+
+	written := l.written.Load()
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+	l.synced = written
+	return nil
+}
+
+func (l *wal) close() error {
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+
+	if err := l.w.Flush(); err != nil {
+		l.f.Close()
+		return err
+	}
+	if err := l.f.Sync(); err != nil {
+		l.f.Close()
+		return err
+	}
+	l.synced = l.written.Load()
+	return l.f.Close()
+}
+
+func appendFileHeader(buf []byte, magic, keyID string) []byte {
+	buf = append(buf, magic...)
+	buf = append(buf, byte(len(keyID)))
+	return append(buf, keyID...)
+}
+
+func readFileHeader(r io.ReaderAt, magic string) (string, int64, error) {
+	prefix := make([]byte, len(magic)+1)
+	if n, _ := r.ReadAt(prefix, 0); n < len(prefix) || !bytes.HasPrefix(prefix, []byte(magic)) {
+		return "", 0, nil
+	}
+
+	keyID := make([]byte, prefix[len(magic)])
+	if _, err := r.ReadAt(keyID, int64(len(prefix))); err != nil {
+		return "", 0, ErrCorrupt
+	}
+	return string(keyID), int64(len(prefix) + len(keyID)), nil
+}
+
+func replayWAL(path string, keys *keyring.Keyring, fn func(key string, rec []byte)) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	keyID, good, err := readFileHeader(f, walMagic)
+	if err != nil || (good == 0 && info.Size() > 0 && info.Size() <= int64(len(walMagic))) {
+		return os.Truncate(path, 0)
+	}
+	if keyID != "" {
+		if err := keys.Check(keyID); err != nil {
+			return err
+		}
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(f, good, info.Size()-good))
+	header := make([]byte, walHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			break
+		}
+		n := int64(binary.LittleEndian.Uint32(header[4:]))
+		if n > info.Size()-good-walHeaderSize {
+			break
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header) {
+			break
+		}
+		size := int64(walHeaderSize + len(payload))
+
+		if keyID != "" {
+			if payload, err = keys.Open(keyID, payload, walAAD(path, good)); err != nil {
+				return err
+			}
+		}
+		d := decoder{data: payload}
+		key := d.string()
+		if d.err != nil {
+			break
+		}
+		fn(key, d.data)
+		good += size
+	}
+
+	if info.Size() > good {
+		return os.Truncate(path, good)
+	}
+	return nil
+}
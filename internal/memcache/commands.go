@@ -0,0 +1,446 @@
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const relativeExpiryLimit = 60 * 60 * 24 * 30
+
+var (
+	errLineTooLong  = errors.New("line too long")
+	errNonNumeric   = errors.New("cannot increment or decrement non-numeric value")
+	errBadDataChunk = errors.New("bad data chunk")
+)
+
+func (sess *session) readLine() (string, error) {
+	line, err := sess.r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) || len(line) > maxLineLength {
+		return "", errLineTooLong
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func (sess *session) reply(line string) {
+	sess.w.WriteString(line + "\r\n")
+}
+
+func (sess *session) replyf(format string, args ...interface{}) {
+	sess.reply(fmt.Sprintf(format, args...))
+}
+
+func (sess *session) readData(length int) ([]byte, error) {
+	data := make([]byte, length+2)
+	if _, err := io.ReadFull(sess.r, data); err != nil {
+		return nil, err
+	}
+	if data[length] != '\r' || data[length+1] != '\n' {
+		return nil, errBadDataChunk
+	}
+	return data[:length], nil
+}
+
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func expiresAt(exptime int64, now time.Time) time.Time {
+	switch {
+	case exptime == 0:
+		return time.Time{}
+	case exptime < 0:
+		return now
+	case exptime <= relativeExpiryLimit:
+		return now.Add(time.Duration(exptime) * time.Second)
+	default:
+		return time.Unix(exptime, 0)
+	}
+}
+
+func (s *Server) dispatch(sess *session, line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		sess.reply("ERROR")
+		return nil
+	}
+
+	cmd := fields[0]
+	if !sess.authenticated && cmd != "set" && cmd != "quit" {
+		sess.reply("CLIENT_ERROR unauthenticated")
+		return nil
+	}
+
+	atomic, ok := s.store.(types.AtomicStore)
+	if !ok && cmd != "quit" && cmd != "version" {
+		sess.reply("SERVER_ERROR storage backend does not support the memcached protocol")
+		return nil
+	}
+
+	switch cmd {
+	case "get", "gets":
+		return s.cmdGet(sess, atomic, fields[1:], cmd == "gets")
+	case "gat", "gats":
+		return s.cmdGat(sess, atomic, fields[1:], cmd == "gats")
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return s.cmdStore(sess, atomic, cmd, fields[1:])
+	case "delete":
+		return s.cmdDelete(sess, fields[1:])
+	case "incr", "decr":
+		return s.cmdIncr(sess, atomic, cmd == "incr", fields[1:])
+	case "touch":
+		return s.cmdTouch(sess, fields[1:])
+	case "version":
+		sess.reply("VERSION 0.1.0")
+	case "verbosity":
+		if len(fields) < 2 || len(fields) > 3 {
+			sess.reply("ERROR")
+			return nil
+		}
+		if !noreply(fields, 2) {
+			sess.reply("OK")
+		}
+	case "quit":
+		sess.quit = true
+	default:
+		sess.reply("ERROR")
+	}
+
+	return nil
+}
+
+func noreply(fields []string, index int) bool {
+	return len(fields) > index && fields[index] == "noreply"
+}
+
+func (s *Server) cmdGet(sess *session, store types.AtomicStore, keys []string, withCAS bool) error {
+	if len(keys) == 0 {
+		sess.reply("ERROR")
+		return nil
+	}
+
+	for _, key := range keys {
+		if !validKey(key) {
+			sess.reply("CLIENT_ERROR bad command line format")
+			return nil
+		}
+	}
+
+	for _, key := range keys {
+		entry, err := store.GetEntry(key)
+		if err != nil || (entry.Type != "" && entry.Type != types.TypeString) {
+			continue
+		}
+		writeValue(sess, key, entry, withCAS)
+	}
+	sess.reply("END")
+	return nil
+}
+
+func writeValue(sess *session, key string, entry types.Entry, withCAS bool) {
+	if withCAS {
+		sess.replyf("VALUE %s %d %d %d", key, entry.Flags, len(entry.Value), entry.Revision)
+	} else {
+		sess.replyf("VALUE %s %d %d", key, entry.Flags, len(entry.Value))
+	}
+	sess.w.Write(entry.Value)
+	sess.reply("")
+}
+
+func (s *Server) cmdGat(sess *session, store types.AtomicStore, args []string, withCAS bool) error {
+	if len(args) < 2 {
+		sess.reply("ERROR")
+		return nil
+	}
+
+	exptime, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		sess.reply("CLIENT_ERROR invalid exptime argument")
+		return nil
+	}
+
+	for _, key := range args[1:] {
+		if !validKey(key) {
+			sess.reply("CLIENT_ERROR bad command line format")
+			return nil
+		}
+	}
+
+	for _, key := range args[1:] {
+		entry, err := s.touch(store, key, exptime)
+		if err != nil || (entry.Type != "" && entry.Type != types.TypeString) {
+			continue
+		}
+		writeValue(sess, key, entry, withCAS)
+	}
+	sess.reply("END")
+	return nil
+}
+
+func (s *Server) touch(store types.AtomicStore, key string, exptime int64) (types.Entry, error) {
+	expiring, ok := s.store.(types.ExpiringStore)
+	if !ok {
+		return types.Entry{}, errors.New("expiry not supported")
+	}
+
+	var err error
+	if deadline := expiresAt(exptime, time.Now()); deadline.IsZero() {
+		err = expiring.Persist(key)
+	} else {
+		err = expiring.Expire(key, time.Until(deadline))
+	}
+	if err != nil {
+		return types.Entry{}, err
+	}
+
+	return store.GetEntry(key)
+}
+
+func (s *Server) cmdStore(sess *session, store types.AtomicStore, cmd string, args []string) error {
+	minArgs := 4
+	if cmd == "cas" {
+		minArgs = 5
+	}
+	if len(args) < minArgs || len(args) > minArgs+1 {
+		sess.reply("ERROR")
+		return nil
+	}
+
+	key := args[0]
+	flags, flagsErr := strconv.ParseUint(args[1], 10, 32)
+	exptime, expErr := strconv.ParseInt(args[2], 10, 64)
+	length, lenErr := strconv.Atoi(args[3])
+	if !validKey(key) || flagsErr != nil || expErr != nil || lenErr != nil || length < 0 {
+		sess.reply("CLIENT_ERROR bad command line format")
+		return nil
+	}
+
+	var casUnique uint64
+	if cmd == "cas" {
+		var err error
+		if casUnique, err = strconv.ParseUint(args[4], 10, 64); err != nil {
+			sess.reply("CLIENT_ERROR bad command line format")
+			return nil
+		}
+	}
+	quiet := noreply(args, minArgs)
+
+	if length > maxValueSize {
+		if _, err := io.CopyN(io.Discard, sess.r, int64(length)+2); err != nil {
+			return err
+		}
+		sess.reply("SERVER_ERROR object too large for cache")
+		return nil
+	}
+
+	data, err := sess.readData(length)
+	if errors.Is(err, errBadDataChunk) {
+		sess.reply("CLIENT_ERROR bad data chunk")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !sess.authenticated {
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 || s.auth.Authenticate(fields[len(fields)-1]) != nil {
+			sess.reply("CLIENT_ERROR authentication failure")
+			return nil
+		}
+		sess.authenticated = true
+		sess.reply("STORED")
+		return nil
+	}
+
+	value := data
+	deadline := expiresAt(exptime, time.Now())
+
+	_, err = store.Update(key, func(current types.Entry, exists bool) (types.Entry, error) {
+		switch cmd {
+		case "add":
+			if exists {
+				return current, types.ErrKeyExists
+			}
+		case "replace":
+			if !exists {
+				return current, types.ErrKeyNotFound
+			}
+		case "append", "prepend":
+			if !exists {
+				return current, types.ErrKeyNotFound
+			}
+			if cmd == "append" {
+				current.Value = append(current.Value, value...)
+			} else {
+				current.Value = append(append([]byte{}, value...), current.Value...)
+			}
+			return current, nil
+		case "cas":
+			if !exists {
+				return current, types.ErrKeyNotFound
+			}
+			if current.Revision != casUnique {
+				return current, types.ErrConflict
+			}
+		}
+
+		return types.Entry{Value: value, Flags: uint32(flags), ExpiresAt: deadline}, nil
+	})
+
+	if quiet {
+		return nil
+	}
+
+	switch {
+	case err == nil:
+		sess.reply("STORED")
+	case errors.Is(err, types.ErrConflict):
+		sess.reply("EXISTS")
+	case cmd == "cas" && errors.Is(err, types.ErrKeyNotFound):
+		sess.reply("NOT_FOUND")
+	case errors.Is(err, types.ErrKeyExists), errors.Is(err, types.ErrKeyNotFound):
+		sess.reply("NOT_STORED")
+	case errors.Is(err, types.ErrValueTooLarge):
+		sess.reply("SERVER_ERROR object too large for cache")
+	case errors.Is(err, types.ErrKeyLimit), errors.Is(err, types.ErrOutOfMemory):
+		sess.reply("SERVER_ERROR out of memory storing object")
+	default:
+		sess.replyf("SERVER_ERROR %v", err)
+	}
+	return nil
+}
+
+func (s *Server) cmdDelete(sess *session, args []string) error {
+	if len(args) < 1 || len(args) > 3 {
+		sess.reply("ERROR")
+		return nil
+	}
+
+	quiet := noreply(args, len(args)-1)
+	if len(args) == 3 || (len(args) == 2 && !quiet) {
+		if args[1] != "0" {
+			sess.reply("CLIENT_ERROR bad command line format.  Usage: delete <key> [noreply]")
+			return nil
+		}
+	}
+
+	if !validKey(args[0]) {
+		sess.reply("CLIENT_ERROR bad command line format")
+		return nil
+	}
+
+	err := s.store.Delete(args[0])
+	if quiet {
+		return nil
+	}
+
+	switch {
+	case err == nil:
+		sess.reply("DELETED")
+	case errors.Is(err, types.ErrKeyNotFound):
+		sess.reply("NOT_FOUND")
+	default:
+		sess.replyf("SERVER_ERROR %v", err)
+	}
+	return nil
+}
+
+func (s *Server) cmdIncr(sess *session, store types.AtomicStore, incr bool, args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		sess.reply("ERROR")
+		return nil
+	}
+
+	if !validKey(args[0]) {
+		sess.reply("CLIENT_ERROR bad command line format")
+		return nil
+	}
+
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		sess.reply("CLIENT_ERROR invalid numeric delta argument")
+		return nil
+	}
+
+	entry, err := store.Update(args[0], func(current types.Entry, exists bool) (types.Entry, error) {
+		if !exists {
+			return current, types.ErrKeyNotFound
+		}
+
+		n, err := strconv.ParseUint(strings.TrimSpace(string(current.Value)), 10, 64)
+		if err != nil {
+			return current, errNonNumeric
+		}
+
+		switch {
+		case incr:
+			n += delta
+		case delta > n:
+			n = 0
+		default:
+			n -= delta
+		}
+
+		current.Value = []byte(strconv.FormatUint(n, 10))
+		return current, nil
+	})
+
+	if noreply(args, 2) {
+		return nil
+	}
+
+	switch {
+	case err == nil:
+		sess.reply(string(entry.Value))
+	case errors.Is(err, types.ErrKeyNotFound):
+		sess.reply("NOT_FOUND")
+	case errors.Is(err, errNonNumeric):
+		sess.replyf("CLIENT_ERROR %v", err)
+	default:
+		sess.replyf("SERVER_ERROR %v", err)
+	}
+	return nil
+}
+
+func (s *Server) cmdTouch(sess *session, args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		sess.reply("ERROR")
+		return nil
+	}
+
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if !validKey(args[0]) || err != nil {
+		sess.reply("CLIENT_ERROR bad command line format")
+		return nil
+	}
+
+	_, err = s.touch(s.store.(types.AtomicStore), args[0], exptime)
+	if noreply(args, 2) {
+		return nil
+	}
+
+	if err != nil {
+		sess.reply("NOT_FOUND")
+		return nil
+	}
+	sess.reply("TOUCHED")
+	return nil
+}
@@ -0,0 +1,144 @@
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/q4ow/qkrn/internal/auth"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const (
+	maxLineLength = 2048
+	maxValueSize  = 1024 * 1024
+	maxKeyLength  = 250
+)
+
+type Server struct {
+	store types.Store
+	port  int
+	auth  *auth.Authenticator
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+func NewServer(store types.Store, port int, authenticator *auth.Authenticator) *Server {
+	return &Server{
+		store: store,
+		port:  port,
+		auth:  authenticator,
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+func (s *Server) Start() error {
+	addr := fmt.Sprintf(":%d", s.port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Starting memcached listener on %s", addr)
+	return s.Serve(listener)
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return net.ErrClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+type session struct {
+	r             *bufio.Reader
+	w             *bufio.Writer
+	authenticated bool
+	quit          bool
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	sess := &session{
+		r:             bufio.NewReader(conn),
+		w:             bufio.NewWriter(conn),
+		authenticated: !s.auth.IsEnabled(),
+	}
+
+	for !sess.quit {
+		line, err := sess.readLine()
+		if err != nil {
+			if errors.Is(err, errLineTooLong) {
+				sess.reply("CLIENT_ERROR line too long")
+				sess.w.Flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("memcached connection: %v", err)
+			}
+			return
+		}
+
+		if err := s.dispatch(sess, line); err != nil {
+			sess.w.Flush()
+			return
+		}
+
+		if sess.r.Buffered() == 0 {
+			if err := sess.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+
+	sess.w.Flush()
+}
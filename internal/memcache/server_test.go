@@ -0,0 +1,183 @@
+package memcache
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q4ow/qkrn/internal/auth"
+	"github.com/q4ow/qkrn/internal/store"
+)
+
+type testConn struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func setupTestServer(t *testing.T, apiKey string) (*store.MemoryStore, string) {
+	t.Helper()
+
+	kvStore := store.NewMemoryStore()
+	server := NewServer(kvStore, 0, auth.NewAuthenticator(apiKey != "", apiKey))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	return kvStore, listener.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *testConn {
+	t.Helper()
+
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	return &testConn{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *testConn) send(lines ...string) {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(strings.Join(lines, "\r\n") + "\r\n")); err != nil {
+		c.t.Fatalf("Failed to write: %v", err)
+	}
+}
+
+func (c *testConn) line() string {
+	c.t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("Failed to read reply: %v", err)
+	}
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+func (c *testConn) expect(want ...string) {
+	c.t.Helper()
+	for _, w := range want {
+		if got := c.line(); got != w {
+			c.t.Errorf("Expected %q, got %q", w, got)
+		}
+	}
+}
+
+func TestMemcacheStorageCommands(t *testing.T) {
+	kvStore, addr := setupTestServer(t, "")
+	c := dial(t, addr)
+
+	c.send("set greeting 5 0 8", "hi there")
+	c.expect("STORED")
+	c.send("get greeting missing")
+	c.expect("VALUE greeting 5 8", "hi there", "END")
+
+	c.send("add greeting 0 0 1", "x")
+	c.expect("NOT_STORED")
+	c.send("replace missing 0 0 1", "x")
+	c.expect("NOT_STORED")
+	c.send("append greeting 0 0 1", "!")
+	c.expect("STORED")
+	c.send("prepend greeting 0 0 3", "oh ")
+	c.expect("STORED")
+	c.send("get greeting")
+	c.expect("VALUE greeting 5 12", "oh hi there!", "END")
+
+	if value, err := kvStore.Get("greeting"); err != nil || string(value) != "oh hi there!" {
+		t.Errorf("Expected value to be shared with the store, got '%s' (%v)", value, err)
+	}
+
+	c.send("set quiet 0 0 1 noreply", "q", "delete greeting", "delete greeting")
+	c.expect("DELETED", "NOT_FOUND")
+
+	c.send("bogus")
+	c.expect("ERROR")
+	c.send("set bad 0 0 2", "toolong")
+	c.expect("CLIENT_ERROR bad data chunk")
+}
+
+func TestMemcacheCAS(t *testing.T) {
+	_, addr := setupTestServer(t, "")
+	c := dial(t, addr)
+
+	c.send("set key 0 0 2", "v1")
+	c.expect("STORED")
+	c.send("gets key")
+	header := strings.Fields(c.line())
+	c.expect("v1", "END")
+	if len(header) != 5 {
+		t.Fatalf("Expected gets header with cas unique, got %v", header)
+	}
+	unique := header[4]
+
+	c.send("cas key 0 0 2 "+unique, "v2")
+	c.expect("STORED")
+	c.send("cas key 0 0 2 "+unique, "v3")
+	c.expect("EXISTS")
+	c.send("cas missing 0 0 2 1", "v3")
+	c.expect("NOT_FOUND")
+	c.send("get key")
+	c.expect("VALUE key 0 2", "v2", "END")
+}
+
+func TestMemcacheIncrDecr(t *testing.T) {
+	_, addr := setupTestServer(t, "")
+	c := dial(t, addr)
+
+	c.send("set counter 0 0 2", "10")
+	c.expect("STORED")
+	c.send("incr counter 5", "decr counter 3", "decr counter 100")
+	c.expect("15", "12", "0")
+	c.send("incr missing 1")
+	c.expect("NOT_FOUND")
+
+	c.send("set text 0 0 3", "abc")
+	c.expect("STORED")
+	c.send("incr text 1")
+	c.expect("CLIENT_ERROR cannot increment or decrement non-numeric value")
+}
+
+func TestMemcacheExpiry(t *testing.T) {
+	kvStore, addr := setupTestServer(t, "")
+	c := dial(t, addr)
+
+	c.send("set short 0 -1 1", "v")
+	c.expect("STORED")
+	c.send("get short")
+	c.expect("END")
+
+	c.send("set key 0 0 1", "v", "touch key 100", "touch missing 100")
+	c.expect("STORED", "TOUCHED", "NOT_FOUND")
+	if ttl, ok, err := kvStore.TTL("key"); err != nil || !ok || ttl <= 0 {
+		t.Errorf("Expected touch to set a TTL, got %v %v %v", ttl, ok, err)
+	}
+
+	c.send("gat 0 key")
+	c.expect("VALUE key 0 1", "v", "END")
+	if _, ok, _ := kvStore.TTL("key"); ok {
+		t.Error("Expected gat with exptime 0 to clear the TTL")
+	}
+}
+
+func TestMemcacheAuth(t *testing.T) {
+	_, addr := setupTestServer(t, "secret")
+	c := dial(t, addr)
+
+	c.send("get key")
+	c.expect("CLIENT_ERROR unauthenticated")
+	c.send("set auth 0 0 10", "user wrong")
+	c.expect("CLIENT_ERROR authentication failure")
+	c.send("set auth 0 0 11", "user secret")
+	c.expect("STORED")
+	c.send("set key 0 0 5", "value", "get key")
+	c.expect("STORED", "VALUE key 0 5", "value", "END")
+}
@@ -0,0 +1,174 @@
+package namespace
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const Default = "default"
+
+var (
+	ErrNotFound          = errors.New("namespace not found")
+	ErrInvalidName       = errors.New("invalid namespace name")
+	ErrDeleteDefault     = errors.New("the default namespace cannot be deleted")
+	ErrLimitsUnsupported = errors.New("storage backend does not support namespace limits")
+)
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+type Namespace struct {
+	Name  string
+	Store types.Store
+}
+
+func (n *Namespace) Limits() types.Limits {
+	if limited, ok := n.Store.(types.LimitedStore); ok {
+		return limited.Limits()
+	}
+	return types.Limits{}
+}
+
+func (n *Namespace) Info() types.NamespaceInfo {
+	limits := n.Limits()
+	info := types.NamespaceInfo{
+		Name:         n.Name,
+		MaxKeys:      limits.MaxKeys,
+		MaxValueSize: limits.MaxValueSize,
+		MaxMemory:    limits.MaxMemory,
+		Keys:         len(n.Store.Keys()),
+	}
+	if limits.DefaultTTL > 0 {
+		info.DefaultTTL = limits.DefaultTTL.String()
+	}
+	if limits.MaxMemory > 0 {
+		info.EvictionPolicy = string(limits.Eviction)
+		if info.EvictionPolicy == "" {
+			info.EvictionPolicy = string(types.EvictNone)
+		}
+	}
+	if stats, ok := n.Store.(types.StatsStore); ok {
+		info.MemoryUsed = stats.Stats().MemoryUsed
+	}
+	return info
+}
+
+type Registry struct {
+	mu         sync.RWMutex
+	namespaces map[string]*Namespace
+	newStore   func(name string) (types.Store, error)
+}
+
+func NewRegistry(defaultStore types.Store) *Registry {
+	return &Registry{
+		namespaces: map[string]*Namespace{
+			Default: {Name: Default, Store: defaultStore},
+		},
+		newStore: func(string) (types.Store, error) { return store.NewMemoryStore(), nil },
+	}
+}
+
+func (r *Registry) SetStoreFactory(newStore func(name string) (types.Store, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.newStore = newStore
+}
+
+func ValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
+func (r *Registry) Get(name string) (*Namespace, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ns, ok := r.namespaces[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return ns, nil
+}
+
+func (r *Registry) Default() *Namespace {
+	ns, _ := r.Get(Default)
+	return ns
+}
+
+func (r *Registry) Ensure(name string, limits types.Limits) (*Namespace, bool, error) {
+	if !ValidName(name) {
+		return nil, false, ErrInvalidName
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ns, exists := r.namespaces[name]
+	if !exists {
+		s, err := r.newStore(name)
+		if err != nil {
+			return nil, false, err
+		}
+		ns = &Namespace{Name: name, Store: s}
+	}
+
+	limited, ok := ns.Store.(types.LimitedStore)
+	switch {
+	case ok:
+		limited.SetLimits(limits)
+	case limits != types.Limits{}:
+		if c, ok := ns.Store.(io.Closer); ok && !exists {
+			c.Close()
+		}
+		return nil, false, ErrLimitsUnsupported
+	}
+
+	r.namespaces[name] = ns
+	return ns, !exists, nil
+}
+
+func (r *Registry) Delete(name string) error {
+	if name == Default {
+		return ErrDeleteDefault
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ns, ok := r.namespaces[name]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(r.namespaces, name)
+
+	if d, ok := ns.Store.(interface{ Drop() error }); ok {
+		return d.Drop()
+	}
+	return nil
+}
+
+func (r *Registry) List() []*Namespace {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]*Namespace, 0, len(r.namespaces))
+	for _, ns := range r.namespaces {
+		list = append(list, ns)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (r *Registry) PurgeExpired() int {
+	purged := 0
+	for _, ns := range r.List() {
+		if p, ok := ns.Store.(interface{ PurgeExpired() int }); ok {
+			purged += p.PurgeExpired()
+		}
+	}
+	return purged
+}
@@ -0,0 +1,92 @@
+package namespace
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func TestRegistry(t *testing.T) {
+	defaultStore := store.NewMemoryStore()
+	registry := NewRegistry(defaultStore)
+
+	if ns := registry.Default(); ns == nil || ns.Store != defaultStore {
+		t.Fatalf("Expected default namespace to use the given store, got %+v", ns)
+	}
+
+	limits := types.Limits{DefaultTTL: time.Hour, MaxKeys: 10}
+	billing, created, err := registry.Ensure("billing", limits)
+	if err != nil || !created {
+		t.Fatalf("Expected namespace to be created, got %v %v", created, err)
+	}
+	if billing.Limits() != limits {
+		t.Errorf("Expected limits %+v, got %+v", limits, billing.Limits())
+	}
+
+	billing.Store.Set("key", []byte("value"))
+	if _, err := defaultStore.Get("key"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected namespaces to be isolated, got %v", err)
+	}
+
+	again, created, err := registry.Ensure("billing", types.Limits{MaxKeys: 5})
+	if err != nil || created || again != billing || again.Limits().MaxKeys != 5 {
+		t.Errorf("Expected settings update in place, got %+v %v %v", again, created, err)
+	}
+	if info := again.Info(); info.Keys != 1 || info.DefaultTTL != "" {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+
+	if _, _, err := registry.Ensure("Bad Name", types.Limits{}); err != ErrInvalidName {
+		t.Errorf("Expected ErrInvalidName, got %v", err)
+	}
+
+	if list := registry.List(); len(list) != 2 || list[0].Name != "billing" || list[1].Name != Default {
+		t.Errorf("Expected sorted namespaces, got %v", list)
+	}
+
+	if err := registry.Delete(Default); err != ErrDeleteDefault {
+		t.Errorf("Expected ErrDeleteDefault, got %v", err)
+	}
+	if err := registry.Delete("billing"); err != nil {
+		t.Errorf("Failed to delete namespace: %v", err)
+	}
+	if _, err := registry.Get("billing"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+type droppableStore struct {
+	*store.MemoryStore
+	dropped bool
+}
+
+func (d *droppableStore) Drop() error {
+	d.dropped = true
+	return nil
+}
+
+func TestRegistryStoreFactory(t *testing.T) {
+	registry := NewRegistry(store.NewMemoryStore())
+
+	failure := errors.New("disk full")
+	registry.SetStoreFactory(func(name string) (types.Store, error) { return nil, failure })
+	if _, _, err := registry.Ensure("billing", types.Limits{}); err != failure {
+		t.Errorf("Expected the factory error, got %v", err)
+	}
+	if _, err := registry.Get("billing"); err != ErrNotFound {
+		t.Errorf("Expected no namespace after a failed create, got %v", err)
+	}
+
+	var created *droppableStore
+	registry.SetStoreFactory(func(name string) (types.Store, error) {
+		created = &droppableStore{MemoryStore: store.NewMemoryStore()}
+		return created, nil
+	})
+	registry.Ensure("billing", types.Limits{})
+	if err := registry.Delete("billing"); err != nil || !created.dropped {
+		t.Errorf("Expected deleting a namespace to drop its store, got %v", err)
+	}
+}
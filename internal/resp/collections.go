@@ -0,0 +1,301 @@
+package resp
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func (s *Server) lists(w *writer) (types.ListStore, bool) {
+	store, ok := s.store.(types.ListStore)
+	if !ok {
+		w.error("ERR lists are not supported by the storage backend")
+	}
+	return store, ok
+}
+
+func (s *Server) sets(w *writer) (types.SetStore, bool) {
+	store, ok := s.store.(types.SetStore)
+	if !ok {
+		w.error("ERR sets are not supported by the storage backend")
+	}
+	return store, ok
+}
+
+func (s *Server) hashes(w *writer) (types.HashStore, bool) {
+	store, ok := s.store.(types.HashStore)
+	if !ok {
+		w.error("ERR hashes are not supported by the storage backend")
+	}
+	return store, ok
+}
+
+func cmdType(s *Server, sess *session, w *writer, args []string) {
+	atomic, ok := s.store.(types.AtomicStore)
+	if !ok {
+		w.error("ERR TYPE is not supported by the storage backend")
+		return
+	}
+
+	entry, err := atomic.GetEntry(args[1])
+	switch {
+	case errors.Is(err, types.ErrKeyNotFound):
+		w.simple("none")
+	case err != nil:
+		writeStoreError(w, err)
+	case entry.Type == "":
+		w.simple(string(types.TypeString))
+	default:
+		w.simple(string(entry.Type))
+	}
+}
+
+func cmdLen(s *Server, sess *session, w *writer, args []string) {
+	atomic, ok := s.store.(types.AtomicStore)
+	if !ok {
+		w.errorf("ERR %s is not supported by the storage backend", strings.ToUpper(args[0]))
+		return
+	}
+
+	kind := map[string]types.ValueType{
+		"llen":  types.TypeList,
+		"scard": types.TypeSet,
+		"hlen":  types.TypeHash,
+	}[strings.ToLower(args[0])]
+
+	entry, err := atomic.GetEntry(args[1])
+	switch {
+	case errors.Is(err, types.ErrKeyNotFound):
+		w.integer(0)
+	case err != nil:
+		writeStoreError(w, err)
+	case entry.Type != kind:
+		writeStoreError(w, types.ErrWrongType)
+	default:
+		w.integer(int64(entry.Length))
+	}
+}
+
+func cmdPush(s *Server, sess *session, w *writer, args []string) {
+	lists, ok := s.lists(w)
+	if !ok {
+		return
+	}
+
+	values := make([][]byte, 0, len(args)-2)
+	for _, v := range args[2:] {
+		values = append(values, []byte(v))
+	}
+
+	n, err := lists.ListPush(args[1], values, strings.ToLower(args[0]) == "lpush")
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+func cmdPop(s *Server, sess *session, w *writer, args []string) {
+	lists, ok := s.lists(w)
+	if !ok {
+		return
+	}
+	if len(args) > 3 {
+		w.error("ERR syntax error")
+		return
+	}
+
+	count := 1
+	if len(args) == 3 {
+		var err error
+		if count, err = strconv.Atoi(args[2]); err != nil || count < 0 {
+			w.error("ERR value is out of range, must be positive")
+			return
+		}
+		if count == 0 {
+			w.array(0)
+			return
+		}
+	}
+
+	values, err := lists.ListPop(args[1], count, strings.ToLower(args[0]) == "lpop")
+	switch {
+	case errors.Is(err, types.ErrKeyNotFound):
+		w.null()
+	case err != nil:
+		writeStoreError(w, err)
+	case len(args) == 2:
+		w.bulk(string(values[0]))
+	default:
+		w.array(len(values))
+		for _, v := range values {
+			w.bulk(string(v))
+		}
+	}
+}
+
+func cmdLRange(s *Server, sess *session, w *writer, args []string) {
+	lists, ok := s.lists(w)
+	if !ok {
+		return
+	}
+
+	start, err1 := strconv.Atoi(args[2])
+	stop, err2 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil {
+		w.error("ERR value is not an integer or out of range")
+		return
+	}
+
+	values, err := lists.ListRange(args[1], start, stop)
+	if err != nil && !errors.Is(err, types.ErrKeyNotFound) {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.array(len(values))
+	for _, v := range values {
+		w.bulk(string(v))
+	}
+}
+
+func cmdSAdd(s *Server, sess *session, w *writer, args []string) {
+	sets, ok := s.sets(w)
+	if !ok {
+		return
+	}
+
+	n, err := sets.SetAdd(args[1], args[2:]...)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+func cmdSRem(s *Server, sess *session, w *writer, args []string) {
+	sets, ok := s.sets(w)
+	if !ok {
+		return
+	}
+
+	n, err := sets.SetRemove(args[1], args[2:]...)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+func cmdSMembers(s *Server, sess *session, w *writer, args []string) {
+	sets, ok := s.sets(w)
+	if !ok {
+		return
+	}
+
+	members, err := sets.SetMembers(args[1])
+	if err != nil && !errors.Is(err, types.ErrKeyNotFound) {
+		writeStoreError(w, err)
+		return
+	}
+	w.bulks(members)
+}
+
+func cmdSIsMember(s *Server, sess *session, w *writer, args []string) {
+	sets, ok := s.sets(w)
+	if !ok {
+		return
+	}
+
+	isMember, err := sets.SetIsMember(args[1], args[2])
+	switch {
+	case err != nil:
+		writeStoreError(w, err)
+	case isMember:
+		w.integer(1)
+	default:
+		w.integer(0)
+	}
+}
+
+func cmdHSet(s *Server, sess *session, w *writer, args []string) {
+	hashes, ok := s.hashes(w)
+	if !ok {
+		return
+	}
+	if len(args)%2 != 0 {
+		w.errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(args[0]))
+		return
+	}
+
+	fields := make(map[string][]byte, (len(args)-2)/2)
+	for i := 2; i < len(args); i += 2 {
+		fields[args[i]] = []byte(args[i+1])
+	}
+
+	n, err := hashes.HashSet(args[1], fields)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+func cmdHGet(s *Server, sess *session, w *writer, args []string) {
+	hashes, ok := s.hashes(w)
+	if !ok {
+		return
+	}
+
+	value, err := hashes.HashGet(args[1], args[2])
+	switch {
+	case errors.Is(err, types.ErrKeyNotFound):
+		w.null()
+	case err != nil:
+		writeStoreError(w, err)
+	default:
+		w.bulk(string(value))
+	}
+}
+
+func cmdHDel(s *Server, sess *session, w *writer, args []string) {
+	hashes, ok := s.hashes(w)
+	if !ok {
+		return
+	}
+
+	n, err := hashes.HashDelete(args[1], args[2:]...)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+func cmdHGetAll(s *Server, sess *session, w *writer, args []string) {
+	hashes, ok := s.hashes(w)
+	if !ok {
+		return
+	}
+
+	fields, err := hashes.HashGetAll(args[1])
+	if err != nil && !errors.Is(err, types.ErrKeyNotFound) {
+		writeStoreError(w, err)
+		return
+	}
+
+	names := make([]string, 0, len(fields))
+	for f := range fields {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+
+	w.mapHeader(len(names))
+	for _, f := range names {
+		w.bulk(f)
+		w.bulk(string(fields[f]))
+	}
+}
@@ -0,0 +1,675 @@
+package resp
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+type handler func(s *Server, sess *session, w *writer, args []string)
+
+type commandSpec struct {
+	handler handler
+	arity   int
+	noAuth  bool
+}
+
+var commandTable = map[string]commandSpec{
+	"ping":    {handler: cmdPing, arity: -1},
+	"echo":    {handler: cmdEcho, arity: 2},
+	"quit":    {handler: cmdQuit, arity: 1, noAuth: true},
+	"auth":    {handler: cmdAuth, arity: -2, noAuth: true},
+	"hello":   {handler: cmdHello, arity: -1, noAuth: true},
+	"select":  {handler: cmdSelect, arity: 2},
+	"command": {handler: cmdCommand, arity: -1},
+	"client":  {handler: cmdClient, arity: -2},
+	"get":     {handler: cmdGet, arity: 2},
+	"set":     {handler: cmdSet, arity: -3},
+	"del":     {handler: cmdDel, arity: -2},
+	"exists":  {handler: cmdExists, arity: -2},
+	"keys":    {handler: cmdKeys, arity: 2},
+	"scan":    {handler: cmdScan, arity: -2},
+	"dbsize":  {handler: cmdDBSize, arity: 1},
+	"expire":  {handler: cmdExpire, arity: 3},
+	"pexpire": {handler: cmdExpire, arity: 3},
+	"persist": {handler: cmdPersist, arity: 2},
+	"ttl":     {handler: cmdTTL, arity: 2},
+	"pttl":    {handler: cmdTTL, arity: 2},
+	"mget":    {handler: cmdMGet, arity: -2},
+	"mset":    {handler: cmdMSet, arity: -3},
+
+	"incr":        {handler: cmdIncr, arity: 2},
+	"decr":        {handler: cmdIncr, arity: 2},
+	"incrby":      {handler: cmdIncr, arity: 3},
+	"decrby":      {handler: cmdIncr, arity: 3},
+	"incrbyfloat": {handler: cmdIncrByFloat, arity: 3},
+
+	"type":      {handler: cmdType, arity: 2},
+	"lpush":     {handler: cmdPush, arity: -3},
+	"rpush":     {handler: cmdPush, arity: -3},
+	"lpop":      {handler: cmdPop, arity: -2},
+	"rpop":      {handler: cmdPop, arity: -2},
+	"lrange":    {handler: cmdLRange, arity: 4},
+	"llen":      {handler: cmdLen, arity: 2},
+	"sadd":      {handler: cmdSAdd, arity: -3},
+	"srem":      {handler: cmdSRem, arity: -3},
+	"smembers":  {handler: cmdSMembers, arity: 2},
+	"sismember": {handler: cmdSIsMember, arity: 3},
+	"scard":     {handler: cmdLen, arity: 2},
+	"hset":      {handler: cmdHSet, arity: -4},
+	"hget":      {handler: cmdHGet, arity: 3},
+	"hdel":      {handler: cmdHDel, arity: -3},
+	"hgetall":   {handler: cmdHGetAll, arity: 2},
+	"hlen":      {handler: cmdLen, arity: 2},
+}
+
+func (s *Server) dispatch(sess *session, w *writer, args []string) {
+	name := strings.ToLower(args[0])
+	spec, ok := commandTable[name]
+	if !ok {
+		w.errorf("ERR unknown command '%s'", args[0])
+		return
+	}
+
+	if !sess.authenticated && !spec.noAuth {
+		w.error("NOAUTH Authentication required.")
+		return
+	}
+
+	if (spec.arity > 0 && len(args) != spec.arity) || (spec.arity < 0 && len(args) < -spec.arity) {
+		w.errorf("ERR wrong number of arguments for '%s' command", name)
+		return
+	}
+
+	spec.handler(s, sess, w, args)
+}
+
+func (s *Server) counters(w *writer) (types.CounterStore, bool) {
+	store, ok := s.store.(types.CounterStore)
+	if !ok {
+		w.error("ERR counters are not supported by the storage backend")
+	}
+	return store, ok
+}
+
+func (s *Server) expiring(w *writer) (types.ExpiringStore, bool) {
+	store, ok := s.store.(types.ExpiringStore)
+	if !ok {
+		w.error("ERR expiry is not supported by the storage backend")
+	}
+	return store, ok
+}
+
+func writeStoreError(w *writer, err error) {
+	switch {
+	case errors.Is(err, types.ErrEmptyKey):
+		w.error("ERR key cannot be empty")
+	case errors.Is(err, types.ErrKeyLimit):
+		w.error("OOM key limit reached")
+	case errors.Is(err, types.ErrOutOfMemory):
+		w.error("OOM command not allowed when used memory > 'maxmemory'")
+	case errors.Is(err, types.ErrWrongType):
+		w.error("WRONGTYPE Operation against a key holding the wrong kind of value")
+	default:
+		w.errorf("ERR %v", err)
+	}
+}
+
+func cmdPing(s *Server, sess *session, w *writer, args []string) {
+	switch len(args) {
+	case 1:
+		w.simple("PONG")
+	case 2:
+		w.bulk(args[1])
+	default:
+		w.error("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func cmdEcho(s *Server, sess *session, w *writer, args []string) {
+	w.bulk(args[1])
+}
+
+func cmdQuit(s *Server, sess *session, w *writer, args []string) {
+	sess.quit = true
+	w.simple("OK")
+}
+
+func (s *Server) authenticate(sess *session, w *writer, password string) bool {
+	if err := s.auth.Authenticate(password); err != nil {
+		w.error("WRONGPASS invalid username-password pair or user is disabled.")
+		return false
+	}
+	sess.authenticated = true
+	return true
+}
+
+func cmdAuth(s *Server, sess *session, w *writer, args []string) {
+	if len(args) > 3 {
+		w.error("ERR syntax error")
+		return
+	}
+
+	if s.authenticate(sess, w, args[len(args)-1]) {
+		w.simple("OK")
+	}
+}
+
+func cmdHello(s *Server, sess *session, w *writer, args []string) {
+	proto := w.proto
+	if len(args) > 1 {
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			w.error("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if version != 2 && version != 3 {
+			w.error("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = version
+	}
+
+	for i := 2; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "auth":
+			if i+2 >= len(args) {
+				w.error("ERR syntax error")
+				return
+			}
+			if !s.authenticate(sess, w, args[i+2]) {
+				return
+			}
+			i += 2
+		case "setname":
+			if i+1 >= len(args) {
+				w.error("ERR syntax error")
+				return
+			}
+			i++
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+
+	if !sess.authenticated {
+		w.error("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+		return
+	}
+
+	w.proto = proto
+	w.mapHeader(7)
+	w.bulk("server")
+	w.bulk("qkrn")
+	w.bulk("version")
+	w.bulk("0.1.0")
+	w.bulk("proto")
+	w.integer(int64(proto))
+	w.bulk("id")
+	w.integer(sess.id)
+	w.bulk("mode")
+	w.bulk("standalone")
+	w.bulk("role")
+	w.bulk("master")
+	w.bulk("modules")
+	w.array(0)
+}
+
+func cmdSelect(s *Server, sess *session, w *writer, args []string) {
+	if args[1] != "0" {
+		w.error("ERR DB index is out of range")
+		return
+	}
+	w.simple("OK")
+}
+
+func cmdCommand(s *Server, sess *session, w *writer, args []string) {
+	w.array(0)
+}
+
+func cmdClient(s *Server, sess *session, w *writer, args []string) {
+	switch strings.ToLower(args[1]) {
+	case "setname", "setinfo", "no-evict", "no-touch":
+		w.simple("OK")
+	case "id":
+		w.integer(sess.id)
+	case "getname":
+		w.null()
+	default:
+		w.errorf("ERR unknown subcommand '%s'", args[1])
+	}
+}
+
+func cmdGet(s *Server, sess *session, w *writer, args []string) {
+	value, err := s.store.Get(args[1])
+	if errors.Is(err, types.ErrKeyNotFound) {
+		w.null()
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.bulk(string(value))
+}
+
+func cmdSet(s *Server, sess *session, w *writer, args []string) {
+	var opts types.SetOptions
+	conditional, expires := false, false
+
+	for i := 3; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); option {
+		case "NX":
+			opts.OnlyIfAbsent = true
+			conditional = true
+		case "XX":
+			opts.OnlyIfExists = true
+			conditional = true
+		case "KEEPTTL":
+			opts.KeepTTL = true
+			expires = true
+		case "EX", "PX":
+			if i+1 >= len(args) {
+				w.error("ERR syntax error")
+				return
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				w.error("ERR value is not an integer or out of range")
+				return
+			}
+			unit := time.Second
+			if option == "PX" {
+				unit = time.Millisecond
+			}
+			if n <= 0 || n > math.MaxInt64/int64(unit) {
+				w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			opts.TTL = time.Duration(n) * unit
+			expires = true
+			i++
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+
+	if (opts.OnlyIfAbsent && opts.OnlyIfExists) || (opts.KeepTTL && opts.TTL > 0) {
+		w.error("ERR syntax error")
+		return
+	}
+
+	var err error
+	if conditional || expires {
+		store, ok := s.expiring(w)
+		if !ok {
+			return
+		}
+		err = store.SetWithOptions(args[1], []byte(args[2]), opts)
+	} else {
+		err = s.store.Set(args[1], []byte(args[2]))
+	}
+
+	switch {
+	case err == nil:
+		w.simple("OK")
+	case errors.Is(err, types.ErrKeyExists), errors.Is(err, types.ErrKeyNotFound):
+		w.null()
+	default:
+		writeStoreError(w, err)
+	}
+}
+
+func cmdDel(s *Server, sess *session, w *writer, args []string) {
+	var deleted int64
+	for _, key := range args[1:] {
+		if err := s.store.Delete(key); err == nil {
+			deleted++
+		}
+	}
+	w.integer(deleted)
+}
+
+func cmdExists(s *Server, sess *session, w *writer, args []string) {
+	var count int64
+	for _, key := range args[1:] {
+		if _, err := s.store.Get(key); err == nil || errors.Is(err, types.ErrWrongType) {
+			count++
+		}
+	}
+	w.integer(count)
+}
+
+func (s *Server) sortedKeys() []string {
+	keys := s.store.Keys()
+	sort.Strings(keys)
+	return keys
+}
+
+func cmdKeys(s *Server, sess *session, w *writer, args []string) {
+	matched := []string{}
+	for _, key := range s.sortedKeys() {
+		if globMatch(args[1], key) {
+			matched = append(matched, key)
+		}
+	}
+	w.bulks(matched)
+}
+
+const maxCursors = 4096
+
+type cursors struct {
+	mu    sync.Mutex
+	next  uint64
+	keys  map[uint64]string
+	order []uint64
+}
+
+func (c *cursors) save(key string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.next++
+	c.keys[c.next] = key
+	c.order = append(c.order, c.next)
+	if len(c.order) > maxCursors {
+		delete(c.keys, c.order[0])
+		c.order = c.order[1:]
+	}
+	return c.next
+}
+
+func (c *cursors) load(id uint64) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, ok := c.keys[id]
+	return key, ok
+}
+
+func (s *Server) keysAfter(after string, count int) ([]string, bool) {
+	var keys []string
+	if rs, ok := s.store.(types.RangeStore); ok {
+		if _, collections := s.store.(types.ListStore); !collections {
+			more := false
+			rs.Scan(after+"\x00", "", func(kv types.KeyValue) bool {
+				if len(keys) == count {
+					more = true
+					return false
+				}
+				keys = append(keys, kv.Key)
+				return true
+			})
+			return keys, more
+		}
+	}
+
+	for _, key := range s.store.Keys() {
+		if key <= after || (len(keys) > count && key >= keys[count]) {
+			continue
+		}
+		i, _ := slices.BinarySearch(keys, key)
+		keys = slices.Insert(keys, i, key)
+		if len(keys) > count+1 {
+			keys = keys[:count+1]
+		}
+	}
+	if len(keys) > count {
+		return keys[:count], true
+	}
+	return keys, false
+}
+
+func (s *Server) keyType(key string) types.ValueType {
+	atomic, ok := s.store.(types.AtomicStore)
+	if !ok {
+		return types.TypeString
+	}
+	entry, err := atomic.GetEntry(key)
+	switch {
+	case err != nil:
+		return ""
+	case entry.Type == "":
+		return types.TypeString
+	}
+	return entry.Type
+}
+
+func cmdScan(s *Server, sess *session, w *writer, args []string) {
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		w.error("ERR invalid cursor")
+		return
+	}
+	after := ""
+	if cursor != 0 {
+		var ok bool
+		if after, ok = s.cursors.load(cursor); !ok {
+			w.error("ERR invalid cursor")
+			return
+		}
+	}
+
+	pattern, kind, count := "*", "", 10
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			w.error("ERR syntax error")
+			return
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 1 {
+				w.error("ERR value is not an integer or out of range")
+				return
+			}
+		case "TYPE":
+			kind = strings.ToLower(args[i+1])
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+
+	keys, more := s.keysAfter(after, count)
+	matched := []string{}
+	for _, key := range keys {
+		if globMatch(pattern, key) && (kind == "" || string(s.keyType(key)) == kind) {
+			matched = append(matched, key)
+		}
+	}
+
+	next := uint64(0)
+	if more {
+		next = s.cursors.save(keys[len(keys)-1])
+	}
+	w.array(2)
+	w.bulk(strconv.FormatUint(next, 10))
+	w.bulks(matched)
+}
+
+func cmdDBSize(s *Server, sess *session, w *writer, args []string) {
+	w.integer(int64(len(s.store.Keys())))
+}
+
+func cmdExpire(s *Server, sess *session, w *writer, args []string) {
+	store, ok := s.expiring(w)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		w.error("ERR value is not an integer or out of range")
+		return
+	}
+
+	unit := time.Second
+	if strings.EqualFold(args[0], "pexpire") {
+		unit = time.Millisecond
+	}
+	if n > math.MaxInt64/int64(unit) {
+		w.error("ERR invalid expire time in '" + strings.ToLower(args[0]) + "' command")
+		return
+	}
+
+	if err := store.Expire(args[1], time.Duration(n)*unit); err != nil {
+		if errors.Is(err, types.ErrKeyNotFound) {
+			w.integer(0)
+			return
+		}
+		writeStoreError(w, err)
+		return
+	}
+	w.integer(1)
+}
+
+func cmdPersist(s *Server, sess *session, w *writer, args []string) {
+	store, ok := s.expiring(w)
+	if !ok {
+		return
+	}
+
+	_, hasTTL, err := store.TTL(args[1])
+	if err != nil || !hasTTL {
+		w.integer(0)
+		return
+	}
+
+	if err := store.Persist(args[1]); err != nil {
+		w.integer(0)
+		return
+	}
+	w.integer(1)
+}
+
+func cmdTTL(s *Server, sess *session, w *writer, args []string) {
+	store, ok := s.expiring(w)
+	if !ok {
+		return
+	}
+
+	ttl, hasTTL, err := store.TTL(args[1])
+	switch {
+	case errors.Is(err, types.ErrKeyNotFound):
+		w.integer(-2)
+	case err != nil:
+		writeStoreError(w, err)
+	case !hasTTL:
+		w.integer(-1)
+	case strings.EqualFold(args[0], "pttl"):
+		w.integer(ttl.Milliseconds())
+	default:
+		w.integer(int64((ttl + 500*time.Millisecond) / time.Second))
+	}
+}
+
+func cmdMGet(s *Server, sess *session, w *writer, args []string) {
+	w.array(len(args) - 1)
+	for _, key := range args[1:] {
+		value, err := s.store.Get(key)
+		if err != nil {
+			w.null()
+			continue
+		}
+		w.bulk(string(value))
+	}
+}
+
+func cmdMSet(s *Server, sess *session, w *writer, args []string) {
+	if len(args)%2 != 1 {
+		w.error("ERR wrong number of arguments for 'mset' command")
+		return
+	}
+
+	var err error
+	if txnStore, ok := s.store.(types.TxnStore); ok {
+		err = txnStore.Txn(func(tx types.Tx) error {
+			for i := 1; i < len(args); i += 2 {
+				if _, err := tx.Put(args[i], types.Entry{Value: []byte(args[i+1])}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	} else {
+		for i := 1; i < len(args) && err == nil; i += 2 {
+			err = s.store.Set(args[i], []byte(args[i+1]))
+		}
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.simple("OK")
+}
+
+func cmdIncr(s *Server, sess *session, w *writer, args []string) {
+	counters, ok := s.counters(w)
+	if !ok {
+		return
+	}
+
+	delta := int64(1)
+	if len(args) == 3 {
+		var err error
+		if delta, err = strconv.ParseInt(args[2], 10, 64); err != nil {
+			w.error("ERR value is not an integer or out of range")
+			return
+		}
+	}
+	if name := strings.ToLower(args[0]); name == "decr" || name == "decrby" {
+		if delta == math.MinInt64 {
+			w.error("ERR decrement would overflow")
+			return
+		}
+		delta = -delta
+	}
+
+	n, err := counters.Incr(args[1], delta, types.IncrOptions{})
+	switch {
+	case errors.Is(err, types.ErrNotNumber):
+		w.error("ERR value is not an integer or out of range")
+	case errors.Is(err, types.ErrOutOfRange):
+		w.error("ERR increment or decrement would overflow")
+	case err != nil:
+		writeStoreError(w, err)
+	default:
+		w.integer(n)
+	}
+}
+
+func cmdIncrByFloat(s *Server, sess *session, w *writer, args []string) {
+	counters, ok := s.counters(w)
+	if !ok {
+		return
+	}
+
+	delta, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		w.error("ERR value is not a valid float")
+		return
+	}
+
+	f, err := counters.IncrFloat(args[1], delta, types.IncrFloatOptions{})
+	switch {
+	case errors.Is(err, types.ErrNotNumber):
+		w.error("ERR value is not a valid float")
+	case errors.Is(err, types.ErrOutOfRange):
+		w.error("ERR increment would produce NaN or Infinity")
+	case err != nil:
+		writeStoreError(w, err)
+	default:
+		w.bulk(strconv.FormatFloat(f, 'f', -1, 64))
+	}
+}
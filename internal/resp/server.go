@@ -0,0 +1,149 @@
+package resp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/q4ow/qkrn/internal/auth"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+type Server struct {
+	store types.Store
+	port  int
+	auth  *auth.Authenticator
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	nextID   int64
+
+	cursors cursors
+}
+
+func NewServer(store types.Store, port int, authenticator *auth.Authenticator) *Server {
+	return &Server{
+		store: store,
+		port:  port,
+		auth:  authenticator,
+		conns: make(map[net.Conn]struct{}),
+		cursors: cursors{
+			keys: make(map[uint64]string),
+		},
+	}
+}
+
+func (s *Server) Start() error {
+	addr := fmt.Sprintf(":%d", s.port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Starting RESP listener on %s", addr)
+	return s.Serve(listener)
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return net.ErrClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.nextID++
+		id := s.nextID
+		s.mu.Unlock()
+
+		go s.handleConn(conn, id)
+	}
+}
+
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+type session struct {
+	id            int64
+	authenticated bool
+	quit          bool
+}
+
+func (s *Server) handleConn(conn net.Conn, id int64) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	r := newReader(conn)
+	w := newWriter(conn)
+	sess := &session{
+		id:            id,
+		authenticated: !s.auth.IsEnabled(),
+	}
+
+	for !sess.quit {
+		if sess.authenticated {
+			r.maxBulk, r.maxArray = maxBulkLength, maxArrayLength
+		}
+		args, err := r.readCommand()
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				w.errorf("ERR %v", err)
+				w.flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("RESP connection %d: %v", id, err)
+			}
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		s.dispatch(sess, w, args)
+
+		if r.r.Buffered() == 0 {
+			if err := w.flush(); err != nil {
+				return
+			}
+		}
+	}
+
+	w.flush()
+}
@@ -0,0 +1,419 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q4ow/qkrn/internal/auth"
+	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+type respError string
+
+type testConn struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func setupTestServer(t *testing.T, apiKey string) (*Server, *store.MemoryStore, string) {
+	t.Helper()
+
+	kvStore := store.NewMemoryStore()
+	server := NewServer(kvStore, 0, auth.NewAuthenticator(apiKey != "", apiKey))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	return server, kvStore, listener.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *testConn {
+	t.Helper()
+
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	return &testConn{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *testConn) send(args ...string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		c.t.Fatalf("Failed to write: %v", err)
+	}
+}
+
+func (c *testConn) do(args ...string) interface{} {
+	c.t.Helper()
+	c.send(args...)
+	return c.read()
+}
+
+func (c *testConn) read() interface{} {
+	c.t.Helper()
+
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("Failed to read reply: %v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return respError(line[1:])
+	case ':':
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+		return n
+	case '_':
+		return nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			c.t.Fatalf("Failed to read bulk: %v", err)
+		}
+		return string(buf[:n])
+	case '*', '%':
+		n, _ := strconv.Atoi(line[1:])
+		if line[0] == '%' {
+			n *= 2
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			items[i] = c.read()
+		}
+		return items
+	}
+
+	c.t.Fatalf("Unexpected reply: %q", line)
+	return nil
+}
+
+func expect(t *testing.T, got, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %#v, got %#v", want, got)
+	}
+}
+
+func TestRESPBasicCommands(t *testing.T) {
+	_, kvStore, addr := setupTestServer(t, "")
+	c := dial(t, addr)
+
+	expect(t, c.do("PING"), "PONG")
+	expect(t, c.do("PING", "hello"), "hello")
+	expect(t, c.do("SET", "greeting", "hi there"), "OK")
+	expect(t, c.do("GET", "greeting"), "hi there")
+	expect(t, c.do("GET", "missing"), nil)
+	expect(t, c.do("EXISTS", "greeting", "missing", "greeting"), int64(2))
+	expect(t, c.do("MSET", "a", "1", "b", "2"), "OK")
+	expect(t, c.do("MGET", "a", "missing", "b"), []interface{}{"1", nil, "2"})
+	expect(t, c.do("KEYS", "[ab]"), []interface{}{"a", "b"})
+	expect(t, c.do("DBSIZE"), int64(3))
+	expect(t, c.do("DEL", "a", "b", "missing"), int64(2))
+
+	if value, err := kvStore.Get("greeting"); err != nil || string(value) != "hi there" {
+		t.Errorf("Expected value to be shared with the store, got '%s' (%v)", value, err)
+	}
+
+	if _, ok := c.do("NOSUCH").(respError); !ok {
+		t.Error("Expected error for unknown command")
+	}
+	if _, ok := c.do("GET").(respError); !ok {
+		t.Error("Expected error for wrong arity")
+	}
+}
+
+func TestRESPSetOptions(t *testing.T) {
+	_, _, addr := setupTestServer(t, "")
+	c := dial(t, addr)
+
+	expect(t, c.do("SET", "key", "v1", "XX"), nil)
+	expect(t, c.do("SET", "key", "v1", "NX", "EX", "100"), "OK")
+	expect(t, c.do("SET", "key", "v2", "NX"), nil)
+	expect(t, c.do("GET", "key"), "v1")
+
+	ttl := c.do("TTL", "key").(int64)
+	if ttl < 99 || ttl > 100 {
+		t.Errorf("Expected TTL around 100, got %d", ttl)
+	}
+
+	expect(t, c.do("SET", "key", "v3", "XX", "KEEPTTL"), "OK")
+	if pttl := c.do("PTTL", "key").(int64); pttl <= 0 {
+		t.Errorf("Expected KEEPTTL to keep expiry, got %d", pttl)
+	}
+
+	expect(t, c.do("PERSIST", "key"), int64(1))
+	expect(t, c.do("TTL", "key"), int64(-1))
+	expect(t, c.do("TTL", "missing"), int64(-2))
+	expect(t, c.do("EXPIRE", "missing", "10"), int64(0))
+
+	expect(t, c.do("SET", "short", "v", "PX", "20"), "OK")
+	time.Sleep(40 * time.Millisecond)
+	expect(t, c.do("GET", "short"), nil)
+
+	if _, ok := c.do("SET", "key", "v", "EX", "0").(respError); !ok {
+		t.Error("Expected error for invalid expire time")
+	}
+	expect(t, c.do("SET", "key", "v", "EX", "9223372036854775807"), respError("ERR invalid expire time in 'set' command"))
+	expect(t, c.do("PEXPIRE", "key", "9223372036854775807"), respError("ERR invalid expire time in 'pexpire' command"))
+	expect(t, c.do("TTL", "key"), int64(-1))
+	if _, ok := c.do("SET", "key", "v", "NX", "XX").(respError); !ok {
+		t.Error("Expected syntax error for NX with XX")
+	}
+}
+
+func TestRESPCounters(t *testing.T) {
+	_, _, addr := setupTestServer(t, "")
+	c := dial(t, addr)
+
+	expect(t, c.do("INCR", "hits"), int64(1))
+	expect(t, c.do("INCRBY", "hits", "10"), int64(11))
+	expect(t, c.do("DECR", "hits"), int64(10))
+	expect(t, c.do("DECRBY", "hits", "15"), int64(-5))
+	expect(t, c.do("INCRBYFLOAT", "hits", "0.5"), "-4.5")
+	expect(t, c.do("GET", "hits"), "-4.5")
+
+	if _, ok := c.do("INCR", "hits").(respError); !ok {
+		t.Error("Expected error incrementing a float with INCR")
+	}
+	if _, ok := c.do("INCRBY", "hits", "abc").(respError); !ok {
+		t.Error("Expected error for a non-integer increment")
+	}
+
+	expect(t, c.do("SET", "max", "9223372036854775807"), "OK")
+	if _, ok := c.do("INCR", "max").(respError); !ok {
+		t.Error("Expected overflow error")
+	}
+}
+
+func TestRESPCollections(t *testing.T) {
+	_, _, addr := setupTestServer(t, "")
+	c := dial(t, addr)
+
+	expect(t, c.do("RPUSH", "queue", "a", "b"), int64(2))
+	expect(t, c.do("LPUSH", "queue", "y", "z"), int64(4))
+	expect(t, c.do("LRANGE", "queue", "0", "-1"), []interface{}{"z", "y", "a", "b"})
+	expect(t, c.do("LLEN", "queue"), int64(4))
+	expect(t, c.do("LPOP", "queue"), "z")
+	expect(t, c.do("RPOP", "queue", "2"), []interface{}{"b", "a"})
+	expect(t, c.do("LRANGE", "missing", "0", "-1"), []interface{}{})
+	expect(t, c.do("TYPE", "queue"), "list")
+
+	expect(t, c.do("SADD", "tags", "go", "kv", "go"), int64(2))
+	expect(t, c.do("SISMEMBER", "tags", "kv"), int64(1))
+	expect(t, c.do("SREM", "tags", "kv"), int64(1))
+	expect(t, c.do("SMEMBERS", "tags"), []interface{}{"go"})
+	expect(t, c.do("SCARD", "tags"), int64(1))
+
+	expect(t, c.do("HSET", "user", "name", "ada", "lang", "go"), int64(2))
+	expect(t, c.do("HGET", "user", "name"), "ada")
+	expect(t, c.do("HGET", "user", "missing"), nil)
+	expect(t, c.do("HDEL", "user", "lang"), int64(1))
+	expect(t, c.do("HGETALL", "user"), []interface{}{"name", "ada"})
+	expect(t, c.do("HLEN", "user"), int64(1))
+	expect(t, c.do("TYPE", "user"), "hash")
+	expect(t, c.do("TYPE", "missing"), "none")
+	expect(t, c.do("EXISTS", "user", "tags", "missing"), int64(2))
+
+	for _, args := range [][]string{{"GET", "queue"}, {"SADD", "user", "x"}, {"LLEN", "tags"}, {"INCR", "tags"}} {
+		reply, ok := c.do(args...).(respError)
+		if !ok || !strings.HasPrefix(string(reply), "WRONGTYPE") {
+			t.Errorf("%v: expected WRONGTYPE error, got %v", args, reply)
+		}
+	}
+}
+
+func TestRESPScan(t *testing.T) {
+	_, kvStore, addr := setupTestServer(t, "")
+	c := dial(t, addr)
+
+	for i := 0; i < 25; i++ {
+		kvStore.Set(fmt.Sprintf("user:%02d", i), []byte("v"))
+	}
+	kvStore.Set("other", []byte("v"))
+
+	seen := map[string]bool{}
+	cursor := "0"
+	for {
+		reply := c.do("SCAN", cursor, "MATCH", "user:*", "COUNT", "7").([]interface{})
+		cursor = reply[0].(string)
+		for _, key := range reply[1].([]interface{}) {
+			if seen[key.(string)] {
+				t.Errorf("SCAN returned %s twice", key)
+			}
+			seen[key.(string)] = true
+			kvStore.Delete(key.(string))
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+
+	if len(seen) != 25 {
+		t.Errorf("Expected 25 keys from SCAN, got %d", len(seen))
+	}
+
+	c.do("RPUSH", "queue", "a")
+	c.do("HSET", "user", "name", "alice")
+	expect(t, c.do("SCAN", "0", "TYPE", "list"), []interface{}{"0", []interface{}{"queue"}})
+	expect(t, c.do("SCAN", "0", "TYPE", "hash"), []interface{}{"0", []interface{}{"user"}})
+	if _, ok := c.do("SCAN", "12345").(respError); !ok {
+		t.Error("Expected error for unknown SCAN cursor")
+	}
+}
+
+func TestRESPAuth(t *testing.T) {
+	_, _, addr := setupTestServer(t, "secret")
+	c := dial(t, addr)
+
+	expect(t, c.do("GET", "key"), respError("NOAUTH Authentication required."))
+	if _, ok := c.do("AUTH", "wrong").(respError); !ok {
+		t.Error("Expected error for wrong password")
+	}
+	expect(t, c.do("AUTH", "default", "secret"), "OK")
+	expect(t, c.do("SET", "key", "value"), "OK")
+
+	c2 := dial(t, addr)
+	hello := c2.do("HELLO", "3", "AUTH", "default", "secret").([]interface{})
+	if hello[0] != "server" || hello[1] != "qkrn" || hello[5] != int64(3) {
+		t.Errorf("Unexpected HELLO reply: %v", hello)
+	}
+	expect(t, c2.do("GET", "key"), "value")
+	expect(t, c2.do("GET", "missing"), nil)
+}
+
+func TestRESPInlineAndPipelining(t *testing.T) {
+	_, _, addr := setupTestServer(t, "")
+	c := dial(t, addr)
+
+	c.conn.Write([]byte("SET inline value\r\nGET inline\r\n"))
+	expect(t, c.read(), "OK")
+	expect(t, c.read(), "value")
+
+	c.send("INCRNOT")
+	c.send("PING")
+	if _, ok := c.read().(respError); !ok {
+		t.Error("Expected error for unknown command")
+	}
+	expect(t, c.read(), "PONG")
+
+	expect(t, c.do("QUIT"), "OK")
+}
+
+func TestRESPLineLimit(t *testing.T) {
+	r := newReader(io.MultiReader(strings.NewReader(strings.Repeat("a", maxInlineSize)), neverEnding('a')))
+	if _, err := r.readLine(); !errors.Is(err, errProtocol) {
+		t.Errorf("Expected a line without a newline to hit the limit, got %v", err)
+	}
+
+	r = newReader(strings.NewReader(strings.Repeat("a", 10000) + "\r\n"))
+	if line, err := r.readLine(); err != nil || len(line) != 10000 {
+		t.Errorf("Expected a line longer than the read buffer to be read, got %d bytes (%v)", len(line), err)
+	}
+}
+
+func TestRESPPreAuthLimits(t *testing.T) {
+	_, _, addr := setupTestServer(t, "secret")
+
+	c := dial(t, addr)
+	c.conn.Write([]byte("*2\r\n$3\r\nGET\r\n$" + strconv.Itoa(preAuthBulkLength+1) + "\r\n"))
+	if _, ok := c.read().(respError); !ok {
+		t.Error("Expected an oversized bulk string to be refused before AUTH")
+	}
+
+	c = dial(t, addr)
+	c.conn.Write([]byte("*" + strconv.Itoa(preAuthArrayLength+1) + "\r\n"))
+	if _, ok := c.read().(respError); !ok {
+		t.Error("Expected an oversized array to be refused before AUTH")
+	}
+
+	c = dial(t, addr)
+	expect(t, c.do("AUTH", "secret"), "OK")
+	value := strings.Repeat("v", preAuthBulkLength+1)
+	expect(t, c.do("SET", "big", value), "OK")
+	if got, _ := c.do("GET", "big").(string); got != value {
+		t.Errorf("Expected large values after AUTH, got %d bytes", len(got))
+	}
+
+	r := newReader(strings.NewReader("$1000000\r\nshort"))
+	if _, err := r.readBulk(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected a bulk string cut short to fail, got %v", err)
+	}
+}
+
+type neverEnding byte
+
+func (b neverEnding) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+	return len(p), nil
+}
+
+func TestRESPMSetIsAtomic(t *testing.T) {
+	_, kvStore, addr := setupTestServer(t, "")
+	kvStore.SetLimits(types.Limits{MaxValueSize: 4})
+	c := dial(t, addr)
+
+	if _, ok := c.do("MSET", "a", "1", "b", "too large").(respError); !ok {
+		t.Fatal("Expected MSET to fail")
+	}
+	expect(t, c.do("GET", "a"), nil)
+	expect(t, c.do("MSET", "a", "1", "b", "2"), "OK")
+	expect(t, c.do("MGET", "a", "b"), []interface{}{"1", "2"})
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		match   bool
+	}{
+		{"*", "anything/with/slashes", true},
+		{"user:*", "user:42", true},
+		{"user:*", "admin:1", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{"*b*d", "abcd", true},
+	}
+
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.input); got != tt.match {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.input, got, tt.match)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/q4ow/qkrn/internal/keyring"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+var ErrUnknownBackend = errors.New("unknown storage engine")
+
+type BackendConfig struct {
+	Namespace string
+	Dir       string
+	History   types.HistoryLimits
+	Keys      *keyring.Keyring
+	Options   map[string]any
+}
+
+type OpenFunc func(cfg BackendConfig) (types.Store, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]OpenFunc{}
+)
+
+func init() {
+	RegisterBackend("memory", openMemory)
+}
+
+func RegisterBackend(name string, open OpenFunc) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if _, exists := backends[name]; exists {
+		panic("store: backend " + name + " registered twice")
+	}
+	backends[name] = open
+}
+
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func OpenBackend(name string, cfg BackendConfig) (types.Store, error) {
+	backendsMu.RLock()
+	open, ok := backends[name]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w %q (available: %s)", ErrUnknownBackend, name, strings.Join(Backends(), ", "))
+	}
+	return open(cfg)
+}
+
+func DecodeOptions(options map[string]any, v any) error {
+	if len(options) == 0 {
+		return nil
+	}
+
+	data, err := toml.Marshal(options)
+	if err != nil {
+		return err
+	}
+	md, err := toml.Decode(string(data), v)
+	if err != nil {
+		return err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("unknown option %q", undecoded[0].String())
+	}
+	return nil
+}
+
+type memoryOptions struct {
+	Shards               int    `toml:"shards"`
+	Compression          string `toml:"compression"`
+	CompressionThreshold int    `toml:"compression_threshold"`
+}
+
+func openMemory(cfg BackendConfig) (types.Store, error) {
+	var opts memoryOptions
+	if err := DecodeOptions(cfg.Options, &opts); err != nil {
+		return nil, err
+	}
+
+	s := NewShardedMemoryStore(opts.Shards)
+	s.SetHistoryLimits(cfg.History)
+	if err := s.SetCompression(types.Compression{Codec: opts.Compression, Threshold: opts.CompressionThreshold}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
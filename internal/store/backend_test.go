@@ -0,0 +1,43 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/q4ow/qkrn/internal/store/storetest"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func TestMemoryStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) types.Store { return NewMemoryStore() })
+}
+
+func TestBackendRegistry(t *testing.T) {
+	s, err := OpenBackend("memory", BackendConfig{
+		History: types.HistoryLimits{MaxVersions: 3},
+		Options: map[string]any{"shards": int64(4)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to open the memory backend: %v", err)
+	}
+	if m, ok := s.(*MemoryStore); !ok || m.Shards() != 4 || m.HistoryLimits().MaxVersions != 3 {
+		t.Errorf("Expected a memory store with 4 shards and history, got %T", s)
+	}
+
+	if _, err := OpenBackend("memory", BackendConfig{Options: map[string]any{"shard": 4}}); err == nil {
+		t.Error("Expected an unknown option to be rejected")
+	}
+	if _, err := OpenBackend("tape", BackendConfig{}); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("Expected ErrUnknownBackend, got %v", err)
+	}
+
+	RegisterBackend("test", func(cfg BackendConfig) (types.Store, error) { return NewMemoryStore(), nil })
+	defer func() {
+		backendsMu.Lock()
+		delete(backends, "test")
+		backendsMu.Unlock()
+	}()
+	if names := Backends(); len(names) != 2 || names[0] != "memory" || names[1] != "test" {
+		t.Errorf("Expected [memory test], got %v", names)
+	}
+}
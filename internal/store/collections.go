@@ -0,0 +1,472 @@
+package store
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func (e entry) valueType() types.ValueType {
+	if e.kind == "" {
+		return types.TypeString
+	}
+	return e.kind
+}
+
+func (e entry) length() int {
+	switch e.kind {
+	case types.TypeList:
+		return len(e.list)
+	case types.TypeSet:
+		return len(e.set)
+	case types.TypeHash:
+		return len(e.hash)
+	}
+	return 0
+}
+
+func (s *MemoryStore) collection(sh *shard, key string, kind types.ValueType) (entry, bool, error) {
+	if key == "" {
+		return entry{}, false, types.ErrEmptyKey
+	}
+
+	now := s.now()
+	e, exists := sh.data[key]
+	if !exists || e.expired(now) {
+		return entry{kind: kind}, false, nil
+	}
+	if e.valueType() != kind {
+		return entry{}, false, types.ErrWrongType
+	}
+	e.touch(now)
+	return e, true, nil
+}
+
+func (s *MemoryStore) storeCollection(sh *shard, key string, e entry) error {
+	if e.length() == 0 {
+		if _, exists := sh.data[key]; exists {
+			s.remove(sh, key)
+		}
+		return nil
+	}
+
+	_, err := s.apply(sh, key, e)
+	return err
+}
+
+func (s *MemoryStore) updateCollection(sh *shard, key string, e entry, exists bool, grow int64, mutate func(e *entry)) error {
+	if !exists || s.keepsHistory() {
+		e = e.cloneCollection()
+		mutate(&e)
+		return s.storeCollection(sh, key, e)
+	}
+
+	now := s.now()
+	r, err := s.reserveBytes(key, grow, now)
+	if err != nil {
+		return err
+	}
+	defer s.release(reservation{memory: r})
+
+	current := sh.data[key]
+	mutate(&e)
+	if s.limits.DefaultTTL > 0 && e.expiresAt.IsZero() {
+		e.expiresAt = now.Add(s.limits.DefaultTTL)
+	}
+	e.revision = s.seq.Add(1)
+	e.modifiedAt = now
+	e.version++
+	e.touch(now)
+	s.record(sh, key, current, e.revision, now)
+
+	sh.data[key] = e
+	s.used.Add(grow)
+	s.publishPut(key, e)
+	return nil
+}
+
+func (e entry) cloneCollection() entry {
+	switch e.kind {
+	case types.TypeList:
+		e.list = append([][]byte(nil), e.list...)
+	case types.TypeSet:
+		set := make(map[string]struct{}, len(e.set))
+		for m := range e.set {
+			set[m] = struct{}{}
+		}
+		e.set = set
+	case types.TypeHash:
+		hash := make(map[string][]byte, len(e.hash))
+		for f, v := range e.hash {
+			hash[f] = v
+		}
+		e.hash = hash
+	}
+	return e
+}
+
+func (s *MemoryStore) tooLarge(size int) bool {
+	return s.limits.MaxValueSize > 0 && size > s.limits.MaxValueSize
+}
+
+func (s *MemoryStore) ListPush(key string, values [][]byte, front bool) (int, error) {
+	length := 0
+	err := s.write(key, func(sh *shard) error {
+		e, exists, err := s.collection(sh, key, types.TypeList)
+		if err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			length = len(e.list)
+			return nil
+		}
+
+		var grow int64
+		items := make([][]byte, len(values))
+		for i, v := range values {
+			if s.tooLarge(len(v)) {
+				return types.ErrValueTooLarge
+			}
+			grow += int64(elementOverhead/2 + len(v))
+			if front {
+				items[len(values)-1-i] = cloneBytes(v)
+			} else {
+				items[i] = cloneBytes(v)
+			}
+		}
+
+		err = s.updateCollection(sh, key, e, exists, grow, func(e *entry) {
+			if front {
+				e.list = slices.Insert(e.list, 0, items...)
+			} else {
+				e.list = append(e.list, items...)
+			}
+			length = len(e.list)
+		})
+		if err != nil {
+			length = 0
+		}
+		return err
+	})
+	return length, err
+}
+
+func (s *MemoryStore) ListPop(key string, count int, front bool) ([][]byte, error) {
+	var popped [][]byte
+	err := s.write(key, func(sh *shard) error {
+		e, exists, err := s.collection(sh, key, types.TypeList)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return types.ErrKeyNotFound
+		}
+
+		n := count
+		if n < 1 {
+			n = 1
+		}
+		if n > len(e.list) {
+			n = len(e.list)
+		}
+
+		values := make([][]byte, 0, n)
+		var grow int64
+		if front {
+			values = append(values, e.list[:n]...)
+		} else {
+			for i := len(e.list) - 1; i >= len(e.list)-n; i-- {
+				values = append(values, e.list[i])
+			}
+		}
+		for _, v := range values {
+			grow -= int64(elementOverhead/2 + len(v))
+		}
+
+		if n == len(e.list) {
+			s.remove(sh, key)
+			popped = values
+			return nil
+		}
+
+		err = s.updateCollection(sh, key, e, exists, grow, func(e *entry) {
+			if front {
+				clear(e.list[:n])
+				e.list = e.list[n:]
+			} else {
+				clear(e.list[len(e.list)-n:])
+				e.list = e.list[:len(e.list)-n]
+			}
+		})
+		if err != nil {
+			return err
+		}
+		popped = values
+		return nil
+	})
+	return popped, err
+}
+
+func (s *MemoryStore) ListRange(key string, start, stop int) ([][]byte, error) {
+	sh := s.read(key)
+	defer sh.mu.RUnlock()
+
+	e, exists, err := s.collection(sh, key, types.TypeList)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, types.ErrKeyNotFound
+	}
+
+	n := len(e.list)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+
+	values := [][]byte{}
+	for i := start; i <= stop; i++ {
+		values = append(values, cloneBytes(e.list[i]))
+	}
+	return values, nil
+}
+
+func (s *MemoryStore) SetAdd(key string, members ...string) (int, error) {
+	added := 0
+	err := s.write(key, func(sh *shard) error {
+		e, exists, err := s.collection(sh, key, types.TypeSet)
+		if err != nil {
+			return err
+		}
+
+		fresh := make(map[string]struct{}, len(members))
+		var grow int64
+		for _, m := range members {
+			if s.tooLarge(len(m)) {
+				return types.ErrValueTooLarge
+			}
+			if _, ok := e.set[m]; ok {
+				continue
+			}
+			if _, ok := fresh[m]; !ok {
+				fresh[m] = struct{}{}
+				grow += int64(elementOverhead + len(m))
+			}
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+
+		err = s.updateCollection(sh, key, e, exists, grow, func(e *entry) {
+			if e.set == nil {
+				e.set = make(map[string]struct{}, len(fresh))
+			}
+			for m := range fresh {
+				e.set[m] = struct{}{}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		added = len(fresh)
+		return nil
+	})
+	return added, err
+}
+
+func (s *MemoryStore) SetRemove(key string, members ...string) (int, error) {
+	removed := 0
+	err := s.write(key, func(sh *shard) error {
+		e, exists, err := s.collection(sh, key, types.TypeSet)
+		if err != nil || !exists {
+			return err
+		}
+
+		hits := make(map[string]struct{}, len(members))
+		var grow int64
+		for _, m := range members {
+			if _, ok := e.set[m]; !ok {
+				continue
+			}
+			if _, ok := hits[m]; !ok {
+				hits[m] = struct{}{}
+				grow -= int64(elementOverhead + len(m))
+			}
+		}
+		if len(hits) == 0 {
+			return nil
+		}
+
+		if len(hits) == len(e.set) {
+			s.remove(sh, key)
+		} else if err := s.updateCollection(sh, key, e, exists, grow, func(e *entry) {
+			for m := range hits {
+				delete(e.set, m)
+			}
+		}); err != nil {
+			return err
+		}
+		removed = len(hits)
+		return nil
+	})
+	return removed, err
+}
+
+func (s *MemoryStore) SetMembers(key string) ([]string, error) {
+	sh := s.read(key)
+	defer sh.mu.RUnlock()
+
+	e, exists, err := s.collection(sh, key, types.TypeSet)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, types.ErrKeyNotFound
+	}
+
+	members := make([]string, 0, len(e.set))
+	for m := range e.set {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+func (s *MemoryStore) SetIsMember(key, member string) (bool, error) {
+	sh := s.read(key)
+	defer sh.mu.RUnlock()
+
+	e, _, err := s.collection(sh, key, types.TypeSet)
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := e.set[member]
+	return ok, nil
+}
+
+func (s *MemoryStore) HashSet(key string, fields map[string][]byte) (int, error) {
+	added := 0
+	err := s.write(key, func(sh *shard) error {
+		e, exists, err := s.collection(sh, key, types.TypeHash)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			return nil
+		}
+
+		n := 0
+		var grow int64
+		for f, v := range fields {
+			if s.tooLarge(len(v)) {
+				return types.ErrValueTooLarge
+			}
+			if old, ok := e.hash[f]; ok {
+				grow += int64(len(v) - len(old))
+			} else {
+				grow += int64(elementOverhead + len(f) + len(v))
+				n++
+			}
+		}
+
+		err = s.updateCollection(sh, key, e, exists, grow, func(e *entry) {
+			if e.hash == nil {
+				e.hash = make(map[string][]byte, len(fields))
+			}
+			for f, v := range fields {
+				e.hash[f] = cloneBytes(v)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		added = n
+		return nil
+	})
+	return added, err
+}
+
+func (s *MemoryStore) HashGet(key, field string) ([]byte, error) {
+	sh := s.read(key)
+	defer sh.mu.RUnlock()
+
+	e, _, err := s.collection(sh, key, types.TypeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := e.hash[field]
+	if !ok {
+		return nil, types.ErrKeyNotFound
+	}
+	return cloneBytes(value), nil
+}
+
+func (s *MemoryStore) HashGetAll(key string) (map[string][]byte, error) {
+	sh := s.read(key)
+	defer sh.mu.RUnlock()
+
+	e, exists, err := s.collection(sh, key, types.TypeHash)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, types.ErrKeyNotFound
+	}
+
+	fields := make(map[string][]byte, len(e.hash))
+	for f, v := range e.hash {
+		fields[f] = cloneBytes(v)
+	}
+	return fields, nil
+}
+
+func (s *MemoryStore) HashDelete(key string, fields ...string) (int, error) {
+	deleted := 0
+	err := s.write(key, func(sh *shard) error {
+		e, exists, err := s.collection(sh, key, types.TypeHash)
+		if err != nil || !exists {
+			return err
+		}
+
+		hits := make(map[string]struct{}, len(fields))
+		var grow int64
+		for _, f := range fields {
+			v, ok := e.hash[f]
+			if !ok {
+				continue
+			}
+			if _, ok := hits[f]; !ok {
+				hits[f] = struct{}{}
+				grow -= int64(elementOverhead + len(f) + len(v))
+			}
+		}
+		if len(hits) == 0 {
+			return nil
+		}
+
+		if len(hits) == len(e.hash) {
+			s.remove(sh, key)
+		} else if err := s.updateCollection(sh, key, e, exists, grow, func(e *entry) {
+			for f := range hits {
+				delete(e.hash, f)
+			}
+		}); err != nil {
+			return err
+		}
+		deleted = len(hits)
+		return nil
+	})
+	return deleted, err
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func strs(values [][]byte) []string {
+	result := make([]string, len(values))
+	for i, v := range values {
+		result[i] = string(v)
+	}
+	return result
+}
+
+func TestMemoryStoreLists(t *testing.T) {
+	store := NewMemoryStore()
+
+	if n, err := store.ListPush("queue", [][]byte{[]byte("b"), []byte("c")}, false); err != nil || n != 2 {
+		t.Fatalf("Expected length 2, got %d (%v)", n, err)
+	}
+	if n, _ := store.ListPush("queue", [][]byte{[]byte("a"), []byte("z")}, true); n != 4 {
+		t.Errorf("Expected length 4, got %d", n)
+	}
+
+	values, err := store.ListRange("queue", 0, -1)
+	if err != nil || !reflect.DeepEqual(strs(values), []string{"z", "a", "b", "c"}) {
+		t.Errorf("Unexpected range: %q (%v)", strs(values), err)
+	}
+	if values, _ := store.ListRange("queue", -2, 10); !reflect.DeepEqual(strs(values), []string{"b", "c"}) {
+		t.Errorf("Unexpected negative range: %q", strs(values))
+	}
+	if values, _ := store.ListRange("queue", 3, 1); len(values) != 0 {
+		t.Errorf("Expected empty range, got %q", strs(values))
+	}
+
+	if values, _ := store.ListPop("queue", 1, true); !reflect.DeepEqual(strs(values), []string{"z"}) {
+		t.Errorf("Unexpected front pop: %q", strs(values))
+	}
+	if values, _ := store.ListPop("queue", 2, false); !reflect.DeepEqual(strs(values), []string{"c", "b"}) {
+		t.Errorf("Unexpected back pop: %q", strs(values))
+	}
+
+	entry, err := store.GetEntry("queue")
+	if err != nil || entry.Type != types.TypeList || entry.Length != 1 {
+		t.Errorf("Unexpected entry: %+v (%v)", entry, err)
+	}
+
+	store.ListPop("queue", 5, true)
+	if _, err := store.GetEntry("queue"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected empty list to be removed, got %v", err)
+	}
+	if _, err := store.ListPop("queue", 1, true); err != types.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStoreSets(t *testing.T) {
+	store := NewMemoryStore()
+
+	if n, err := store.SetAdd("tags", "go", "kv", "go"); err != nil || n != 2 {
+		t.Fatalf("Expected 2 members added, got %d (%v)", n, err)
+	}
+	if n, _ := store.SetAdd("tags", "kv", "db"); n != 1 {
+		t.Errorf("Expected 1 member added, got %d", n)
+	}
+
+	members, err := store.SetMembers("tags")
+	if err != nil || !reflect.DeepEqual(members, []string{"db", "go", "kv"}) {
+		t.Errorf("Unexpected members: %q (%v)", members, err)
+	}
+	if ok, _ := store.SetIsMember("tags", "go"); !ok {
+		t.Error("Expected go to be a member")
+	}
+	if ok, err := store.SetIsMember("missing", "go"); ok || err != nil {
+		t.Errorf("Expected missing set to have no members, got %v (%v)", ok, err)
+	}
+
+	if n, _ := store.SetRemove("tags", "go", "nope"); n != 1 {
+		t.Errorf("Expected 1 member removed, got %d", n)
+	}
+	store.SetRemove("tags", "db", "kv")
+	if _, err := store.SetMembers("tags"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected empty set to be removed, got %v", err)
+	}
+}
+
+func TestMemoryStoreHashes(t *testing.T) {
+	store := NewMemoryStore()
+
+	n, err := store.HashSet("user", map[string][]byte{"name": []byte("ada"), "lang": []byte("go")})
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 fields added, got %d (%v)", n, err)
+	}
+	if n, _ := store.HashSet("user", map[string][]byte{"name": []byte("grace"), "age": []byte("36")}); n != 1 {
+		t.Errorf("Expected 1 new field, got %d", n)
+	}
+
+	if value, err := store.HashGet("user", "name"); err != nil || string(value) != "grace" {
+		t.Errorf("Expected grace, got %s (%v)", value, err)
+	}
+	if _, err := store.HashGet("user", "missing"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound for missing field, got %v", err)
+	}
+
+	fields, _ := store.HashGetAll("user")
+	if len(fields) != 3 || string(fields["lang"]) != "go" {
+		t.Errorf("Unexpected fields: %v", fields)
+	}
+
+	if n, _ := store.HashDelete("user", "age", "missing"); n != 1 {
+		t.Errorf("Expected 1 field deleted, got %d", n)
+	}
+	if entry, _ := store.GetEntry("user"); entry.Type != types.TypeHash || entry.Length != 2 || entry.Version != 3 {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+}
+
+func TestMemoryStoreWrongType(t *testing.T) {
+	store := NewMemoryStore()
+	store.Set("str", []byte("1"))
+	store.ListPush("list", [][]byte{[]byte("a")}, false)
+	store.SetAdd("set", "a")
+
+	if _, err := store.ListPush("str", [][]byte{[]byte("a")}, false); err != types.ErrWrongType {
+		t.Errorf("Expected ErrWrongType pushing to a string, got %v", err)
+	}
+	if _, err := store.SetAdd("list", "a"); err != types.ErrWrongType {
+		t.Errorf("Expected ErrWrongType adding to a list, got %v", err)
+	}
+	if _, err := store.HashGet("set", "a"); err != types.ErrWrongType {
+		t.Errorf("Expected ErrWrongType reading a set as a hash, got %v", err)
+	}
+	if _, err := store.Get("list"); err != types.ErrWrongType {
+		t.Errorf("Expected ErrWrongType reading a list as a string, got %v", err)
+	}
+	if _, err := store.Incr("set", 1, types.IncrOptions{}); err != types.ErrWrongType {
+		t.Errorf("Expected ErrWrongType incrementing a set, got %v", err)
+	}
+
+	if err := store.Set("list", []byte("v")); err != nil {
+		t.Fatalf("Expected set to overwrite a list, got %v", err)
+	}
+	if value, _ := store.Get("list"); string(value) != "v" {
+		t.Errorf("Expected v, got %s", value)
+	}
+}
+
+func TestMemoryStoreCollectionLimits(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetLimits(types.Limits{MaxKeys: 1, MaxValueSize: 3})
+
+	if _, err := store.ListPush("list", [][]byte{[]byte("toolong")}, false); err != types.ErrValueTooLarge {
+		t.Errorf("Expected ErrValueTooLarge, got %v", err)
+	}
+	store.SetAdd("set", "a")
+	if _, err := store.HashSet("hash", map[string][]byte{"f": []byte("v")}); err != types.ErrKeyLimit {
+		t.Errorf("Expected ErrKeyLimit, got %v", err)
+	}
+	if n, err := store.SetAdd("set", "b"); err != nil || n != 1 {
+		t.Errorf("Expected existing set to accept members, got %d (%v)", n, err)
+	}
+}
+
+func TestMemoryStoreCollectionAccounting(t *testing.T) {
+	store := NewShardedMemoryStore(1)
+	store.SetLimits(types.Limits{MaxMemory: 1 << 20})
+
+	store.ListPush("list", [][]byte{[]byte("a"), []byte("bb")}, false)
+	store.ListPush("list", [][]byte{[]byte("c"), []byte("d")}, true)
+	store.ListPop("list", 2, false)
+	store.SetAdd("set", "a", "b", "b", "c")
+	store.SetRemove("set", "a", "a", "z")
+	store.HashSet("hash", map[string][]byte{"f": []byte("1"), "g": []byte("22")})
+	store.HashSet("hash", map[string][]byte{"f": []byte("111"), "h": []byte("3")})
+	store.HashDelete("hash", "g", "g")
+
+	if values, _ := store.ListRange("list", 0, -1); !reflect.DeepEqual(strs(values), []string{"d", "c"}) {
+		t.Errorf("Expected [d c], got %v", strs(values))
+	}
+	want := int64(0)
+	for key, e := range store.shards[0].data {
+		want += entrySize(key, e)
+	}
+	if got := store.Stats().MemoryUsed; got != want {
+		t.Errorf("Expected in-place updates to keep %d bytes in use, got %d", want, got)
+	}
+
+	store.SetHistoryLimits(types.HistoryLimits{MaxVersions: 5})
+	store.SetAdd("set", "x")
+	before, _ := store.GetEntry("set")
+	store.SetAdd("set", "y")
+	if _, err := store.Restore("set", before.Revision); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if members, _ := store.SetMembers("set"); !reflect.DeepEqual(members, []string{"b", "c", "x"}) {
+		t.Errorf("Expected the recorded version to be left alone, got %v", members)
+	}
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const DefaultCompressionThreshold = 1024
+
+var (
+	ErrUnknownCodec = errors.New("unknown compression codec")
+	ErrCorruptValue = errors.New("corrupt compressed value")
+)
+
+type codec uint8
+
+const (
+	codecNone codec = iota
+	codecGzip
+	codecFlate
+)
+
+func parseCodec(name string) (codec, bool) {
+	switch name {
+	case "", "none":
+		return codecNone, true
+	case "gzip":
+		return codecGzip, true
+	case "flate":
+		return codecFlate, true
+	}
+	return codecNone, false
+}
+
+func (c codec) String() string {
+	switch c {
+	case codecGzip:
+		return "gzip"
+	case codecFlate:
+		return "flate"
+	}
+	return "none"
+}
+
+type compression struct {
+	codec     codec
+	threshold int
+}
+
+var (
+	gzipWriters  = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+	flateWriters = sync.Pool{New: func() any { w, _ := flate.NewWriter(nil, flate.DefaultCompression); return w }}
+	gzipReaders  sync.Pool
+	flateReaders sync.Pool
+)
+
+func compress(c codec, value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	switch c {
+	case codecGzip:
+		w := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(w)
+		w.Reset(&buf)
+		if _, err := w.Write(value); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	case codecFlate:
+		w := flateWriters.Get().(*flate.Writer)
+		defer flateWriters.Put(w)
+		w.Reset(&buf)
+		if _, err := w.Write(value); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnknownCodec
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+func decompress(c codec, data []byte, size int) ([]byte, error) {
+	var r io.Reader
+	switch c {
+	case codecGzip:
+		zr, _ := gzipReaders.Get().(*gzip.Reader)
+		if zr == nil {
+			var err error
+			if zr, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+				return nil, err
+			}
+		} else if err := zr.Reset(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		defer gzipReaders.Put(zr)
+		r = zr
+	case codecFlate:
+		fr, _ := flateReaders.Get().(io.ReadCloser)
+		if fr == nil {
+			fr = flate.NewReader(bytes.NewReader(data))
+		} else if err := fr.(flate.Resetter).Reset(bytes.NewReader(data), nil); err != nil {
+			return nil, err
+		}
+		defer flateReaders.Put(fr)
+		r = fr
+	default:
+		return nil, ErrUnknownCodec
+	}
+
+	value := make([]byte, size)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (e entry) plain() ([]byte, error) {
+	if e.codec == codecNone {
+		return cloneBytes(e.value), nil
+	}
+
+	value, err := decompress(e.codec, e.value, e.rawSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrCorruptValue, e.codec, err)
+	}
+	return value, nil
+}
+
+func (e entry) valueSize() int {
+	if e.codec == codecNone {
+		return len(e.value)
+	}
+	return e.rawSize
+}
+
+func (s *MemoryStore) compress(e *entry) {
+	c := s.compression
+	if c.codec == codecNone || e.kind != "" || e.codec != codecNone || len(e.value) < c.threshold {
+		return
+	}
+
+	packed, err := compress(c.codec, e.value)
+	if err != nil || len(packed) >= len(e.value) {
+		return
+	}
+	e.value, e.codec, e.rawSize = packed, c.codec, len(e.value)
+}
+
+func (s *MemoryStore) trackCompression(e entry, sign int64) {
+	if e.codec == codecNone {
+		return
+	}
+	s.compressedKeys.Add(sign)
+	s.compressedBytes.Add(sign * int64(len(e.value)))
+	s.uncompressedBytes.Add(sign * int64(e.rawSize))
+}
+
+func (s *MemoryStore) SetCompression(c types.Compression) error {
+	codec, ok := parseCodec(c.Codec)
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownCodec, c.Codec)
+	}
+	threshold := c.Threshold
+	if threshold <= 0 {
+		threshold = DefaultCompressionThreshold
+	}
+
+	s.lockAll()
+	defer s.unlockAll()
+	s.compression = compression{codec: codec, threshold: threshold}
+	return nil
+}
+
+func (s *MemoryStore) Compression() types.Compression {
+	s.rlockAll()
+	defer s.runlockAll()
+
+	if s.compression.codec == codecNone {
+		return types.Compression{}
+	}
+	return types.Compression{Codec: s.compression.codec.String(), Threshold: s.compression.threshold}
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/q4ow/qkrn/internal/store/storetest"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func compressible(n int) []byte {
+	return []byte(strings.Repeat(`{"name":"qkrn","tags":["a","b"]},`, n))
+}
+
+func TestCompression(t *testing.T) {
+	for _, codec := range []string{"gzip", "flate"} {
+		t.Run(codec, func(t *testing.T) {
+			store := NewMemoryStore()
+			if err := store.SetCompression(types.Compression{Codec: codec, Threshold: 256}); err != nil {
+				t.Fatalf("SetCompression failed: %v", err)
+			}
+
+			large := compressible(100)
+			store.Set("large", large)
+			store.Set("small", []byte("tiny"))
+
+			sh := store.shardFor("large")
+			if e := sh.data["large"]; e.codec.String() != codec || e.rawSize != len(large) || len(e.value) >= len(large) {
+				t.Errorf("Expected large to be stored with %s, got %s (%d of %d bytes)", codec, e.codec, len(e.value), e.rawSize)
+			}
+			if e := store.shardFor("small").data["small"]; e.codec != codecNone {
+				t.Errorf("Expected values under the threshold to stay uncompressed, got %s", e.codec)
+			}
+
+			if v, err := store.Get("large"); err != nil || !bytes.Equal(v, large) {
+				t.Errorf("Expected the original value back, got %d bytes (%v)", len(v), err)
+			}
+			if e, _ := store.GetEntry("large"); !bytes.Equal(e.Value, large) {
+				t.Errorf("Expected GetEntry to decompress, got %d bytes", len(e.Value))
+			}
+
+			stats := store.Stats()
+			if stats.CompressedKeys != 1 || stats.UncompressedBytes != int64(len(large)) || stats.CompressedBytes >= stats.UncompressedBytes {
+				t.Errorf("Unexpected compression stats %+v", stats)
+			}
+			if stats.MemoryUsed >= int64(len(large)) {
+				t.Errorf("Expected memory accounting to use the compressed size, got %d", stats.MemoryUsed)
+			}
+
+			store.Delete("large")
+			if stats := store.Stats(); stats.CompressedKeys != 0 || stats.CompressedBytes != 0 || stats.UncompressedBytes != 0 {
+				t.Errorf("Expected deleting to reset the compression stats, got %+v", stats)
+			}
+		})
+	}
+}
+
+func TestCompressionIncompressible(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetCompression(types.Compression{Codec: "gzip", Threshold: 16})
+
+	value := []byte("0123456789abcdefghij")
+	store.Set("random", value)
+	if e := store.shardFor("random").data["random"]; e.codec != codecNone || !bytes.Equal(e.value, value) {
+		t.Errorf("Expected values that do not shrink to be stored as is, got %s", e.codec)
+	}
+}
+
+func TestCompressionLimitsAndCounters(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetCompression(types.Compression{Codec: "gzip", Threshold: 64})
+	store.SetLimits(types.Limits{MaxValueSize: 1000})
+
+	if err := store.Set("large", compressible(100)); !errors.Is(err, types.ErrValueTooLarge) {
+		t.Errorf("Expected the value size limit to apply before compression, got %v", err)
+	}
+
+	store.Set("counter", []byte(strings.Repeat("0", 100)+"41"))
+	if n, err := store.Incr("counter", 1, types.IncrOptions{}); err != nil || n != 42 {
+		t.Errorf("Expected increments of compressed numbers to work, got %d (%v)", n, err)
+	}
+	if e := store.shardFor("counter").data["counter"]; e.codec != codecNone {
+		t.Errorf("Expected the short result to be stored uncompressed, got %s", e.codec)
+	}
+}
+
+func TestCompressionHistoryAndSnapshot(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetHistoryLimits(types.HistoryLimits{MaxVersions: 5})
+	store.SetCompression(types.Compression{Codec: "flate", Threshold: 64})
+
+	first := compressible(50)
+	store.Set("doc", first)
+	revision := store.Revision()
+	store.Set("doc", []byte("second"))
+
+	if e, err := store.GetRevision("doc", revision); err != nil || !bytes.Equal(e.Value, first) {
+		t.Errorf("Expected the compressed history version back, got %d bytes (%v)", len(e.Value), err)
+	}
+	if e, err := store.Restore("doc", revision); err != nil || !bytes.Equal(e.Value, first) {
+		t.Errorf("Expected restore to bring back the compressed version, got %d bytes (%v)", len(e.Value), err)
+	}
+
+	store.Snapshot("").Each(func(rec types.Record) error {
+		if !bytes.Equal(rec.Value, first) {
+			t.Errorf("Expected snapshots to hold decompressed values, got %d bytes", len(rec.Value))
+		}
+		return nil
+	})
+}
+
+func TestCompressionConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) types.Store {
+		store := NewMemoryStore()
+		store.SetCompression(types.Compression{Codec: "gzip", Threshold: 1})
+		return store
+	})
+}
+
+func TestSetCompressionUnknownCodec(t *testing.T) {
+	store := NewMemoryStore()
+	if err := store.SetCompression(types.Compression{Codec: "zstd"}); !errors.Is(err, ErrUnknownCodec) {
+		t.Errorf("Expected ErrUnknownCodec, got %v", err)
+	}
+	if c := store.Compression(); c != (types.Compression{}) {
+		t.Errorf("Expected compression to stay off, got %+v", c)
+	}
+}
+
+func TestCompressionCorruptValue(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetCompression(types.Compression{Codec: "gzip", Threshold: 16})
+	store.Set("key", compressible(1024))
+
+	sh := store.shardFor("key")
+	e := sh.data["key"]
+	e.value = append(cloneBytes(e.value[:len(e.value)/2]), 0xff)
+	sh.data["key"] = e
+
+	if _, err := store.Get("key"); !errors.Is(err, ErrCorruptValue) {
+		t.Errorf("Get: expected ErrCorruptValue, got %v", err)
+	}
+	if _, err := store.GetEntry("key"); !errors.Is(err, ErrCorruptValue) {
+		t.Errorf("GetEntry: expected ErrCorruptValue, got %v", err)
+	}
+	if err := store.Scan("", "", func(types.KeyValue) bool { return true }); !errors.Is(err, ErrCorruptValue) {
+		t.Errorf("Scan: expected ErrCorruptValue, got %v", err)
+	}
+	if err := store.Snapshot("").Each(func(types.Record) error { return nil }); !errors.Is(err, ErrCorruptValue) {
+		t.Errorf("Snapshot: expected ErrCorruptValue, got %v", err)
+	}
+}
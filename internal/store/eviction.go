@@ -0,0 +1,256 @@
+package store
+
+import (
+	"math"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const (
+	entryOverhead   = 240
+	elementOverhead = 48
+	evictionSamples = 16
+)
+
+type access struct {
+	lastUsed atomic.Int64
+	hits     atomic.Uint32
+}
+
+func (e entry) touch(now time.Time) {
+	if e.access == nil {
+		return
+	}
+	e.access.lastUsed.Store(now.UnixNano())
+	if hits := e.access.hits.Load(); hits < math.MaxUint32 {
+		e.access.hits.CompareAndSwap(hits, hits+1)
+	}
+}
+
+func entrySize(key string, e entry) int64 {
+	size := entryOverhead + len(key) + len(e.value) + len(e.contentType)
+	for name, value := range e.labels {
+		size += elementOverhead + len(name) + len(value)
+	}
+	for _, item := range e.list {
+		size += elementOverhead/2 + len(item)
+	}
+	for member := range e.set {
+		size += elementOverhead + len(member)
+	}
+	for field, value := range e.hash {
+		size += elementOverhead + len(field) + len(value)
+	}
+	return int64(size)
+}
+
+func (s *MemoryStore) setEntry(sh *shard, key string, e entry) {
+	size := entrySize(key, e)
+	if current, exists := sh.data[key]; exists {
+		size -= entrySize(key, current)
+	} else {
+		s.count.Add(1)
+	}
+	if current, exists := sh.data[key]; exists {
+		s.trackCompression(current, -1)
+	}
+	s.trackCompression(e, 1)
+	sh.data[key] = e
+	s.used.Add(size)
+}
+
+func (s *MemoryStore) dropEntry(sh *shard, key string) {
+	if current, exists := sh.data[key]; exists {
+		s.used.Add(-entrySize(key, current))
+		s.count.Add(-1)
+		s.trackCompression(current, -1)
+		delete(sh.data, key)
+	}
+}
+
+type reservation struct {
+	keys   int64
+	memory int64
+}
+
+func tryAdd(counter *atomic.Int64, delta, limit int64) bool {
+	for {
+		current := counter.Load()
+		if current+delta > limit {
+			return false
+		}
+		if counter.CompareAndSwap(current, current+delta) {
+			return true
+		}
+	}
+}
+
+func (s *MemoryStore) reserve(sh *shard, key string, e entry, now time.Time) (int64, error) {
+	if s.limits.MaxMemory <= 0 {
+		return 0, nil
+	}
+
+	need := entrySize(key, e)
+	if need > s.limits.MaxMemory {
+		return 0, types.ErrOutOfMemory
+	}
+	if current, exists := sh.data[key]; exists {
+		need -= s.replacedSize(sh, key, current)
+	}
+	return s.reserveBytes(key, need, now)
+}
+
+func (s *MemoryStore) reserveBytes(key string, need int64, now time.Time) (int64, error) {
+	if s.limits.MaxMemory <= 0 {
+		return 0, nil
+	}
+
+	evicts := s.limits.Eviction != "" && s.limits.Eviction != types.EvictNone && s.pending == nil
+	if !s.exclusive {
+		switch {
+		case need <= 0 && s.used.Load()+need <= s.limits.MaxMemory:
+			return 0, nil
+		case need > 0 && tryAdd(&s.used, need, s.limits.MaxMemory):
+			return need, nil
+		case !evicts:
+			return 0, types.ErrOutOfMemory
+		}
+		return 0, errExclusive
+	}
+
+	for s.used.Load()+need > s.limits.MaxMemory {
+		if !evicts || !s.evictOne(key, now) {
+			return 0, types.ErrOutOfMemory
+		}
+	}
+	return 0, nil
+}
+
+func (s *MemoryStore) replacedSize(sh *shard, key string, current entry) int64 {
+	if !s.keepsHistory() {
+		return entrySize(key, current)
+	}
+
+	versions := sh.history[key]
+	drop := 0
+	if limit := s.historyLimits.MaxVersions; limit > 0 && len(versions)+1 > limit {
+		drop = len(versions) + 1 - limit
+	}
+	return historySize(key, versions[:drop])
+}
+
+func (s *MemoryStore) evict(sh *shard, key string) {
+	if _, exists := sh.data[key]; exists {
+		s.remove(sh, key)
+	}
+	s.dropVersions(sh, key, len(sh.history[key]))
+}
+
+func (s *MemoryStore) evictOne(skip string, now time.Time) bool {
+	var victim string
+	var from *shard
+	var best entry
+	sampled := 0
+
+	start := rand.IntN(len(s.shards))
+	for i := range s.shards {
+		sh := s.shards[(start+i)%len(s.shards)]
+		for key, e := range sh.data {
+			if key == skip {
+				continue
+			}
+			if e.expired(now) {
+				s.evict(sh, key)
+				return true
+			}
+			if s.limits.Eviction == types.EvictVolatileTTL && e.expiresAt.IsZero() {
+				continue
+			}
+
+			if from == nil || s.evictsBefore(e, best) {
+				victim, from, best = key, sh, e
+			}
+			if sampled++; sampled >= evictionSamples {
+				break
+			}
+		}
+		if sampled >= evictionSamples {
+			break
+		}
+	}
+
+	if from == nil {
+		return s.evictHistory(skip)
+	}
+	s.evict(from, victim)
+	s.evictions.Add(1)
+	return true
+}
+
+func (s *MemoryStore) evictHistory(skip string) bool {
+	for _, sh := range s.shards {
+		for key, versions := range sh.history {
+			if key == skip {
+				continue
+			}
+			if _, live := sh.data[key]; !live {
+				s.dropVersions(sh, key, len(versions))
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) evictsBefore(a, b entry) bool {
+	switch s.limits.Eviction {
+	case types.EvictLRU:
+		return lastUsed(a) < lastUsed(b)
+	case types.EvictLFU:
+		if ha, hb := hits(a), hits(b); ha != hb {
+			return ha < hb
+		}
+		return lastUsed(a) < lastUsed(b)
+	case types.EvictVolatileTTL:
+		return a.expiresAt.Before(b.expiresAt)
+	}
+	return false
+}
+
+func lastUsed(e entry) int64 {
+	if e.access == nil {
+		return 0
+	}
+	return e.access.lastUsed.Load()
+}
+
+func hits(e entry) uint32 {
+	if e.access == nil {
+		return 0
+	}
+	return e.access.hits.Load()
+}
+
+func (s *MemoryStore) Stats() types.StoreStats {
+	s.rlockAll()
+	defer s.runlockAll()
+
+	policy := s.limits.Eviction
+	if policy == "" {
+		policy = types.EvictNone
+	}
+	return types.StoreStats{
+		Keys:           s.liveKeys(s.now()),
+		MemoryUsed:     s.used.Load(),
+		MaxMemory:      s.limits.MaxMemory,
+		EvictionPolicy: policy,
+		Evictions:      s.evictions.Load(),
+
+		CompressedKeys:    s.compressedKeys.Load(),
+		CompressedBytes:   s.compressedBytes.Load(),
+		UncompressedBytes: s.uncompressedBytes.Load(),
+	}
+}
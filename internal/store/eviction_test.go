@@ -0,0 +1,231 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func newEvictingStore(policy types.EvictionPolicy, entries int) (*MemoryStore, *time.Time) {
+	store := NewMemoryStore()
+	now := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return now }
+	store.SetLimits(types.Limits{
+		MaxMemory: int64(entries) * entrySize("k0", entry{value: make([]byte, 10)}),
+		Eviction:  policy,
+	})
+	return store, &now
+}
+
+func fill(t *testing.T, store *MemoryStore, now *time.Time, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		*now = now.Add(time.Second)
+		if err := store.Set(key, make([]byte, 10)); err != nil {
+			t.Fatalf("Set %s failed: %v", key, err)
+		}
+	}
+}
+
+func TestMemoryAccounting(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.Set("a", []byte("hello"))
+	store.SetWithOptions("b", []byte("x"), types.SetOptions{Labels: map[string]string{"env": "prod"}})
+	store.HashSet("h", map[string][]byte{"f": []byte("v")})
+	want := entrySize("a", entry{value: []byte("hello")}) +
+		entrySize("b", entry{value: []byte("x"), labels: map[string]string{"env": "prod"}}) +
+		entrySize("h", entry{hash: map[string][]byte{"f": []byte("v")}})
+	if got := store.Stats().MemoryUsed; got != want {
+		t.Errorf("Expected %d bytes in use, got %d", want, got)
+	}
+
+	store.Set("a", []byte("hello, world"))
+	store.Delete("b")
+	store.HashDelete("h", "f")
+	if got, want := store.Stats().MemoryUsed, entrySize("a", entry{value: []byte("hello, world")}); got != want {
+		t.Errorf("Expected %d bytes after overwrite and deletes, got %d", want, got)
+	}
+
+	store.Txn(func(tx types.Tx) error {
+		tx.Put("c", types.Entry{Value: []byte("c")})
+		tx.Delete("a")
+		return errors.New("abort")
+	})
+	if got, want := store.Stats().MemoryUsed, entrySize("a", entry{value: []byte("hello, world")}); got != want {
+		t.Errorf("Expected rollback to restore accounting to %d, got %d", want, got)
+	}
+}
+
+func TestNoEviction(t *testing.T) {
+	store, now := newEvictingStore(types.EvictNone, 2)
+	fill(t, store, now, "k1", "k2")
+
+	if err := store.Set("k3", make([]byte, 10)); !errors.Is(err, types.ErrOutOfMemory) {
+		t.Errorf("Expected out of memory, got %v", err)
+	}
+	if err := store.Set("k1", make([]byte, 10)); err != nil {
+		t.Errorf("Expected overwriting with the same size to fit, got %v", err)
+	}
+	if _, err := store.ListPush("k9", [][]byte{make([]byte, 10)}, false); !errors.Is(err, types.ErrOutOfMemory) {
+		t.Errorf("Expected collections to be limited too, got %v", err)
+	}
+	if stats := store.Stats(); stats.Evictions != 0 || stats.Keys != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestEvictionPolicies(t *testing.T) {
+	t.Run("lru", func(t *testing.T) {
+		store, now := newEvictingStore(types.EvictLRU, 3)
+		fill(t, store, now, "k1", "k2", "k3")
+		*now = now.Add(time.Second)
+		store.Get("k1")
+
+		fill(t, store, now, "k4")
+		if _, err := store.Get("k2"); !errors.Is(err, types.ErrKeyNotFound) {
+			t.Errorf("Expected least recently used key to be evicted, got %v", err)
+		}
+		if _, err := store.Get("k1"); err != nil {
+			t.Errorf("Expected recently read key to survive, got %v", err)
+		}
+	})
+
+	t.Run("lfu", func(t *testing.T) {
+		store, now := newEvictingStore(types.EvictLFU, 3)
+		fill(t, store, now, "k1", "k2", "k3")
+		for i := 0; i < 3; i++ {
+			store.Get("k1")
+			store.Get("k2")
+		}
+		store.Get("k3")
+
+		fill(t, store, now, "k4")
+		if _, err := store.Get("k3"); !errors.Is(err, types.ErrKeyNotFound) {
+			t.Errorf("Expected least frequently used key to be evicted, got %v", err)
+		}
+	})
+
+	t.Run("volatile-ttl", func(t *testing.T) {
+		store, now := newEvictingStore(types.EvictVolatileTTL, 3)
+		fill(t, store, now, "k1")
+		store.SetWithOptions("k2", make([]byte, 10), types.SetOptions{TTL: time.Hour})
+		store.SetWithOptions("k3", make([]byte, 10), types.SetOptions{TTL: time.Minute})
+
+		fill(t, store, now, "k4", "k5")
+		if keys := store.Keys(); len(keys) != 3 {
+			t.Errorf("Expected 3 keys, got %v", keys)
+		}
+		for _, key := range []string{"k2", "k3"} {
+			if _, err := store.Get(key); !errors.Is(err, types.ErrKeyNotFound) {
+				t.Errorf("Expected %s to be evicted, got %v", key, err)
+			}
+		}
+		if err := store.Set("k6", make([]byte, 10)); !errors.Is(err, types.ErrOutOfMemory) {
+			t.Errorf("Expected out of memory once no key has a TTL, got %v", err)
+		}
+	})
+
+	t.Run("random", func(t *testing.T) {
+		store, now := newEvictingStore(types.EvictRandom, 3)
+		for i := 0; i < 20; i++ {
+			fill(t, store, now, fmt.Sprintf("k%c", 'a'+i))
+		}
+		stats := store.Stats()
+		if stats.Keys != 3 || stats.Evictions != 17 || stats.MemoryUsed > stats.MaxMemory {
+			t.Errorf("Unexpected stats %+v", stats)
+		}
+		if _, err := store.Get("kt"); err != nil {
+			t.Errorf("Expected the newest key to be kept, got %v", err)
+		}
+	})
+}
+
+func TestEvictionEvents(t *testing.T) {
+	store, now := newEvictingStore(types.EvictLRU, 1)
+	events, cancel := store.Subscribe("")
+	defer cancel()
+
+	fill(t, store, now, "k1", "k2")
+
+	want := []types.EventType{types.EventPut, types.EventDelete, types.EventPut}
+	for i, typ := range want {
+		event := <-events
+		if event.Type != typ {
+			t.Fatalf("Event %d: expected %s, got %s for %s", i, typ, event.Type, event.Key)
+		}
+	}
+}
+
+func TestNoEvictionInsideTxn(t *testing.T) {
+	store, now := newEvictingStore(types.EvictLRU, 1)
+	fill(t, store, now, "k1")
+
+	err := store.Txn(func(tx types.Tx) error {
+		_, err := tx.Put("k2", types.Entry{Value: make([]byte, 10)})
+		return err
+	})
+	if !errors.Is(err, types.ErrOutOfMemory) {
+		t.Errorf("Expected transactions not to evict, got %v", err)
+	}
+	if _, err := store.Get("k1"); err != nil {
+		t.Errorf("Expected k1 to survive, got %v", err)
+	}
+}
+
+func TestHistoryMemoryAccounting(t *testing.T) {
+	size := entrySize("k1", entry{value: make([]byte, 10)})
+
+	store, now := newEvictingStore(types.EvictNone, 3)
+	store.SetHistoryLimits(types.HistoryLimits{MaxVersions: 3})
+	fill(t, store, now, "k1", "k1", "k1")
+	if got := store.Stats().MemoryUsed; got != 3*size {
+		t.Errorf("Expected retained versions to be charged, got %d bytes in use", got)
+	}
+	if err := store.Set("k1", make([]byte, 10)); !errors.Is(err, types.ErrOutOfMemory) {
+		t.Errorf("Expected history to count against the limit, got %v", err)
+	}
+
+	store.Txn(func(tx types.Tx) error {
+		tx.Delete("k1")
+		return errors.New("abort")
+	})
+	if got := store.Stats().MemoryUsed; got != 3*size {
+		t.Errorf("Expected rollback to restore accounting to %d, got %d", 3*size, got)
+	}
+
+	store.SetHistoryLimits(types.HistoryLimits{MaxVersions: 1})
+	if got := store.Stats().MemoryUsed; got != 2*size {
+		t.Errorf("Expected trimming history to release a version, got %d bytes in use", got)
+	}
+	if err := store.Compact(store.Revision()); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if got := store.Stats().MemoryUsed; got != size {
+		t.Errorf("Expected compaction to release the versions, got %d bytes in use", got)
+	}
+
+	fill(t, store, now, "k1", "k2")
+	if err := store.Set("k1", make([]byte, 10)); err != nil {
+		t.Errorf("Expected the version dropped by the write to make room, got %v", err)
+	}
+
+	store, now = newEvictingStore(types.EvictLRU, 3)
+	store.SetHistoryLimits(types.HistoryLimits{MaxVersions: 5})
+	fill(t, store, now, "k1", "k1", "k2")
+	store.Delete("k2")
+	fill(t, store, now, "k3", "k4")
+	stats := store.Stats()
+	if stats.MemoryUsed > stats.MaxMemory {
+		t.Errorf("Expected eviction to stay under the limit, got %+v", stats)
+	}
+	if history, err := store.History("k1"); err == nil {
+		t.Errorf("Expected evicting a key to drop its history, got %d versions", len(history))
+	}
+	if _, err := store.Get("k4"); err != nil {
+		t.Errorf("Expected the newest key to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,261 @@
+package store
+
+import (
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+type version struct {
+	entry      entry
+	replacedBy uint64
+	replacedAt time.Time
+}
+
+func (s *MemoryStore) HistoryLimits() types.HistoryLimits {
+	s.rlockAll()
+	defer s.runlockAll()
+	return s.historyLimits
+}
+
+func (s *MemoryStore) SetHistoryLimits(limits types.HistoryLimits) {
+	s.lockAll()
+	defer s.unlockAll()
+
+	s.historyLimits = limits
+	now := s.now()
+	for _, sh := range s.shards {
+		for key := range sh.history {
+			s.pruneHistory(sh, key, now)
+		}
+	}
+}
+
+func (s *MemoryStore) record(sh *shard, key string, e entry, replacedBy uint64, now time.Time) {
+	if !s.keepsHistory() {
+		s.compactTo(replacedBy)
+		return
+	}
+
+	sh.history[key] = append(sh.history[key], version{entry: e, replacedBy: replacedBy, replacedAt: now})
+	s.used.Add(entrySize(key, e))
+	s.pruneHistory(sh, key, now)
+}
+
+func (s *MemoryStore) compactTo(revision uint64) {
+	for {
+		current := s.compacted.Load()
+		if revision <= current || s.compacted.CompareAndSwap(current, revision) {
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) pruneHistory(sh *shard, key string, now time.Time) {
+	versions := sh.history[key]
+	limits := s.historyLimits
+
+	drop := 0
+	if limits.MaxVersions <= 0 && limits.MaxAge <= 0 {
+		drop = len(versions)
+	}
+	if limits.MaxVersions > 0 && len(versions)-drop > limits.MaxVersions {
+		drop = len(versions) - limits.MaxVersions
+	}
+	if limits.MaxAge > 0 {
+		for drop < len(versions) && now.Sub(versions[drop].replacedAt) > limits.MaxAge {
+			drop++
+		}
+	}
+
+	s.dropVersions(sh, key, drop)
+}
+
+func (s *MemoryStore) dropVersions(sh *shard, key string, drop int) {
+	versions := sh.history[key]
+	if drop == 0 {
+		return
+	}
+
+	s.compactTo(versions[drop-1].replacedBy)
+	s.used.Add(-historySize(key, versions[:drop]))
+	if drop == len(versions) {
+		delete(sh.history, key)
+		return
+	}
+	sh.history[key] = versions[drop:]
+}
+
+func historySize(key string, versions []version) int64 {
+	var size int64
+	for _, v := range versions {
+		size += entrySize(key, v.entry)
+	}
+	return size
+}
+
+func (s *MemoryStore) keepsHistory() bool {
+	return s.historyLimits.MaxVersions > 0 || s.historyLimits.MaxAge > 0
+}
+
+func (s *MemoryStore) Revision() uint64 {
+	return s.seq.Load()
+}
+
+func (s *MemoryStore) Compact(revision uint64) error {
+	s.lockAll()
+	defer s.unlockAll()
+
+	if err := s.checkRevision(revision); err != nil {
+		return err
+	}
+
+	for _, sh := range s.shards {
+		for key, versions := range sh.history {
+			drop := 0
+			for drop < len(versions) && versions[drop].replacedBy <= revision {
+				drop++
+			}
+			s.dropVersions(sh, key, drop)
+		}
+	}
+	s.compacted.Store(revision)
+	return nil
+}
+
+func (s *MemoryStore) KeysAt(revision uint64) ([]string, error) {
+	s.rlockAll()
+	defer s.runlockAll()
+
+	if err := s.checkRevision(revision); err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for _, sh := range s.shards {
+		for key := range sh.data {
+			if _, err := s.lookupRevision(sh, key, revision); err == nil {
+				keys = append(keys, key)
+			}
+		}
+		for key := range sh.history {
+			if _, live := sh.data[key]; live {
+				continue
+			}
+			if _, err := s.lookupRevision(sh, key, revision); err == nil {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (s *MemoryStore) checkRevision(revision uint64) error {
+	if revision > s.seq.Load() {
+		return types.ErrFutureRevision
+	}
+	if revision < s.compacted.Load() {
+		return types.ErrCompacted
+	}
+	return nil
+}
+
+func (s *MemoryStore) History(key string) ([]types.Entry, error) {
+	if key == "" {
+		return nil, types.ErrEmptyKey
+	}
+
+	sh := s.read(key)
+	defer sh.mu.RUnlock()
+
+	var entries []types.Entry
+	if current, exists := sh.data[key]; exists && !current.expired(s.now()) {
+		e, err := current.export()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	versions := sh.history[key]
+	for i := len(versions) - 1; i >= 0; i-- {
+		e, err := versions[i].entry.export()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	if len(entries) == 0 {
+		return nil, types.ErrKeyNotFound
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) GetRevision(key string, revision uint64) (types.Entry, error) {
+	if key == "" {
+		return types.Entry{}, types.ErrEmptyKey
+	}
+
+	sh := s.read(key)
+	defer sh.mu.RUnlock()
+
+	e, err := s.lookupRevision(sh, key, revision)
+	if err != nil {
+		return types.Entry{}, err
+	}
+	return e.export()
+}
+
+func (s *MemoryStore) lookupRevision(sh *shard, key string, revision uint64) (entry, error) {
+	if revision > s.seq.Load() {
+		return entry{}, types.ErrFutureRevision
+	}
+
+	if current, exists := sh.data[key]; exists && current.revision <= revision {
+		if current.expired(s.now()) {
+			return entry{}, types.ErrKeyNotFound
+		}
+		return current, nil
+	}
+
+	versions := sh.history[key]
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if v.entry.revision > revision {
+			continue
+		}
+		if revision >= v.replacedBy {
+			return entry{}, types.ErrKeyNotFound
+		}
+		return v.entry, nil
+	}
+
+	if revision < s.compacted.Load() {
+		return entry{}, types.ErrCompacted
+	}
+	return entry{}, types.ErrKeyNotFound
+}
+
+func (s *MemoryStore) Restore(key string, revision uint64) (types.Entry, error) {
+	if key == "" {
+		return types.Entry{}, types.ErrEmptyKey
+	}
+
+	var result types.Entry
+	err := s.write(key, func(sh *shard) error {
+		old, err := s.lookupRevision(sh, key, revision)
+		if err != nil {
+			return err
+		}
+
+		e := old.cloneCollection()
+		e.expiresAt = time.Time{}
+		if e, err = s.apply(sh, key, e); err != nil {
+			return err
+		}
+		result, err = e.export()
+		return err
+	})
+	return result, err
+}
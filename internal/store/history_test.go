@@ -0,0 +1,191 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func TestMemoryStoreHistory(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetHistoryLimits(types.HistoryLimits{MaxVersions: 2})
+
+	store.Set("config", []byte("v1"))
+	first, _ := store.GetEntry("config")
+	store.Set("config", []byte("v2"))
+	store.Set("other", []byte("x"))
+	store.Set("config", []byte("v3"))
+	store.Set("config", []byte("v4"))
+
+	entries, err := store.History("config")
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	var values []string
+	for _, e := range entries {
+		values = append(values, string(e.Value))
+	}
+	if len(values) != 3 || values[0] != "v4" || values[1] != "v3" || values[2] != "v2" {
+		t.Errorf("Expected current value and two past versions newest first, got %v", values)
+	}
+
+	if _, err := store.GetRevision("config", first.Revision); err != types.ErrCompacted {
+		t.Errorf("Expected pruned revision to be gone, got %v", err)
+	}
+
+	v2 := entries[2]
+	if e, err := store.GetRevision("config", v2.Revision); err != nil || string(e.Value) != "v2" {
+		t.Errorf("Expected v2 at its revision, got %s (%v)", e.Value, err)
+	}
+	if e, _ := store.GetRevision("config", v2.Revision+1); string(e.Value) != "v2" {
+		t.Errorf("Expected v2 as of the write to another key, got %s", e.Value)
+	}
+
+	restored, err := store.Restore("config", v2.Revision)
+	if err != nil || string(restored.Value) != "v2" || restored.Version != 5 {
+		t.Errorf("Expected restore to write v2 as version 5, got %+v (%v)", restored, err)
+	}
+	if value, _ := store.Get("config"); string(value) != "v2" {
+		t.Errorf("Expected current value v2, got %s", value)
+	}
+}
+
+func TestMemoryStoreHistoryDelete(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetHistoryLimits(types.HistoryLimits{MaxVersions: 10})
+
+	store.Set("config", []byte("v1"))
+	entry, _ := store.GetEntry("config")
+	store.Delete("config")
+	deleted := store.Revision()
+
+	if _, err := store.GetRevision("config", deleted); err != types.ErrKeyNotFound {
+		t.Errorf("Expected key to be missing after its deletion, got %v", err)
+	}
+	if e, err := store.GetRevision("config", entry.Revision); err != nil || string(e.Value) != "v1" {
+		t.Errorf("Expected v1 before the deletion, got %s (%v)", e.Value, err)
+	}
+
+	if _, err := store.Restore("config", entry.Revision); err != nil {
+		t.Fatalf("Failed to restore deleted key: %v", err)
+	}
+	if value, _ := store.Get("config"); string(value) != "v1" {
+		t.Errorf("Expected restored value v1, got %s", value)
+	}
+
+	if _, err := store.History("missing"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStoreHistoryMaxAge(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	store.SetHistoryLimits(types.HistoryLimits{MaxAge: time.Hour})
+
+	store.Set("config", []byte("v1"))
+	store.Set("config", []byte("v2"))
+	now = now.Add(30 * time.Minute)
+	store.Set("config", []byte("v3"))
+
+	if entries, _ := store.History("config"); len(entries) != 3 {
+		t.Errorf("Expected 3 versions, got %d", len(entries))
+	}
+
+	now = now.Add(45 * time.Minute)
+	store.PurgeExpired()
+	if entries, _ := store.History("config"); len(entries) != 2 {
+		t.Errorf("Expected the oldest version to age out, got %d versions", len(entries))
+	}
+
+	store.SetHistoryLimits(types.HistoryLimits{})
+	if entries, _ := store.History("config"); len(entries) != 1 {
+		t.Errorf("Expected disabling history to drop past versions, got %d", len(entries))
+	}
+}
+
+func TestMemoryStoreHistoryTxnRollback(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetHistoryLimits(types.HistoryLimits{MaxVersions: 10})
+	store.Set("config", []byte("v1"))
+
+	store.Txn(func(tx types.Tx) error {
+		tx.Put("config", types.Entry{Value: []byte("v2")})
+		return types.ErrConflict
+	})
+
+	if entries, _ := store.History("config"); len(entries) != 1 {
+		t.Errorf("Expected rolled back write to leave no history, got %d versions", len(entries))
+	}
+}
+
+func TestMemoryStoreSnapshotReads(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetHistoryLimits(types.HistoryLimits{MaxAge: time.Hour})
+
+	store.Set("a", []byte("1"))
+	store.Set("b", []byte("1"))
+	snapshot := store.Revision()
+	store.Set("a", []byte("2"))
+	store.Delete("b")
+	store.Set("c", []byte("1"))
+
+	keys, err := store.KeysAt(snapshot)
+	sort.Strings(keys)
+	if err != nil || strings.Join(keys, ",") != "a,b" {
+		t.Errorf("Expected a,b at revision %d, got %v (%v)", snapshot, keys, err)
+	}
+	if e, _ := store.GetRevision("a", snapshot); string(e.Value) != "1" {
+		t.Errorf("Expected a=1 at revision %d, got %s", snapshot, e.Value)
+	}
+	if _, err := store.GetRevision("c", snapshot); err != types.ErrKeyNotFound {
+		t.Errorf("Expected c to be missing at revision %d, got %v", snapshot, err)
+	}
+	if _, err := store.KeysAt(store.Revision() + 1); err != types.ErrFutureRevision {
+		t.Errorf("Expected ErrFutureRevision, got %v", err)
+	}
+
+	if err := store.Compact(snapshot + 1); err != nil {
+		t.Fatalf("Failed to compact: %v", err)
+	}
+	if _, err := store.KeysAt(snapshot); err != types.ErrCompacted {
+		t.Errorf("Expected ErrCompacted, got %v", err)
+	}
+	if _, err := store.GetRevision("a", snapshot); err != types.ErrCompacted {
+		t.Errorf("Expected ErrCompacted, got %v", err)
+	}
+	if keys, _ := store.KeysAt(snapshot + 1); len(keys) != 2 {
+		t.Errorf("Expected reads at the compaction revision to still work, got %v", keys)
+	}
+	if err := store.Compact(snapshot); err != types.ErrCompacted {
+		t.Errorf("Expected compacting backwards to fail, got %v", err)
+	}
+	versioned := 0
+	for _, sh := range store.shards {
+		versioned += len(sh.history)
+	}
+	if versioned != 1 {
+		t.Errorf("Expected only the versions needed after compaction, got %d keys", versioned)
+	}
+}
+
+func TestMemoryStoreSnapshotReadsWithoutHistory(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.Set("a", []byte("1"))
+	before := store.Revision()
+	store.Set("b", []byte("1"))
+
+	if keys, err := store.KeysAt(before); err != nil || len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("Expected only a before b was created, got %v (%v)", keys, err)
+	}
+
+	store.Set("a", []byte("2"))
+	if _, err := store.GetRevision("a", before); err != types.ErrCompacted {
+		t.Errorf("Expected overwritten version to be unavailable without history, got %v", err)
+	}
+}
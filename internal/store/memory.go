@@ -1,80 +1,811 @@
 package store
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/q4ow/qkrn/pkg/types"
 )
 
+type entry struct {
+	value       []byte
+	kind        types.ValueType
+	list        [][]byte
+	set         map[string]struct{}
+	hash        map[string][]byte
+	contentType string
+	labels      map[string]string
+	flags       uint32
+	codec       codec
+	rawSize     int
+	version     uint64
+	revision    uint64
+	createdAt   time.Time
+	modifiedAt  time.Time
+	expiresAt   time.Time
+	access      *access
+}
+
+func newEntry(e types.Entry) entry {
+	return entry{
+		value:       cloneBytes(e.Value),
+		contentType: e.ContentType,
+		labels:      cloneLabels(e.Labels),
+		flags:       e.Flags,
+		expiresAt:   e.ExpiresAt,
+	}
+}
+
+func (e entry) export() (types.Entry, error) {
+	value, err := e.plain()
+	if err != nil {
+		return types.Entry{}, err
+	}
+	return types.Entry{
+		Value:       value,
+		Type:        e.valueType(),
+		Length:      e.length(),
+		ContentType: e.contentType,
+		Labels:      cloneLabels(e.labels),
+		Flags:       e.flags,
+		Version:     e.version,
+		Revision:    e.revision,
+		CreatedAt:   e.createdAt,
+		ModifiedAt:  e.modifiedAt,
+		ExpiresAt:   e.expiresAt,
+	}, nil
+}
+
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func cloneLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	clone := make(map[string]string, len(labels))
+	for k, v := range labels {
+		clone[k] = v
+	}
+	return clone
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+const subscriberBuffer = 256
+
+type subscriber struct {
+	prefix string
+	ch     chan types.Event
+}
+
 type MemoryStore struct {
-	data map[string]string
-	mu   sync.RWMutex
+	shards    []*shard
+	mask      uint32
+	seq       atomic.Uint64
+	count     atomic.Int64
+	now       func() time.Time
+	pending   *[]types.Event
+	exclusive bool
+	limits    types.Limits
+
+	subMu    sync.Mutex
+	subs     map[*subscriber]struct{}
+	watchers atomic.Int32
+
+	used      atomic.Int64
+	evictions atomic.Uint64
+
+	historyLimits types.HistoryLimits
+	compacted     atomic.Uint64
+
+	compression       compression
+	compressedKeys    atomic.Int64
+	compressedBytes   atomic.Int64
+	uncompressedBytes atomic.Int64
 }
 
 func NewMemoryStore() *MemoryStore {
-	return &MemoryStore{
-		data: make(map[string]string),
+	return NewShardedMemoryStore(DefaultShards)
+}
+
+func NewShardedMemoryStore(shards int) *MemoryStore {
+	n := shardCount(shards)
+	s := &MemoryStore{
+		shards: make([]*shard, n),
+		mask:   uint32(n - 1),
+		now:    time.Now,
+		subs:   make(map[*subscriber]struct{}),
+	}
+	for i := range s.shards {
+		s.shards[i] = &shard{data: make(map[string]entry), history: make(map[string][]version)}
+	}
+	return s
+}
+
+func (s *MemoryStore) Get(key string) ([]byte, error) {
+	if key == "" {
+		return nil, types.ErrEmptyKey
+	}
+
+	sh := s.read(key)
+	defer sh.mu.RUnlock()
+
+	now := s.now()
+	e, exists := sh.data[key]
+	if !exists || e.expired(now) {
+		return nil, types.ErrKeyNotFound
+	}
+	if e.valueType() != types.TypeString {
+		return nil, types.ErrWrongType
+	}
+
+	e.touch(now)
+	return e.plain()
+}
+
+func (s *MemoryStore) Set(key string, value []byte) error {
+	return s.SetWithOptions(key, value, types.SetOptions{})
+}
+
+func (s *MemoryStore) SetWithOptions(key string, value []byte, opts types.SetOptions) error {
+	if key == "" {
+		return types.ErrEmptyKey
+	}
+
+	return s.write(key, func(sh *shard) error {
+		now := s.now()
+		current, exists := sh.data[key]
+		if exists && current.expired(now) {
+			exists = false
+		}
+
+		if opts.OnlyIfAbsent && exists {
+			return types.ErrKeyExists
+		}
+		if opts.OnlyIfExists && !exists {
+			return types.ErrKeyNotFound
+		}
+
+		e := entry{value: cloneBytes(value), contentType: opts.ContentType, labels: cloneLabels(opts.Labels)}
+		switch {
+		case opts.TTL > 0:
+			e.expiresAt = now.Add(opts.TTL)
+		case opts.KeepTTL && exists:
+			e.expiresAt = current.expiresAt
+		}
+
+		_, err := s.apply(sh, key, e)
+		return err
+	})
+}
+
+func (s *MemoryStore) apply(sh *shard, key string, e entry) (entry, error) {
+	r, err := s.admit(sh, key, &e)
+	if err != nil {
+		return entry{}, err
+	}
+	defer s.release(r)
+	return s.put(sh, key, e), nil
+}
+
+func (s *MemoryStore) admit(sh *shard, key string, e *entry) (reservation, error) {
+	if s.limits.MaxValueSize > 0 && e.valueSize() > s.limits.MaxValueSize {
+		return reservation{}, types.ErrValueTooLarge
+	}
+	s.compress(e)
+
+	var r reservation
+	now := s.now()
+	if max := int64(s.limits.MaxKeys); max > 0 {
+		if current, exists := sh.data[key]; !exists || current.expired(now) {
+			switch {
+			case !s.exclusive && !tryAdd(&s.count, 1, max):
+				return reservation{}, errExclusive
+			case !s.exclusive:
+				r.keys = 1
+			case s.count.Load() >= max && s.liveKeys(now) >= int(max):
+				return reservation{}, types.ErrKeyLimit
+			}
+		}
+	}
+
+	if s.limits.DefaultTTL > 0 && e.expiresAt.IsZero() {
+		e.expiresAt = now.Add(s.limits.DefaultTTL)
+	}
+
+	memory, err := s.reserve(sh, key, *e, now)
+	if err != nil {
+		s.release(r)
+		return reservation{}, err
+	}
+	r.memory = memory
+	return r, nil
+}
+
+func (s *MemoryStore) release(r reservation) {
+	if r.keys != 0 {
+		s.count.Add(-r.keys)
+	}
+	if r.memory != 0 {
+		s.used.Add(-r.memory)
+	}
+}
+
+func (s *MemoryStore) liveKeys(now time.Time) int {
+	live := 0
+	for _, sh := range s.shards {
+		for _, e := range sh.data {
+			if !e.expired(now) {
+				live++
+			}
+		}
+	}
+	return live
+}
+
+func (s *MemoryStore) Limits() types.Limits {
+	s.rlockAll()
+	defer s.runlockAll()
+	return s.limits
+}
+
+func (s *MemoryStore) SetLimits(limits types.Limits) {
+	s.lockAll()
+	defer s.unlockAll()
+	s.limits = limits
+}
+
+func (s *MemoryStore) put(sh *shard, key string, e entry) entry {
+	now := s.now()
+	e.revision = s.seq.Add(1)
+	e.modifiedAt = now
+
+	current, exists := sh.data[key]
+	if exists && !current.expired(now) {
+		e.version = current.version + 1
+		e.createdAt = current.createdAt
+		e.access = current.access
+	} else {
+		e.version = 1
+		e.createdAt = now
+		e.access = &access{}
+	}
+	e.touch(now)
+	if exists {
+		s.record(sh, key, current, e.revision, now)
+	}
+
+	s.setEntry(sh, key, e)
+	s.publishPut(key, e)
+	return e
+}
+
+func (s *MemoryStore) publishPut(key string, e entry) {
+	if !s.publishing() {
+		return
+	}
+	exported, err := e.export()
+	if err != nil {
+		return
+	}
+	s.publish(types.Event{Type: types.EventPut, Key: key, Entry: exported})
+}
+
+func (s *MemoryStore) remove(sh *shard, key string) {
+	revision := s.seq.Add(1)
+	if current, exists := sh.data[key]; exists {
+		s.record(sh, key, current, revision, s.now())
+	}
+	s.dropEntry(sh, key)
+	if s.publishing() {
+		s.publish(types.Event{Type: types.EventDelete, Key: key, Entry: types.Entry{Revision: revision}})
+	}
+}
+
+func (s *MemoryStore) publishing() bool {
+	return s.pending != nil || s.watchers.Load() > 0
+}
+
+func (s *MemoryStore) publish(event types.Event) {
+	if s.pending != nil {
+		*s.pending = append(*s.pending, event)
+		return
+	}
+
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+
+	for sub := range s.subs {
+		if !strings.HasPrefix(event.Key, sub.prefix) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			s.unsubscribe(sub)
+		}
+	}
+}
+
+func (s *MemoryStore) unsubscribe(sub *subscriber) {
+	if _, ok := s.subs[sub]; ok {
+		delete(s.subs, sub)
+		s.watchers.Add(-1)
+		close(sub.ch)
+	}
+}
+
+func (s *MemoryStore) Subscribe(prefix string) (<-chan types.Event, func()) {
+	sub := &subscriber{prefix: prefix, ch: make(chan types.Event, subscriberBuffer)}
+
+	s.subMu.Lock()
+	s.subs[sub] = struct{}{}
+	s.watchers.Add(1)
+	s.subMu.Unlock()
+
+	cancel := func() {
+		s.subMu.Lock()
+		defer s.subMu.Unlock()
+		s.unsubscribe(sub)
+	}
+	return sub.ch, cancel
+}
+
+func (s *MemoryStore) GetEntry(key string) (types.Entry, error) {
+	if key == "" {
+		return types.Entry{}, types.ErrEmptyKey
+	}
+
+	sh := s.read(key)
+	defer sh.mu.RUnlock()
+
+	now := s.now()
+	e, exists := sh.data[key]
+	if !exists || e.expired(now) {
+		return types.Entry{}, types.ErrKeyNotFound
+	}
+
+	e.touch(now)
+	return e.export()
+}
+
+func (s *MemoryStore) Update(key string, fn types.UpdateFunc) (types.Entry, error) {
+	if key == "" {
+		return types.Entry{}, types.ErrEmptyKey
 	}
+
+	var result types.Entry
+	err := s.write(key, func(sh *shard) error {
+		current, exists := sh.data[key]
+		if exists && current.expired(s.now()) {
+			current, exists = entry{}, false
+		}
+		if exists && current.valueType() != types.TypeString {
+			return types.ErrWrongType
+		}
+
+		prev, err := current.export()
+		if err != nil {
+			return err
+		}
+		next, err := fn(prev, exists)
+		if err != nil {
+			return err
+		}
+
+		e, err := s.apply(sh, key, newEntry(next))
+		if err != nil {
+			return err
+		}
+		result, err = e.export()
+		return err
+	})
+	return result, err
+}
+
+func (s *MemoryStore) Incr(key string, delta int64, opts types.IncrOptions) (int64, error) {
+	var result int64
+	err := s.updateNumber(key, func(current []byte, exists bool) ([]byte, error) {
+		n := opts.Initial
+		if exists {
+			var err error
+			if n, err = strconv.ParseInt(string(current), 10, 64); err != nil {
+				return nil, types.ErrNotNumber
+			}
+		}
+
+		next := n + delta
+		if (delta > 0 && next < n) || (delta < 0 && next > n) {
+			return nil, types.ErrOutOfRange
+		}
+		if (opts.Min != nil && next < *opts.Min) || (opts.Max != nil && next > *opts.Max) {
+			return nil, types.ErrOutOfRange
+		}
+
+		result = next
+		return []byte(strconv.FormatInt(next, 10)), nil
+	})
+	return result, err
 }
 
-func (s *MemoryStore) Get(key string) (string, error) {
+func (s *MemoryStore) IncrFloat(key string, delta float64, opts types.IncrFloatOptions) (float64, error) {
+	var result float64
+	err := s.updateNumber(key, func(current []byte, exists bool) ([]byte, error) {
+		n := opts.Initial
+		if exists {
+			var err error
+			if n, err = strconv.ParseFloat(string(current), 64); err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+				return nil, types.ErrNotNumber
+			}
+		}
+
+		next := n + delta
+		if math.IsNaN(next) || math.IsInf(next, 0) {
+			return nil, types.ErrOutOfRange
+		}
+		if (opts.Min != nil && next < *opts.Min) || (opts.Max != nil && next > *opts.Max) {
+			return nil, types.ErrOutOfRange
+		}
+
+		result = next
+		return []byte(strconv.FormatFloat(next, 'f', -1, 64)), nil
+	})
+	return result, err
+}
+
+func (s *MemoryStore) updateNumber(key string, fn func(current []byte, exists bool) ([]byte, error)) error {
 	if key == "" {
-		return "", types.ErrEmptyKey
+		return types.ErrEmptyKey
 	}
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return s.write(key, func(sh *shard) error {
+		current, exists := sh.data[key]
+		if exists && current.expired(s.now()) {
+			current, exists = entry{}, false
+		}
+		if exists && current.valueType() != types.TypeString {
+			return types.ErrWrongType
+		}
+
+		prev, err := current.plain()
+		if err != nil {
+			return err
+		}
+		value, err := fn(prev, exists)
+		if err != nil {
+			return err
+		}
+
+		e := current
+		e.value, e.codec, e.rawSize = value, codecNone, 0
+		_, err = s.apply(sh, key, e)
+		return err
+	})
+}
 
-	value, exists := s.data[key]
+func (s *MemoryStore) Txn(fn func(tx types.Tx) error) error {
+	s.lockAll()
+	defer s.unlockAll()
+
+	events := []types.Event{}
+	s.pending = &events
+	s.exclusive = true
+	tx := &memoryTx{store: s, undo: make(map[string]undoEntry)}
+	err := fn(tx)
+	s.pending = nil
+	s.exclusive = false
+
+	if err != nil {
+		tx.rollback()
+		return err
+	}
+
+	for _, event := range events {
+		s.publish(event)
+	}
+	return nil
+}
+
+type undoEntry struct {
+	entry   entry
+	exists  bool
+	history []version
+}
+
+type memoryTx struct {
+	store *MemoryStore
+	undo  map[string]undoEntry
+}
+
+func (tx *memoryTx) lookup(key string) (entry, bool) {
+	e, exists := tx.store.shardFor(key).data[key]
+	if !exists || e.expired(tx.store.now()) {
+		return entry{}, false
+	}
+	return e, true
+}
+
+func (tx *memoryTx) save(key string) {
+	if _, saved := tx.undo[key]; saved {
+		return
+	}
+	sh := tx.store.shardFor(key)
+	e, exists := sh.data[key]
+	tx.undo[key] = undoEntry{entry: e, exists: exists, history: sh.history[key]}
+}
+
+func (tx *memoryTx) rollback() {
+	for key, u := range tx.undo {
+		sh := tx.store.shardFor(key)
+		if u.exists {
+			tx.store.setEntry(sh, key, u.entry)
+		} else {
+			tx.store.dropEntry(sh, key)
+		}
+		tx.store.used.Add(historySize(key, u.history) - historySize(key, sh.history[key]))
+		if u.history != nil {
+			sh.history[key] = u.history
+		} else {
+			delete(sh.history, key)
+		}
+	}
+}
+
+func (tx *memoryTx) GetEntry(key string) (types.Entry, error) {
+	if key == "" {
+		return types.Entry{}, types.ErrEmptyKey
+	}
+
+	e, exists := tx.lookup(key)
 	if !exists {
-		return "", types.ErrKeyNotFound
+		return types.Entry{}, types.ErrKeyNotFound
+	}
+	return e.export()
+}
+
+func (tx *memoryTx) Put(key string, next types.Entry) (types.Entry, error) {
+	if key == "" {
+		return types.Entry{}, types.ErrEmptyKey
 	}
 
-	return value, nil
+	sh := tx.store.shardFor(key)
+	e := newEntry(next)
+	r, err := tx.store.admit(sh, key, &e)
+	if err != nil {
+		return types.Entry{}, err
+	}
+	defer tx.store.release(r)
+
+	tx.save(key)
+	e = tx.store.put(sh, key, e)
+	return e.export()
 }
 
-func (s *MemoryStore) Set(key, value string) error {
+func (tx *memoryTx) Delete(key string) error {
 	if key == "" {
 		return types.ErrEmptyKey
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	if _, exists := tx.lookup(key); !exists {
+		return types.ErrKeyNotFound
+	}
 
-	s.data[key] = value
+	tx.save(key)
+	tx.store.remove(tx.store.shardFor(key), key)
 	return nil
 }
 
+func (tx *memoryTx) Keys() []string {
+	return tx.store.keys(tx.store.now())
+}
+
 func (s *MemoryStore) Delete(key string) error {
 	if key == "" {
 		return types.ErrEmptyKey
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
 
-	if _, exists := s.data[key]; !exists {
+	e, exists := sh.data[key]
+	if !exists {
 		return types.ErrKeyNotFound
 	}
 
-	delete(s.data, key)
+	if e.expired(s.now()) {
+		s.dropEntry(sh, key)
+		return types.ErrKeyNotFound
+	}
+	s.remove(sh, key)
 	return nil
 }
 
-func (s *MemoryStore) Keys() []string {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *MemoryStore) Expire(key string, ttl time.Duration) error {
+	if key == "" {
+		return types.ErrEmptyKey
+	}
+
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
 
-	keys := make([]string, 0, len(s.data))
-	for key := range s.data {
-		keys = append(keys, key)
+	now := s.now()
+	e, exists := sh.data[key]
+	if !exists || e.expired(now) {
+		return types.ErrKeyNotFound
+	}
+
+	if ttl <= 0 {
+		s.remove(sh, key)
+		return nil
+	}
+
+	e.expiresAt = now.Add(ttl)
+	s.setEntry(sh, key, e)
+	return nil
+}
+
+func (s *MemoryStore) Persist(key string) error {
+	if key == "" {
+		return types.ErrEmptyKey
+	}
+
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	e, exists := sh.data[key]
+	if !exists || e.expired(s.now()) {
+		return types.ErrKeyNotFound
+	}
+
+	e.expiresAt = time.Time{}
+	s.setEntry(sh, key, e)
+	return nil
+}
+
+func (s *MemoryStore) TTL(key string) (time.Duration, bool, error) {
+	if key == "" {
+		return 0, false, types.ErrEmptyKey
+	}
+
+	sh := s.read(key)
+	defer sh.mu.RUnlock()
+
+	now := s.now()
+	e, exists := sh.data[key]
+	if !exists || e.expired(now) {
+		return 0, false, types.ErrKeyNotFound
+	}
+
+	if e.expiresAt.IsZero() {
+		return 0, false, nil
+	}
+	return e.expiresAt.Sub(now), true, nil
+}
+
+func (s *MemoryStore) PurgeExpired() int {
+	purged := 0
+	for _, sh := range s.shards {
+		purged += s.purgeShard(sh)
 	}
+	return purged
+}
+
+func (s *MemoryStore) purgeShard(sh *shard) int {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	now := s.now()
+	purged := 0
+	for key, e := range sh.data {
+		if e.expired(now) {
+			s.remove(sh, key)
+			purged++
+		}
+	}
+	for key := range sh.history {
+		s.pruneHistory(sh, key, now)
+	}
+
+	return purged
+}
 
+func (s *MemoryStore) Keys() []string {
+	s.rlockAll()
+	defer s.runlockAll()
+
+	return s.keys(s.now())
+}
+
+func (s *MemoryStore) keys(now time.Time) []string {
+	keys := make([]string, 0, s.count.Load())
+	for _, sh := range s.shards {
+		for key, e := range sh.data {
+			if !e.expired(now) {
+				keys = append(keys, key)
+			}
+		}
+	}
 	return keys
 }
 
+func (s *MemoryStore) Scan(start, end string, fn func(kv types.KeyValue) bool) error {
+	s.rlockAll()
+	now := s.now()
+	var kvs []types.KeyValue
+	for _, sh := range s.shards {
+		for key, e := range sh.data {
+			if key < start || (end != "" && key >= end) || e.expired(now) || e.valueType() != types.TypeString {
+				continue
+			}
+			value, err := e.plain()
+			if err != nil {
+				s.runlockAll()
+				return err
+			}
+			kvs = append(kvs, types.KeyValue{Key: key, Value: value})
+		}
+	}
+	s.runlockAll()
+
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	for _, kv := range kvs {
+		if !fn(kv) {
+			break
+		}
+	}
+	return nil
+}
+
+var healthTimeout = time.Second
+
+func (s *MemoryStore) Healthy() error {
+	if len(s.shards) == 0 {
+		return errors.New("store not initialized")
+	}
+
+	var limits types.Limits
+	deadline := time.Now().Add(healthTimeout)
+	for i, sh := range s.shards {
+		for !sh.mu.TryRLock() {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("store is not responding: timed out waiting for shard %d", i)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if i == 0 {
+			limits = s.limits
+		}
+		sh.mu.RUnlock()
+	}
+
+	evicts := limits.Eviction != "" && limits.Eviction != types.EvictNone
+	if used := s.used.Load(); limits.MaxMemory > 0 && !evicts && used+entryOverhead > limits.MaxMemory {
+		return fmt.Errorf("store is full: %d of %d bytes used and eviction is disabled", used, limits.MaxMemory)
+	}
+	return nil
+}
+
 func (s *MemoryStore) Size() int {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return len(s.data)
+	s.rlockAll()
+	defer s.runlockAll()
+
+	return s.liveKeys(s.now())
 }
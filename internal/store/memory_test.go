@@ -1,7 +1,13 @@
 package store
 
 import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/q4ow/qkrn/pkg/types"
 )
@@ -9,7 +15,7 @@ import (
 func TestMemoryStore(t *testing.T) {
 	store := NewMemoryStore()
 
-	err := store.Set("key1", "value1")
+	err := store.Set("key1", []byte("value1"))
 	if err != nil {
 		t.Fatalf("Set failed: %v", err)
 	}
@@ -18,7 +24,7 @@ func TestMemoryStore(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Get failed: %v", err)
 	}
-	if value != "value1" {
+	if string(value) != "value1" {
 		t.Errorf("Expected 'value1', got '%s'", value)
 	}
 
@@ -37,9 +43,9 @@ func TestMemoryStore(t *testing.T) {
 		t.Errorf("Expected ErrKeyNotFound after delete, got %v", err)
 	}
 
-	store.Set("a", "1")
-	store.Set("b", "2")
-	store.Set("c", "3")
+	store.Set("a", []byte("1"))
+	store.Set("b", []byte("2"))
+	store.Set("c", []byte("3"))
 
 	keys := store.Keys()
 	if len(keys) != 3 {
@@ -50,8 +56,447 @@ func TestMemoryStore(t *testing.T) {
 		t.Errorf("Expected size 3, got %d", store.Size())
 	}
 
-	err = store.Set("", "value")
+	err = store.Set("", []byte("value"))
 	if err != types.ErrEmptyKey {
 		t.Errorf("Expected ErrEmptyKey, got %v", err)
 	}
 }
+
+func TestMemoryStoreTTL(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	if err := store.SetWithOptions("session", []byte("abc"), types.SetOptions{TTL: time.Minute}); err != nil {
+		t.Fatalf("SetWithOptions failed: %v", err)
+	}
+
+	ttl, hasTTL, err := store.TTL("session")
+	if err != nil || !hasTTL || ttl != time.Minute {
+		t.Errorf("Expected 1m TTL, got %v %v %v", ttl, hasTTL, err)
+	}
+
+	store.Set("permanent", []byte("value"))
+	if _, hasTTL, _ := store.TTL("permanent"); hasTTL {
+		t.Error("Expected key without TTL")
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if _, err := store.Get("session"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected expired key to be not found, got %v", err)
+	}
+
+	if keys := store.Keys(); len(keys) != 1 || keys[0] != "permanent" {
+		t.Errorf("Expected only permanent key, got %v", keys)
+	}
+
+	if purged := store.PurgeExpired(); purged != 1 {
+		t.Errorf("Expected 1 purged key, got %d", purged)
+	}
+
+	if err := store.Expire("permanent", time.Second); err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	if err := store.Persist("permanent"); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+	if _, hasTTL, _ := store.TTL("permanent"); hasTTL {
+		t.Error("Expected TTL to be removed by Persist")
+	}
+
+	if err := store.Expire("missing", time.Second); err != types.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStoreConditionalSet(t *testing.T) {
+	store := NewMemoryStore()
+
+	if err := store.SetWithOptions("key", []byte("v1"), types.SetOptions{OnlyIfExists: true}); err != types.ErrKeyNotFound {
+		t.Errorf("Expected ErrKeyNotFound for XX on missing key, got %v", err)
+	}
+
+	if err := store.SetWithOptions("key", []byte("v1"), types.SetOptions{OnlyIfAbsent: true, TTL: time.Hour}); err != nil {
+		t.Fatalf("Expected NX set to succeed, got %v", err)
+	}
+
+	if err := store.SetWithOptions("key", []byte("v2"), types.SetOptions{OnlyIfAbsent: true}); err != types.ErrKeyExists {
+		t.Errorf("Expected ErrKeyExists for NX on existing key, got %v", err)
+	}
+
+	if err := store.SetWithOptions("key", []byte("v3"), types.SetOptions{OnlyIfExists: true, KeepTTL: true}); err != nil {
+		t.Fatalf("Expected XX set to succeed, got %v", err)
+	}
+
+	if _, hasTTL, _ := store.TTL("key"); !hasTTL {
+		t.Error("Expected KEEPTTL to preserve expiry")
+	}
+
+	store.Set("key", []byte("v4"))
+	if _, hasTTL, _ := store.TTL("key"); hasTTL {
+		t.Error("Expected plain Set to clear expiry")
+	}
+}
+
+func TestMemoryStoreUpdate(t *testing.T) {
+	store := NewMemoryStore()
+
+	created, err := store.Update("counter", func(current types.Entry, exists bool) (types.Entry, error) {
+		if exists {
+			t.Error("Expected key not to exist yet")
+		}
+		return types.Entry{Value: []byte("1"), Flags: 42}, nil
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if created.Version == 0 || created.Flags != 42 {
+		t.Errorf("Unexpected entry: %+v", created)
+	}
+
+	store.Set("other", []byte("value"))
+
+	updated, err := store.Update("counter", func(current types.Entry, exists bool) (types.Entry, error) {
+		if !exists || string(current.Value) != "1" {
+			t.Errorf("Expected existing value '1', got %+v", current)
+		}
+		current.Value = []byte("2")
+		return current, nil
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Version <= created.Version {
+		t.Errorf("Expected version to increase, got %d after %d", updated.Version, created.Version)
+	}
+
+	_, err = store.Update("counter", func(current types.Entry, exists bool) (types.Entry, error) {
+		return current, types.ErrConflict
+	})
+	if err != types.ErrConflict {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+
+	entry, err := store.GetEntry("counter")
+	if err != nil {
+		t.Fatalf("GetEntry failed: %v", err)
+	}
+	if string(entry.Value) != "2" || entry.Version != updated.Version || entry.Flags != 42 {
+		t.Errorf("Unexpected entry after failed update: %+v", entry)
+	}
+
+	store.Set("counter", []byte("plain"))
+	if entry, _ := store.GetEntry("counter"); entry.Flags != 0 {
+		t.Errorf("Expected plain Set to reset flags, got %d", entry.Flags)
+	}
+}
+
+func TestMemoryStoreSubscribe(t *testing.T) {
+	store := NewMemoryStore()
+
+	events, cancel := store.Subscribe("app/")
+	store.Set("app/a", []byte("1"))
+	store.Set("other", []byte("x"))
+	store.Delete("app/a")
+
+	put := <-events
+	if put.Type != types.EventPut || put.Key != "app/a" || string(put.Entry.Value) != "1" {
+		t.Errorf("Unexpected put event: %+v", put)
+	}
+	del := <-events
+	if del.Type != types.EventDelete || del.Key != "app/a" || del.Entry.Revision <= put.Entry.Revision {
+		t.Errorf("Unexpected delete event: %+v", del)
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("Expected channel to be closed after cancel")
+	}
+	cancel()
+
+	slow, cancelSlow := store.Subscribe("")
+	defer cancelSlow()
+	for i := 0; i < subscriberBuffer+1; i++ {
+		store.Set("key", []byte("v"))
+	}
+	for range slow {
+	}
+}
+
+func TestMemoryStoreTxn(t *testing.T) {
+	store := NewMemoryStore()
+	store.Set("a", []byte("1"))
+	events, cancel := store.Subscribe("")
+	defer cancel()
+
+	err := store.Txn(func(tx types.Tx) error {
+		if _, err := tx.Put("b", types.Entry{Value: []byte("2")}); err != nil {
+			return err
+		}
+		if err := tx.Delete("a"); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	if err == nil || err.Error() != "abort" {
+		t.Fatalf("Expected abort error, got %v", err)
+	}
+	if value, _ := store.Get("a"); string(value) != "1" {
+		t.Errorf("Expected rollback to restore 'a', got '%s'", value)
+	}
+	if _, err := store.Get("b"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected rollback to remove 'b', got %v", err)
+	}
+
+	err = store.Txn(func(tx types.Tx) error {
+		entry, err := tx.GetEntry("a")
+		if err != nil {
+			return err
+		}
+		_, err = tx.Put("a", types.Entry{Value: append(entry.Value, '1')})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Txn failed: %v", err)
+	}
+	if value, _ := store.Get("a"); string(value) != "11" {
+		t.Errorf("Expected '11', got '%s'", value)
+	}
+
+	event := <-events
+	if event.Key != "a" || string(event.Entry.Value) != "11" {
+		t.Errorf("Expected only the committed event, got %+v", event)
+	}
+}
+
+func TestMemoryStoreBinaryValues(t *testing.T) {
+	store := NewMemoryStore()
+	value := []byte{0x00, 0xff, 'a'}
+
+	if err := store.SetWithOptions("blob", value, types.SetOptions{ContentType: "image/png"}); err != nil {
+		t.Fatalf("SetWithOptions failed: %v", err)
+	}
+	value[0] = 'x'
+
+	entry, err := store.GetEntry("blob")
+	if err != nil {
+		t.Fatalf("GetEntry failed: %v", err)
+	}
+	if entry.Value[0] != 0x00 || entry.ContentType != "image/png" {
+		t.Errorf("Expected stored copy with content type, got %v %q", entry.Value, entry.ContentType)
+	}
+
+	entry.Value[1] = 'y'
+	if got, _ := store.Get("blob"); got[1] != 0xff {
+		t.Error("Expected Get to be unaffected by mutating a returned value")
+	}
+
+	store.Set("blob", []byte("text"))
+	if entry, _ := store.GetEntry("blob"); entry.ContentType != "" {
+		t.Errorf("Expected plain Set to clear content type, got %q", entry.ContentType)
+	}
+}
+
+func TestMemoryStoreMetadata(t *testing.T) {
+	store := NewMemoryStore()
+	labels := map[string]string{"owner": "billing"}
+
+	if err := store.SetWithOptions("key", []byte("v1"), types.SetOptions{Labels: labels}); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+	labels["owner"] = "mutated"
+
+	first, err := store.GetEntry("key")
+	if err != nil {
+		t.Fatalf("Failed to get entry: %v", err)
+	}
+	if first.Version != 1 || first.CreatedAt.IsZero() || !first.CreatedAt.Equal(first.ModifiedAt) {
+		t.Errorf("Unexpected metadata after create: %+v", first)
+	}
+	if first.Labels["owner"] != "billing" {
+		t.Errorf("Expected labels to be copied on write, got %v", first.Labels)
+	}
+
+	time.Sleep(time.Millisecond)
+	store.Set("key", []byte("v2"))
+
+	second, _ := store.GetEntry("key")
+	if second.Version != 2 || second.Revision <= first.Revision {
+		t.Errorf("Expected version and revision to advance, got %+v", second)
+	}
+	if !second.CreatedAt.Equal(first.CreatedAt) || !second.ModifiedAt.After(first.ModifiedAt) {
+		t.Errorf("Expected created time to be kept and modified time to advance, got %+v", second)
+	}
+
+	store.Delete("key")
+	store.Set("key", []byte("v3"))
+	if third, _ := store.GetEntry("key"); third.Version != 1 || third.Revision <= second.Revision {
+		t.Errorf("Expected recreated key to restart its version, got %+v", third)
+	}
+}
+
+func TestMemoryStoreLimits(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetLimits(types.Limits{DefaultTTL: time.Minute, MaxKeys: 2, MaxValueSize: 4})
+
+	if err := store.Set("big", []byte("too large")); err != types.ErrValueTooLarge {
+		t.Errorf("Expected ErrValueTooLarge, got %v", err)
+	}
+
+	store.Set("a", []byte("1"))
+	store.Set("b", []byte("2"))
+	if err := store.Set("c", []byte("3")); err != types.ErrKeyLimit {
+		t.Errorf("Expected ErrKeyLimit, got %v", err)
+	}
+	if err := store.Set("a", []byte("11")); err != nil {
+		t.Errorf("Expected overwrite at the key limit to succeed, got %v", err)
+	}
+	if _, err := store.Update("c", func(types.Entry, bool) (types.Entry, error) {
+		return types.Entry{Value: []byte("3")}, nil
+	}); err != types.ErrKeyLimit {
+		t.Errorf("Expected Update to respect the key limit, got %v", err)
+	}
+
+	if ttl, ok, _ := store.TTL("a"); !ok || ttl <= 0 || ttl > time.Minute {
+		t.Errorf("Expected default TTL to apply, got %v %v", ttl, ok)
+	}
+	store.SetWithOptions("b", []byte("2"), types.SetOptions{TTL: time.Hour})
+	if ttl, _, _ := store.TTL("b"); ttl <= time.Minute {
+		t.Errorf("Expected explicit TTL to win over the default, got %v", ttl)
+	}
+
+	store.Delete("b")
+	if err := store.Set("c", []byte("3")); err != nil {
+		t.Errorf("Expected a free slot after delete, got %v", err)
+	}
+}
+
+func TestMemoryStoreHealthy(t *testing.T) {
+	store := NewMemoryStore()
+	store.Set("a", []byte("1"))
+	if err := store.Healthy(); err != nil {
+		t.Fatalf("Expected a new store to be healthy, got %v", err)
+	}
+
+	store.SetLimits(types.Limits{MaxMemory: store.Stats().MemoryUsed})
+	if err := store.Healthy(); err == nil {
+		t.Error("Expected a full store without eviction to be unhealthy")
+	}
+	store.SetLimits(types.Limits{MaxMemory: store.Stats().MemoryUsed, Eviction: types.EvictLRU})
+	if err := store.Healthy(); err != nil {
+		t.Errorf("Expected a full store with eviction to be healthy, got %v", err)
+	}
+
+	defer func(timeout time.Duration) { healthTimeout = timeout }(healthTimeout)
+	healthTimeout = 10 * time.Millisecond
+	store.shards[0].mu.RLock()
+	err := store.Healthy()
+	store.shards[0].mu.RUnlock()
+	if err != nil {
+		t.Errorf("Expected concurrent readers not to make the store unhealthy, got %v", err)
+	}
+
+	store.shards[0].mu.Lock()
+	err = store.Healthy()
+	store.shards[0].mu.Unlock()
+	if err == nil {
+		t.Error("Expected a store with a stuck shard to be unhealthy")
+	}
+}
+
+func TestMemoryStoreIncr(t *testing.T) {
+	store := NewMemoryStore()
+
+	if n, err := store.Incr("hits", 5, types.IncrOptions{Initial: 10}); err != nil || n != 15 {
+		t.Errorf("Expected initial value plus delta, got %d (%v)", n, err)
+	}
+	if n, err := store.Incr("hits", -20, types.IncrOptions{Initial: 10}); err != nil || n != -5 {
+		t.Errorf("Expected -5, got %d (%v)", n, err)
+	}
+
+	min, max := int64(0), int64(3)
+	bounded := types.IncrOptions{Min: &min, Max: &max}
+	store.Incr("slots", 3, bounded)
+	if _, err := store.Incr("slots", 1, bounded); err != types.ErrOutOfRange {
+		t.Errorf("Expected ErrOutOfRange above max, got %v", err)
+	}
+	if value, _ := store.Get("slots"); string(value) != "3" {
+		t.Errorf("Expected rejected increment to leave the value alone, got %s", value)
+	}
+	if _, err := store.Incr("slots", -4, bounded); err != types.ErrOutOfRange {
+		t.Errorf("Expected ErrOutOfRange below min, got %v", err)
+	}
+
+	store.Set("big", []byte(strconv.FormatInt(math.MaxInt64, 10)))
+	if _, err := store.Incr("big", 1, types.IncrOptions{}); err != types.ErrOutOfRange {
+		t.Errorf("Expected overflow to be rejected, got %v", err)
+	}
+
+	store.SetWithOptions("text", []byte("abc"), types.SetOptions{TTL: time.Hour})
+	if _, err := store.Incr("text", 1, types.IncrOptions{}); err != types.ErrNotNumber {
+		t.Errorf("Expected ErrNotNumber, got %v", err)
+	}
+
+	store.SetWithOptions("ttl", []byte("1"), types.SetOptions{TTL: time.Hour, Labels: map[string]string{"k": "v"}})
+	store.Incr("ttl", 1, types.IncrOptions{})
+	if entry, _ := store.GetEntry("ttl"); entry.ExpiresAt.IsZero() || entry.Labels["k"] != "v" || entry.Version != 2 {
+		t.Errorf("Expected increment to keep TTL and labels, got %+v", entry)
+	}
+
+	if f, err := store.IncrFloat("temp", 1.5, types.IncrFloatOptions{Initial: 20}); err != nil || f != 21.5 {
+		t.Errorf("Expected 21.5, got %v (%v)", f, err)
+	}
+	if n, err := store.Incr("temp", 1, types.IncrOptions{}); err != types.ErrNotNumber {
+		t.Errorf("Expected integer increment of a float to fail, got %d (%v)", n, err)
+	}
+	if f, err := store.IncrFloat("hits", 0.25, types.IncrFloatOptions{}); err != nil || f != -4.75 {
+		t.Errorf("Expected float increment of an integer to work, got %v (%v)", f, err)
+	}
+}
+
+func TestMemoryStoreIncrConcurrent(t *testing.T) {
+	store := NewMemoryStore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				store.Incr("counter", 1, types.IncrOptions{})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if value, _ := store.Get("counter"); string(value) != "5000" {
+		t.Errorf("Expected 5000, got %s", value)
+	}
+}
+
+func TestMemoryStoreScan(t *testing.T) {
+	store := NewMemoryStore()
+	for _, key := range []string{"user/3", "user/1", "user/2", "other"} {
+		store.Set(key, []byte(key))
+	}
+	store.SetAdd("user/set", "x")
+
+	var keys []string
+	store.Scan("user/", "user/3", func(kv types.KeyValue) bool {
+		keys = append(keys, kv.Key)
+		return true
+	})
+	if strings.Join(keys, ",") != "user/1,user/2" {
+		t.Errorf("Expected user/1,user/2, got %v", keys)
+	}
+
+	n := 0
+	store.Scan("", "", func(kv types.KeyValue) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("Expected the scan to stop after one key, got %d", n)
+	}
+}
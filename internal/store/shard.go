@@ -0,0 +1,86 @@
+package store
+
+import (
+	"errors"
+	"sync"
+)
+
+const DefaultShards = 64
+
+var errExclusive = errors.New("operation needs every shard locked")
+
+type shard struct {
+	mu      sync.RWMutex
+	data    map[string]entry
+	history map[string][]version
+}
+
+func shardCount(n int) int {
+	if n <= 0 {
+		n = DefaultShards
+	}
+	count := 1
+	for count < n {
+		count <<= 1
+	}
+	return count
+}
+
+func (s *MemoryStore) shardFor(key string) *shard {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return s.shards[h&s.mask]
+}
+
+func (s *MemoryStore) lockAll() {
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+	}
+}
+
+func (s *MemoryStore) unlockAll() {
+	for _, sh := range s.shards {
+		sh.mu.Unlock()
+	}
+}
+
+func (s *MemoryStore) rlockAll() {
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+	}
+}
+
+func (s *MemoryStore) runlockAll() {
+	for _, sh := range s.shards {
+		sh.mu.RUnlock()
+	}
+}
+
+func (s *MemoryStore) read(key string) *shard {
+	sh := s.shardFor(key)
+	sh.mu.RLock()
+	return sh
+}
+
+func (s *MemoryStore) write(key string, fn func(sh *shard) error) error {
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+	err := fn(sh)
+	sh.mu.Unlock()
+	if err != errExclusive {
+		return err
+	}
+
+	s.lockAll()
+	defer s.unlockAll()
+	s.exclusive = true
+	defer func() { s.exclusive = false }()
+	return fn(sh)
+}
+
+func (s *MemoryStore) Shards() int {
+	return len(s.shards)
+}
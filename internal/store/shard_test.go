@@ -0,0 +1,160 @@
+package store
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func TestShardCount(t *testing.T) {
+	cases := map[int]int{0: DefaultShards, -1: DefaultShards, 1: 1, 3: 4, 64: 64, 100: 128}
+	for n, want := range cases {
+		if got := NewShardedMemoryStore(n).Shards(); got != want {
+			t.Errorf("NewShardedMemoryStore(%d): expected %d shards, got %d", n, want, got)
+		}
+	}
+}
+
+func TestShardedStoreConcurrent(t *testing.T) {
+	store := NewShardedMemoryStore(8)
+	store.SetHistoryLimits(types.HistoryLimits{MaxVersions: 2})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("w%d/k%d", w, i)
+				store.Set(key, []byte("v1"))
+				store.Set(key, []byte("v2"))
+				if i%4 == 0 {
+					store.Delete(key)
+				}
+				store.Get(fmt.Sprintf("w%d/k%d", (w+1)%8, i))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if got := store.Size(); got != 8*150 {
+		t.Errorf("Expected %d keys, got %d", 8*150, got)
+	}
+	if got := len(store.Keys()); got != 8*150 {
+		t.Errorf("Expected Keys to list %d keys, got %d", 8*150, got)
+	}
+	if got := store.Revision(); got != 8*450 {
+		t.Errorf("Expected every write to take a revision, got %d", got)
+	}
+
+	want := int64(0)
+	for w := 0; w < 8; w++ {
+		for i := 0; i < 200; i++ {
+			want += 2 * entrySize(fmt.Sprintf("w%d/k%d", w, i), entry{value: []byte("v2")})
+		}
+	}
+	if got := store.Stats().MemoryUsed; got != want {
+		t.Errorf("Expected %d bytes in use, counting retained versions, got %d", want, got)
+	}
+}
+
+func TestShardedStoreLimitsConcurrent(t *testing.T) {
+	store := NewShardedMemoryStore(8)
+	store.SetLimits(types.Limits{MaxKeys: 100})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				store.Set(fmt.Sprintf("w%d/k%d", w, i), []byte("v"))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if got := store.Size(); got != 100 {
+		t.Errorf("Expected the key limit to hold under concurrent writes, got %d keys", got)
+	}
+}
+
+func TestShardedStoreMemoryLimitConcurrent(t *testing.T) {
+	store := NewShardedMemoryStore(8)
+	limit := 100 * entrySize("w0/k00", entry{value: []byte("v")})
+	store.SetLimits(types.Limits{MaxMemory: limit})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				store.Set(fmt.Sprintf("w%d/k%02d", w, i), []byte("v"))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if got := store.Size(); got != 100 {
+		t.Errorf("Expected exactly 100 keys to fit, got %d", got)
+	}
+	if got := store.Stats().MemoryUsed; got > limit {
+		t.Errorf("Expected the memory limit to hold under concurrent writes, got %d of %d bytes", got, limit)
+	}
+}
+
+func benchmarkMixed(b *testing.B, shards, readPercent int, limits types.Limits) {
+	store := NewShardedMemoryStore(shards)
+	store.SetLimits(limits)
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		store.Set(keys[i], []byte("value"))
+	}
+	value := make([]byte, 128)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+		for pb.Next() {
+			key := keys[r.IntN(len(keys))]
+			if r.IntN(100) < readPercent {
+				store.Get(key)
+			} else {
+				store.Set(key, value)
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryStoreParallel(b *testing.B) {
+	for _, shards := range []int{1, DefaultShards} {
+		for _, reads := range []int{50, 90} {
+			b.Run(fmt.Sprintf("shards=%d/reads=%d%%", shards, reads), func(b *testing.B) {
+				benchmarkMixed(b, shards, reads, types.Limits{})
+			})
+		}
+	}
+}
+
+func BenchmarkMemoryStoreParallelSet(b *testing.B) {
+	for _, shards := range []int{1, DefaultShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			benchmarkMixed(b, shards, 0, types.Limits{})
+		})
+	}
+}
+
+func BenchmarkMemoryStoreParallelSetLimited(b *testing.B) {
+	limits := types.Limits{MaxMemory: 64 << 20, MaxKeys: 1 << 20}
+	for _, shards := range []int{1, DefaultShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			benchmarkMixed(b, shards, 0, limits)
+		})
+	}
+}
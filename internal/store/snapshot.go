@@ -0,0 +1,180 @@
+package store
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+type snapshotEntry struct {
+	key string
+	entry
+}
+
+type memorySnapshot struct {
+	revision uint64
+	entries  []snapshotEntry
+}
+
+func (s *MemoryStore) Snapshot(prefix string) types.Snapshot {
+	s.rlockAll()
+	defer s.runlockAll()
+
+	now := s.now()
+	snap := &memorySnapshot{revision: s.seq.Load()}
+	for _, sh := range s.shards {
+		for key, e := range sh.data {
+			if strings.HasPrefix(key, prefix) && !e.expired(now) {
+				snap.entries = append(snap.entries, snapshotEntry{key: key, entry: e.cloneCollection()})
+			}
+		}
+	}
+	return snap
+}
+
+func (snap *memorySnapshot) Revision() uint64 {
+	return snap.revision
+}
+
+func (snap *memorySnapshot) Len() int {
+	return len(snap.entries)
+}
+
+func (snap *memorySnapshot) Each(fn func(types.Record) error) error {
+	sort.Slice(snap.entries, func(i, j int) bool { return snap.entries[i].key < snap.entries[j].key })
+
+	for _, se := range snap.entries {
+		rec, err := se.record(se.key)
+		if err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e entry) record(key string) (types.Record, error) {
+	rec := types.Record{
+		Key:         key,
+		ContentType: e.contentType,
+		Labels:      cloneLabels(e.labels),
+		Flags:       e.flags,
+	}
+	if !e.expiresAt.IsZero() {
+		expiresAt := e.expiresAt
+		rec.ExpiresAt = &expiresAt
+	}
+
+	switch e.kind {
+	case types.TypeList:
+		rec.Type = types.TypeList
+		rec.List = make([][]byte, len(e.list))
+		for i, v := range e.list {
+			rec.List[i] = cloneBytes(v)
+		}
+	case types.TypeSet:
+		rec.Type = types.TypeSet
+		rec.Set = make([]string, 0, len(e.set))
+		for member := range e.set {
+			rec.Set = append(rec.Set, member)
+		}
+		sort.Strings(rec.Set)
+	case types.TypeHash:
+		rec.Type = types.TypeHash
+		rec.Hash = make(map[string][]byte, len(e.hash))
+		for field, v := range e.hash {
+			rec.Hash[field] = cloneBytes(v)
+		}
+	default:
+		value, err := e.plain()
+		if err != nil {
+			return types.Record{}, err
+		}
+		rec.Value = value
+	}
+	return rec, nil
+}
+
+func recordEntry(rec types.Record) (entry, error) {
+	e := entry{
+		contentType: rec.ContentType,
+		labels:      cloneLabels(rec.Labels),
+		flags:       rec.Flags,
+	}
+	if rec.ExpiresAt != nil {
+		e.expiresAt = *rec.ExpiresAt
+	}
+
+	switch rec.Type {
+	case "", types.TypeString:
+		e.value = cloneBytes(rec.Value)
+		if e.value == nil {
+			e.value = []byte{}
+		}
+	case types.TypeList:
+		e.kind = types.TypeList
+		for _, v := range rec.List {
+			e.list = append(e.list, cloneBytes(v))
+		}
+	case types.TypeSet:
+		e.kind = types.TypeSet
+		e.set = make(map[string]struct{}, len(rec.Set))
+		for _, member := range rec.Set {
+			e.set[member] = struct{}{}
+		}
+	case types.TypeHash:
+		e.kind = types.TypeHash
+		e.hash = make(map[string][]byte, len(rec.Hash))
+		for field, v := range rec.Hash {
+			e.hash[field] = cloneBytes(v)
+		}
+	default:
+		return entry{}, types.ErrWrongType
+	}
+	return e, nil
+}
+
+func (s *MemoryStore) Load(rec types.Record, overwrite bool) (bool, error) {
+	if rec.Key == "" {
+		return false, types.ErrEmptyKey
+	}
+
+	e, err := recordEntry(rec)
+	if err != nil {
+		return false, err
+	}
+	if e.kind != "" && e.length() == 0 {
+		return false, nil
+	}
+
+	loaded := false
+	err = s.write(rec.Key, func(sh *shard) error {
+		now := s.now()
+		if e.expired(now) {
+			return nil
+		}
+		if current, exists := sh.data[rec.Key]; exists && !current.expired(now) && !overwrite {
+			return nil
+		}
+
+		for _, v := range e.list {
+			if s.tooLarge(len(v)) {
+				return types.ErrValueTooLarge
+			}
+		}
+		for _, v := range e.hash {
+			if s.tooLarge(len(v)) {
+				return types.ErrValueTooLarge
+			}
+		}
+		if _, err := s.apply(sh, rec.Key, e); err != nil {
+			return err
+		}
+		loaded = true
+		return nil
+	})
+	return loaded, err
+}
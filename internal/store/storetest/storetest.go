@@ -0,0 +1,274 @@
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+type Factory func(t *testing.T) types.Store
+
+func Run(t *testing.T, newStore Factory) {
+	t.Run("CRUD", func(t *testing.T) { testCRUD(t, newStore(t)) })
+	t.Run("EmptyKey", func(t *testing.T) { testEmptyKey(t, newStore(t)) })
+	t.Run("BinaryValues", func(t *testing.T) { testBinaryValues(t, newStore(t)) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, newStore(t)) })
+	t.Run("Scan", func(t *testing.T) { testScan(t, newStore(t)) })
+	t.Run("Expiring", func(t *testing.T) { testExpiring(t, newStore(t)) })
+	t.Run("Atomic", func(t *testing.T) { testAtomic(t, newStore(t)) })
+}
+
+func testCRUD(t *testing.T, s types.Store) {
+	if _, err := s.Get("missing"); !errors.Is(err, types.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound for a missing key, got %v", err)
+	}
+	if keys := s.Keys(); len(keys) != 0 {
+		t.Errorf("Expected a new store to be empty, got %v", keys)
+	}
+
+	if err := s.Set("key", []byte("one")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if v, err := s.Get("key"); err != nil || string(v) != "one" {
+		t.Errorf("Expected one, got %q (%v)", v, err)
+	}
+	if err := s.Set("key", []byte("two")); err != nil {
+		t.Fatalf("Overwrite failed: %v", err)
+	}
+	if v, err := s.Get("key"); err != nil || string(v) != "two" {
+		t.Errorf("Expected the overwrite to win, got %q (%v)", v, err)
+	}
+
+	s.Set("other", []byte("x"))
+	if keys := sorted(s.Keys()); len(keys) != 2 || keys[0] != "key" || keys[1] != "other" {
+		t.Errorf("Expected [key other], got %v", keys)
+	}
+
+	if err := s.Delete("key"); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if _, err := s.Get("key"); !errors.Is(err, types.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound after delete, got %v", err)
+	}
+	if err := s.Delete("key"); !errors.Is(err, types.ErrKeyNotFound) {
+		t.Errorf("Expected deleting a missing key to fail with ErrKeyNotFound, got %v", err)
+	}
+	if keys := s.Keys(); len(keys) != 1 || keys[0] != "other" {
+		t.Errorf("Expected [other], got %v", keys)
+	}
+
+	if err := s.Set("key", []byte("three")); err != nil {
+		t.Fatalf("Set after delete failed: %v", err)
+	}
+	if v, err := s.Get("key"); err != nil || string(v) != "three" {
+		t.Errorf("Expected a deleted key to be writable again, got %q (%v)", v, err)
+	}
+}
+
+func testEmptyKey(t *testing.T, s types.Store) {
+	if err := s.Set("", []byte("x")); !errors.Is(err, types.ErrEmptyKey) {
+		t.Errorf("Expected Set to reject an empty key, got %v", err)
+	}
+	if _, err := s.Get(""); !errors.Is(err, types.ErrEmptyKey) {
+		t.Errorf("Expected Get to reject an empty key, got %v", err)
+	}
+	if err := s.Delete(""); !errors.Is(err, types.ErrEmptyKey) {
+		t.Errorf("Expected Delete to reject an empty key, got %v", err)
+	}
+	if keys := s.Keys(); len(keys) != 0 {
+		t.Errorf("Expected nothing to be stored, got %v", keys)
+	}
+}
+
+func testBinaryValues(t *testing.T, s types.Store) {
+	value := []byte{0, 1, 2, 0xff, '\n', 0}
+	if err := s.Set("binary", value); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	value[0] = 42
+
+	got, err := s.Get("binary")
+	if err != nil || string(got) != "\x00\x01\x02\xff\n\x00" {
+		t.Fatalf("Expected the stored value to be unaffected by the caller, got %q (%v)", got, err)
+	}
+	got[1] = 42
+	if again, _ := s.Get("binary"); again[1] != 1 {
+		t.Errorf("Expected returned values to be copies, got %q", again)
+	}
+
+	if err := s.Set("empty", []byte{}); err != nil {
+		t.Fatalf("Set of an empty value failed: %v", err)
+	}
+	if v, err := s.Get("empty"); err != nil || len(v) != 0 {
+		t.Errorf("Expected an empty value, got %q (%v)", v, err)
+	}
+}
+
+func testConcurrency(t *testing.T, s types.Store) {
+	const workers, ops = 8, 200
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < ops; i++ {
+				key := fmt.Sprintf("w%d/%03d", w, i)
+				if err := s.Set(key, []byte(key)); err != nil {
+					errs <- err
+					return
+				}
+				if err := s.Set("shared", []byte(key)); err != nil {
+					errs <- err
+					return
+				}
+				if v, err := s.Get(key); err != nil || string(v) != key {
+					errs <- fmt.Errorf("read of %s returned %q (%v)", key, v, err)
+					return
+				}
+				if i%2 == 1 {
+					if err := s.Delete(key); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if got := len(s.Keys()); got != workers*ops/2+1 {
+		t.Errorf("Expected %d keys, got %d", workers*ops/2+1, got)
+	}
+	if _, err := s.Get("shared"); err != nil {
+		t.Errorf("Expected the shared key to exist, got %v", err)
+	}
+}
+
+func testScan(t *testing.T, s types.Store) {
+	rs, ok := s.(types.RangeStore)
+	if !ok {
+		t.Skip("store does not support range scans")
+	}
+
+	for _, key := range []string{"b/2", "a", "b/1", "b/3", "c"} {
+		rs.Set(key, []byte("v-"+key))
+	}
+	rs.Delete("b/3")
+
+	var got []string
+	err := rs.Scan("b/", "c", func(kv types.KeyValue) bool {
+		if string(kv.Value) != "v-"+kv.Key {
+			t.Errorf("Unexpected value %q for %s", kv.Value, kv.Key)
+		}
+		got = append(got, kv.Key)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if fmt.Sprint(got) != "[b/1 b/2]" {
+		t.Errorf("Expected [b/1 b/2], got %v", got)
+	}
+
+	got = nil
+	rs.Scan("", "", func(kv types.KeyValue) bool {
+		got = append(got, kv.Key)
+		return len(got) < 3
+	})
+	if fmt.Sprint(got) != "[a b/1 b/2]" {
+		t.Errorf("Expected an ordered scan that stops early, got %v", got)
+	}
+}
+
+func testExpiring(t *testing.T, s types.Store) {
+	es, ok := s.(types.ExpiringStore)
+	if !ok {
+		t.Skip("store does not support expiry")
+	}
+
+	if err := es.SetWithOptions("session", []byte("x"), types.SetOptions{TTL: time.Hour}); err != nil {
+		t.Fatalf("SetWithOptions failed: %v", err)
+	}
+	if ttl, ok, err := es.TTL("session"); err != nil || !ok || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("Expected a TTL of up to an hour, got %v %v (%v)", ttl, ok, err)
+	}
+	if err := es.Persist("session"); err != nil {
+		t.Errorf("Persist failed: %v", err)
+	}
+	if _, ok, _ := es.TTL("session"); ok {
+		t.Error("Expected Persist to clear the TTL")
+	}
+
+	es.SetWithOptions("gone", []byte("x"), types.SetOptions{TTL: time.Millisecond})
+	time.Sleep(5 * time.Millisecond)
+	if _, err := es.Get("gone"); !errors.Is(err, types.ErrKeyNotFound) {
+		t.Errorf("Expected the key to expire, got %v", err)
+	}
+	if err := es.Expire("missing", time.Hour); !errors.Is(err, types.ErrKeyNotFound) {
+		t.Errorf("Expected expiring a missing key to fail, got %v", err)
+	}
+
+	err := es.SetWithOptions("session", []byte("y"), types.SetOptions{OnlyIfAbsent: true})
+	if !errors.Is(err, types.ErrKeyExists) {
+		t.Errorf("Expected OnlyIfAbsent to fail for an existing key, got %v", err)
+	}
+	err = es.SetWithOptions("absent", []byte("y"), types.SetOptions{OnlyIfExists: true})
+	if !errors.Is(err, types.ErrKeyNotFound) {
+		t.Errorf("Expected OnlyIfExists to fail for a missing key, got %v", err)
+	}
+}
+
+func testAtomic(t *testing.T, s types.Store) {
+	as, ok := s.(types.AtomicStore)
+	if !ok {
+		t.Skip("store does not support atomic updates")
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				as.Update("counter", func(current types.Entry, exists bool) (types.Entry, error) {
+					n := 0
+					if exists {
+						fmt.Sscan(string(current.Value), &n)
+					}
+					return types.Entry{Value: []byte(fmt.Sprint(n + 1))}, nil
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	e, err := as.GetEntry("counter")
+	if err != nil || string(e.Value) != "400" || e.Version != 400 {
+		t.Errorf("Expected 400 serialized updates, got %q version %d (%v)", e.Value, e.Version, err)
+	}
+
+	failure := errors.New("abort")
+	_, err = as.Update("counter", func(types.Entry, bool) (types.Entry, error) { return types.Entry{}, failure })
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected the update error, got %v", err)
+	}
+	if v, _ := as.Get("counter"); string(v) != "400" {
+		t.Errorf("Expected a failed update to leave the value alone, got %q", v)
+	}
+}
+
+func sorted(keys []string) []string {
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const (
+	RestoreMerge     = "merge"
+	RestoreOverwrite = "overwrite"
+	RestoreReplace   = "replace"
+)
+
+func (c *Client) Backup(ctx context.Context, w io.Writer, prefix string) (int64, error) {
+	path := c.scoped("/backup")
+	if prefix != "" {
+		path += "?prefix=" + url.QueryEscape(prefix)
+	}
+
+	resp, err := c.stream(ctx, http.MethodGet, path, nil, "")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return io.Copy(w, resp.Body)
+}
+
+func (c *Client) RestoreBackup(ctx context.Context, r io.Reader, policy string) (types.RestoreResult, error) {
+	path := c.scoped("/restore")
+	if policy != "" {
+		path += "?policy=" + url.QueryEscape(policy)
+	}
+
+	resp, err := c.stream(ctx, http.MethodPost, path, r, "application/gzip")
+	if err != nil {
+		return types.RestoreResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var result types.RestoreResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return types.RestoreResult{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result, nil
+}
+
+func (c *Client) stream(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	c.setAuth(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, decodeError(resp.StatusCode, data)
+	}
+	return resp, nil
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+const (
+	FormatNDJSON = "ndjson"
+	FormatJSON   = "json"
+	FormatCSV    = "csv"
+)
+
+func (c *Client) Export(ctx context.Context, w io.Writer, prefix, format string) (int64, error) {
+	query := url.Values{}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	if format != "" {
+		query.Set("format", format)
+	}
+
+	path := c.scoped("/export")
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	resp, err := c.stream(ctx, http.MethodGet, path, nil, "")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return io.Copy(w, resp.Body)
+}
+
+func (c *Client) Import(ctx context.Context, r io.Reader, format string, dryRun bool) (types.ImportResult, error) {
+	if c.keys != nil {
+		return types.ImportResult{}, ErrEncryptionUnsupported
+	}
+
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", format)
+	}
+	if dryRun {
+		query.Set("dry_run", "true")
+	}
+
+	path := c.scoped("/import")
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	resp, err := c.stream(ctx, http.MethodPost, path, r, "")
+	if err != nil {
+		return types.ImportResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var result types.ImportResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return types.ImportResult{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result, nil
+}
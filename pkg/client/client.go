@@ -0,0 +1,542 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+var (
+	ErrKeyNotFound  = types.ErrKeyNotFound
+	ErrEmptyKey     = types.ErrEmptyKey
+	ErrUnauthorized = types.ErrUnauthorized
+)
+
+type AuthScheme int
+
+const (
+	AuthBearer AuthScheme = iota
+	AuthAPIKeyHeader
+)
+
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("qkrn: %s (status %d)", e.Message, e.StatusCode)
+}
+
+func (e *Error) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrKeyNotFound
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+type RedirectError struct {
+	StatusCode int
+	Location   string
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("qkrn: redirected to %s (status %d)", e.Location, e.StatusCode)
+}
+
+const labelsHeader = "X-Qkrn-Labels"
+
+type Object struct {
+	Data        []byte
+	ContentType string
+	Labels      map[string]string
+}
+
+type versionedObject struct {
+	Object
+	revision uint64
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	timeout    time.Duration
+	token      string
+	authScheme AuthScheme
+	namespace  string
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	keys       *DataKeys
+}
+
+type Option func(*Client)
+
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+		c.authScheme = AuthBearer
+	}
+}
+
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.token = key
+		c.authScheme = AuthAPIKeyHeader
+	}
+}
+
+func WithNamespace(name string) Option {
+	return func(c *Client) {
+		c.namespace = name
+	}
+}
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+func WithRetries(maxRetries int, backoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		maxRetries: 3,
+		backoff:    100 * time.Millisecond,
+		maxBackoff: 2 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.timeout > 0 {
+		httpClient := *c.httpClient
+		httpClient.Timeout = c.timeout
+		c.httpClient = &httpClient
+	}
+
+	return c
+}
+
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	if key == "" {
+		return "", ErrEmptyKey
+	}
+
+	if c.keys != nil {
+		obj, err := c.getDecrypted(ctx, key, c.keyPath(key))
+		return string(obj.Data), err
+	}
+
+	var resp types.Response
+	if err := c.do(ctx, http.MethodGet, c.keyPath(key), nil, true, &resp); err != nil {
+		return "", err
+	}
+
+	return responseValue(resp)
+}
+
+func responseValue(resp types.Response) (string, error) {
+	if resp.Encoding != "base64" {
+		return resp.Value, nil
+	}
+	value, err := base64.StdEncoding.DecodeString(resp.Value)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	return string(value), nil
+}
+
+func (c *Client) Set(ctx context.Context, key, value string) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+
+	if c.keys != nil {
+		return c.SetObject(ctx, key, Object{Data: []byte(value)})
+	}
+
+	return c.do(ctx, http.MethodPut, c.keyPath(key), types.Request{Value: value}, true, nil)
+}
+
+func (c *Client) GetBytes(ctx context.Context, key string) (Object, error) {
+	if key == "" {
+		return Object{}, ErrEmptyKey
+	}
+
+	if c.keys != nil {
+		return c.getDecrypted(ctx, key, c.keyPath(key))
+	}
+
+	var obj Object
+	if err := c.do(ctx, http.MethodGet, c.keyPath(key), nil, true, &obj); err != nil {
+		return Object{}, err
+	}
+
+	return obj, nil
+}
+
+func (c *Client) SetBytes(ctx context.Context, key string, data []byte, contentType string) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return c.SetObject(ctx, key, Object{Data: data, ContentType: contentType})
+}
+
+func (c *Client) SetObject(ctx context.Context, key string, obj Object) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+
+	if c.keys != nil {
+		var err error
+		if obj, err = c.encrypt(key, obj); err != nil {
+			return err
+		}
+	}
+	if obj.ContentType == "" {
+		obj.ContentType = "application/octet-stream"
+	}
+
+	return c.do(ctx, http.MethodPut, c.keyPath(key)+"?raw=true", obj, true, nil)
+}
+
+func (c *Client) Delete(ctx context.Context, key string) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+
+	return c.do(ctx, http.MethodDelete, c.keyPath(key), nil, true, nil)
+}
+
+func (c *Client) Incr(ctx context.Context, key string, by int64) (int64, error) {
+	value, err := c.incr(ctx, key, types.CounterRequest{By: json.Number(strconv.FormatInt(by, 10))})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (c *Client) IncrFloat(ctx context.Context, key string, by float64) (float64, error) {
+	value, err := c.incr(ctx, key, types.CounterRequest{By: json.Number(strconv.FormatFloat(by, 'f', -1, 64)), Float: true})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+func (c *Client) incr(ctx context.Context, key string, req types.CounterRequest) (string, error) {
+	if key == "" {
+		return "", ErrEmptyKey
+	}
+	if c.keys != nil {
+		return "", ErrEncryptionUnsupported
+	}
+
+	var resp types.Response
+	if err := c.do(ctx, http.MethodPost, c.scoped("/incr/"+url.PathEscape(key)), req, false, &resp); err != nil {
+		return "", err
+	}
+	return responseValue(resp)
+}
+
+func (c *Client) Keys(ctx context.Context) ([]string, error) {
+	return c.KeysWithPrefix(ctx, "")
+}
+
+func (c *Client) KeysWithPrefix(ctx context.Context, prefix string) ([]string, error) {
+	return c.KeysAt(ctx, prefix, 0)
+}
+
+func (c *Client) KeysAt(ctx context.Context, prefix string, revision uint64) ([]string, error) {
+	query := url.Values{}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	if revision > 0 {
+		query.Set("revision", strconv.FormatUint(revision, 10))
+	}
+
+	path := c.scoped("/keys")
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var resp struct {
+		Keys []string `json:"keys"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, true, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Keys, nil
+}
+
+func (c *Client) Ready(ctx context.Context) (types.ReadinessReport, error) {
+	var report types.ReadinessReport
+
+	statusCode, _, data, err := c.send(ctx, http.MethodGet, "/health/ready", nil, jsonHeader(false))
+	if err != nil {
+		return report, err
+	}
+
+	if statusCode != http.StatusOK && statusCode != http.StatusServiceUnavailable {
+		return report, decodeError(statusCode, data)
+	}
+
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return report, nil
+}
+
+func (c *Client) scoped(path string) string {
+	if c.namespace == "" {
+		return path
+	}
+	return "/ns/" + url.PathEscape(c.namespace) + path
+}
+
+func (c *Client) keyPath(key string) string {
+	return c.scoped("/kv/" + url.PathEscape(key))
+}
+
+func jsonHeader(withBody bool) http.Header {
+	header := http.Header{"Accept": {"application/json"}}
+	if withBody {
+		header.Set("Content-Type", "application/json")
+	}
+	return header
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, idempotent bool, out interface{}) error {
+	var payload []byte
+	header := jsonHeader(body != nil)
+	switch b := body.(type) {
+	case nil:
+	case Object:
+		payload = objectPayload(header, b)
+	case versionedObject:
+		payload = objectPayload(header, b.Object)
+		header.Set("If-Match", strconv.FormatUint(b.revision, 10))
+	default:
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+	switch out.(type) {
+	case *Object, *versionedObject:
+		header.Set("Accept", "application/octet-stream")
+	}
+
+	attempts := 1
+	if idempotent {
+		attempts += c.maxRetries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := c.sleep(ctx, attempt); err != nil {
+				return err
+			}
+		}
+
+		retry, err := c.doOnce(ctx, method, path, payload, header, out)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		if !retry || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return lastErr
+}
+
+func objectPayload(header http.Header, obj Object) []byte {
+	header.Set("Content-Type", obj.ContentType)
+	if len(obj.Labels) > 0 {
+		labels := url.Values{}
+		for name, value := range obj.Labels {
+			labels.Set(name, value)
+		}
+		header.Set(labelsHeader, labels.Encode())
+	}
+	if obj.Data == nil {
+		return []byte{}
+	}
+	return obj.Data
+}
+
+func (c *Client) doOnce(ctx context.Context, method, path string, payload []byte, reqHeader http.Header, out interface{}) (bool, error) {
+	statusCode, header, data, err := c.send(ctx, method, path, payload, reqHeader)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+
+	if statusCode >= 300 && statusCode < 400 {
+		return false, &RedirectError{StatusCode: statusCode, Location: header.Get("Location")}
+	}
+
+	if statusCode >= 400 {
+		return retryableStatus(statusCode), decodeError(statusCode, data)
+	}
+
+	switch obj := out.(type) {
+	case *Object:
+		*obj = Object{Data: data, ContentType: header.Get("Content-Type"), Labels: parseLabels(header.Get(labelsHeader))}
+		return false, nil
+	case *versionedObject:
+		revision, _ := strconv.ParseUint(header.Get("X-Qkrn-Revision"), 10, 64)
+		*obj = versionedObject{
+			Object:   Object{Data: data, ContentType: header.Get("Content-Type"), Labels: parseLabels(header.Get(labelsHeader))},
+			revision: revision,
+		}
+		return false, nil
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return false, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return false, nil
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, header http.Header) (int, http.Header, []byte, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	c.setAuth(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	return resp.StatusCode, resp.Header, data, nil
+}
+
+func (c *Client) setAuth(req *http.Request) {
+	if c.token == "" {
+		return
+	}
+
+	switch c.authScheme {
+	case AuthAPIKeyHeader:
+		req.Header.Set("X-API-Key", c.token)
+	default:
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+func (c *Client) sleep(ctx context.Context, attempt int) error {
+	delay := c.backoff << (attempt - 1)
+	if delay > c.maxBackoff || delay <= 0 {
+		delay = c.maxBackoff
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func decodeError(statusCode int, data []byte) error {
+	var resp types.Response
+	if err := json.Unmarshal(data, &resp); err == nil && resp.Error != "" {
+		return &Error{StatusCode: statusCode, Message: resp.Error}
+	}
+
+	message := strings.TrimSpace(string(data))
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	return &Error{StatusCode: statusCode, Message: message}
+}
+
+func parseLabels(header string) map[string]string {
+	values, err := url.ParseQuery(header)
+	if err != nil || len(values) == 0 {
+		return nil
+	}
+
+	labels := make(map[string]string, len(values))
+	for name, v := range values {
+		labels[name] = v[len(v)-1]
+	}
+	return labels
+}
+
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrKeyNotFound)
+}
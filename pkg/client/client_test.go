@@ -0,0 +1,455 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/q4ow/qkrn/internal/api"
+	"github.com/q4ow/qkrn/internal/auth"
+	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func setupTestClient(t *testing.T, apiKey string, opts ...Option) *Client {
+	t.Helper()
+
+	authenticator := auth.NewAuthenticator(apiKey != "", apiKey)
+	kvStore := store.NewMemoryStore()
+	kvStore.SetHistoryLimits(types.HistoryLimits{MaxVersions: 10})
+	server := api.NewServer(kvStore, 0, authenticator)
+
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+
+	return New(ts.URL, opts...)
+}
+
+func TestClientCRUD(t *testing.T) {
+	c := setupTestClient(t, "")
+	ctx := context.Background()
+
+	if err := c.Set(ctx, "hello", "world"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if err := c.Set(ctx, "path/with spaces", "nested"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	value, err := c.Get(ctx, "hello")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if value != "world" {
+		t.Errorf("Expected 'world', got '%s'", value)
+	}
+
+	value, err = c.Get(ctx, "path/with spaces")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if value != "nested" {
+		t.Errorf("Expected 'nested', got '%s'", value)
+	}
+
+	keys, err := c.Keys(ctx)
+	if err != nil {
+		t.Fatalf("Keys failed: %v", err)
+	}
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "hello" || keys[1] != "path/with spaces" {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+
+	if err := c.Delete(ctx, "hello"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	_, err = c.Get(ctx, "hello")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected *Error with status 404, got %v", err)
+	}
+
+	if err := c.Delete(ctx, "hello"); !IsNotFound(err) {
+		t.Errorf("Expected not found on second delete, got %v", err)
+	}
+
+	if _, err := c.Get(ctx, ""); err != ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey, got %v", err)
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	apiKey := "client-test-key"
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		expectedErr error
+	}{
+		{
+			name:        "missing credentials",
+			opts:        nil,
+			expectedErr: ErrUnauthorized,
+		},
+		{
+			name:        "wrong bearer token",
+			opts:        []Option{WithBearerToken("wrong")},
+			expectedErr: ErrUnauthorized,
+		},
+		{
+			name: "valid bearer token",
+			opts: []Option{WithBearerToken(apiKey)},
+		},
+		{
+			name: "valid x-api-key header",
+			opts: []Option{WithAPIKey(apiKey)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := setupTestClient(t, apiKey, tt.opts...)
+
+			_, err := c.Keys(context.Background())
+			if tt.expectedErr == nil && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true,"value":"eventually"}`))
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, WithRetries(3, time.Millisecond, 5*time.Millisecond))
+
+	value, err := c.Get(context.Background(), "key")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if value != "eventually" {
+		t.Errorf("Expected 'eventually', got '%s'", value)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+}
+
+func TestClientContextCancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, WithRetries(10, 50*time.Millisecond, time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := c.Get(ctx, "key")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context deadline exceeded, got %v", err)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	shared := &http.Client{Timeout: time.Minute}
+
+	for _, opts := range [][]Option{
+		{WithHTTPClient(shared), WithTimeout(time.Second)},
+		{WithTimeout(time.Second), WithHTTPClient(shared)},
+	} {
+		c := New("http://localhost", opts...)
+		if c.httpClient.Timeout != time.Second {
+			t.Errorf("Expected a 1s timeout regardless of option order, got %v", c.httpClient.Timeout)
+		}
+		if shared.Timeout != time.Minute {
+			t.Fatalf("Expected the shared client to be left alone, got %v", shared.Timeout)
+		}
+	}
+	if c := New("http://localhost", WithHTTPClient(shared)); c.httpClient != shared {
+		t.Error("Expected the given client to be used as is without WithTimeout")
+	}
+}
+
+func TestClientKeysWithPrefix(t *testing.T) {
+	c := setupTestClient(t, "")
+	ctx := context.Background()
+
+	for _, key := range []string{"app/a", "app/b", "other"} {
+		if err := c.Set(ctx, key, "v"); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	keys, err := c.KeysWithPrefix(ctx, "app/")
+	if err != nil {
+		t.Fatalf("KeysWithPrefix failed: %v", err)
+	}
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "app/a" || keys[1] != "app/b" {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+}
+
+func TestClientReady(t *testing.T) {
+	c := setupTestClient(t, "")
+
+	report, err := c.Ready(context.Background())
+	if err != nil {
+		t.Fatalf("Ready failed: %v", err)
+	}
+	if report.Status != "ready" {
+		t.Errorf("Expected status 'ready', got '%s'", report.Status)
+	}
+	if len(report.Checks) == 0 {
+		t.Error("Expected readiness checks to be reported")
+	}
+}
+
+func TestClientBytes(t *testing.T) {
+	c := setupTestClient(t, "")
+	ctx := context.Background()
+	data := []byte{0x00, 0x01, 0xfe, 0xff}
+
+	if err := c.SetBytes(ctx, "blob", data, "application/x-protobuf"); err != nil {
+		t.Fatalf("SetBytes failed: %v", err)
+	}
+
+	obj, err := c.GetBytes(ctx, "blob")
+	if err != nil {
+		t.Fatalf("GetBytes failed: %v", err)
+	}
+	if string(obj.Data) != string(data) || obj.ContentType != "application/x-protobuf" {
+		t.Errorf("Unexpected object: %+v", obj)
+	}
+	if value, err := c.Get(ctx, "blob"); err != nil || value != string(data) {
+		t.Errorf("Expected Get to decode base64 values, got %q (%v)", value, err)
+	}
+
+	if err := c.Set(ctx, "text", "hello"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	obj, err = c.GetBytes(ctx, "text")
+	if err != nil || string(obj.Data) != "hello" || obj.ContentType != "application/octet-stream" {
+		t.Errorf("Expected raw bytes for a JSON-set value, got %+v (%v)", obj, err)
+	}
+
+	if _, err := c.GetBytes(ctx, "missing"); !IsNotFound(err) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+}
+
+func TestClientNamespace(t *testing.T) {
+	server := api.NewServer(store.NewMemoryStore(), 0, auth.NewAuthenticator(false, ""))
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	ctx := context.Background()
+
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/ns/team", strings.NewReader(`{}`))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+	resp.Body.Close()
+
+	team := New(ts.URL, WithNamespace("team"))
+	if err := team.Set(ctx, "key", "scoped"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if value, err := team.Get(ctx, "key"); err != nil || value != "scoped" {
+		t.Errorf("Expected 'scoped', got '%s' (%v)", value, err)
+	}
+	if keys, err := team.Keys(ctx); err != nil || len(keys) != 1 {
+		t.Errorf("Expected 1 namespaced key, got %v (%v)", keys, err)
+	}
+
+	if _, err := New(ts.URL).Get(ctx, "key"); !IsNotFound(err) {
+		t.Errorf("Expected key to be absent from the default namespace, got %v", err)
+	}
+	if _, err := New(ts.URL, WithNamespace("missing")).Get(ctx, "key"); !IsNotFound(err) {
+		t.Errorf("Expected not found for a missing namespace, got %v", err)
+	}
+}
+
+func TestClientCollections(t *testing.T) {
+	c := setupTestClient(t, "")
+	ctx := context.Background()
+
+	c.RPush(ctx, "queue", "a", "b")
+	if n, err := c.LPush(ctx, "queue", "z"); err != nil || n != 3 {
+		t.Errorf("Expected length 3, got %d (%v)", n, err)
+	}
+	if values, err := c.LRange(ctx, "queue", 0, -1); err != nil || strings.Join(values, ",") != "z,a,b" {
+		t.Errorf("Unexpected range %q (%v)", values, err)
+	}
+	if values, err := c.RPop(ctx, "queue", 2); err != nil || strings.Join(values, ",") != "b,a" {
+		t.Errorf("Unexpected pop %q (%v)", values, err)
+	}
+
+	c.SAdd(ctx, "tags", "go", "kv")
+	if n, err := c.SRem(ctx, "tags", "kv", "nope"); err != nil || n != 1 {
+		t.Errorf("Expected 1 member removed, got %d (%v)", n, err)
+	}
+	if ok, err := c.SIsMember(ctx, "tags", "go"); err != nil || !ok {
+		t.Errorf("Expected go to be a member (%v)", err)
+	}
+	if members, _ := c.SMembers(ctx, "tags"); strings.Join(members, ",") != "go" {
+		t.Errorf("Unexpected members %q", members)
+	}
+
+	c.HSet(ctx, "user", map[string]string{"name": "ada", "lang": "go"})
+	if n, err := c.HDel(ctx, "user", "lang"); err != nil || n != 1 {
+		t.Errorf("Expected 1 field deleted, got %d (%v)", n, err)
+	}
+	if value, err := c.HGet(ctx, "user", "name"); err != nil || value != "ada" {
+		t.Errorf("Expected ada, got %s (%v)", value, err)
+	}
+	if fields, _ := c.HGetAll(ctx, "user"); len(fields) != 1 {
+		t.Errorf("Unexpected fields %v", fields)
+	}
+
+	var apiErr *Error
+	if _, err := c.Get(ctx, "user"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("Expected conflict reading a hash as a string, got %v", err)
+	}
+}
+
+func TestClientHistory(t *testing.T) {
+	c := setupTestClient(t, "")
+	ctx := context.Background()
+
+	c.Set(ctx, "config", "v1")
+	c.Set(ctx, "config", "v2")
+
+	revisions, err := c.History(ctx, "config")
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, got %v (%v)", revisions, err)
+	}
+	first := revisions[1].Revision
+
+	if value, err := c.GetRevision(ctx, "config", first); err != nil || value != "v1" {
+		t.Errorf("Expected v1, got %s (%v)", value, err)
+	}
+	if meta, err := c.Restore(ctx, "config", first); err != nil || meta.Version != 3 {
+		t.Errorf("Expected restore to write version 3, got %+v (%v)", meta, err)
+	}
+	if value, _ := c.Get(ctx, "config"); value != "v1" {
+		t.Errorf("Expected restored value v1, got %s", value)
+	}
+
+	c.Set(ctx, "later", "x")
+	if keys, err := c.KeysAt(ctx, "", first); err != nil || len(keys) != 1 || keys[0] != "config" {
+		t.Errorf("Expected only config at revision %d, got %v (%v)", first, keys, err)
+	}
+	if revision, err := c.Compact(ctx, 0); err != nil || revision != 4 {
+		t.Errorf("Expected compaction to revision 4, got %d (%v)", revision, err)
+	}
+	if _, err := c.KeysAt(ctx, "", first); err == nil {
+		t.Error("Expected reads before the compaction revision to fail")
+	}
+}
+
+func TestClientIncr(t *testing.T) {
+	c := setupTestClient(t, "")
+	ctx := context.Background()
+
+	if n, err := c.Incr(ctx, "hits", 5); err != nil || n != 5 {
+		t.Errorf("Expected 5, got %d (%v)", n, err)
+	}
+	if n, err := c.Incr(ctx, "hits", -2); err != nil || n != 3 {
+		t.Errorf("Expected 3, got %d (%v)", n, err)
+	}
+	if f, err := c.IncrFloat(ctx, "hits", 0.5); err != nil || f != 3.5 {
+		t.Errorf("Expected 3.5, got %v (%v)", f, err)
+	}
+
+	var apiErr *Error
+	if _, err := c.Incr(ctx, "hits", 1); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("Expected conflict for integer increment of a float, got %v", err)
+	}
+}
+
+func TestClientBackup(t *testing.T) {
+	c := setupTestClient(t, "")
+	ctx := context.Background()
+
+	c.Set(ctx, "app/a", "1")
+	c.Set(ctx, "app/b", "2")
+	c.Set(ctx, "other", "3")
+
+	var buf bytes.Buffer
+	if _, err := c.Backup(ctx, &buf, "app/"); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	c.Set(ctx, "app/a", "changed")
+	c.Delete(ctx, "app/b")
+
+	result, err := c.RestoreBackup(ctx, bytes.NewReader(buf.Bytes()), RestoreOverwrite)
+	if err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	if result.Revision != 3 || result.Loaded != 2 {
+		t.Errorf("Unexpected restore result %+v", result)
+	}
+	if value, _ := c.Get(ctx, "app/a"); value != "1" {
+		t.Errorf("Expected restored value 1, got %q", value)
+	}
+	if value, _ := c.Get(ctx, "app/b"); value != "2" {
+		t.Errorf("Expected deleted key to be restored, got %q", value)
+	}
+
+	_, err = c.RestoreBackup(ctx, strings.NewReader("garbage"), "")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid backup, got %v", err)
+	}
+}
+
+func TestClientImportExport(t *testing.T) {
+	c := setupTestClient(t, "")
+	ctx := context.Background()
+
+	result, err := c.Import(ctx, strings.NewReader(`{"a": "1", "b": "2", "": "3"}`), FormatJSON, false)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Imported != 2 || result.Failed != 1 || len(result.Errors) != 1 {
+		t.Errorf("Unexpected import result %+v", result)
+	}
+
+	var buf bytes.Buffer
+	if _, err := c.Export(ctx, &buf, "", FormatNDJSON); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if got := buf.String(); got != "{\"key\":\"a\",\"value\":\"1\"}\n{\"key\":\"b\",\"value\":\"2\"}\n" {
+		t.Errorf("Unexpected export %q", got)
+	}
+}
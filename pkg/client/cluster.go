@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+var ErrNoLeader = errors.New("qkrn: cluster has no known leader")
+
+type Consistency int
+
+const (
+	ConsistencyStrong Consistency = iota
+	ConsistencyEventual
+)
+
+type ClusterConfig struct {
+	Seeds           []string
+	ReadConsistency Consistency
+	Options         []Option
+}
+
+type ClusterClient struct {
+	cfg ClusterConfig
+
+	mu       sync.RWMutex
+	topology types.ClusterInfo
+	leader   *Client
+	nodes    []*Client
+
+	next uint64
+}
+
+func NewClusterClient(cfg ClusterConfig) (*ClusterClient, error) {
+	if len(cfg.Seeds) == 0 {
+		return nil, errors.New("qkrn: at least one seed address is required")
+	}
+
+	return &ClusterClient{cfg: cfg}, nil
+}
+
+func (c *Client) Cluster(ctx context.Context) (types.ClusterInfo, error) {
+	var info types.ClusterInfo
+	if err := c.do(ctx, http.MethodGet, "/cluster", nil, true, &info); err != nil {
+		return types.ClusterInfo{}, err
+	}
+
+	return info, nil
+}
+
+func (cc *ClusterClient) Topology() types.ClusterInfo {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	return cc.topology
+}
+
+func (cc *ClusterClient) Refresh(ctx context.Context) error {
+	cc.mu.RLock()
+	candidates := make([]string, 0, len(cc.nodes)+len(cc.cfg.Seeds))
+	for _, node := range cc.nodes {
+		candidates = append(candidates, node.baseURL)
+	}
+	cc.mu.RUnlock()
+	candidates = append(candidates, cc.cfg.Seeds...)
+
+	var lastErr error
+	for _, candidate := range candidates {
+		info, err := cc.newNodeClient(candidate).Cluster(ctx)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if err := cc.apply(candidate, info); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+
+	return fmt.Errorf("qkrn: failed to refresh cluster topology: %w", lastErr)
+}
+
+func (cc *ClusterClient) apply(source string, info types.ClusterInfo) error {
+	base, err := url.Parse(source)
+	if err != nil {
+		return err
+	}
+
+	var leader *Client
+	nodes := make([]*Client, 0, len(info.Nodes))
+	for _, node := range info.Nodes {
+		client := cc.newNodeClient(nodeURL(base, node))
+		nodes = append(nodes, client)
+		if node.ID == info.Leader {
+			leader = client
+		}
+	}
+
+	if leader == nil {
+		return ErrNoLeader
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.topology = info
+	cc.leader = leader
+	cc.nodes = nodes
+	return nil
+}
+
+func (cc *ClusterClient) newNodeClient(baseURL string) *Client {
+	c := New(baseURL, cc.cfg.Options...)
+
+	httpClient := *c.httpClient
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	c.httpClient = &httpClient
+
+	return c
+}
+
+func nodeURL(base *url.URL, node types.Node) string {
+	host := node.Address
+	ip := net.ParseIP(host)
+	if host == "" || strings.EqualFold(host, "localhost") || (ip != nil && (ip.IsUnspecified() || ip.IsLoopback())) {
+		host = base.Hostname()
+	}
+
+	u := url.URL{
+		Scheme: base.Scheme,
+		Host:   net.JoinHostPort(host, strconv.Itoa(node.Port)),
+	}
+	return u.String()
+}
+
+func (cc *ClusterClient) Get(ctx context.Context, key string) (string, error) {
+	return cc.GetWithConsistency(ctx, key, cc.cfg.ReadConsistency)
+}
+
+func (cc *ClusterClient) GetWithConsistency(ctx context.Context, key string, level Consistency) (string, error) {
+	var value string
+	err := cc.route(ctx, false, level, func(c *Client) error {
+		var err error
+		value, err = c.Get(ctx, key)
+		return err
+	})
+	return value, err
+}
+
+func (cc *ClusterClient) Set(ctx context.Context, key, value string) error {
+	return cc.route(ctx, true, ConsistencyStrong, func(c *Client) error {
+		return c.Set(ctx, key, value)
+	})
+}
+
+func (cc *ClusterClient) Delete(ctx context.Context, key string) error {
+	return cc.route(ctx, true, ConsistencyStrong, func(c *Client) error {
+		return c.Delete(ctx, key)
+	})
+}
+
+func (cc *ClusterClient) Keys(ctx context.Context) ([]string, error) {
+	var keys []string
+	err := cc.route(ctx, false, cc.cfg.ReadConsistency, func(c *Client) error {
+		var err error
+		keys, err = c.Keys(ctx)
+		return err
+	})
+	return keys, err
+}
+
+func (cc *ClusterClient) route(ctx context.Context, write bool, level Consistency, fn func(*Client) error) error {
+	cc.mu.RLock()
+	known := cc.leader != nil
+	cc.mu.RUnlock()
+
+	if !known {
+		if err := cc.Refresh(ctx); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = fn(cc.pick(write, level)); err == nil || !needsRefresh(err) || ctx.Err() != nil {
+			return err
+		}
+
+		if refreshErr := cc.Refresh(ctx); refreshErr != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (cc *ClusterClient) pick(write bool, level Consistency) *Client {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+
+	if write || level == ConsistencyStrong || len(cc.nodes) == 0 {
+		return cc.leader
+	}
+
+	n := atomic.AddUint64(&cc.next, 1)
+	return cc.nodes[n%uint64(len(cc.nodes))]
+}
+
+func needsRefresh(err error) bool {
+	var redirect *RedirectError
+	if errors.As(err, &redirect) {
+		return true
+	}
+
+	var transportErr *url.Error
+	return errors.As(err, &transportErr) && !transportErr.Timeout() && !errors.Is(err, context.Canceled)
+}
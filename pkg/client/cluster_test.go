@@ -0,0 +1,206 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/q4ow/qkrn/internal/api"
+	"github.com/q4ow/qkrn/internal/auth"
+	"github.com/q4ow/qkrn/internal/store"
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+type testCluster struct {
+	leaderStore   *store.MemoryStore
+	followerStore *store.MemoryStore
+	leader        *httptest.Server
+	follower      *httptest.Server
+	redirects     int32
+	staleTopology int32
+}
+
+func testNode(t *testing.T, id, rawURL string) types.Node {
+	t.Helper()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Failed to parse URL: %v", err)
+	}
+	port, _ := strconv.Atoi(u.Port())
+
+	return types.Node{ID: id, Address: u.Hostname(), Port: port}
+}
+
+func setupTestCluster(t *testing.T) *testCluster {
+	t.Helper()
+
+	tc := &testCluster{
+		leaderStore:   store.NewMemoryStore(),
+		followerStore: store.NewMemoryStore(),
+	}
+
+	leaderServer := api.NewServer(tc.leaderStore, 0, auth.NewAuthenticator(false, ""))
+	tc.leader = httptest.NewServer(leaderServer.Handler())
+	t.Cleanup(tc.leader.Close)
+	leaderServer.SetNode(testNode(t, "leader", tc.leader.URL))
+
+	followerServer := api.NewServer(tc.followerStore, 0, auth.NewAuthenticator(false, ""))
+	tc.follower = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/cluster":
+			info := types.ClusterInfo{
+				Leader: "leader",
+				Nodes: []types.Node{
+					testNode(t, "leader", tc.leader.URL),
+					testNode(t, "follower", tc.follower.URL),
+				},
+			}
+			if atomic.LoadInt32(&tc.staleTopology) == 1 {
+				info.Leader = "follower"
+			}
+			json.NewEncoder(w).Encode(info)
+		case r.Method == http.MethodPut || r.Method == http.MethodDelete:
+			atomic.AddInt32(&tc.redirects, 1)
+			atomic.StoreInt32(&tc.staleTopology, 0)
+			http.Redirect(w, r, tc.leader.URL+r.URL.Path, http.StatusTemporaryRedirect)
+		default:
+			followerServer.Handler().ServeHTTP(w, r)
+		}
+	}))
+	t.Cleanup(tc.follower.Close)
+
+	return tc
+}
+
+func TestClusterClientRoutesWritesToLeader(t *testing.T) {
+	tc := setupTestCluster(t)
+	ctx := context.Background()
+
+	cc, err := NewClusterClient(ClusterConfig{Seeds: []string{tc.follower.URL}})
+	if err != nil {
+		t.Fatalf("NewClusterClient failed: %v", err)
+	}
+
+	if err := cc.Set(ctx, "hello", "world"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if value, err := tc.leaderStore.Get("hello"); err != nil || string(value) != "world" {
+		t.Errorf("Expected leader to hold 'world', got '%s' (%v)", value, err)
+	}
+
+	if _, err := tc.followerStore.Get("hello"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected follower not to receive write, got %v", err)
+	}
+
+	if redirects := atomic.LoadInt32(&tc.redirects); redirects != 0 {
+		t.Errorf("Expected no redirects, got %d", redirects)
+	}
+
+	if leader := cc.Topology().Leader; leader != "leader" {
+		t.Errorf("Expected leader 'leader', got '%s'", leader)
+	}
+}
+
+func TestClusterClientReadConsistency(t *testing.T) {
+	tc := setupTestCluster(t)
+	ctx := context.Background()
+
+	tc.leaderStore.Set("key", []byte("from-leader"))
+	tc.followerStore.Set("key", []byte("from-follower"))
+
+	cc, _ := NewClusterClient(ClusterConfig{Seeds: []string{tc.follower.URL}})
+
+	for i := 0; i < 4; i++ {
+		value, err := cc.GetWithConsistency(ctx, "key", ConsistencyStrong)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if value != "from-leader" {
+			t.Errorf("Expected strong read from leader, got '%s'", value)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		value, err := cc.GetWithConsistency(ctx, "key", ConsistencyEventual)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		seen[value] = true
+	}
+
+	if !seen["from-leader"] || !seen["from-follower"] {
+		t.Errorf("Expected eventual reads to be spread across nodes, got %v", seen)
+	}
+}
+
+func TestClusterClientRefreshesOnRedirect(t *testing.T) {
+	tc := setupTestCluster(t)
+	atomic.StoreInt32(&tc.staleTopology, 1)
+	ctx := context.Background()
+
+	cc, _ := NewClusterClient(ClusterConfig{Seeds: []string{tc.follower.URL}})
+
+	if err := cc.Set(ctx, "hello", "world"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if redirects := atomic.LoadInt32(&tc.redirects); redirects != 1 {
+		t.Errorf("Expected exactly one redirect, got %d", redirects)
+	}
+
+	if leader := cc.Topology().Leader; leader != "leader" {
+		t.Errorf("Expected topology to be refreshed to 'leader', got '%s'", leader)
+	}
+
+	if value, err := tc.leaderStore.Get("hello"); err != nil || string(value) != "world" {
+		t.Errorf("Expected leader to hold 'world', got '%s' (%v)", value, err)
+	}
+}
+
+func TestClusterClientFailover(t *testing.T) {
+	tc := setupTestCluster(t)
+	ctx := context.Background()
+
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	cc, _ := NewClusterClient(ClusterConfig{
+		Seeds:   []string{dead.URL, tc.leader.URL},
+		Options: []Option{WithRetries(0, 0, 0)},
+	})
+
+	if err := cc.Set(ctx, "hello", "world"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	if _, err := NewClusterClient(ClusterConfig{}); err == nil {
+		t.Error("Expected error when no seeds are configured")
+	}
+}
+
+func TestNodeURL(t *testing.T) {
+	base, _ := url.Parse("https://db.example.com:8080")
+	cases := map[string]string{
+		"":          "https://db.example.com:9000",
+		"0.0.0.0":   "https://db.example.com:9000",
+		"::":        "https://db.example.com:9000",
+		"127.0.0.1": "https://db.example.com:9000",
+		"::1":       "https://db.example.com:9000",
+		"localhost": "https://db.example.com:9000",
+		"10.0.0.5":  "https://10.0.0.5:9000",
+		"node-2":    "https://node-2:9000",
+	}
+	for address, want := range cases {
+		if got := nodeURL(base, types.Node{Address: address, Port: 9000}); got != want {
+			t.Errorf("nodeURL(%q): expected %s, got %s", address, want, got)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func (c *Client) LPush(ctx context.Context, key string, values ...string) (int, error) {
+	return c.push(ctx, key, "front", values)
+}
+
+func (c *Client) RPush(ctx context.Context, key string, values ...string) (int, error) {
+	return c.push(ctx, key, "back", values)
+}
+
+func (c *Client) push(ctx context.Context, key, end string, values []string) (int, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
+	if c.keys != nil {
+		return 0, ErrEncryptionUnsupported
+	}
+
+	var resp struct {
+		Length int `json:"length"`
+	}
+	path := c.scoped("/list/"+url.PathEscape(key)) + "?end=" + end
+	if err := c.do(ctx, http.MethodPost, path, types.CollectionRequest{Values: values}, false, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Length, nil
+}
+
+func (c *Client) LPop(ctx context.Context, key string, count int) ([]string, error) {
+	return c.pop(ctx, key, "front", count)
+}
+
+func (c *Client) RPop(ctx context.Context, key string, count int) ([]string, error) {
+	return c.pop(ctx, key, "back", count)
+}
+
+func (c *Client) pop(ctx context.Context, key, end string, count int) ([]string, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	if c.keys != nil {
+		return nil, ErrEncryptionUnsupported
+	}
+
+	var resp struct {
+		Values []string `json:"values"`
+	}
+	path := c.scoped("/list/"+url.PathEscape(key)) + "?end=" + end + "&count=" + strconv.Itoa(count)
+	if err := c.do(ctx, http.MethodDelete, path, nil, false, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Values, nil
+}
+
+func (c *Client) LRange(ctx context.Context, key string, start, stop int) ([]string, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	if c.keys != nil {
+		return nil, ErrEncryptionUnsupported
+	}
+
+	var resp struct {
+		Values []string `json:"values"`
+	}
+	path := c.scoped("/list/"+url.PathEscape(key)) + "?start=" + strconv.Itoa(start) + "&stop=" + strconv.Itoa(stop)
+	if err := c.do(ctx, http.MethodGet, path, nil, true, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Values, nil
+}
+
+func (c *Client) SAdd(ctx context.Context, key string, members ...string) (int, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
+	if c.keys != nil {
+		return 0, ErrEncryptionUnsupported
+	}
+
+	var resp struct {
+		Count int `json:"count"`
+	}
+	if err := c.do(ctx, http.MethodPost, c.scoped("/set/"+url.PathEscape(key)), types.CollectionRequest{Members: members}, false, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Count, nil
+}
+
+func (c *Client) SRem(ctx context.Context, key string, members ...string) (int, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
+	if c.keys != nil {
+		return 0, ErrEncryptionUnsupported
+	}
+
+	query := url.Values{"member": members}
+	var resp struct {
+		Count int `json:"count"`
+	}
+	if err := c.do(ctx, http.MethodDelete, c.scoped("/set/"+url.PathEscape(key))+"?"+query.Encode(), nil, false, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Count, nil
+}
+
+func (c *Client) SMembers(ctx context.Context, key string) ([]string, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	if c.keys != nil {
+		return nil, ErrEncryptionUnsupported
+	}
+
+	var resp struct {
+		Members []string `json:"members"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.scoped("/set/"+url.PathEscape(key)), nil, true, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Members, nil
+}
+
+func (c *Client) SIsMember(ctx context.Context, key, member string) (bool, error) {
+	if key == "" {
+		return false, ErrEmptyKey
+	}
+	if c.keys != nil {
+		return false, ErrEncryptionUnsupported
+	}
+
+	var resp struct {
+		IsMember bool `json:"is_member"`
+	}
+	path := c.scoped("/set/"+url.PathEscape(key)) + "?member=" + url.QueryEscape(member)
+	if err := c.do(ctx, http.MethodGet, path, nil, true, &resp); err != nil {
+		return false, err
+	}
+	return resp.IsMember, nil
+}
+
+func (c *Client) HSet(ctx context.Context, key string, fields map[string]string) (int, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
+	if c.keys != nil {
+		return 0, ErrEncryptionUnsupported
+	}
+
+	var resp struct {
+		Count int `json:"count"`
+	}
+	if err := c.do(ctx, http.MethodPut, c.scoped("/hash/"+url.PathEscape(key)), types.CollectionRequest{Fields: fields}, false, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Count, nil
+}
+
+func (c *Client) HGet(ctx context.Context, key, field string) (string, error) {
+	if key == "" {
+		return "", ErrEmptyKey
+	}
+	if c.keys != nil {
+		return "", ErrEncryptionUnsupported
+	}
+
+	var resp struct {
+		Value string `json:"value"`
+	}
+	path := c.scoped("/hash/"+url.PathEscape(key)) + "?field=" + url.QueryEscape(field)
+	if err := c.do(ctx, http.MethodGet, path, nil, true, &resp); err != nil {
+		return "", err
+	}
+	return resp.Value, nil
+}
+
+func (c *Client) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+	if c.keys != nil {
+		return nil, ErrEncryptionUnsupported
+	}
+
+	var resp struct {
+		Fields map[string]string `json:"fields"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.scoped("/hash/"+url.PathEscape(key)), nil, true, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Fields, nil
+}
+
+func (c *Client) HDel(ctx context.Context, key string, fields ...string) (int, error) {
+	if key == "" {
+		return 0, ErrEmptyKey
+	}
+	if c.keys != nil {
+		return 0, ErrEncryptionUnsupported
+	}
+
+	query := url.Values{"field": fields}
+	var resp struct {
+		Count int `json:"count"`
+	}
+	if err := c.do(ctx, http.MethodDelete, c.scoped("/hash/"+url.PathEscape(key))+"?"+query.Encode(), nil, false, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Count, nil
+}
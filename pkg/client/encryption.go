@@ -0,0 +1,378 @@
+package client
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	LabelKeyID       = "qkrn-key-id"
+	LabelContentType = "qkrn-content-type"
+
+	defaultNamespace = "default"
+	envelopeVersion  = 2
+	dataKeySize      = 32
+)
+
+var (
+	ErrNoDataKey      = errors.New("qkrn: no data key for namespace")
+	ErrUnknownDataKey = errors.New("qkrn: unknown data key")
+	ErrDecrypt        = errors.New("qkrn: decryption failed")
+
+	ErrEncryptionUnsupported = errors.New("qkrn: operation does not support client-side encryption")
+
+	errPlaintext = errors.New("qkrn: value is not encrypted")
+)
+
+type ReencryptOptions struct {
+	EncryptPlaintext bool
+}
+
+type ReencryptResult struct {
+	Rewritten int
+	Plaintext []string
+}
+
+type DataKeys struct {
+	mu     sync.RWMutex
+	keys   map[string]map[string]cipher.AEAD
+	active map[string]string
+}
+
+func NewDataKeys() *DataKeys {
+	return &DataKeys{
+		keys:   make(map[string]map[string]cipher.AEAD),
+		active: make(map[string]string),
+	}
+}
+
+func GenerateDataKey() ([]byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *DataKeys) Add(namespace, id string, key []byte) error {
+	if id == "" {
+		return errors.New("qkrn: data key ID is required")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return fmt.Errorf("qkrn: data key %q: %w", id, err)
+	}
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.keys[namespace] == nil {
+		k.keys[namespace] = make(map[string]cipher.AEAD)
+	}
+	k.keys[namespace][id] = aead
+	k.active[namespace] = id
+	return nil
+}
+
+func (k *DataKeys) SetActive(namespace, id string) error {
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[namespace][id]; !ok {
+		return fmt.Errorf("%w %q in namespace %q", ErrUnknownDataKey, id, namespace)
+	}
+	k.active[namespace] = id
+	return nil
+}
+
+func (k *DataKeys) Active(namespace string) string {
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active[namespace]
+}
+
+func (k *DataKeys) get(namespace, id string) (cipher.AEAD, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	aead, ok := k.keys[namespace][id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q in namespace %q", ErrUnknownDataKey, id, namespace)
+	}
+	return aead, nil
+}
+
+func WithEncryption(keys *DataKeys) Option {
+	return func(c *Client) {
+		c.keys = keys
+	}
+}
+
+func (c *Client) keyNamespace() string {
+	if c.namespace == "" {
+		return defaultNamespace
+	}
+	return c.namespace
+}
+
+func envelopeAAD(version byte, key, keyID string) []byte {
+	aad := append([]byte{version}, keyID...)
+	aad = append(aad, 0)
+	return append(aad, key...)
+}
+
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, data, aad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func (c *Client) encrypt(key string, obj Object) (Object, error) {
+	namespace := c.keyNamespace()
+	id := c.keys.Active(namespace)
+	if id == "" {
+		return Object{}, fmt.Errorf("%w %q", ErrNoDataKey, namespace)
+	}
+	dataKey, err := c.keys.get(namespace, id)
+	if err != nil {
+		return Object{}, err
+	}
+
+	valueKey, err := GenerateDataKey()
+	if err != nil {
+		return Object{}, err
+	}
+	aead, err := newAEAD(valueKey)
+	if err != nil {
+		return Object{}, err
+	}
+
+	aad := envelopeAAD(envelopeVersion, key, id)
+	wrapped, err := seal(dataKey, valueKey, aad)
+	if err != nil {
+		return Object{}, err
+	}
+	sealed, err := seal(aead, appendMetadata(nil, obj), aad)
+	if err != nil {
+		return Object{}, err
+	}
+
+	data := binary.BigEndian.AppendUint16([]byte{envelopeVersion}, uint16(len(wrapped)))
+	data = append(append(data, wrapped...), sealed...)
+	return Object{Data: data, ContentType: "application/octet-stream", Labels: map[string]string{LabelKeyID: id}}, nil
+}
+
+func appendMetadata(buf []byte, obj Object) []byte {
+	buf = appendString(buf, obj.ContentType)
+	buf = binary.AppendUvarint(buf, uint64(len(obj.Labels)))
+	for name, value := range obj.Labels {
+		buf = appendString(appendString(buf, name), value)
+	}
+	return append(buf, obj.Data...)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func readMetadata(data []byte) (Object, error) {
+	var obj Object
+	var ok bool
+	if obj.ContentType, data, ok = readString(data); !ok {
+		return Object{}, ErrDecrypt
+	}
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)) {
+		return Object{}, ErrDecrypt
+	}
+	data = data[n:]
+	for ; count > 0; count-- {
+		var name, value string
+		if name, data, ok = readString(data); !ok {
+			return Object{}, ErrDecrypt
+		}
+		if value, data, ok = readString(data); !ok {
+			return Object{}, ErrDecrypt
+		}
+		if obj.Labels == nil {
+			obj.Labels = make(map[string]string)
+		}
+		obj.Labels[name] = value
+	}
+	obj.Data = data
+	return obj, nil
+}
+
+func readString(data []byte) (string, []byte, bool) {
+	size, n := binary.Uvarint(data)
+	if n <= 0 || size > uint64(len(data)-n) {
+		return "", nil, false
+	}
+	data = data[n:]
+	return string(data[:size]), data[size:], true
+}
+
+func (c *Client) decrypt(key string, obj Object) (Object, error) {
+	id, ok := obj.Labels[LabelKeyID]
+	if !ok {
+		return Object{}, fmt.Errorf("%w: value is not encrypted", ErrDecrypt)
+	}
+
+	data := obj.Data
+	if len(data) < 3 || (data[0] != 1 && data[0] != envelopeVersion) {
+		return Object{}, ErrDecrypt
+	}
+	version := data[0]
+	n := int(binary.BigEndian.Uint16(data[1:3]))
+	if len(data) < 3+n {
+		return Object{}, ErrDecrypt
+	}
+	wrapped, sealed := data[3:3+n], data[3+n:]
+
+	dataKey, err := c.keys.get(c.keyNamespace(), id)
+	if err != nil {
+		return Object{}, err
+	}
+	aad := envelopeAAD(version, key, id)
+	valueKey, err := open(dataKey, wrapped, aad)
+	if err != nil {
+		return Object{}, err
+	}
+	aead, err := newAEAD(valueKey)
+	if err != nil {
+		return Object{}, ErrDecrypt
+	}
+	plaintext, err := open(aead, sealed, aad)
+	if err != nil {
+		return Object{}, err
+	}
+	if version != 1 {
+		return readMetadata(plaintext)
+	}
+
+	plain := Object{Data: plaintext, ContentType: obj.Labels[LabelContentType]}
+	for name, value := range obj.Labels {
+		if name == LabelKeyID || name == LabelContentType {
+			continue
+		}
+		if plain.Labels == nil {
+			plain.Labels = make(map[string]string)
+		}
+		plain.Labels[name] = value
+	}
+	return plain, nil
+}
+
+func (c *Client) getDecrypted(ctx context.Context, key, path string) (Object, error) {
+	var obj Object
+	if err := c.do(ctx, http.MethodGet, path, nil, true, &obj); err != nil {
+		return Object{}, err
+	}
+	return c.decrypt(key, obj)
+}
+
+func (c *Client) Reencrypt(ctx context.Context, prefix string, opts ReencryptOptions) (ReencryptResult, error) {
+	var result ReencryptResult
+	if c.keys == nil || c.keys.Active(c.keyNamespace()) == "" {
+		return result, fmt.Errorf("%w %q", ErrNoDataKey, c.keyNamespace())
+	}
+
+	keys, err := c.KeysWithPrefix(ctx, prefix)
+	if err != nil {
+		return result, err
+	}
+
+	for _, key := range keys {
+		rewritten, err := c.reencryptKey(ctx, key, opts)
+		switch {
+		case errors.Is(err, errPlaintext):
+			result.Plaintext = append(result.Plaintext, key)
+		case err != nil:
+			return result, fmt.Errorf("%s: %w", key, err)
+		case rewritten:
+			result.Rewritten++
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) reencryptKey(ctx context.Context, key string, opts ReencryptOptions) (bool, error) {
+	for attempt := 0; ; attempt++ {
+		var obj versionedObject
+		if err := c.do(ctx, http.MethodGet, c.keyPath(key), nil, true, &obj); err != nil {
+			var apiErr *Error
+			if IsNotFound(err) || (errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		id, encrypted := obj.Labels[LabelKeyID]
+		if id == c.keys.Active(c.keyNamespace()) && len(obj.Data) > 0 && obj.Data[0] == envelopeVersion {
+			return false, nil
+		}
+
+		if !encrypted && !opts.EncryptPlaintext {
+			return false, errPlaintext
+		}
+
+		plain := obj.Object
+		if encrypted {
+			var err error
+			if plain, err = c.decrypt(key, obj.Object); err != nil {
+				return false, err
+			}
+		}
+		sealed, err := c.encrypt(key, plain)
+		if err != nil {
+			return false, err
+		}
+
+		err = c.do(ctx, http.MethodPut, c.keyPath(key)+"?raw=true&keep_ttl=true", versionedObject{Object: sealed, revision: obj.revision}, false, nil)
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusPreconditionFailed && attempt < c.maxRetries {
+			continue
+		}
+		return err == nil, err
+	}
+}
@@ -0,0 +1,232 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/q4ow/qkrn/internal/api"
+	"github.com/q4ow/qkrn/internal/auth"
+	"github.com/q4ow/qkrn/internal/store"
+)
+
+func testDataKeys(t *testing.T, namespace string, ids ...string) *DataKeys {
+	t.Helper()
+	keys := NewDataKeys()
+	for _, id := range ids {
+		key, err := GenerateDataKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := keys.Add(namespace, id, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return keys
+}
+
+func TestClientEncryption(t *testing.T) {
+	keys := testDataKeys(t, "", "v1")
+	c := setupTestClient(t, "", WithEncryption(keys))
+	plain := New(c.baseURL)
+	ctx := context.Background()
+
+	if err := c.Set(ctx, "secret", "hunter2"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if value, err := c.Get(ctx, "secret"); err != nil || value != "hunter2" {
+		t.Errorf("Expected 'hunter2', got '%s' (%v)", value, err)
+	}
+
+	stored, err := plain.GetBytes(ctx, "secret")
+	if err != nil {
+		t.Fatalf("GetBytes failed: %v", err)
+	}
+	if bytes.Contains(stored.Data, []byte("hunter2")) {
+		t.Error("Expected the server to hold only ciphertext")
+	}
+	if stored.Labels[LabelKeyID] != "v1" {
+		t.Errorf("Expected the key ID in the labels, got %v", stored.Labels)
+	}
+
+	if err := c.SetBytes(ctx, "blob", []byte{0x00, 0xff}, "application/x-protobuf"); err != nil {
+		t.Fatalf("SetBytes failed: %v", err)
+	}
+	obj, err := c.GetBytes(ctx, "blob")
+	if err != nil || !bytes.Equal(obj.Data, []byte{0x00, 0xff}) || obj.ContentType != "application/x-protobuf" || obj.Labels != nil {
+		t.Errorf("Unexpected object: %+v (%v)", obj, err)
+	}
+
+	labeled := Object{Data: []byte("doc"), ContentType: "text/markdown", Labels: map[string]string{"owner": "alice"}}
+	if err := c.SetObject(ctx, "labeled", labeled); err != nil {
+		t.Fatalf("SetObject failed: %v", err)
+	}
+	stored, _ = plain.GetBytes(ctx, "labeled")
+	if len(stored.Labels) != 1 || stored.ContentType != "application/octet-stream" {
+		t.Errorf("Expected the server to see only the key ID, got %+v", stored)
+	}
+	if obj, err := c.GetBytes(ctx, "labeled"); err != nil || obj.ContentType != "text/markdown" || obj.Labels["owner"] != "alice" {
+		t.Errorf("Expected the sealed metadata back, got %+v (%v)", obj, err)
+	}
+
+	c.Set(ctx, "secret", "hunter3")
+	history, _ := c.History(ctx, "secret")
+	if value, err := c.GetRevision(ctx, "secret", history[len(history)-1].Revision); err != nil || value != "hunter2" {
+		t.Errorf("Expected the old revision decrypted, got '%s' (%v)", value, err)
+	}
+
+	plain.SetObject(ctx, "copy", stored)
+	if _, err := c.Get(ctx, "copy"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected a value moved to another key to fail, got %v", err)
+	}
+
+	plain.Set(ctx, "plain", "visible")
+	if _, err := c.Get(ctx, "plain"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected an unencrypted value to be refused, got %v", err)
+	}
+	if _, err := c.GetBytes(ctx, "plain"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected an unencrypted object to be refused, got %v", err)
+	}
+
+	if err := New(c.baseURL, WithNamespace("team"), WithEncryption(keys)).Set(ctx, "k", "v"); !errors.Is(err, ErrNoDataKey) {
+		t.Errorf("Expected ErrNoDataKey for a namespace without keys, got %v", err)
+	}
+	other := testDataKeys(t, "", "v2")
+	if _, err := New(c.baseURL, WithEncryption(other)).Get(ctx, "secret"); !errors.Is(err, ErrUnknownDataKey) {
+		t.Errorf("Expected ErrUnknownDataKey, got %v", err)
+	}
+}
+
+func TestClientEncryptionUnsupported(t *testing.T) {
+	c := setupTestClient(t, "", WithEncryption(testDataKeys(t, "", "v1")))
+	plain := New(c.baseURL)
+	ctx := context.Background()
+
+	calls := map[string]func() error{
+		"LPush":     func() error { _, err := c.LPush(ctx, "list", "secret"); return err },
+		"RPush":     func() error { _, err := c.RPush(ctx, "list", "secret"); return err },
+		"LPop":      func() error { _, err := c.LPop(ctx, "list", 1); return err },
+		"RPop":      func() error { _, err := c.RPop(ctx, "list", 1); return err },
+		"LRange":    func() error { _, err := c.LRange(ctx, "list", 0, -1); return err },
+		"SAdd":      func() error { _, err := c.SAdd(ctx, "set", "secret"); return err },
+		"SRem":      func() error { _, err := c.SRem(ctx, "set", "secret"); return err },
+		"SMembers":  func() error { _, err := c.SMembers(ctx, "set"); return err },
+		"SIsMember": func() error { _, err := c.SIsMember(ctx, "set", "secret"); return err },
+		"HSet":      func() error { _, err := c.HSet(ctx, "hash", map[string]string{"f": "secret"}); return err },
+		"HGet":      func() error { _, err := c.HGet(ctx, "hash", "f"); return err },
+		"HGetAll":   func() error { _, err := c.HGetAll(ctx, "hash"); return err },
+		"HDel":      func() error { _, err := c.HDel(ctx, "hash", "f"); return err },
+		"Incr":      func() error { _, err := c.Incr(ctx, "counter", 1); return err },
+		"IncrFloat": func() error { _, err := c.IncrFloat(ctx, "counter", 1.5); return err },
+		"Import": func() error {
+			_, err := c.Import(ctx, strings.NewReader(`{"key":"imported","value":"secret"}`), "ndjson", false)
+			return err
+		},
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrEncryptionUnsupported) {
+			t.Errorf("%s: expected ErrEncryptionUnsupported, got %v", name, err)
+		}
+	}
+
+	if keys, err := plain.Keys(ctx); err != nil || len(keys) != 0 {
+		t.Errorf("Expected nothing sent to the server in plaintext, got %v (%v)", keys, err)
+	}
+}
+
+func TestClientReencrypt(t *testing.T) {
+	keys := testDataKeys(t, "", "v1")
+	c := setupTestClient(t, "", WithEncryption(keys))
+	plain := New(c.baseURL)
+	ctx := context.Background()
+
+	c.Set(ctx, "app/a", "1")
+	c.Set(ctx, "app/b", "2")
+	c.Set(ctx, "other", "3")
+	plain.Set(ctx, "app/legacy", "4")
+	plain.LPush(ctx, "app/list", "x")
+
+	result, err := c.Reencrypt(ctx, "app/", ReencryptOptions{})
+	if err != nil || result.Rewritten != 0 || len(result.Plaintext) != 1 || result.Plaintext[0] != "app/legacy" {
+		t.Fatalf("Expected the plaintext value to be skipped and reported, got %+v (%v)", result, err)
+	}
+	if value, err := plain.Get(ctx, "app/legacy"); err != nil || value != "4" {
+		t.Errorf("Expected the plaintext value to be left alone, got '%s' (%v)", value, err)
+	}
+
+	key, _ := GenerateDataKey()
+	keys.Add("", "v2", key)
+	if value, err := c.Get(ctx, "app/a"); err != nil || value != "1" {
+		t.Errorf("Expected values under the old key to stay readable, got '%s' (%v)", value, err)
+	}
+
+	result, err = c.Reencrypt(ctx, "app/", ReencryptOptions{EncryptPlaintext: true})
+	if err != nil || result.Rewritten != 3 || len(result.Plaintext) != 0 {
+		t.Fatalf("Expected 3 keys re-encrypted, got %+v (%v)", result, err)
+	}
+	if result, _ := c.Reencrypt(ctx, "app/", ReencryptOptions{}); result.Rewritten != 0 {
+		t.Errorf("Expected nothing left to re-encrypt, got %+v", result)
+	}
+
+	only := NewDataKeys()
+	only.Add("", "v2", key)
+	rotated := New(c.baseURL, WithEncryption(only))
+	for key, want := range map[string]string{"app/a": "1", "app/b": "2", "app/legacy": "4"} {
+		if value, err := rotated.Get(ctx, key); err != nil || value != want {
+			t.Errorf("Expected '%s' for %s with only the new key, got '%s' (%v)", want, key, value, err)
+		}
+	}
+	if _, err := rotated.Get(ctx, "other"); !errors.Is(err, ErrUnknownDataKey) {
+		t.Errorf("Expected keys outside the prefix to keep the old key, got %v", err)
+	}
+}
+
+func TestClientReencryptKeepsTTLAndConcurrentWrites(t *testing.T) {
+	kvStore := store.NewMemoryStore()
+	handler := api.NewServer(kvStore, 0, auth.NewAuthenticator(false, "")).Handler()
+
+	keys := testDataKeys(t, "", "v1")
+	var writer *Client
+	var raced atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.Header.Get("If-Match") != "" && strings.HasSuffix(r.URL.Path, "/session") && !raced.Swap(true) {
+			if err := writer.Set(r.Context(), "session", "newer"); err != nil {
+				t.Errorf("Concurrent write failed: %v", err)
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	c := New(ts.URL, WithEncryption(keys))
+	writer = New(ts.URL, WithEncryption(keys))
+	ctx := context.Background()
+
+	c.Set(ctx, "session", "older")
+	c.Set(ctx, "token", "abc")
+	kvStore.Expire("token", time.Hour)
+
+	key, _ := GenerateDataKey()
+	keys.Add("", "v2", key)
+	result, err := c.Reencrypt(ctx, "", ReencryptOptions{})
+	if err != nil || result.Rewritten != 1 {
+		t.Fatalf("Expected only the untouched key rewritten, got %+v (%v)", result, err)
+	}
+	if !raced.Load() {
+		t.Fatal("Expected a write between the read and the rewrite")
+	}
+
+	if value, err := c.Get(ctx, "session"); err != nil || value != "newer" {
+		t.Errorf("Expected the concurrent write to survive, got '%s' (%v)", value, err)
+	}
+	e, err := kvStore.GetEntry("token")
+	if err != nil || e.Labels[LabelKeyID] != "v2" || e.ExpiresAt.IsZero() {
+		t.Errorf("Expected the rewritten key to keep its TTL, got %+v (%v)", e, err)
+	}
+}
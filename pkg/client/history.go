@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/q4ow/qkrn/pkg/types"
+)
+
+func (c *Client) History(ctx context.Context, key string) ([]types.Metadata, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+
+	var resp struct {
+		Revisions []types.Metadata `json:"revisions"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.historyPath(key), nil, true, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Revisions, nil
+}
+
+func (c *Client) GetRevision(ctx context.Context, key string, revision uint64) (string, error) {
+	if key == "" {
+		return "", ErrEmptyKey
+	}
+
+	path := c.keyPath(key) + "?revision=" + strconv.FormatUint(revision, 10)
+	if c.keys != nil {
+		obj, err := c.getDecrypted(ctx, key, path)
+		return string(obj.Data), err
+	}
+
+	var resp types.Response
+	if err := c.do(ctx, http.MethodGet, path, nil, true, &resp); err != nil {
+		return "", err
+	}
+	return responseValue(resp)
+}
+
+func (c *Client) Restore(ctx context.Context, key string, revision uint64) (types.Metadata, error) {
+	if key == "" {
+		return types.Metadata{}, ErrEmptyKey
+	}
+
+	var resp types.Response
+	if err := c.do(ctx, http.MethodPost, c.historyPath(key), types.RevisionRequest{Revision: revision}, false, &resp); err != nil {
+		return types.Metadata{}, err
+	}
+	if resp.Metadata == nil {
+		return types.Metadata{}, nil
+	}
+	return *resp.Metadata, nil
+}
+
+func (c *Client) Compact(ctx context.Context, revision uint64) (uint64, error) {
+	var resp struct {
+		Revision uint64 `json:"revision"`
+	}
+	if err := c.do(ctx, http.MethodPost, c.scoped("/compact"), types.RevisionRequest{Revision: revision}, true, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Revision, nil
+}
+
+func (c *Client) historyPath(key string) string {
+	return c.scoped("/history/" + url.PathEscape(key))
+}
@@ -1,37 +1,414 @@
 package types
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
 
 var (
-	ErrKeyNotFound  = errors.New("key not found")
-	ErrEmptyKey     = errors.New("key cannot be empty")
-	ErrUnauthorized = errors.New("unauthorized")
-	ErrInvalidToken = errors.New("invalid authentication token")
-	ErrMissingToken = errors.New("missing authentication token")
+	ErrKeyNotFound    = errors.New("key not found")
+	ErrKeyExists      = errors.New("key already exists")
+	ErrConflict       = errors.New("version conflict")
+	ErrEmptyKey       = errors.New("key cannot be empty")
+	ErrUnauthorized   = errors.New("unauthorized")
+	ErrInvalidToken   = errors.New("invalid authentication token")
+	ErrMissingToken   = errors.New("missing authentication token")
+	ErrValueTooLarge  = errors.New("value too large")
+	ErrKeyLimit       = errors.New("key limit reached")
+	ErrNotNumber      = errors.New("value is not a number")
+	ErrOutOfRange     = errors.New("value out of range")
+	ErrWrongType      = errors.New("operation against a key holding the wrong kind of value")
+	ErrCompacted      = errors.New("revision has been compacted")
+	ErrFutureRevision = errors.New("revision is in the future")
+	ErrOutOfMemory    = errors.New("memory limit reached")
 )
 
 type Store interface {
-	Get(key string) (string, error)
-	Set(key, value string) error
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte) error
+	Delete(key string) error
+	Keys() []string
+}
+
+type SetOptions struct {
+	TTL          time.Duration
+	KeepTTL      bool
+	OnlyIfAbsent bool
+	OnlyIfExists bool
+	ContentType  string
+	Labels       map[string]string
+}
+
+type ExpiringStore interface {
+	Store
+	SetWithOptions(key string, value []byte, opts SetOptions) error
+	Expire(key string, ttl time.Duration) error
+	Persist(key string) error
+	TTL(key string) (time.Duration, bool, error)
+}
+
+type ValueType string
+
+const (
+	TypeString ValueType = "string"
+	TypeList   ValueType = "list"
+	TypeSet    ValueType = "set"
+	TypeHash   ValueType = "hash"
+)
+
+type Entry struct {
+	Value       []byte
+	Type        ValueType
+	Length      int
+	ContentType string
+	Labels      map[string]string
+	Flags       uint32
+	Version     uint64
+	Revision    uint64
+	CreatedAt   time.Time
+	ModifiedAt  time.Time
+	ExpiresAt   time.Time
+}
+
+type Metadata struct {
+	Type        ValueType         `json:"type"`
+	Version     uint64            `json:"version"`
+	Revision    uint64            `json:"revision"`
+	ContentType string            `json:"content_type,omitempty"`
+	Size        int               `json:"size"`
+	Length      int               `json:"length,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	ModifiedAt  time.Time         `json:"modified_at"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+}
+
+func (e Entry) Metadata() Metadata {
+	meta := Metadata{
+		Type:        e.Type,
+		Version:     e.Version,
+		Revision:    e.Revision,
+		ContentType: e.ContentType,
+		Size:        len(e.Value),
+		Length:      e.Length,
+		Labels:      e.Labels,
+		CreatedAt:   e.CreatedAt,
+		ModifiedAt:  e.ModifiedAt,
+	}
+	if meta.Type == "" {
+		meta.Type = TypeString
+	}
+	if !e.ExpiresAt.IsZero() {
+		expiresAt := e.ExpiresAt
+		meta.ExpiresAt = &expiresAt
+	}
+	return meta
+}
+
+type EvictionPolicy string
+
+const (
+	EvictNone        EvictionPolicy = "noeviction"
+	EvictLRU         EvictionPolicy = "lru"
+	EvictLFU         EvictionPolicy = "lfu"
+	EvictRandom      EvictionPolicy = "random"
+	EvictVolatileTTL EvictionPolicy = "volatile-ttl"
+)
+
+func ParseEvictionPolicy(s string) (EvictionPolicy, bool) {
+	switch policy := EvictionPolicy(s); policy {
+	case "":
+		return EvictNone, true
+	case EvictNone, EvictLRU, EvictLFU, EvictRandom, EvictVolatileTTL:
+		return policy, true
+	}
+	return "", false
+}
+
+type Limits struct {
+	DefaultTTL   time.Duration
+	MaxKeys      int
+	MaxValueSize int
+	MaxMemory    int64
+	Eviction     EvictionPolicy
+}
+
+type LimitedStore interface {
+	Store
+	Limits() Limits
+	SetLimits(limits Limits)
+}
+
+type NamespaceInfo struct {
+	Name           string `json:"name"`
+	DefaultTTL     string `json:"default_ttl,omitempty"`
+	MaxKeys        int    `json:"max_keys,omitempty"`
+	MaxValueSize   int    `json:"max_value_size,omitempty"`
+	MaxMemory      int64  `json:"max_memory,omitempty"`
+	EvictionPolicy string `json:"eviction_policy,omitempty"`
+	Keys           int    `json:"keys"`
+	MemoryUsed     int64  `json:"memory_used,omitempty"`
+}
+
+type StoreStats struct {
+	Keys              int
+	MemoryUsed        int64
+	MaxMemory         int64
+	EvictionPolicy    EvictionPolicy
+	Evictions         uint64
+	CompressedKeys    int64
+	CompressedBytes   int64
+	UncompressedBytes int64
+}
+
+type Compression struct {
+	Codec     string
+	Threshold int
+}
+
+type StatsStore interface {
+	Store
+	Stats() StoreStats
+}
+
+type IncrOptions struct {
+	Initial int64
+	Min     *int64
+	Max     *int64
+}
+
+type IncrFloatOptions struct {
+	Initial float64
+	Min     *float64
+	Max     *float64
+}
+
+type CounterStore interface {
+	Store
+	Incr(key string, delta int64, opts IncrOptions) (int64, error)
+	IncrFloat(key string, delta float64, opts IncrFloatOptions) (float64, error)
+}
+
+type ListStore interface {
+	Store
+	ListPush(key string, values [][]byte, front bool) (int, error)
+	ListPop(key string, count int, front bool) ([][]byte, error)
+	ListRange(key string, start, stop int) ([][]byte, error)
+}
+
+type SetStore interface {
+	Store
+	SetAdd(key string, members ...string) (int, error)
+	SetRemove(key string, members ...string) (int, error)
+	SetMembers(key string) ([]string, error)
+	SetIsMember(key, member string) (bool, error)
+}
+
+type HashStore interface {
+	Store
+	HashSet(key string, fields map[string][]byte) (int, error)
+	HashGet(key, field string) ([]byte, error)
+	HashGetAll(key string) (map[string][]byte, error)
+	HashDelete(key string, fields ...string) (int, error)
+}
+
+type KeyValue struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type RangeStore interface {
+	Store
+	Scan(start, end string, fn func(kv KeyValue) bool) error
+}
+
+type HistoryLimits struct {
+	MaxVersions int
+	MaxAge      time.Duration
+}
+
+type HistoryStore interface {
+	Store
+	History(key string) ([]Entry, error)
+	GetRevision(key string, revision uint64) (Entry, error)
+	Restore(key string, revision uint64) (Entry, error)
+}
+
+type MVCCStore interface {
+	HistoryStore
+	Revision() uint64
+	KeysAt(revision uint64) ([]string, error)
+	Compact(revision uint64) error
+}
+
+type Record struct {
+	Key         string            `json:"key"`
+	Type        ValueType         `json:"type,omitempty"`
+	Value       []byte            `json:"value,omitempty"`
+	List        [][]byte          `json:"list,omitempty"`
+	Set         []string          `json:"set,omitempty"`
+	Hash        map[string][]byte `json:"hash,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Flags       uint32            `json:"flags,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+}
+
+type Snapshot interface {
+	Revision() uint64
+	Len() int
+	Each(fn func(Record) error) error
+}
+
+type BackupStore interface {
+	Store
+	Snapshot(prefix string) Snapshot
+	Load(record Record, overwrite bool) (bool, error)
+}
+
+type UpdateFunc func(current Entry, exists bool) (Entry, error)
+
+type AtomicStore interface {
+	Store
+	GetEntry(key string) (Entry, error)
+	Update(key string, fn UpdateFunc) (Entry, error)
+}
+
+type Tx interface {
+	GetEntry(key string) (Entry, error)
+	Put(key string, entry Entry) (Entry, error)
 	Delete(key string) error
 	Keys() []string
 }
 
+type TxnStore interface {
+	Store
+	Txn(fn func(tx Tx) error) error
+}
+
+type EventType string
+
+const (
+	EventPut    EventType = "put"
+	EventDelete EventType = "delete"
+)
+
+type Event struct {
+	Type  EventType
+	Key   string
+	Entry Entry
+}
+
+type WatchableStore interface {
+	Store
+	Subscribe(prefix string) (<-chan Event, func())
+}
+
+type HealthChecker interface {
+	Healthy() error
+}
+
 type Node struct {
 	ID      string `json:"id"`
 	Address string `json:"address"`
 	Port    int    `json:"port"`
 }
 
+type ClusterInfo struct {
+	Leader string `json:"leader"`
+	Nodes  []Node `json:"nodes"`
+}
+
+type CheckResult struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type ReadinessReport struct {
+	Status     string        `json:"status"`
+	DurationMs float64       `json:"duration_ms"`
+	Checks     []CheckResult `json:"checks"`
+}
+
 type Request struct {
-	Key   string `json:"key"`
-	Value string `json:"value,omitempty"`
+	Key    string            `json:"key"`
+	Value  string            `json:"value,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 type Response struct {
-	Success bool   `json:"success"`
-	Value   string `json:"value,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success     bool      `json:"success"`
+	Value       string    `json:"value,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Encoding    string    `json:"encoding,omitempty"`
+	Metadata    *Metadata `json:"metadata,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type CounterRequest struct {
+	By      json.Number `json:"by,omitempty"`
+	Initial json.Number `json:"initial,omitempty"`
+	Min     json.Number `json:"min,omitempty"`
+	Max     json.Number `json:"max,omitempty"`
+	Float   bool        `json:"float,omitempty"`
+}
+
+type RevisionRequest struct {
+	Revision uint64 `json:"revision"`
+}
+
+type RestoreResult struct {
+	Revision uint64 `json:"revision"`
+	Loaded   int    `json:"loaded"`
+	Skipped  int    `json:"skipped"`
+	Deleted  int    `json:"deleted"`
+}
+
+type ImportError struct {
+	Line  int    `json:"line,omitempty"`
+	Item  int    `json:"item,omitempty"`
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error"`
+}
+
+type ImportResult struct {
+	DryRun   bool          `json:"dry_run,omitempty"`
+	Imported int           `json:"imported"`
+	Failed   int           `json:"failed"`
+	Errors   []ImportError `json:"errors,omitempty"`
+}
+
+type CollectionRequest struct {
+	Values  []string          `json:"values,omitempty"`
+	Members []string          `json:"members,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+type WebSocketRequest struct {
+	ID           string      `json:"id"`
+	Op           string      `json:"op"`
+	Key          string      `json:"key,omitempty"`
+	Value        string      `json:"value,omitempty"`
+	Prefix       bool        `json:"prefix,omitempty"`
+	Subscription string      `json:"subscription,omitempty"`
+	By           json.Number `json:"by,omitempty"`
+	Float        bool        `json:"float,omitempty"`
+}
+
+type WebSocketMessage struct {
+	Type     string    `json:"type"`
+	ID       string    `json:"id,omitempty"`
+	Success  bool      `json:"success,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Event    EventType `json:"event,omitempty"`
+	Key      string    `json:"key,omitempty"`
+	Value    string    `json:"value,omitempty"`
+	Encoding string    `json:"encoding,omitempty"`
+	Version  uint64    `json:"version,omitempty"`
+	Revision uint64    `json:"revision,omitempty"`
 }
 
 type AuthRequest struct {